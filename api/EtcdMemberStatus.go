@@ -0,0 +1,28 @@
+package api
+
+// EtcdMemberStatusCode describes the health of the etcd member which runs on a control plane node
+type EtcdMemberStatusCode int
+
+const (
+	// EtcdMemberUnknown is used when the etcd member health couldn't be determined, or by peers which don't report it
+	EtcdMemberUnknown EtcdMemberStatusCode = iota
+	// EtcdMemberHealthy is used when the node runs a ready etcd member
+	EtcdMemberHealthy
+	// EtcdMemberUnhealthy is used when the node runs an etcd member which isn't ready
+	EtcdMemberUnhealthy
+	// NoEtcdMember is used when the node doesn't run an etcd member, e.g. on worker nodes or with external etcd
+	NoEtcdMember
+)
+
+func (c EtcdMemberStatusCode) String() string {
+	switch c {
+	case EtcdMemberHealthy:
+		return "Healthy"
+	case EtcdMemberUnhealthy:
+		return "Unhealthy"
+	case NoEtcdMember:
+		return "NoMember"
+	default:
+		return "Unknown"
+	}
+}
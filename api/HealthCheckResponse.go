@@ -7,4 +7,7 @@ const (
 	Healthy       HealthCheckResponseCode = iota
 	Unhealthy
 	ApiError
+	// PeerBusy is used when the peer rejected the request because it's answering too many requests. The peer didn't
+	// answer whether this node is healthy, but it could be reached.
+	PeerBusy HealthCheckResponseCode = -2
 )
@@ -36,6 +36,12 @@ const (
 	SucceededConditionType ConditionType = "Succeeded"
 	// DisabledConditionType is the condition type used to signal SNR is disabled
 	DisabledConditionType ConditionType = "Disabled"
+	// WaitingForControlPlaneRemediationConditionType is the condition type used to signal that the remediation of a
+	// control plane node waits for the remediation of another control plane node to complete
+	WaitingForControlPlaneRemediationConditionType ConditionType = "WaitingForControlPlaneRemediation"
+	// RemediationLoopDetectedConditionType is the condition type used to signal that the node was remediated too often
+	// within the configured window, and that it won't be rebooted again
+	RemediationLoopDetectedConditionType ConditionType = "RemediationLoopDetected"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -54,6 +60,11 @@ type SelfNodeRemediationSpec struct {
 	// +kubebuilder:default:="Automatic"
 	// +kubebuilder:validation:Enum=Automatic;ResourceDeletion;OutOfServiceTaint
 	RemediationStrategy RemediationStrategyType `json:"remediationStrategy,omitempty"`
+
+	// DryRun makes the remediation only check whether the node can be remediated, and report the planned remediation
+	// in the status. The node isn't tainted, and it isn't rebooted. The field can't be changed after creation.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
 }
 
 // SelfNodeRemediationStatus defines the observed state of SelfNodeRemediation
@@ -81,6 +92,56 @@ type SelfNodeRemediationStatus struct {
 	// +listMapKey=type
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// DryRunResult reports the preconditions and the planned remediation of a dry run remediation
+	// +optional
+	//+operator-sdk:csv:customresourcedefinitions:type=status
+	DryRunResult *DryRunResult `json:"dryRunResult,omitempty"`
+}
+
+// DryRunResult reports the preconditions and the planned remediation of a dry run remediation
+type DryRunResult struct {
+	// Remediable is true if all preconditions are met, and the node would be remediated
+	Remediable bool `json:"remediable"`
+
+	// Strategy is the remediation strategy which would be used
+	// +optional
+	Strategy RemediationStrategyType `json:"strategy,omitempty"`
+
+	// Preconditions are the results of the checks which need to pass before the node is remediated
+	// +optional
+	Preconditions []DryRunPrecondition `json:"preconditions,omitempty"`
+
+	// Timeline is the planned remediation, relative to its start
+	// +optional
+	Timeline []PlannedRemediationStep `json:"timeline,omitempty"`
+
+	// LastCheckTime is the time when the preconditions were checked
+	// +optional
+	LastCheckTime *metav1.Time `json:"lastCheckTime,omitempty"`
+}
+
+// DryRunPrecondition is the result of a check which needs to pass before the node is remediated
+type DryRunPrecondition struct {
+	// Name of the check
+	Name string `json:"name"`
+
+	// Passed is true if the check passed
+	Passed bool `json:"passed"`
+
+	// Message explains the result of the check
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// PlannedRemediationStep is a step of a planned remediation
+type PlannedRemediationStep struct {
+	// After is the time after the start of the remediation when the step would be done
+	// +kubebuilder:validation:Type:=string
+	After metav1.Duration `json:"after"`
+
+	// Description of the step
+	Description string `json:"description"`
 }
 
 //+kubebuilder:object:root=true
@@ -17,7 +17,10 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/errors"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -45,9 +48,22 @@ func (r *SelfNodeRemediation) ValidateCreate() (warning admission.Warnings, err
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
-func (r *SelfNodeRemediation) ValidateUpdate(_ runtime.Object) (warning admission.Warnings, err error) {
+func (r *SelfNodeRemediation) ValidateUpdate(old runtime.Object) (warning admission.Warnings, err error) {
 	webhookRemediationLog.Info("validate update", "name", r.Name)
-	return admission.Warnings{}, validateStrategy(r.Spec)
+	return admission.Warnings{}, errors.NewAggregate([]error{
+		validateStrategy(r.Spec),
+		r.validateDryRunUnchanged(old),
+	})
+}
+
+// validateDryRunUnchanged prevents turning real remediations into dry runs and vice versa, which would leave nodes
+// tainted, or start fencing them unexpectedly
+func (r *SelfNodeRemediation) validateDryRunUnchanged(old runtime.Object) error {
+	oldSnr, ok := old.(*SelfNodeRemediation)
+	if !ok || oldSnr.Spec.DryRun == r.Spec.DryRun {
+		return nil
+	}
+	return fmt.Errorf("dryRun can't be changed after creation")
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -74,6 +74,19 @@ var _ = Describe("SelfNodeRemediation Validation", func() {
 
 		})
 
+		Context("with dry run", func() {
+			It("should deny changing it", func() {
+				dryRun := snrValid.DeepCopy()
+				dryRun.Spec.DryRun = true
+				_, err := dryRun.ValidateCreate()
+				Expect(err).To(Succeed())
+				_, err = dryRun.ValidateUpdate(snrValid)
+				Expect(err).To(MatchError(ContainSubstring("dryRun can't be changed after creation")))
+				_, err = snrValid.ValidateUpdate(dryRun)
+				Expect(err).To(MatchError(ContainSubstring("dryRun can't be changed after creation")))
+			})
+		})
+
 	})
 
 })
@@ -0,0 +1,146 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// names of the self-test checks
+	WatchdogSelfTestCheck     = "Watchdog"
+	CertificatesSelfTestCheck = "Certificates"
+	PeerSelfTestCheck         = "Peer"
+)
+
+// SelfNodeRemediationAgentStatusSpec identifies the agent
+type SelfNodeRemediationAgentStatusSpec struct {
+	// NodeName is the name of the node the agent runs on
+	//+operator-sdk:csv:customresourcedefinitions:type=spec
+	NodeName string `json:"nodeName"`
+}
+
+// AgentWatchdogStatus describes the watchdog device used by the agent
+type AgentWatchdogStatus struct {
+	// Path is the path of the device file
+	Path string `json:"path,omitempty"`
+	// Identity is the identity reported by the device
+	Identity string `json:"identity,omitempty"`
+	// Driver is the name of the kernel driver of the device
+	Driver string `json:"driver,omitempty"`
+	// FirmwareVersion is the firmware version reported by the device
+	FirmwareVersion uint32 `json:"firmwareVersion,omitempty"`
+	// TimeoutSeconds is the timeout after which the device reboots the node when it isn't fed
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+// AgentPeersStatus describes the peers known by the agent
+type AgentPeersStatus struct {
+	// Workers is the number of worker peers
+	Workers int `json:"workers"`
+	// ControlPlanes is the number of control plane peers
+	ControlPlanes int `json:"controlPlanes"`
+	// LastSuccessfulRequestTime is when a peer answered a health request of the agent the last time
+	// +optional
+	LastSuccessfulRequestTime *metav1.Time `json:"lastSuccessfulRequestTime,omitempty"`
+}
+
+// SelfTestCheck is the result of a single check of a self-test
+type SelfTestCheck struct {
+	// Name is the name of the check, one of Watchdog, Certificates and Peer
+	Name string `json:"name"`
+	// Passed is true if the check passed
+	Passed bool `json:"passed"`
+	// Message describes the result of the check
+	Message string `json:"message,omitempty"`
+}
+
+// SelfTestResult is the result of the periodic self-test of the agent
+type SelfTestResult struct {
+	// Time is when the self-test ran
+	Time metav1.Time `json:"time"`
+	// Passed is true if all checks passed
+	Passed bool `json:"passed"`
+	// Checks holds the results of the single checks
+	Checks []SelfTestCheck `json:"checks,omitempty"`
+}
+
+// SelfNodeRemediationAgentStatusStatus is reported by the agent
+type SelfNodeRemediationAgentStatusStatus struct {
+	// AgentVersion is the version of the agent
+	//+operator-sdk:csv:customresourcedefinitions:type=status
+	AgentVersion string `json:"agentVersion,omitempty"`
+
+	// Watchdog describes the watchdog used by the agent. It's not set if no watchdog is used.
+	//+operator-sdk:csv:customresourcedefinitions:type=status
+	// +optional
+	Watchdog *AgentWatchdogStatus `json:"watchdog,omitempty"`
+
+	// SoftwareRebootEnabled is true if the agent reboots the node by software when no watchdog is available
+	//+operator-sdk:csv:customresourcedefinitions:type=status
+	SoftwareRebootEnabled bool `json:"softwareRebootEnabled"`
+
+	// RebootCapable is true if the agent is able to reboot the node, either with its watchdog or by software
+	//+operator-sdk:csv:customresourcedefinitions:type=status
+	RebootCapable bool `json:"rebootCapable"`
+
+	// Peers describes the peers known by the agent
+	//+operator-sdk:csv:customresourcedefinitions:type=status
+	Peers AgentPeersStatus `json:"peers"`
+
+	// SelfTest is the result of the last self-test
+	//+operator-sdk:csv:customresourcedefinitions:type=status
+	// +optional
+	SelfTest *SelfTestResult `json:"selfTest,omitempty"`
+
+	// LastUpdateTime is when the agent updated the status the last time
+	//+operator-sdk:csv:customresourcedefinitions:type=status
+	// +optional
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=snras;snragentstatus
+//+kubebuilder:printcolumn:name="Node",type="string",JSONPath=".spec.nodeName"
+//+kubebuilder:printcolumn:name="Reboot Capable",type="boolean",JSONPath=".status.rebootCapable"
+//+kubebuilder:printcolumn:name="Self-Test Passed",type="boolean",JSONPath=".status.selfTest.passed"
+//+kubebuilder:printcolumn:name="Last Update",type="date",JSONPath=".status.lastUpdateTime"
+
+// SelfNodeRemediationAgentStatus is the Schema for the selfnoderemediationagentstatuses API, it's written by the
+// self node remediation agent of a node, and named after the node
+// +operator-sdk:csv:customresourcedefinitions:resources={{"SelfNodeRemediationAgentStatus","v1alpha1","selfnoderemediationagentstatuses"}}
+type SelfNodeRemediationAgentStatus struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SelfNodeRemediationAgentStatusSpec   `json:"spec,omitempty"`
+	Status SelfNodeRemediationAgentStatusStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SelfNodeRemediationAgentStatusList contains a list of SelfNodeRemediationAgentStatus
+type SelfNodeRemediationAgentStatusList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SelfNodeRemediationAgentStatus `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SelfNodeRemediationAgentStatus{}, &SelfNodeRemediationAgentStatusList{})
+}
@@ -40,6 +40,22 @@ type SelfNodeRemediationConfigSpec struct {
 	// +optional
 	WatchdogFilePath string `json:"watchdogFilePath,omitempty"`
 
+	// PreferredWatchdogDevices selects the watchdog device by its identity or driver, in order of preference, e.g.
+	// prefer the iTCO_wdt driver, and fall back to the softdog driver. Devices are discovered in /sys/class/watchdog.
+	// The softdog module is loaded when a softdog device is preferred, but none exists yet.
+	// When no preferred device was found, the WatchdogFilePath is used.
+	// +optional
+	PreferredWatchdogDevices []WatchdogDeviceSelector `json:"preferredWatchdogDevices,omitempty"`
+
+	// WatchdogTimeoutSeconds is the timeout the agents configure on their watchdog device. When the softdog module
+	// needs to be loaded, it is loaded with a matching soft_margin. Devices might only support some values, so the
+	// timeout which was accepted by the device is reported in the node's watchdog timeout annotation, and used for the
+	// safe time to assume a node was rebooted.
+	// When not set, the device's current timeout is used.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	WatchdogTimeoutSeconds *int `json:"watchdogTimeoutSeconds,omitempty"`
+
 	// SafeTimeToAssumeNodeRebootedSeconds is the time after which the healthy self node remediation
 	// agents will assume the unhealthy node has been rebooted, and it is safe to recover affected workloads.
 	// This is extremely important as starting replacement Pods while they are still running on the failed
@@ -112,23 +128,346 @@ type SelfNodeRemediationConfigSpec struct {
 	// +optional
 	IsSoftwareRebootEnabled bool `json:"isSoftwareRebootEnabled,omitempty"`
 
+	// IsAgentPrivileged runs the self node remediation agents as privileged containers. This is needed by most
+	// container runtimes for accessing the watchdog device, and for falling back to the host's modprobe and to the sysrq
+	// trigger. When false, the agents only get the SYS_BOOT, SYS_MODULE and SYS_NICE capabilities for rebooting, loading
+	// the softdog module, and feeding the watchdog with real time priority. The host's /dev folder is still mounted, but
+	// the container runtime denies access to the watchdog device of unprivileged containers, so it needs to be made
+	// accessible in another way, e.g. by a device plugin. Otherwise the agents fall back to software reboot, if it's
+	// enabled.
+	// +kubebuilder:default=true
+	// +optional
+	IsAgentPrivileged *bool `json:"isAgentPrivileged,omitempty"`
+
+	// GracefulReboot configures the agents to first ask systemd to reboot an unhealthy node, which allows workloads to
+	// shut down cleanly in case the node is still able to, e.g. when only the API server isn't reachable.
+	// The watchdog keeps protecting the node during the grace period, and the agents fall back to the watchdog or
+	// software reboot if the node wasn't rebooted within the grace period.
+	// The grace period is added to the safe time to assume a node was rebooted.
+	// Graceful reboots are disabled when not set.
+	// +optional
+	GracefulReboot *GracefulReboot `json:"gracefulReboot,omitempty"`
+
+	// DryRun makes the self node remediation agents run their full health checks and remediation decisions, without
+	// rebooting nodes. Instead, the agents emit a WouldHaveRebooted event on the node, and increase the
+	// self_node_remediation_dry_run_reboots_total metric. This allows validating changes of e.g. the ApiCheckInterval or
+	// the MaxApiErrorThreshold safely.
+	// Remediations don't proceed past the reboot of the node, so workloads aren't deleted from nodes which weren't
+	// rebooted.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// RemediationLoopProtection stops rebooting nodes which were remediated too often within a time window, e.g.
+	// because of a hardware failure which isn't fixed by rebooting. Such nodes are left cordoned, and new remediations
+	// of them report the RemediationLoopDetected condition and fail, so that NodeHealthCheck can escalate to another
+	// remediator.
+	// The remediation loop protection is disabled when not set.
+	// +optional
+	RemediationLoopProtection *RemediationLoopProtection `json:"remediationLoopProtection,omitempty"`
+
 	// EndpointHealthCheckUrl is an url that self node remediation agents which run on control-plane node will try to access when they can't contact their peers.
 	// This is a part of self diagnostics which will decide whether the node should be remediated or not.
 	// It will be ignored when empty (which is the default).
+	// Deprecated: use EndpointHealthChecks instead. When set, it is treated as an additional ICMP probe.
 	// +optional
 	EndpointHealthCheckUrl string `json:"endpointHealthCheckUrl,omitempty"`
 
+	// EndpointHealthChecks is a list of probes that self node remediation agents will run as part of their self diagnostics
+	// when they can't contact their peers. A node which could reach the endpoints when the agent started, and can't reach
+	// them anymore, is considered to have lost its connectivity.
+	// +optional
+	EndpointHealthChecks []EndpointHealthCheck `json:"endpointHealthChecks,omitempty"`
+
+	// EndpointHealthCheckPolicy decides how the results of the EndpointHealthChecks are combined.
+	// "Any" considers the endpoints reachable if at least one probe succeeds, "All" only if all probes succeed.
+	// +kubebuilder:default:="Any"
+	// +kubebuilder:validation:Enum=Any;All
+	// +optional
+	EndpointHealthCheckPolicy EndpointHealthCheckPolicyType `json:"endpointHealthCheckPolicy,omitempty"`
+
+	// EndpointHealthCheckOnWorkers indicates whether the endpoint health checks are also used by agents running on
+	// worker nodes. By default, they are only used by agents running on control-plane nodes.
+	// +optional
+	EndpointHealthCheckOnWorkers bool `json:"endpointHealthCheckOnWorkers,omitempty"`
+
+	// KubeletHealthCheck configures how self node remediation agents which run on control-plane nodes check the kubelet
+	// as part of their self diagnostics.
+	// +optional
+	KubeletHealthCheck *KubeletHealthCheck `json:"kubeletHealthCheck,omitempty"`
+
 	// HostPort is used for internal communication between SNR agents.
 	// +kubebuilder:default:=30001
 	// +kubebuilder:validation:Minimum=1
 	// +optional
 	HostPort int `json:"hostPort,omitempty"`
 
+	// PeerHealthServer limits the load which the health requests of peers put on each agent. Requests beyond the
+	// limits are rejected immediately. The requesting agents ask other peers, and don't count busy peers as unreachable.
+	// +optional
+	PeerHealthServer *PeerHealthServer `json:"peerHealthServer,omitempty"`
+
+	// PeerCertificates configures where the certificates come from, which the agents use for authenticating each other.
+	// By default, the operator creates a self signed CA, and issues a certificate for each node with it.
+	// +optional
+	PeerCertificates *PeerCertificates `json:"peerCertificates,omitempty"`
+
 	// CustomDsTolerations allows to add custom tolerations snr agents that are running on the ds in order to support remediation for different types of nodes.
 	// +optional
 	CustomDsTolerations []v1.Toleration `json:"customDsTolerations,omitempty"`
 }
 
+type EndpointHealthCheckType string
+
+const (
+	// ICMPEndpointHealthCheck pings the target host
+	ICMPEndpointHealthCheck EndpointHealthCheckType = "ICMP"
+	// TCPEndpointHealthCheck opens a TCP connection to the target host:port
+	TCPEndpointHealthCheck EndpointHealthCheckType = "TCP"
+	// HTTPEndpointHealthCheck sends a GET request to the target URL and verifies the response status code
+	HTTPEndpointHealthCheck EndpointHealthCheckType = "HTTP"
+	// HTTPSEndpointHealthCheck sends a GET request to the target URL over TLS and verifies the response status code
+	HTTPSEndpointHealthCheck EndpointHealthCheckType = "HTTPS"
+)
+
+type EndpointHealthCheckPolicyType string
+
+const (
+	// AnyEndpointHealthCheckPolicy considers the endpoints reachable if at least one probe succeeds
+	AnyEndpointHealthCheckPolicy EndpointHealthCheckPolicyType = "Any"
+	// AllEndpointHealthCheckPolicy considers the endpoints reachable only if all probes succeed
+	AllEndpointHealthCheckPolicy EndpointHealthCheckPolicyType = "All"
+)
+
+// EndpointHealthCheck defines a single probe used for the self diagnostics of the self node remediation agents
+type EndpointHealthCheck struct {
+	// Type is the type of the probe, one of "ICMP", "TCP", "HTTP" or "HTTPS".
+	// +kubebuilder:validation:Enum=ICMP;TCP;HTTP;HTTPS
+	Type EndpointHealthCheckType `json:"type"`
+
+	// Target is the endpoint to probe.
+	// For ICMP it's a host name or IP address, for TCP it's a host:port pair, and for HTTP and HTTPS it's an URL.
+	// +kubebuilder:validation:MinLength=1
+	Target string `json:"target"`
+
+	// ExpectedStatusCode is the HTTP status code which is expected for HTTP and HTTPS probes.
+	// Defaults to 200 when empty. It's ignored for other probe types.
+	// +kubebuilder:validation:Minimum=100
+	// +kubebuilder:validation:Maximum=599
+	// +optional
+	ExpectedStatusCode int `json:"expectedStatusCode,omitempty"`
+
+	// Timeout for the probe.
+	// Valid time units are "ms", "s", "m", "h".
+	// +kubebuilder:default:="5s"
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"
+	// +kubebuilder:validation:Type:=string
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+type KubeletHealthCheckMode string
+
+const (
+	// AuthenticatedKubeletHealthCheckMode queries the kubelet's secure port with the agent's service account token,
+	// and verifies the kubelet's serving certificate
+	AuthenticatedKubeletHealthCheckMode KubeletHealthCheckMode = "Authenticated"
+	// ReadOnlyKubeletHealthCheckMode queries the kubelet's read-only port
+	ReadOnlyKubeletHealthCheckMode KubeletHealthCheckMode = "ReadOnly"
+)
+
+// KubeletHealthCheck defines how the kubelet's /healthz endpoint is queried during control-plane diagnostics
+type KubeletHealthCheck struct {
+	// Mode is either "Authenticated", which queries /healthz on the kubelet's secure port with the agent's
+	// service account token and verifies the kubelet's serving certificate, or "ReadOnly", which queries /healthz
+	// on the kubelet's read-only port.
+	// The agents don't use the host network, so they query the kubelet on the node's host IP. The kubelet's
+	// dedicated healthz port 10248 isn't used, because it only listens on localhost by default.
+	// When the kubelet rejects the health request with 401 or 403, /healthz on the read-only port 10255 is
+	// queried instead, and the kubelet is only considered running if it answers with 200.
+	// +kubebuilder:default:="Authenticated"
+	// +kubebuilder:validation:Enum=Authenticated;ReadOnly
+	// +optional
+	Mode KubeletHealthCheckMode `json:"mode,omitempty"`
+
+	// Port is the kubelet port to query. Defaults to 10250 for the "Authenticated" mode and to 10255 for the
+	// "ReadOnly" mode.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int `json:"port,omitempty"`
+
+	// ServingCAConfigMap references a ConfigMap with the CA bundle which signed the kubelet serving certificates.
+	// It's only used in the "Authenticated" mode. Defaults to the CA bundle of the API server.
+	// +optional
+	ServingCAConfigMap *ConfigMapKeyReference `json:"servingCAConfigMap,omitempty"`
+}
+
+// ConfigMapKeyReference references a key of a ConfigMap
+type ConfigMapKeyReference struct {
+	// Namespace of the ConfigMap
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+
+	// Name of the ConfigMap
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key in the ConfigMap's data
+	// +kubebuilder:default:="ca-bundle.crt"
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// PeerHealthServer defines the limits of the server which answers the health requests of peers
+type PeerHealthServer struct {
+	// MaxConcurrentStreams is the max number of concurrent requests on a single connection to an agent.
+	// +kubebuilder:default:=10
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxConcurrentStreams int `json:"maxConcurrentStreams,omitempty"`
+
+	// MaxConcurrentRequests is the max number of requests an agent handles at the same time, over all connections.
+	// +kubebuilder:default:=20
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxConcurrentRequests int `json:"maxConcurrentRequests,omitempty"`
+
+	// PeerRequestsPerSecond is the rate of requests an agent accepts from each peer. It's enforced with a token bucket
+	// per peer, which is refilled with this rate.
+	// +kubebuilder:default:=1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PeerRequestsPerSecond int `json:"peerRequestsPerSecond,omitempty"`
+
+	// PeerRequestBurst is the size of the token bucket of each peer, which is the number of requests a peer can send
+	// at once.
+	// +kubebuilder:default:=5
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PeerRequestBurst int `json:"peerRequestBurst,omitempty"`
+
+	// KeepaliveMinTime is the min time between the keepalive pings of a peer. Connections of peers which ping more
+	// often are closed.
+	// +kubebuilder:default:="30s"
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"
+	// +kubebuilder:validation:Type:=string
+	// +optional
+	KeepaliveMinTime *metav1.Duration `json:"keepaliveMinTime,omitempty"`
+}
+
+type PeerCertificatesBackend string
+
+const (
+	// SelfSignedPeerCertificatesBackend creates a self signed CA in the operator, which signs the certificates the
+	// agents request for their nodes
+	SelfSignedPeerCertificatesBackend PeerCertificatesBackend = "SelfSigned"
+	// CertManagerPeerCertificatesBackend signs the certificates the agents request for their nodes with cert-manager
+	CertManagerPeerCertificatesBackend PeerCertificatesBackend = "CertManager"
+	// ExternalPeerCertificatesBackend mounts an externally provided TLS secret into the agents
+	ExternalPeerCertificatesBackend PeerCertificatesBackend = "External"
+)
+
+type PeerCertificatesKeyAlgorithm string
+
+const (
+	// RSAPeerCertificatesKeyAlgorithm uses RSA keys
+	RSAPeerCertificatesKeyAlgorithm PeerCertificatesKeyAlgorithm = "RSA"
+	// ECDSAPeerCertificatesKeyAlgorithm uses ECDSA keys with the P-256 curve, which have cheaper TLS handshakes
+	ECDSAPeerCertificatesKeyAlgorithm PeerCertificatesKeyAlgorithm = "ECDSA"
+)
+
+// PeerCertificates configures the certificates of the agents
+type PeerCertificates struct {
+	// Backend is the source of the certificates, one of "SelfSigned", "CertManager" or "External".
+	// With "SelfSigned" and "CertManager", every agent creates the key of its node and requests its certificate with a
+	// CertificateSigningRequest, which is only signed for the node of the agent.
+	// "SelfSigned" creates a self signed CA in the operator, which signs the certificates, and is rotated
+	// automatically.
+	// "CertManager" signs the certificates with cert-manager CertificateRequests of the IssuerRef. The issuer needs to
+	// provide the CA in the ca.crt key of the certificate secrets, e.g. a CA issuer.
+	// "External" mounts the ExternalSecretName into the agents. The certificate is shared by all nodes, so it doesn't
+	// identify them, and agents answer health requests of all peers with a certificate signed by the CA.
+	// +kubebuilder:default:="SelfSigned"
+	// +kubebuilder:validation:Enum=SelfSigned;CertManager;External
+	// +optional
+	Backend PeerCertificatesBackend `json:"backend,omitempty"`
+
+	// KeyAlgorithm is the algorithm of the keys of the "SelfSigned" CA and of the "CertManager" admin certificate,
+	// either "RSA" or "ECDSA". The agents create keys with the algorithm of the CA. Changing it renews the certificates.
+	// +kubebuilder:default:="RSA"
+	// +kubebuilder:validation:Enum=RSA;ECDSA
+	// +optional
+	KeyAlgorithm PeerCertificatesKeyAlgorithm `json:"keyAlgorithm,omitempty"`
+
+	// IssuerRef references the cert-manager issuer of the "CertManager" backend.
+	// +optional
+	IssuerRef *CertManagerIssuerReference `json:"issuerRef,omitempty"`
+
+	// ExternalSecretName is the name of the secret of the "External" backend, in the namespace of the operator. It needs
+	// the tls.crt, tls.key and ca.crt keys. The certificate needs to be valid for the IP address 192.0.2.1, and for
+	// client and server authentication.
+	// +optional
+	ExternalSecretName string `json:"externalSecretName,omitempty"`
+}
+
+// CertManagerIssuerReference references a cert-manager issuer
+type CertManagerIssuerReference struct {
+	// Name of the issuer
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Kind of the issuer, either "Issuer" in the namespace of the operator, or "ClusterIssuer".
+	// +kubebuilder:default:="Issuer"
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Group of the issuer, for external issuers.
+	// +kubebuilder:default:="cert-manager.io"
+	// +optional
+	Group string `json:"group,omitempty"`
+}
+
+// WatchdogDeviceSelector selects watchdog devices by their identity or driver. When both are set, both need to match.
+type WatchdogDeviceSelector struct {
+	// Identity is the identity reported by the watchdog device, e.g. "iTCO_wdt" or "Software Watchdog"
+	// +optional
+	Identity string `json:"identity,omitempty"`
+
+	// Driver is the name of the kernel driver of the watchdog device, e.g. "iTCO_wdt" or "softdog"
+	// +optional
+	Driver string `json:"driver,omitempty"`
+}
+
+// GracefulReboot configures graceful reboots
+type GracefulReboot struct {
+	// GracePeriod is the max time for the graceful reboot.
+	// Valid time units are "ms", "s", "m", "h".
+	// +kubebuilder:default:="60s"
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"
+	// +kubebuilder:validation:Type:=string
+	// +optional
+	GracePeriod *metav1.Duration `json:"gracePeriod,omitempty"`
+}
+
+// RemediationLoopProtection configures how many remediations of a node are allowed within a time window
+type RemediationLoopProtection struct {
+	// MaxRemediations is the max number of remediations of a node within the Window. Further remediations of the node
+	// won't reboot it.
+	// +kubebuilder:default:=3
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxRemediations int `json:"maxRemediations,omitempty"`
+
+	// Window is the time window in which remediations of a node are counted.
+	// Valid time units are "ms", "s", "m", "h".
+	// +kubebuilder:default:="1h"
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"
+	// +kubebuilder:validation:Type:=string
+	// +optional
+	Window *metav1.Duration `json:"window,omitempty"`
+}
+
 // SelfNodeRemediationConfigStatus defines the observed state of SelfNodeRemediationConfig
 type SelfNodeRemediationConfigStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
@@ -18,6 +18,8 @@ package v1alpha1
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
@@ -49,6 +51,16 @@ const (
 	minDurPeerRequestTimeout   = 10 * time.Millisecond
 	minDurApiCheckInterval     = 1 * time.Second
 	minDurPeerUpdateInterval   = 10 * time.Second
+
+	minDurGracefulRebootGracePeriod = 1 * time.Second
+	// maxDurGracefulRebootGracePeriod limits how much graceful reboots delay the recovery of workloads
+	maxDurGracefulRebootGracePeriod = 10 * time.Minute
+
+	// minDurRemediationLoopProtectionWindow is about the duration of a single remediation, shorter windows can't detect loops
+	minDurRemediationLoopProtectionWindow = 1 * time.Minute
+
+	// minDurPeerHealthKeepaliveMinTime prevents peers from flooding the agents with keepalive pings
+	minDurPeerHealthKeepaliveMinTime = 1 * time.Second
 )
 
 type field struct {
@@ -77,6 +89,12 @@ func (r *SelfNodeRemediationConfig) ValidateCreate() (warning admission.Warnings
 	return admission.Warnings{}, errors.NewAggregate([]error{
 		r.validateTimes(),
 		r.validateCustomTolerations(),
+		r.validateEndpointHealthChecks(),
+		r.validatePreferredWatchdogDevices(),
+		r.validateGracefulReboot(),
+		r.validateRemediationLoopProtection(),
+		r.validatePeerCertificates(),
+		r.validatePeerHealthServer(),
 		r.validateSingleton(),
 	})
 
@@ -89,6 +107,12 @@ func (r *SelfNodeRemediationConfig) ValidateUpdate(_ runtime.Object) (warning ad
 	return admission.Warnings{}, errors.NewAggregate([]error{
 		r.validateTimes(),
 		r.validateCustomTolerations(),
+		r.validateEndpointHealthChecks(),
+		r.validatePreferredWatchdogDevices(),
+		r.validateGracefulReboot(),
+		r.validateRemediationLoopProtection(),
+		r.validatePeerCertificates(),
+		r.validatePeerHealthServer(),
 	})
 }
 
@@ -143,6 +167,57 @@ func (f *field) validate() error {
 	return nil
 }
 
+func (r *SelfNodeRemediationConfig) validateGracefulReboot() error {
+	gracefulReboot := r.Spec.GracefulReboot
+	if gracefulReboot == nil || gracefulReboot.GracePeriod == nil {
+		return nil
+	}
+	if gracePeriod := gracefulReboot.GracePeriod.Duration; gracePeriod < minDurGracefulRebootGracePeriod || gracePeriod > maxDurGracefulRebootGracePeriod {
+		return fmt.Errorf("graceful reboot grace period must be between %s and %s", minDurGracefulRebootGracePeriod, maxDurGracefulRebootGracePeriod)
+	}
+	return nil
+}
+
+func (r *SelfNodeRemediationConfig) validateRemediationLoopProtection() error {
+	loopProtection := r.Spec.RemediationLoopProtection
+	if loopProtection == nil || loopProtection.Window == nil {
+		return nil
+	}
+	if loopProtection.Window.Duration < minDurRemediationLoopProtectionWindow {
+		return fmt.Errorf("remediation loop protection window cannot be less than %s", minDurRemediationLoopProtectionWindow)
+	}
+	return nil
+}
+
+func (r *SelfNodeRemediationConfig) validatePeerHealthServer() error {
+	peerHealthServer := r.Spec.PeerHealthServer
+	if peerHealthServer == nil || peerHealthServer.KeepaliveMinTime == nil {
+		return nil
+	}
+	if peerHealthServer.KeepaliveMinTime.Duration < minDurPeerHealthKeepaliveMinTime {
+		return fmt.Errorf("peer health server keepalive min time cannot be less than %s", minDurPeerHealthKeepaliveMinTime)
+	}
+	return nil
+}
+
+func (r *SelfNodeRemediationConfig) validatePeerCertificates() error {
+	peerCertificates := r.Spec.PeerCertificates
+	if peerCertificates == nil {
+		return nil
+	}
+	switch peerCertificates.Backend {
+	case CertManagerPeerCertificatesBackend:
+		if peerCertificates.IssuerRef == nil {
+			return fmt.Errorf("the %s peer certificates backend needs an issuerRef", peerCertificates.Backend)
+		}
+	case ExternalPeerCertificatesBackend:
+		if peerCertificates.ExternalSecretName == "" {
+			return fmt.Errorf("the %s peer certificates backend needs an externalSecretName", peerCertificates.Backend)
+		}
+	}
+	return nil
+}
+
 func (r *SelfNodeRemediationConfig) validateCustomTolerations() error {
 	customTolerations := r.Spec.CustomDsTolerations
 	for _, toleration := range customTolerations {
@@ -184,6 +259,49 @@ func validateToleration(toleration v1.Toleration) error {
 	return nil
 }
 
+func (r *SelfNodeRemediationConfig) validateEndpointHealthChecks() error {
+	for _, check := range r.Spec.EndpointHealthChecks {
+		if err := validateEndpointHealthCheck(check); err != nil {
+			selfNodeRemediationConfigLog.Error(err, "invalid endpoint health check", "type", check.Type, "target", check.Target)
+			return err
+		}
+	}
+	return nil
+}
+
+func validateEndpointHealthCheck(check EndpointHealthCheck) error {
+	switch check.Type {
+	case ICMPEndpointHealthCheck:
+		if check.Target == "" {
+			return fmt.Errorf("invalid target for %s endpoint health check: target must not be empty", check.Type)
+		}
+	case TCPEndpointHealthCheck:
+		if _, _, err := net.SplitHostPort(check.Target); err != nil {
+			return fmt.Errorf("invalid target for %s endpoint health check, expected host:port: %s", check.Type, check.Target)
+		}
+	case HTTPEndpointHealthCheck, HTTPSEndpointHealthCheck:
+		expectedScheme := "http"
+		if check.Type == HTTPSEndpointHealthCheck {
+			expectedScheme = "https"
+		}
+		if u, err := url.Parse(check.Target); err != nil || u.Scheme != expectedScheme || u.Host == "" {
+			return fmt.Errorf("invalid target for %s endpoint health check, expected %s URL: %s", check.Type, expectedScheme, check.Target)
+		}
+	default:
+		return fmt.Errorf("invalid endpoint health check type: %s", check.Type)
+	}
+	return nil
+}
+
+func (r *SelfNodeRemediationConfig) validatePreferredWatchdogDevices() error {
+	for i, selector := range r.Spec.PreferredWatchdogDevices {
+		if selector.Identity == "" && selector.Driver == "" {
+			return fmt.Errorf("invalid preferred watchdog device at index %d: either identity or driver must be set", i)
+		}
+	}
+	return nil
+}
+
 func (r *SelfNodeRemediationConfig) validateSingleton() error {
 	if r.Name != ConfigCRName {
 		return fmt.Errorf("to enforce only one SelfNodeRemediationConfig in the cluster, a name other than %s is not allowed", ConfigCRName)
@@ -196,6 +196,145 @@ func testSingleInvalidField(validationType validationType) {
 			Expect(err.Error()).To(ContainSubstring("invalid value for toleration, value must be empty for Operator value is Exists"))
 		})
 	})
+
+	Context(fmt.Sprintf("%s validation of endpoint health checks", validationType.getName()), func() {
+		It("should be rejected - TCP target without port", func() {
+			snrc := createTestSelfNodeRemediationConfigCR()
+			snrc.Spec.EndpointHealthChecks = []EndpointHealthCheck{{Type: TCPEndpointHealthCheck, Target: "10.0.0.1"}}
+
+			var err error
+			if validationType == update {
+				snrcOld := createTestSelfNodeRemediationConfigCR()
+				_, err = snrc.ValidateUpdate(snrcOld)
+			} else {
+				_, err = snrc.ValidateCreate()
+			}
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid target for TCP endpoint health check, expected host:port: 10.0.0.1"))
+		})
+		It("should be rejected - HTTPS target with http scheme", func() {
+			snrc := createTestSelfNodeRemediationConfigCR()
+			snrc.Spec.EndpointHealthChecks = []EndpointHealthCheck{{Type: HTTPSEndpointHealthCheck, Target: "http://example.com/healthz"}}
+
+			var err error
+			if validationType == update {
+				snrcOld := createTestSelfNodeRemediationConfigCR()
+				_, err = snrc.ValidateUpdate(snrcOld)
+			} else {
+				_, err = snrc.ValidateCreate()
+			}
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid target for HTTPS endpoint health check, expected https URL"))
+		})
+	})
+
+	Context(fmt.Sprintf("%s validation of preferred watchdog devices", validationType.getName()), func() {
+		It("should be rejected - empty selector", func() {
+			snrc := createTestSelfNodeRemediationConfigCR()
+			snrc.Spec.PreferredWatchdogDevices = []WatchdogDeviceSelector{{Driver: "iTCO_wdt"}, {}}
+
+			var err error
+			if validationType == update {
+				snrcOld := createTestSelfNodeRemediationConfigCR()
+				_, err = snrc.ValidateUpdate(snrcOld)
+			} else {
+				_, err = snrc.ValidateCreate()
+			}
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid preferred watchdog device at index 1: either identity or driver must be set"))
+		})
+	})
+
+	Context(fmt.Sprintf("%s validation of graceful reboot", validationType.getName()), func() {
+		It("should be rejected - grace period too long", func() {
+			snrc := createTestSelfNodeRemediationConfigCR()
+			snrc.Spec.GracefulReboot = &GracefulReboot{GracePeriod: &metav1.Duration{Duration: time.Hour}}
+
+			var err error
+			if validationType == update {
+				snrcOld := createTestSelfNodeRemediationConfigCR()
+				_, err = snrc.ValidateUpdate(snrcOld)
+			} else {
+				_, err = snrc.ValidateCreate()
+			}
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("graceful reboot grace period must be between 1s and 10m0s"))
+		})
+	})
+
+	Context(fmt.Sprintf("%s validation of remediation loop protection", validationType.getName()), func() {
+		It("should be rejected - window too short", func() {
+			snrc := createTestSelfNodeRemediationConfigCR()
+			snrc.Spec.RemediationLoopProtection = &RemediationLoopProtection{MaxRemediations: 3, Window: &metav1.Duration{Duration: 10 * time.Second}}
+
+			var err error
+			if validationType == update {
+				snrcOld := createTestSelfNodeRemediationConfigCR()
+				_, err = snrc.ValidateUpdate(snrcOld)
+			} else {
+				_, err = snrc.ValidateCreate()
+			}
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("remediation loop protection window cannot be less than 1m0s"))
+		})
+	})
+
+	Context(fmt.Sprintf("%s validation of the peer health server", validationType.getName()), func() {
+		It("should be rejected - keepalive min time too short", func() {
+			snrc := createTestSelfNodeRemediationConfigCR()
+			snrc.Spec.PeerHealthServer = &PeerHealthServer{KeepaliveMinTime: &metav1.Duration{Duration: 100 * time.Millisecond}}
+
+			var err error
+			if validationType == update {
+				snrcOld := createTestSelfNodeRemediationConfigCR()
+				_, err = snrc.ValidateUpdate(snrcOld)
+			} else {
+				_, err = snrc.ValidateCreate()
+			}
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("peer health server keepalive min time cannot be less than 1s"))
+		})
+	})
+
+	Context(fmt.Sprintf("%s validation of peer certificates", validationType.getName()), func() {
+		It("should be rejected - cert-manager backend without issuer", func() {
+			snrc := createTestSelfNodeRemediationConfigCR()
+			snrc.Spec.PeerCertificates = &PeerCertificates{Backend: CertManagerPeerCertificatesBackend}
+
+			var err error
+			if validationType == update {
+				snrcOld := createTestSelfNodeRemediationConfigCR()
+				_, err = snrc.ValidateUpdate(snrcOld)
+			} else {
+				_, err = snrc.ValidateCreate()
+			}
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("the CertManager peer certificates backend needs an issuerRef"))
+		})
+
+		It("should be rejected - external backend without secret", func() {
+			snrc := createTestSelfNodeRemediationConfigCR()
+			snrc.Spec.PeerCertificates = &PeerCertificates{Backend: ExternalPeerCertificatesBackend}
+
+			var err error
+			if validationType == update {
+				snrcOld := createTestSelfNodeRemediationConfigCR()
+				_, err = snrc.ValidateUpdate(snrcOld)
+			} else {
+				_, err = snrc.ValidateCreate()
+			}
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("the External peer certificates backend needs an externalSecretName"))
+		})
+	})
 }
 
 func testMultipleInvalidFields(validationType validationType) {
@@ -240,6 +379,18 @@ func testValidCR(validationType validationType) {
 	snrc.Spec.ApiCheckInterval = &metav1.Duration{Duration: 10*time.Second + 500*time.Millisecond}
 	snrc.Spec.PeerUpdateInterval = &metav1.Duration{Duration: 10 * time.Second}
 	snrc.Spec.CustomDsTolerations = []v1.Toleration{{Key: "validValue", Effect: v1.TaintEffectNoExecute}, {}, {Operator: v1.TolerationOpEqual, TolerationSeconds: pointer.Int64(-5)}, {Value: "SomeValidValue"}}
+	snrc.Spec.EndpointHealthChecks = []EndpointHealthCheck{
+		{Type: ICMPEndpointHealthCheck, Target: "10.0.0.1"},
+		{Type: TCPEndpointHealthCheck, Target: "10.0.0.1:443"},
+		{Type: HTTPSEndpointHealthCheck, Target: "https://example.com/healthz", ExpectedStatusCode: 204},
+	}
+	snrc.Spec.PreferredWatchdogDevices = []WatchdogDeviceSelector{{Driver: "iTCO_wdt"}, {Identity: "Software Watchdog"}}
+	snrc.Spec.GracefulReboot = &GracefulReboot{GracePeriod: &metav1.Duration{Duration: 2 * time.Minute}}
+	snrc.Spec.RemediationLoopProtection = &RemediationLoopProtection{MaxRemediations: 3, Window: &metav1.Duration{Duration: time.Hour}}
+	snrc.Spec.PeerCertificates = &PeerCertificates{Backend: CertManagerPeerCertificatesBackend, KeyAlgorithm: ECDSAPeerCertificatesKeyAlgorithm,
+		IssuerRef: &CertManagerIssuerReference{Name: "peer-ca", Kind: "ClusterIssuer"}}
+	snrc.Spec.PeerHealthServer = &PeerHealthServer{MaxConcurrentStreams: 10, MaxConcurrentRequests: 20, PeerRequestsPerSecond: 1,
+		PeerRequestBurst: 5, KeepaliveMinTime: &metav1.Duration{Duration: 30 * time.Second}}
 
 	Context("for valid CR", func() {
 		BeforeEach(func() {
@@ -26,6 +26,250 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgentPeersStatus) DeepCopyInto(out *AgentPeersStatus) {
+	*out = *in
+	if in.LastSuccessfulRequestTime != nil {
+		in, out := &in.LastSuccessfulRequestTime, &out.LastSuccessfulRequestTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentPeersStatus.
+func (in *AgentPeersStatus) DeepCopy() *AgentPeersStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AgentPeersStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgentWatchdogStatus) DeepCopyInto(out *AgentWatchdogStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentWatchdogStatus.
+func (in *AgentWatchdogStatus) DeepCopy() *AgentWatchdogStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AgentWatchdogStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertManagerIssuerReference) DeepCopyInto(out *CertManagerIssuerReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertManagerIssuerReference.
+func (in *CertManagerIssuerReference) DeepCopy() *CertManagerIssuerReference {
+	if in == nil {
+		return nil
+	}
+	out := new(CertManagerIssuerReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapKeyReference) DeepCopyInto(out *ConfigMapKeyReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapKeyReference.
+func (in *ConfigMapKeyReference) DeepCopy() *ConfigMapKeyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapKeyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DryRunPrecondition) DeepCopyInto(out *DryRunPrecondition) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DryRunPrecondition.
+func (in *DryRunPrecondition) DeepCopy() *DryRunPrecondition {
+	if in == nil {
+		return nil
+	}
+	out := new(DryRunPrecondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DryRunResult) DeepCopyInto(out *DryRunResult) {
+	*out = *in
+	if in.Preconditions != nil {
+		in, out := &in.Preconditions, &out.Preconditions
+		*out = make([]DryRunPrecondition, len(*in))
+		copy(*out, *in)
+	}
+	if in.Timeline != nil {
+		in, out := &in.Timeline, &out.Timeline
+		*out = make([]PlannedRemediationStep, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastCheckTime != nil {
+		in, out := &in.LastCheckTime, &out.LastCheckTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DryRunResult.
+func (in *DryRunResult) DeepCopy() *DryRunResult {
+	if in == nil {
+		return nil
+	}
+	out := new(DryRunResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EndpointHealthCheck) DeepCopyInto(out *EndpointHealthCheck) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointHealthCheck.
+func (in *EndpointHealthCheck) DeepCopy() *EndpointHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(EndpointHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GracefulReboot) DeepCopyInto(out *GracefulReboot) {
+	*out = *in
+	if in.GracePeriod != nil {
+		in, out := &in.GracePeriod, &out.GracePeriod
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GracefulReboot.
+func (in *GracefulReboot) DeepCopy() *GracefulReboot {
+	if in == nil {
+		return nil
+	}
+	out := new(GracefulReboot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubeletHealthCheck) DeepCopyInto(out *KubeletHealthCheck) {
+	*out = *in
+	if in.ServingCAConfigMap != nil {
+		in, out := &in.ServingCAConfigMap, &out.ServingCAConfigMap
+		*out = new(ConfigMapKeyReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeletHealthCheck.
+func (in *KubeletHealthCheck) DeepCopy() *KubeletHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(KubeletHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PeerCertificates) DeepCopyInto(out *PeerCertificates) {
+	*out = *in
+	if in.IssuerRef != nil {
+		in, out := &in.IssuerRef, &out.IssuerRef
+		*out = new(CertManagerIssuerReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeerCertificates.
+func (in *PeerCertificates) DeepCopy() *PeerCertificates {
+	if in == nil {
+		return nil
+	}
+	out := new(PeerCertificates)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PeerHealthServer) DeepCopyInto(out *PeerHealthServer) {
+	*out = *in
+	if in.KeepaliveMinTime != nil {
+		in, out := &in.KeepaliveMinTime, &out.KeepaliveMinTime
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeerHealthServer.
+func (in *PeerHealthServer) DeepCopy() *PeerHealthServer {
+	if in == nil {
+		return nil
+	}
+	out := new(PeerHealthServer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlannedRemediationStep) DeepCopyInto(out *PlannedRemediationStep) {
+	*out = *in
+	out.After = in.After
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlannedRemediationStep.
+func (in *PlannedRemediationStep) DeepCopy() *PlannedRemediationStep {
+	if in == nil {
+		return nil
+	}
+	out := new(PlannedRemediationStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RemediationLoopProtection) DeepCopyInto(out *RemediationLoopProtection) {
+	*out = *in
+	if in.Window != nil {
+		in, out := &in.Window, &out.Window
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemediationLoopProtection.
+func (in *RemediationLoopProtection) DeepCopy() *RemediationLoopProtection {
+	if in == nil {
+		return nil
+	}
+	out := new(RemediationLoopProtection)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SelfNodeRemediation) DeepCopyInto(out *SelfNodeRemediation) {
 	*out = *in
@@ -53,6 +297,110 @@ func (in *SelfNodeRemediation) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SelfNodeRemediationAgentStatus) DeepCopyInto(out *SelfNodeRemediationAgentStatus) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SelfNodeRemediationAgentStatus.
+func (in *SelfNodeRemediationAgentStatus) DeepCopy() *SelfNodeRemediationAgentStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SelfNodeRemediationAgentStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SelfNodeRemediationAgentStatus) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SelfNodeRemediationAgentStatusList) DeepCopyInto(out *SelfNodeRemediationAgentStatusList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SelfNodeRemediationAgentStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SelfNodeRemediationAgentStatusList.
+func (in *SelfNodeRemediationAgentStatusList) DeepCopy() *SelfNodeRemediationAgentStatusList {
+	if in == nil {
+		return nil
+	}
+	out := new(SelfNodeRemediationAgentStatusList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SelfNodeRemediationAgentStatusList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SelfNodeRemediationAgentStatusSpec) DeepCopyInto(out *SelfNodeRemediationAgentStatusSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SelfNodeRemediationAgentStatusSpec.
+func (in *SelfNodeRemediationAgentStatusSpec) DeepCopy() *SelfNodeRemediationAgentStatusSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SelfNodeRemediationAgentStatusSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SelfNodeRemediationAgentStatusStatus) DeepCopyInto(out *SelfNodeRemediationAgentStatusStatus) {
+	*out = *in
+	if in.Watchdog != nil {
+		in, out := &in.Watchdog, &out.Watchdog
+		*out = new(AgentWatchdogStatus)
+		**out = **in
+	}
+	in.Peers.DeepCopyInto(&out.Peers)
+	if in.SelfTest != nil {
+		in, out := &in.SelfTest, &out.SelfTest
+		*out = new(SelfTestResult)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastUpdateTime != nil {
+		in, out := &in.LastUpdateTime, &out.LastUpdateTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SelfNodeRemediationAgentStatusStatus.
+func (in *SelfNodeRemediationAgentStatusStatus) DeepCopy() *SelfNodeRemediationAgentStatusStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SelfNodeRemediationAgentStatusStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SelfNodeRemediationConfig) DeepCopyInto(out *SelfNodeRemediationConfig) {
 	*out = *in
@@ -115,6 +463,16 @@ func (in *SelfNodeRemediationConfigList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SelfNodeRemediationConfigSpec) DeepCopyInto(out *SelfNodeRemediationConfigSpec) {
 	*out = *in
+	if in.PreferredWatchdogDevices != nil {
+		in, out := &in.PreferredWatchdogDevices, &out.PreferredWatchdogDevices
+		*out = make([]WatchdogDeviceSelector, len(*in))
+		copy(*out, *in)
+	}
+	if in.WatchdogTimeoutSeconds != nil {
+		in, out := &in.WatchdogTimeoutSeconds, &out.WatchdogTimeoutSeconds
+		*out = new(int)
+		**out = **in
+	}
 	if in.SafeTimeToAssumeNodeRebootedSeconds != nil {
 		in, out := &in.SafeTimeToAssumeNodeRebootedSeconds, &out.SafeTimeToAssumeNodeRebootedSeconds
 		*out = new(int)
@@ -150,6 +508,43 @@ func (in *SelfNodeRemediationConfigSpec) DeepCopyInto(out *SelfNodeRemediationCo
 		*out = new(v1.Duration)
 		**out = **in
 	}
+	if in.IsAgentPrivileged != nil {
+		in, out := &in.IsAgentPrivileged, &out.IsAgentPrivileged
+		*out = new(bool)
+		**out = **in
+	}
+	if in.GracefulReboot != nil {
+		in, out := &in.GracefulReboot, &out.GracefulReboot
+		*out = new(GracefulReboot)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RemediationLoopProtection != nil {
+		in, out := &in.RemediationLoopProtection, &out.RemediationLoopProtection
+		*out = new(RemediationLoopProtection)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EndpointHealthChecks != nil {
+		in, out := &in.EndpointHealthChecks, &out.EndpointHealthChecks
+		*out = make([]EndpointHealthCheck, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KubeletHealthCheck != nil {
+		in, out := &in.KubeletHealthCheck, &out.KubeletHealthCheck
+		*out = new(KubeletHealthCheck)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PeerHealthServer != nil {
+		in, out := &in.PeerHealthServer, &out.PeerHealthServer
+		*out = new(PeerHealthServer)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PeerCertificates != nil {
+		in, out := &in.PeerCertificates, &out.PeerCertificates
+		*out = new(PeerCertificates)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.CustomDsTolerations != nil {
 		in, out := &in.CustomDsTolerations, &out.CustomDsTolerations
 		*out = make([]corev1.Toleration, len(*in))
@@ -250,6 +645,11 @@ func (in *SelfNodeRemediationStatus) DeepCopyInto(out *SelfNodeRemediationStatus
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.DryRunResult != nil {
+		in, out := &in.DryRunResult, &out.DryRunResult
+		*out = new(DryRunResult)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SelfNodeRemediationStatus.
@@ -367,3 +767,54 @@ func (in *SelfNodeRemediationTemplateStatus) DeepCopy() *SelfNodeRemediationTemp
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SelfTestCheck) DeepCopyInto(out *SelfTestCheck) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SelfTestCheck.
+func (in *SelfTestCheck) DeepCopy() *SelfTestCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(SelfTestCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SelfTestResult) DeepCopyInto(out *SelfTestResult) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.Checks != nil {
+		in, out := &in.Checks, &out.Checks
+		*out = make([]SelfTestCheck, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SelfTestResult.
+func (in *SelfTestResult) DeepCopy() *SelfTestResult {
+	if in == nil {
+		return nil
+	}
+	out := new(SelfTestResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WatchdogDeviceSelector) DeepCopyInto(out *WatchdogDeviceSelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WatchdogDeviceSelector.
+func (in *WatchdogDeviceSelector) DeepCopy() *WatchdogDeviceSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(WatchdogDeviceSelector)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// snrctl is a command line tool for inspecting and manually driving self node remediations
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	pkgruntime "k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+)
+
+const usage = `snrctl inspects and manually drives self node remediations.
+
+Usage:
+  snrctl [--kubeconfig <file>] [-n <namespace>] <command> [arguments]
+
+Commands:
+  list                                      list remediations with their phase, strategy and the time the node is
+                                            assumed rebooted
+  nodes                                     show which nodes are reboot capable, and their watchdog timeouts
+  reboot-time <node>                        compute the safe time to assume the node rebooted
+  agent-state <node> [--address <address>]  query the agent of the node for its internal state, on the given address
+                                            when using kubectl port-forward
+  create <node> [--strategy <strategy>] [--dry-run]
+                                            create a remediation for the node
+  cancel <remediation>                      stop a remediation, like NHC does when a remediation timed out
+  annotate <remediation> <key=value>...     set annotations of a remediation, "key-" removes the annotation
+
+The namespace defaults to the namespace of the SelfNodeRemediationConfig for all commands except list, which lists
+remediations of all namespaces by default.
+`
+
+// apiTimeout is the timeout of a command
+const apiTimeout = 30 * time.Second
+
+var scheme = pkgruntime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(v1alpha1.AddToScheme(scheme))
+}
+
+// snrctl runs the commands
+type snrctl struct {
+	client    client.Client
+	namespace string
+	out       io.Writer
+}
+
+type command struct {
+	// minArgs is the minimal number of arguments, all commands except list and nodes need at least one
+	minArgs int
+	run     func(s *snrctl, ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{
+	"list":        {run: (*snrctl).list},
+	"nodes":       {run: (*snrctl).nodes},
+	"reboot-time": {minArgs: 1, run: (*snrctl).rebootTime},
+	"agent-state": {minArgs: 1, run: (*snrctl).agentState},
+	"create":      {minArgs: 1, run: (*snrctl).create},
+	"cancel":      {minArgs: 1, run: (*snrctl).cancel},
+	"annotate":    {minArgs: 2, run: (*snrctl).annotate},
+}
+
+func main() {
+	var namespace string
+	flag.StringVar(&namespace, "n", "", "the namespace")
+	flag.StringVar(&namespace, "namespace", "", "the namespace")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+	}
+	// the kubeconfig flag is registered by controller-runtime
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd, found := commands[flag.Arg(0)]
+	if !found {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+	args := flag.Args()[1:]
+	if len(args) < cmd.minArgs {
+		fmt.Fprintf(os.Stderr, "%s needs at least %d argument(s)\n\n", flag.Arg(0), cmd.minArgs)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get kubeconfig: %v\n", err)
+		os.Exit(1)
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create client: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+	s := &snrctl{client: c, namespace: namespace, out: os.Stdout}
+	if err := cmd.run(s, ctx, args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+	"github.com/medik8s/self-node-remediation/controllers"
+	"github.com/medik8s/self-node-remediation/pkg/agentready"
+	"github.com/medik8s/self-node-remediation/pkg/certificates"
+	"github.com/medik8s/self-node-remediation/pkg/peerhealth"
+	"github.com/medik8s/self-node-remediation/pkg/reboot"
+	"github.com/medik8s/self-node-remediation/pkg/utils"
+)
+
+const (
+	// defaultHostPort is the default of the config's HostPort
+	defaultHostPort = 30001
+	dialTimeout     = 5 * time.Second
+)
+
+// nodes prints whether the nodes are reboot capable, and their watchdogs
+func (s *snrctl) nodes(ctx context.Context, _ []string) error {
+	namespace, err := s.getNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	nodes := &v1.NodeList{}
+	if err := s.client.List(ctx, nodes); err != nil {
+		return errors.Wrap(err, "failed to list nodes")
+	}
+
+	w := tabwriter.NewWriter(s.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tREBOOT-CAPABLE\tWATCHDOG-TIMEOUT\tWATCHDOG-DEVICE\tAGENT-READY\tMESSAGE")
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		rebootCapable, message := true, ""
+		if err := controllers.CheckRebootCapable(ctx, s.client, namespace, node); err != nil {
+			rebootCapable, message = false, err.Error()
+		}
+		watchdogTimeout := "-"
+		if timeout, err := utils.GetWatchdogTimeout(node); err == nil {
+			watchdogTimeout = timeout.String()
+		}
+		watchdogDevice := node.Annotations[utils.WatchdogDeviceAnnotation]
+		if watchdogDevice == "" {
+			watchdogDevice = "-"
+		}
+		agentReady := "Unknown"
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == agentready.NodeConditionType {
+				agentReady = string(condition.Status)
+				if condition.Status != v1.ConditionTrue {
+					agentReady += " (" + condition.Reason + ")"
+				}
+			}
+		}
+		fmt.Fprintf(w, "%s\t%t\t%s\t%s\t%s\t%s\n", node.Name, rebootCapable, watchdogTimeout, watchdogDevice, agentReady, message)
+	}
+	return w.Flush()
+}
+
+// rebootTime prints the safe time to assume the given node rebooted, as calculated by the operator
+func (s *snrctl) rebootTime(ctx context.Context, args []string) error {
+	config, err := s.getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	node := &v1.Node{}
+	if err := s.client.Get(ctx, client.ObjectKey{Name: args[0]}, node); err != nil {
+		return errors.Wrap(err, "failed to get node")
+	}
+
+	calculator := reboot.NewCalculator(s.client, logr.Discard())
+	calculator.SetConfig(config)
+	duration, err := calculator.GetRebootDuration(ctx, node)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "safe time to assume node %s rebooted: %s\n", node.Name, duration)
+	if timeout, err := utils.GetWatchdogTimeout(node); err != nil {
+		fmt.Fprintf(s.out, "the watchdog timeout of the node is unknown, the default of 60s was used\n")
+	} else {
+		fmt.Fprintf(s.out, "watchdog timeout: %s\n", timeout)
+	}
+	if specified := config.Spec.SafeTimeToAssumeNodeRebootedSeconds; specified != nil {
+		fmt.Fprintf(s.out, "specified safeTimeToAssumeNodeRebootedSeconds: %ds\n", *specified)
+	}
+	return nil
+}
+
+// agentState prints the internal state of the agent of the given node. The agent is queried with an admin
+// certificate of the configured certificates backend, on its pod IP by default, or on the given address, e.g. when
+// using kubectl port-forward.
+func (s *snrctl) agentState(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("agent-state", flag.ContinueOnError)
+	address := flags.String("address", "", "the address of the agent, e.g. localhost:30001 when using kubectl port-forward")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	config, err := s.getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if *address == "" {
+		if *address, err = s.getAgentAddress(ctx, config, args[0]); err != nil {
+			return err
+		}
+	}
+
+	clientCreds, err := certificates.GetClientCredentialsFromCerts(certificates.NewAdminCertReader(s.client, config.Namespace, config.Spec.PeerCertificates))
+	if err != nil {
+		return errors.Wrap(err, "failed to get client credentials")
+	}
+	phClient, err := peerhealth.NewClient(*address, dialTimeout, logr.Discard(), clientCreds)
+	if err != nil {
+		return errors.Wrapf(err, "failed to connect to agent at %s", *address)
+	}
+	defer phClient.Close()
+
+	resp, err := phClient.GetAgentState(ctx, &peerhealth.AgentStateRequest{})
+	if err != nil {
+		return errors.Wrap(err, "failed to get agent state")
+	}
+	state := &bytes.Buffer{}
+	if err := json.Indent(state, []byte(resp.GetState()), "", "  "); err != nil {
+		return errors.Wrap(err, "failed to format agent state")
+	}
+	fmt.Fprintln(s.out, state.String())
+	return nil
+}
+
+// getAgentAddress returns the address of the peer health server of the agent running on the given node
+func (s *snrctl) getAgentAddress(ctx context.Context, config *v1alpha1.SelfNodeRemediationConfig, nodeName string) (string, error) {
+	pods := &v1.PodList{}
+	agentLabels := client.MatchingLabels{"app.kubernetes.io/name": "self-node-remediation", "app.kubernetes.io/component": "agent"}
+	if err := s.client.List(ctx, pods, client.InNamespace(config.Namespace), agentLabels); err != nil {
+		return "", errors.Wrap(err, "failed to list agent pods")
+	}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != nodeName || pod.Status.PodIP == "" {
+			continue
+		}
+		port := config.Spec.HostPort
+		if port == 0 {
+			port = defaultHostPort
+		}
+		return fmt.Sprintf("%s:%d", pod.Status.PodIP, port), nil
+	}
+	return "", fmt.Errorf("no running agent found on node %s", nodeName)
+}
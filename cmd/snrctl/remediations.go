@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+	"github.com/medik8s/self-node-remediation/controllers"
+)
+
+// list prints the remediations of the namespace, or of all namespaces if none was given
+func (s *snrctl) list(ctx context.Context, _ []string) error {
+	snrs := &v1alpha1.SelfNodeRemediationList{}
+	if err := s.client.List(ctx, snrs, client.InNamespace(s.namespace)); err != nil {
+		return errors.Wrap(err, "failed to list remediations")
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(s.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tPHASE\tSTRATEGY\tDRY-RUN\tASSUMED-REBOOTED\tAGE")
+	for _, snr := range snrs.Items {
+		phase := "-"
+		if snr.Status.Phase != nil {
+			phase = *snr.Status.Phase
+		}
+		if controllers.IsRemediationLoopDetected(&snr) {
+			phase += " (loop detected)"
+		} else if _, cancelled := snr.Annotations[controllers.NhcTimeOutAnnotation]; cancelled {
+			phase += " (cancelled)"
+		}
+		strategy := snr.Spec.RemediationStrategy
+		if strategy == "" {
+			strategy = v1alpha1.DefaultRemediationStrategy
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\t%s\n", snr.Namespace, snr.Name, phase, strategy, snr.Spec.DryRun,
+			formatAssumedRebooted(snr.Status.TimeAssumedRebooted, now), formatDuration(now.Sub(snr.CreationTimestamp.Time)))
+	}
+	return w.Flush()
+}
+
+// create creates a remediation for the given node
+func (s *snrctl) create(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	strategy := flags.String("strategy", string(v1alpha1.DefaultRemediationStrategy), "the remediation strategy")
+	dryRun := flags.Bool("dry-run", false, "only report the planned remediation")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	namespace, err := s.getNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	// remediations are matched to nodes by name
+	snr := &v1alpha1.SelfNodeRemediation{
+		ObjectMeta: metav1.ObjectMeta{Name: args[0], Namespace: namespace},
+		Spec: v1alpha1.SelfNodeRemediationSpec{
+			RemediationStrategy: v1alpha1.RemediationStrategyType(*strategy),
+			DryRun:              *dryRun,
+		},
+	}
+	if err := s.client.Create(ctx, snr); err != nil {
+		return errors.Wrap(err, "failed to create remediation")
+	}
+	fmt.Fprintf(s.out, "remediation %s/%s created\n", snr.Namespace, snr.Name)
+	return nil
+}
+
+// cancel stops the given remediation, like NHC does when the remediation timed out
+func (s *snrctl) cancel(ctx context.Context, args []string) error {
+	return s.annotate(ctx, []string{args[0], fmt.Sprintf("%s=%s", controllers.NhcTimeOutAnnotation, time.Now().UTC().Format(time.RFC3339))})
+}
+
+// annotate sets or removes annotations of the given remediation
+func (s *snrctl) annotate(ctx context.Context, args []string) error {
+	namespace, err := s.getNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	snr := &v1alpha1.SelfNodeRemediation{}
+	if err := s.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: args[0]}, snr); err != nil {
+		return errors.Wrap(err, "failed to get remediation")
+	}
+
+	patch := client.MergeFrom(snr.DeepCopy())
+	if snr.Annotations == nil {
+		snr.Annotations = map[string]string{}
+	}
+	for _, annotation := range args[1:] {
+		if key, found := strings.CutSuffix(annotation, "-"); found && !strings.Contains(annotation, "=") {
+			delete(snr.Annotations, key)
+			continue
+		}
+		key, value, found := strings.Cut(annotation, "=")
+		if !found || key == "" {
+			return fmt.Errorf("invalid annotation %q, expected key=value or key-", annotation)
+		}
+		snr.Annotations[key] = value
+	}
+	if err := s.client.Patch(ctx, snr, patch); err != nil {
+		return errors.Wrap(err, "failed to annotate remediation")
+	}
+	fmt.Fprintf(s.out, "remediation %s/%s annotated\n", snr.Namespace, snr.Name)
+	return nil
+}
+
+// getNamespace returns the given namespace, or the namespace of the SelfNodeRemediationConfig
+func (s *snrctl) getNamespace(ctx context.Context) (string, error) {
+	if s.namespace != "" {
+		return s.namespace, nil
+	}
+	config, err := s.getConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	return config.Namespace, nil
+}
+
+// getConfig returns the SelfNodeRemediationConfig of the given namespace, or of any namespace if none was given
+func (s *snrctl) getConfig(ctx context.Context) (*v1alpha1.SelfNodeRemediationConfig, error) {
+	configs := &v1alpha1.SelfNodeRemediationConfigList{}
+	if err := s.client.List(ctx, configs, client.InNamespace(s.namespace)); err != nil {
+		return nil, errors.Wrap(err, "failed to list configs")
+	}
+	for i := range configs.Items {
+		if configs.Items[i].Name == v1alpha1.ConfigCRName {
+			return &configs.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%s not found, please specify the namespace of self node remediation", v1alpha1.ConfigCRName)
+}
+
+func formatAssumedRebooted(assumedRebooted *metav1.Time, now time.Time) string {
+	if assumedRebooted == nil {
+		return "-"
+	}
+	formatted := assumedRebooted.UTC().Format(time.RFC3339)
+	if remaining := assumedRebooted.Sub(now); remaining > 0 {
+		return fmt.Sprintf("%s (in %s)", formatted, formatDuration(remaining))
+	}
+	return fmt.Sprintf("%s (%s ago)", formatted, formatDuration(now.Sub(assumedRebooted.Time)))
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+	"github.com/medik8s/self-node-remediation/controllers"
+	"github.com/medik8s/self-node-remediation/pkg/utils"
+)
+
+var _ = Describe("snrctl", func() {
+
+	const namespace = "snr-ns"
+	var s *snrctl
+	var out *bytes.Buffer
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		config := &v1alpha1.SelfNodeRemediationConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: v1alpha1.ConfigCRName, Namespace: namespace},
+			Spec: v1alpha1.SelfNodeRemediationConfigSpec{
+				ApiCheckInterval:     &metav1.Duration{Duration: 15 * time.Second},
+				ApiServerTimeout:     &metav1.Duration{Duration: 5 * time.Second},
+				MaxApiErrorThreshold: 3,
+				PeerDialTimeout:      &metav1.Duration{Duration: 5 * time.Second},
+				PeerRequestTimeout:   &metav1.Duration{Duration: 5 * time.Second},
+			},
+		}
+		capableNode := &v1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name: "node-1",
+			Annotations: map[string]string{
+				utils.IsRebootCapableAnnotation:        "true",
+				utils.WatchdogTimeoutSecondsAnnotation: "60",
+				utils.WatchdogDeviceAnnotation:         "/dev/watchdog1",
+			},
+		}}
+		incapableNode := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}}
+		agentStatus := &v1alpha1.SelfNodeRemediationAgentStatus{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-2", Namespace: namespace},
+			Status:     v1alpha1.SelfNodeRemediationAgentStatusStatus{RebootCapable: false},
+		}
+		phase := "Fencing-Started"
+		snr := &v1alpha1.SelfNodeRemediation{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1", Namespace: namespace},
+			Spec:       v1alpha1.SelfNodeRemediationSpec{RemediationStrategy: v1alpha1.OutOfServiceTaintRemediationStrategy},
+			Status: v1alpha1.SelfNodeRemediationStatus{
+				Phase:               &phase,
+				TimeAssumedRebooted: &metav1.Time{Time: time.Now().Add(2 * time.Minute)},
+			},
+		}
+
+		out = &bytes.Buffer{}
+		s = &snrctl{
+			client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(config, capableNode, incapableNode, agentStatus, snr).Build(),
+			out:    out,
+		}
+	})
+
+	It("should list remediations", func() {
+		Expect(s.list(ctx, nil)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("PHASE"))
+		Expect(out.String()).To(MatchRegexp(`snr-ns\s+node-1\s+Fencing-Started\s+OutOfServiceTaint\s+false\s+\S+ \(in (1m59s|2m0s)\)`))
+	})
+
+	It("should show which nodes are reboot capable", func() {
+		Expect(s.nodes(ctx, nil)).To(Succeed())
+		Expect(out.String()).To(MatchRegexp(`node-1\s+true\s+1m0s\s+/dev/watchdog1\s+Unknown`))
+		Expect(out.String()).To(MatchRegexp(`node-2\s+false\s+-\s+-\s+Unknown\s+the agent status reports that the node isn't reboot capable`))
+	})
+
+	It("should compute the safe reboot time", func() {
+		Expect(s.rebootTime(ctx, []string{"node-1"})).To(Succeed())
+		// 3 * (15s + 5s) API checks + 30s peer checks + 60s watchdog timeout + 30s buffer
+		Expect(out.String()).To(ContainSubstring("safe time to assume node node-1 rebooted: 3m0s"))
+	})
+
+	It("should create a remediation in the namespace of the config", func() {
+		Expect(s.create(ctx, []string{"node-2", "--dry-run"})).To(Succeed())
+		snr := &v1alpha1.SelfNodeRemediation{}
+		Expect(s.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: "node-2"}, snr)).To(Succeed())
+		Expect(snr.Spec.DryRun).To(BeTrue())
+		Expect(snr.Spec.RemediationStrategy).To(Equal(v1alpha1.DefaultRemediationStrategy))
+	})
+
+	It("should cancel and annotate remediations", func() {
+		getAnnotations := func() map[string]string {
+			snr := &v1alpha1.SelfNodeRemediation{}
+			ExpectWithOffset(1, s.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: "node-1"}, snr)).To(Succeed())
+			return snr.Annotations
+		}
+
+		Expect(s.cancel(ctx, []string{"node-1"})).To(Succeed())
+		Expect(getAnnotations()).To(HaveKey(controllers.NhcTimeOutAnnotation))
+
+		Expect(s.annotate(ctx, []string{"node-1", "foo=bar", controllers.NhcTimeOutAnnotation + "-"})).To(Succeed())
+		Expect(getAnnotations()).To(Equal(map[string]string{"foo": "bar"}))
+
+		Expect(s.annotate(ctx, []string{"node-1", "=bar"})).To(MatchError(ContainSubstring("invalid annotation")))
+	})
+})
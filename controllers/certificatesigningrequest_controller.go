@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	v1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+	"github.com/medik8s/self-node-remediation/pkg/certificates"
+)
+
+const (
+	// csrPendingRequeueInterval is the time until a CertificateSigningRequest is checked again while its certificate is
+	// being issued
+	csrPendingRequeueInterval = 5 * time.Second
+
+	csrApprovedReason = "AgentOfNode"
+	csrDeniedReason   = "NotAgentOfNode"
+)
+
+// CertificateSigningRequestReconciler signs the CertificateSigningRequests of the agents with the configured peer
+// certificates backend. A request is only approved if it was created by the agent pod of the node it's for, so agents
+// can't get the certificates of other nodes.
+type CertificateSigningRequestReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Namespace string
+}
+
+//+kubebuilder:rbac:groups=certificates.k8s.io,resources=certificatesigningrequests,verbs=get;list;watch
+//+kubebuilder:rbac:groups=certificates.k8s.io,resources=certificatesigningrequests/approval;certificatesigningrequests/status,verbs=update
+//+kubebuilder:rbac:groups=certificates.k8s.io,resources=signers,resourceNames=self-node-remediation.medik8s.io/peer,verbs=approve;sign
+
+func (r *CertificateSigningRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Log.WithValues("certificatesigningrequest", req.Name)
+
+	csr := &certificatesv1.CertificateSigningRequest{}
+	if err := r.Get(ctx, req.NamespacedName, csr); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !certificates.IsPendingCSR(csr) {
+		return ctrl.Result{}, nil
+	}
+
+	config := &v1alpha1.SelfNodeRemediationConfig{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: r.Namespace, Name: v1alpha1.ConfigCRName}, config); err != nil {
+		logger.Error(err, "failed to get config")
+		return ctrl.Result{}, err
+	}
+	issuer := certificates.NewCertIssuer(r.Client, r.Log.WithName("CertIssuer"), r.Namespace, config.Spec.PeerCertificates)
+	if issuer == nil {
+		return ctrl.Result{}, r.deny(ctx, csr, "peer certificates are provided externally")
+	}
+
+	pod := &v1.Pod{}
+	if podName := certificates.RequestingPodName(csr); podName != "" {
+		if err := r.Get(ctx, client.ObjectKey{Namespace: r.Namespace, Name: podName}, pod); err != nil && !apiErrors.IsNotFound(err) {
+			logger.Error(err, "failed to get requesting pod", "pod", podName)
+			return ctrl.Result{}, err
+		}
+	}
+	request, nodeName, err := certificates.ValidateNodeCSR(csr, r.Namespace, pod)
+	if err != nil {
+		logger.Info("denying certificate signing request", "reason", err.Error())
+		return ctrl.Result{}, r.deny(ctx, csr, err.Error())
+	}
+
+	if !certificates.IsApprovedCSR(csr) {
+		csr.Status.Conditions = append(csr.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+			Type:           certificatesv1.CertificateApproved,
+			Status:         v1.ConditionTrue,
+			Reason:         csrApprovedReason,
+			Message:        "requested by the agent of node " + nodeName,
+			LastUpdateTime: metav1.Now(),
+		})
+		if err := r.SubResource("approval").Update(ctx, csr); err != nil {
+			logger.Error(err, "failed to approve certificate signing request")
+			return ctrl.Result{}, err
+		}
+	}
+
+	certPem, err := issuer.SignNodeCert(ctx, csr, request, nodeName)
+	if err != nil {
+		logger.Error(err, "failed to sign certificate signing request")
+		return ctrl.Result{}, err
+	}
+	if certPem == nil {
+		return ctrl.Result{RequeueAfter: csrPendingRequeueInterval}, nil
+	}
+	csr.Status.Certificate = certPem
+	if err := r.Status().Update(ctx, csr); err != nil {
+		logger.Error(err, "failed to update certificate of certificate signing request")
+		return ctrl.Result{}, err
+	}
+	logger.Info("signed certificate signing request", "node", nodeName)
+	return ctrl.Result{}, nil
+}
+
+func (r *CertificateSigningRequestReconciler) deny(ctx context.Context, csr *certificatesv1.CertificateSigningRequest, message string) error {
+	csr.Status.Conditions = append(csr.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+		Type:           certificatesv1.CertificateDenied,
+		Status:         v1.ConditionTrue,
+		Reason:         csrDeniedReason,
+		Message:        message,
+		LastUpdateTime: metav1.Now(),
+	})
+	return r.SubResource("approval").Update(ctx, csr)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CertificateSigningRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&certificatesv1.CertificateSigningRequest{}, builder.WithPredicates(predicate.NewPredicateFuncs(
+			func(obj client.Object) bool {
+				return obj.(*certificatesv1.CertificateSigningRequest).Spec.SignerName == certificates.SignerName
+			}),
+		)).
+		Complete(r)
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/medik8s/common/pkg/events"
+	"github.com/medik8s/common/pkg/nodes"
+
+	v1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+)
+
+const (
+	eventReasonWaitingForControlPlaneRemediation = "WaitingForControlPlaneRemediation"
+
+	// Reasons related to WaitingForControlPlaneRemediationConditionType
+	controlPlaneRemediationInProgress conditionReason = "ControlPlaneRemediationInProgress"
+	controlPlaneRemediationAllowed    conditionReason = "ControlPlaneRemediationAllowed"
+
+	controlPlaneRemediationRequeueInterval = 10 * time.Second
+)
+
+// getBlockingControlPlaneRemediation returns the name of the control plane node whose remediation needs to complete
+// before the given control plane SNR may start fencing, or an empty string if the given SNR can start.
+// Only one control plane node is fenced at a time, and the next one is fenced only after the previous one is Ready again.
+// SNRs which didn't start fencing yet take their turn by creation time.
+func (r *SelfNodeRemediationReconciler) getBlockingControlPlaneRemediation(ctx context.Context, snr *v1alpha1.SelfNodeRemediation) (string, error) {
+	snrs := &v1alpha1.SelfNodeRemediationList{}
+	if err := r.List(ctx, snrs); err != nil {
+		r.logger.Error(err, "failed to list SNRs")
+		return "", err
+	}
+
+	for i := range snrs.Items {
+		other := &snrs.Items[i]
+		if other.UID == snr.UID || other.Spec.DryRun || r.isStoppedByNHC(other) {
+			continue
+		}
+
+		node, err := r.getNodeFromSnr(ctx, other)
+		if err != nil {
+			if !apiErrors.IsNotFound(err) {
+				r.logger.Error(err, "failed to get node of SNR, ignoring it for control plane remediation ordering", "snr", other.Name)
+			}
+			continue
+		}
+		if !nodes.IsControlPlane(node) || node.Labels[excludeRemediationLabel] == "true" {
+			continue
+		}
+
+		if controllerutil.ContainsFinalizer(other, SNRFinalizer) {
+			// the other remediation started fencing
+			if r.getPhase(other) != fencingCompletedPhase || !isNodeReady(node) {
+				return node.Name, nil
+			}
+			continue
+		}
+
+		if other.DeletionTimestamp == nil && isCreatedBefore(other, snr) {
+			// the other remediation waits as well, and it's its turn first
+			return node.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// waitForControlPlaneRemediationTurn returns true if the remediation of the given control plane node needs to wait,
+// and updates the SNR's WaitingForControlPlaneRemediation condition accordingly.
+func (r *SelfNodeRemediationReconciler) waitForControlPlaneRemediationTurn(ctx context.Context, snr *v1alpha1.SelfNodeRemediation, node *v1.Node) (bool, error) {
+	if !nodes.IsControlPlane(node) || controllerutil.ContainsFinalizer(snr, SNRFinalizer) || r.getPhase(snr) != fencingStartedPhase {
+		// not a control plane node, or this remediation already started fencing
+		return false, nil
+	}
+
+	blockingNodeName, err := r.getBlockingControlPlaneRemediation(ctx, snr)
+	if err != nil {
+		return false, err
+	}
+
+	if blockingNodeName == "" {
+		if meta.IsStatusConditionTrue(snr.Status.Conditions, string(v1alpha1.WaitingForControlPlaneRemediationConditionType)) {
+			meta.SetStatusCondition(&snr.Status.Conditions, metav1.Condition{
+				Type:    string(v1alpha1.WaitingForControlPlaneRemediationConditionType),
+				Status:  metav1.ConditionFalse,
+				Reason:  string(controlPlaneRemediationAllowed),
+				Message: "No other control plane node is being remediated",
+			})
+		}
+		return false, nil
+	}
+
+	message := fmt.Sprintf("Waiting for the remediation of control plane node %s to complete and for that node to be Ready", blockingNodeName)
+	r.logger.Info("control plane remediation postponed", "node name", node.Name, "blocking node name", blockingNodeName)
+	if !meta.IsStatusConditionPresentAndEqual(snr.Status.Conditions, string(v1alpha1.WaitingForControlPlaneRemediationConditionType), metav1.ConditionTrue) {
+		events.NormalEvent(r.Recorder, snr, eventReasonWaitingForControlPlaneRemediation, message)
+	}
+	meta.SetStatusCondition(&snr.Status.Conditions, metav1.Condition{
+		Type:    string(v1alpha1.WaitingForControlPlaneRemediationConditionType),
+		Status:  metav1.ConditionTrue,
+		Reason:  string(controlPlaneRemediationInProgress),
+		Message: message,
+	})
+	return true, nil
+}
+
+// IsWaitingForControlPlaneRemediation returns true if the given SNR doesn't fence its node yet, because another control
+// plane node is remediated first
+func IsWaitingForControlPlaneRemediation(snr *v1alpha1.SelfNodeRemediation) bool {
+	return meta.IsStatusConditionTrue(snr.Status.Conditions, string(v1alpha1.WaitingForControlPlaneRemediationConditionType))
+}
+
+func isNodeReady(node *v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// isCreatedBefore orders SNRs by creation time, and by name for SNRs created within the same second
+func isCreatedBefore(snr, other client.Object) bool {
+	snrCreation, otherCreation := snr.GetCreationTimestamp(), other.GetCreationTimestamp()
+	if !snrCreation.Equal(&otherCreation) {
+		return snrCreation.Before(&otherCreation)
+	}
+	return snr.GetName() < other.GetName()
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+)
+
+const (
+	// names of the preconditions checked by dry run remediations
+	nodeExistsPrecondition           = "NodeExists"
+	notExcludedPrecondition          = "NotExcludedFromRemediation"
+	noRemediationLoopPrecondition    = "NoRemediationLoop"
+	agentPodExistsPrecondition       = "AgentPodExists"
+	rebootCapablePrecondition        = "RebootCapable"
+	safeRebootDurationPrecondition   = "SafeTimeToAssumeNodeRebooted"
+	dryRunRemediationRecheckInterval = time.Minute
+)
+
+// dryRunRemediation checks the preconditions of the remediation of the given dry run SNR, and reports them together
+// with the planned remediation in the SNR's status. It never changes the node or the SNR's phase, so the agents ignore
+// the SNR. The preconditions are checked periodically, because they might change, e.g. when the agent pod is deleted.
+func (r *SelfNodeRemediationReconciler) dryRunRemediation(ctx context.Context, snr *v1alpha1.SelfNodeRemediation) (ctrl.Result, error) {
+	result := &v1alpha1.DryRunResult{
+		Strategy: r.getRuntimeStrategy(snr),
+	}
+	snr.Status.DryRunResult = result
+
+	addPrecondition := func(name string, err error, passedMessage string) bool {
+		precondition := v1alpha1.DryRunPrecondition{Name: name, Passed: err == nil, Message: passedMessage}
+		if err != nil {
+			precondition.Message = err.Error()
+		}
+		result.Preconditions = append(result.Preconditions, precondition)
+		return precondition.Passed
+	}
+
+	node, err := r.getNodeFromSnr(ctx, snr)
+	if !addPrecondition(nodeExistsPrecondition, err, "The node exists") {
+		r.logger.Info("dry run remediation, node not found", "error", err)
+		return ctrl.Result{RequeueAfter: dryRunRemediationRecheckInterval}, nil
+	}
+
+	var excludedErr error
+	if node.Labels[excludeRemediationLabel] == "true" {
+		excludedErr = fmt.Errorf("the node has the %s label", excludeRemediationLabel)
+	}
+	remediable := addPrecondition(notExcludedPrecondition, excludedErr, "The node isn't excluded from remediation")
+
+	loopErr := r.checkNoRemediationLoop(ctx, snr, node)
+	remediable = addPrecondition(noRemediationLoopPrecondition, loopErr, "The node wasn't remediated too often recently") && remediable
+
+	remediable = addPrecondition(agentPodExistsPrecondition, r.checkAgentPodExists(node), "The self node remediation agent runs on the node") && remediable
+	remediable = addPrecondition(rebootCapablePrecondition, r.checkRebootCapable(ctx, node), "The node is reboot capable") && remediable
+
+	rebootDuration, err := r.RebootDurationCalculator.GetRebootDuration(ctx, node)
+	remediable = addPrecondition(safeRebootDurationPrecondition, err, fmt.Sprintf("The node is assumed to be rebooted after %s", rebootDuration)) && remediable
+
+	result.Remediable = remediable
+	if err == nil {
+		result.Timeline = r.planRemediation(node, result.Strategy, rebootDuration)
+	}
+	r.logger.Info("dry run remediation checked", "node name", node.Name, "remediable", remediable)
+	return ctrl.Result{RequeueAfter: dryRunRemediationRecheckInterval}, nil
+}
+
+// checkNoRemediationLoop returns an error if the remediation loop protection would stop the remediation of the node
+func (r *SelfNodeRemediationReconciler) checkNoRemediationLoop(ctx context.Context, snr *v1alpha1.SelfNodeRemediation, node *v1.Node) error {
+	maxRemediations, window, err := r.getRemediationLoopProtection(ctx)
+	if err != nil || maxRemediations == 0 {
+		return err
+	}
+	if recentRemediations := r.countRecentRemediations(node, snr, window); recentRemediations >= maxRemediations {
+		return fmt.Errorf("the node was remediated %d times within %s", recentRemediations, window)
+	}
+	return nil
+}
+
+// planRemediation returns the steps the remediation of the given node would take
+func (r *SelfNodeRemediationReconciler) planRemediation(node *v1.Node, strategy v1alpha1.RemediationStrategyType, rebootDuration time.Duration) []v1alpha1.PlannedRemediationStep {
+	step := func(after time.Duration, description string) v1alpha1.PlannedRemediationStep {
+		return v1alpha1.PlannedRemediationStep{After: metav1.Duration{Duration: after}, Description: description}
+	}
+
+	timeline := []v1alpha1.PlannedRemediationStep{
+		step(0, fmt.Sprintf("Add the %s NoExecute taint to node %s, and mark it unschedulable", NodeNoExecuteTaint.Key, node.Name)),
+		step(0, "The self node remediation agent on the node reboots it"),
+	}
+	switch strategy {
+	case v1alpha1.OutOfServiceTaintRemediationStrategy:
+		timeline = append(timeline, step(rebootDuration, fmt.Sprintf("Assume the node was rebooted, and add the %s taint until its workloads were deleted", OutOfServiceTaint.Key)))
+	default:
+		timeline = append(timeline, step(rebootDuration, "Assume the node was rebooted, and delete its pods and volume attachments"))
+	}
+	return append(timeline, step(rebootDuration, "When the node is healthy again, mark it schedulable and remove the NoExecute taint"))
+}
@@ -15,6 +15,83 @@ import (
 	"github.com/medik8s/self-node-remediation/api/v1alpha1"
 )
 
+const (
+	// SNRTargetIndex indexes SelfNodeRemediations by the nodes and machines they target
+	SNRTargetIndex = "snrTarget"
+	// machineOwnedTarget is indexed for SNRs which are owned by a Machine, and not by NHC. Their node is only known by
+	// getting the Machine.
+	machineOwnedTarget = "machine-owned"
+)
+
+// IndexSNRTargets adds the SNRTargetIndex to the given indexer
+func IndexSNRTargets(ctx context.Context, indexer client.FieldIndexer) error {
+	return indexer.IndexField(ctx, &v1alpha1.SelfNodeRemediation{}, SNRTargetIndex, getSNRTargets)
+}
+
+func getSNRTargets(obj client.Object) []string {
+	snr, ok := obj.(*v1alpha1.SelfNodeRemediation)
+	if !ok {
+		return nil
+	}
+	var targets []string
+	isOwnedByMachine, ref := isOwnedByMachine(snr)
+	if isOwnedByMachine {
+		targets = append(targets, machineTarget(ref.Name))
+	}
+	// like getNodeName
+	if ownedByNHC, _ := isOwnedByNHC(snr); ownedByNHC || !isOwnedByMachine {
+		targets = append(targets, nodeTarget(getNodeNameDirect(snr)))
+	} else {
+		targets = append(targets, machineOwnedTarget)
+	}
+	return targets
+}
+
+func nodeTarget(nodeName string) string {
+	return "node/" + nodeName
+}
+
+func machineTarget(machineName string) string {
+	return "machine/" + machineName
+}
+
+// GetMatchingSNRs returns the SNRs which match the given node or machine name, like IsSNRMatching. The candidates are
+// looked up with the SNRTargetIndex, so the given client needs to be backed by a cache with that index.
+// When the machine name is empty, SNRs owned by a Machine are matched by the node of their Machine.
+func GetMatchingSNRs(ctx context.Context, c client.Client, nodeName string, machineName string, log logr.Logger) ([]v1alpha1.SelfNodeRemediation, error) {
+	targets := []string{nodeTarget(nodeName)}
+	if machineName != "" {
+		targets = append(targets, machineTarget(machineName))
+	} else {
+		targets = append(targets, machineOwnedTarget)
+	}
+
+	var matching []v1alpha1.SelfNodeRemediation
+	found := map[client.ObjectKey]bool{}
+	for _, target := range targets {
+		snrs := &v1alpha1.SelfNodeRemediationList{}
+		if err := c.List(ctx, snrs, client.MatchingFields{SNRTargetIndex: target}); err != nil {
+			return nil, err
+		}
+		for i := range snrs.Items {
+			snr := &snrs.Items[i]
+			if found[client.ObjectKeyFromObject(snr)] {
+				continue
+			}
+			snrMatches, _, err := IsSNRMatching(ctx, c, snr, nodeName, machineName, log)
+			if err != nil {
+				log.Error(err, "failed to check if SNR matches node", "snr", snr.Name)
+				continue
+			}
+			if snrMatches {
+				found[client.ObjectKeyFromObject(snr)] = true
+				matching = append(matching, *snr)
+			}
+		}
+	}
+	return matching, nil
+}
+
 // IsSNRMatching checks if the SNR CR is matching the node or machine name,
 // and additionally returns the node name for the SNR in case machineName is empty
 func IsSNRMatching(ctx context.Context, c client.Client, snr *v1alpha1.SelfNodeRemediation, nodeName string, machineName string, log logr.Logger) (bool, string, error) {
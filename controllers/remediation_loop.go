@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/medik8s/common/pkg/events"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+	"github.com/medik8s/self-node-remediation/pkg/utils"
+)
+
+const (
+	eventReasonRemediationLoopDetected = "RemediationLoopDetected"
+
+	// Reasons related to RemediationLoopDetectedConditionType
+	remediationLimitExceeded conditionReason = "RemediationLimitExceeded"
+
+	defaultMaxRemediations       = 3
+	defaultRemediationLoopWindow = time.Hour
+)
+
+// remediationRecord is an entry of the remediation history of a node
+type remediationRecord struct {
+	// Remediation is the UID of the SNR which started fencing the node
+	Remediation types.UID `json:"remediation"`
+	// Time is when the SNR started fencing the node
+	Time metav1.Time `json:"time"`
+}
+
+// IsRemediationLoopDetected returns true if the given SNR won't reboot its node, because the node was remediated too
+// often within the configured window
+func IsRemediationLoopDetected(snr *v1alpha1.SelfNodeRemediation) bool {
+	return meta.IsStatusConditionTrue(snr.Status.Conditions, string(v1alpha1.RemediationLoopDetectedConditionType))
+}
+
+// getRemediationLoopProtection returns the max number of remediations of a node within the returned window,
+// or 0 if the remediation loop protection is disabled
+func (r *SelfNodeRemediationReconciler) getRemediationLoopProtection(ctx context.Context) (int, time.Duration, error) {
+	snrConfig, err := r.getConfiguration(ctx)
+	if err != nil || snrConfig == nil || snrConfig.Spec.RemediationLoopProtection == nil {
+		return 0, 0, err
+	}
+
+	loopProtection := snrConfig.Spec.RemediationLoopProtection
+	maxRemediations, window := loopProtection.MaxRemediations, defaultRemediationLoopWindow
+	if maxRemediations <= 0 {
+		maxRemediations = defaultMaxRemediations
+	}
+	if loopProtection.Window != nil && loopProtection.Window.Duration > 0 {
+		window = loopProtection.Window.Duration
+	}
+	return maxRemediations, window, nil
+}
+
+// getRemediationHistory returns the recent remediations of the given node. An invalid history is ignored.
+func (r *SelfNodeRemediationReconciler) getRemediationHistory(node *v1.Node) []remediationRecord {
+	value, exists := node.Annotations[utils.RemediationHistoryAnnotation]
+	if !exists {
+		return nil
+	}
+	var history []remediationRecord
+	if err := json.Unmarshal([]byte(value), &history); err != nil {
+		r.logger.Error(err, "failed to parse node's remediation history, ignoring it", "node name", node.Name, "annotation value", value)
+		return nil
+	}
+	return history
+}
+
+// recordRemediation adds the given SNR to the remediation history of its node, and drops remediations which are
+// out of the window. It's a no-op when the remediation loop protection is disabled.
+func (r *SelfNodeRemediationReconciler) recordRemediation(ctx context.Context, node *v1.Node, snr *v1alpha1.SelfNodeRemediation) error {
+	if snr.DeletionTimestamp != nil {
+		// the remediation won't start
+		return nil
+	}
+
+	maxRemediations, window, err := r.getRemediationLoopProtection(ctx)
+	if err != nil || maxRemediations == 0 {
+		return err
+	}
+
+	history := []remediationRecord{}
+	for _, record := range r.getRemediationHistory(node) {
+		if record.Remediation == snr.UID {
+			// already recorded
+			return nil
+		}
+		if time.Since(record.Time.Time) < window {
+			history = append(history, record)
+		}
+	}
+	history = append(history, remediationRecord{Remediation: snr.UID, Time: metav1.Now()})
+
+	value, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	patch := client.MergeFrom(node.DeepCopy())
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[utils.RemediationHistoryAnnotation] = string(value)
+	if err := r.Client.Patch(ctx, node, patch); err != nil {
+		r.logger.Error(err, "failed to update node's remediation history", "node name", node.Name)
+		return err
+	}
+	return nil
+}
+
+// detectRemediationLoop returns true if the given SNR must not reboot its node, because the node was remediated
+// MaxRemediations times within the window already. In that case the SNR's conditions are updated, so that
+// NodeHealthCheck can escalate to another remediator.
+func (r *SelfNodeRemediationReconciler) detectRemediationLoop(ctx context.Context, snr *v1alpha1.SelfNodeRemediation, node *v1.Node) (bool, error) {
+	if IsRemediationLoopDetected(snr) {
+		return true, nil
+	}
+	if controllerutil.ContainsFinalizer(snr, SNRFinalizer) || r.getPhase(snr) != fencingStartedPhase {
+		// this remediation already started fencing
+		return false, nil
+	}
+
+	maxRemediations, window, err := r.getRemediationLoopProtection(ctx)
+	if err != nil || maxRemediations == 0 {
+		return false, err
+	}
+
+	recentRemediations := r.countRecentRemediations(node, snr, window)
+	if recentRemediations < maxRemediations {
+		return false, nil
+	}
+
+	message := fmt.Sprintf("Node %s was remediated %d times within %s, it won't be rebooted again and stays cordoned. Uncordon it manually after fixing it", node.Name, recentRemediations, window)
+	r.logger.Info("remediation loop detected, skipping remediation", "node name", node.Name, "remediations", recentRemediations, "window", window)
+	events.WarningEvent(r.Recorder, snr, eventReasonRemediationLoopDetected, message)
+	meta.SetStatusCondition(&snr.Status.Conditions, metav1.Condition{
+		Type:    string(v1alpha1.RemediationLoopDetectedConditionType),
+		Status:  metav1.ConditionTrue,
+		Reason:  string(remediationLimitExceeded),
+		Message: message,
+	})
+	return true, r.updateConditions(remediationSkippedLoopDetected, snr)
+}
+
+// countRecentRemediations returns the number of remediations of the given node within the window, other than the given SNR
+func (r *SelfNodeRemediationReconciler) countRecentRemediations(node *v1.Node, snr *v1alpha1.SelfNodeRemediation, window time.Duration) int {
+	recentRemediations := 0
+	for _, record := range r.getRemediationHistory(node) {
+		if record.Remediation != snr.UID && time.Since(record.Time.Time) < window {
+			recentRemediations++
+		}
+	}
+	return recentRemediations
+}
+
+// keepNodeCordoned makes sure that a node which won't be rebooted anymore doesn't take new workloads
+func (r *SelfNodeRemediationReconciler) keepNodeCordoned(node *v1.Node) (ctrl.Result, error) {
+	if node.Spec.Unschedulable {
+		return ctrl.Result{}, nil
+	}
+	return r.markNodeAsUnschedulable(node)
+}
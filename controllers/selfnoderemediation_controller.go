@@ -44,8 +44,9 @@ import (
 )
 
 const (
-	SNRFinalizer            = "self-node-remediation.medik8s.io/snr-finalizer"
-	nhcTimeOutAnnotation    = "remediation.medik8s.io/nhc-timed-out"
+	SNRFinalizer = "self-node-remediation.medik8s.io/snr-finalizer"
+	// NhcTimeOutAnnotation is set by NHC when a remediation timed out, it stops the remediation
+	NhcTimeOutAnnotation    = "remediation.medik8s.io/nhc-timed-out"
 	excludeRemediationLabel = "remediation.medik8s.io/exclude-from-remediation"
 
 	eventReasonRemediationSkipped = "RemediationSkipped"
@@ -62,6 +63,7 @@ const (
 	eventReasonRemoveNoExecute           = "RemoveNoExecuteTaint"
 	eventReasonRemoveOutOfService        = "RemoveOutOfService"
 	eventReasonNodeReboot                = "NodeReboot"
+	eventReasonDryRunRemediationHeld     = "DryRunRemediationHeld"
 )
 
 var (
@@ -91,6 +93,7 @@ const (
 	remediationTimeoutByNHC         conditionReason = "RemediationTimeoutByNHC"
 	remediationFinishedSuccessfully conditionReason = "RemediationFinishedSuccessfully"
 	remediationSkippedNodeNotFound  conditionReason = "RemediationSkippedNodeNotFound"
+	remediationSkippedLoopDetected  conditionReason = "RemediationSkippedLoopDetected"
 
 	// Other Reasons
 	snrDisabledNoConfig conditionReason = "ConfigurationNotFound"
@@ -144,7 +147,10 @@ func (r *SelfNodeRemediationReconciler) SetupWithManager(mgr ctrl.Manager) error
 //+kubebuilder:rbac:groups=self-node-remediation.medik8s.io,resources=selfnoderemediations,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=self-node-remediation.medik8s.io,resources=selfnoderemediations/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=self-node-remediation.medik8s.io,resources=selfnoderemediations/finalizers,verbs=update
+//+kubebuilder:rbac:groups=self-node-remediation.medik8s.io,resources=selfnoderemediationagentstatuses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=self-node-remediation.medik8s.io,resources=selfnoderemediationagentstatuses/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=nodes/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=machine.openshift.io,resources=machines,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=namespaces,verbs=list;get;watch
 
@@ -240,6 +246,10 @@ func (r *SelfNodeRemediationReconciler) ReconcileManager(ctx context.Context, re
 		return ctrl.Result{}, nil
 	}
 
+	if snr.Spec.DryRun {
+		return r.dryRunRemediation(ctx, snr)
+	}
+
 	if r.isStoppedByNHC(snr) {
 		//This remediation is no longer relevant, most likely because fixed by a different remediator.
 		if snr.GetDeletionTimestamp() != nil {
@@ -252,7 +262,7 @@ func (r *SelfNodeRemediationReconciler) ReconcileManager(ctx context.Context, re
 		return ctrl.Result{}, r.updateConditions(remediationTimeoutByNHC, snr)
 	}
 
-	if r.getPhase(snr) != fencingCompletedPhase {
+	if r.getPhase(snr) != fencingCompletedPhase && !IsRemediationLoopDetected(snr) {
 		if err := r.updateConditions(remediationStarted, snr); err != nil {
 			return ctrl.Result{}, err
 		}
@@ -281,6 +291,18 @@ func (r *SelfNodeRemediationReconciler) ReconcileManager(ctx context.Context, re
 		return ctrl.Result{}, nil
 	}
 
+	if isLoop, err := r.detectRemediationLoop(ctx, snr, node); err != nil {
+		return ctrl.Result{}, r.updateSnrStatusLastError(snr, err)
+	} else if isLoop {
+		return r.keepNodeCordoned(node)
+	}
+
+	if isWaiting, err := r.waitForControlPlaneRemediationTurn(ctx, snr, node); err != nil {
+		return ctrl.Result{}, r.updateSnrStatusLastError(snr, err)
+	} else if isWaiting {
+		return ctrl.Result{RequeueAfter: controlPlaneRemediationRequeueInterval}, nil
+	}
+
 	// used as an indication not to spam the event
 	if isFinalizerAlreadyAdded := controllerutil.ContainsFinalizer(snr, SNRFinalizer); !isFinalizerAlreadyAdded {
 		eventMessage := "Remediation started by SNR manager"
@@ -303,25 +325,31 @@ func (r *SelfNodeRemediationReconciler) ReconcileManager(ctx context.Context, re
 }
 
 func (r *SelfNodeRemediationReconciler) isConfigurationExist(ctx context.Context) (bool, error) {
-	if ns, err := utils.GetDeploymentNamespace(); err != nil {
+	snrConfig, err := r.getConfiguration(ctx)
+	return snrConfig != nil, err
+}
+
+// getConfiguration returns the SelfNodeRemediationConfig, or nil if it doesn't exist or is being deleted
+func (r *SelfNodeRemediationReconciler) getConfiguration(ctx context.Context) (*v1alpha1.SelfNodeRemediationConfig, error) {
+	ns, err := utils.GetDeploymentNamespace()
+	if err != nil {
 		r.logger.Error(err, "Failed getting snr namespace")
-		return false, err
-	} else {
-		snrConfig := &v1alpha1.SelfNodeRemediationConfig{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      v1alpha1.ConfigCRName,
-				Namespace: ns,
-			},
-		}
-		err := r.Get(ctx, client.ObjectKeyFromObject(snrConfig), snrConfig)
-		if apiErrors.IsNotFound(err) || err == nil && snrConfig.DeletionTimestamp != nil {
-			return false, nil
-		} else if err != nil {
-			r.logger.Error(err, "failed to get SNR configuration")
-			return false, err
-		}
+		return nil, err
 	}
-	return true, nil
+	snrConfig := &v1alpha1.SelfNodeRemediationConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      v1alpha1.ConfigCRName,
+			Namespace: ns,
+		},
+	}
+	err = r.Get(ctx, client.ObjectKeyFromObject(snrConfig), snrConfig)
+	if apiErrors.IsNotFound(err) || err == nil && snrConfig.DeletionTimestamp != nil {
+		return nil, nil
+	} else if err != nil {
+		r.logger.Error(err, "failed to get SNR configuration")
+		return nil, err
+	}
+	return snrConfig, nil
 }
 
 func (r *SelfNodeRemediationReconciler) updateConditions(processingTypeReason conditionReason, snr *v1alpha1.SelfNodeRemediation) error {
@@ -339,6 +367,9 @@ func (r *SelfNodeRemediationReconciler) updateConditions(processingTypeReason co
 	case remediationSkippedNodeNotFound:
 		processingConditionStatus = metav1.ConditionFalse
 		succeededConditionStatus = metav1.ConditionFalse
+	case remediationSkippedLoopDetected:
+		processingConditionStatus = metav1.ConditionFalse
+		succeededConditionStatus = metav1.ConditionFalse
 	default:
 		err := fmt.Errorf("unknown condition reason:%s", processingTypeReason)
 		r.logger.Error(err, "couldn't update snr processing condition")
@@ -437,7 +468,7 @@ func (r *SelfNodeRemediationReconciler) remediateWithResourceRemoval(ctx context
 	case fencingStartedPhase:
 		result, err = r.handleFencingStartedPhase(ctx, node, snr)
 	case preRebootCompletedPhase:
-		result, err = r.handlePreRebootCompletedPhase(node, snr)
+		result, err = r.handlePreRebootCompletedPhase(ctx, node, snr)
 	case rebootCompletedPhase:
 		result, err = r.handleRebootCompletedPhase(node, snr, rmNodeResources)
 	case fencingCompletedPhase:
@@ -456,12 +487,15 @@ func (r *SelfNodeRemediationReconciler) handleFencingStartedPhase(ctx context.Co
 
 func (r *SelfNodeRemediationReconciler) prepareReboot(ctx context.Context, node *v1.Node, snr *v1alpha1.SelfNodeRemediation) (ctrl.Result, error) {
 	r.logger.Info("pre-reboot not completed yet, prepare for rebooting")
-	if !r.isNodeRebootCapable(node) {
+	if !r.isNodeRebootCapable(ctx, node) {
 		// use err to trigger exponential backoff
 		return ctrl.Result{}, errors.New("Node is not capable to reboot itself")
 	}
 
 	if !controllerutil.ContainsFinalizer(snr, SNRFinalizer) {
+		if err := r.recordRemediation(ctx, node, snr); err != nil {
+			return ctrl.Result{}, err
+		}
 		return r.addFinalizer(snr)
 	}
 
@@ -483,10 +517,33 @@ func (r *SelfNodeRemediationReconciler) prepareReboot(ctx context.Context, node
 	return ctrl.Result{}, nil
 }
 
-func (r *SelfNodeRemediationReconciler) handlePreRebootCompletedPhase(node *v1.Node, snr *v1alpha1.SelfNodeRemediation) (ctrl.Result, error) {
+func (r *SelfNodeRemediationReconciler) handlePreRebootCompletedPhase(ctx context.Context, node *v1.Node, snr *v1alpha1.SelfNodeRemediation) (ctrl.Result, error) {
+	snrConfig, err := r.getConfiguration(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if snrConfig != nil && snrConfig.Spec.DryRun {
+		return r.holdDryRunRemediation(node, snr)
+	}
 	return r.waitForNodeRebooted(node, snr)
 }
 
+// holdDryRunRemediation doesn't let the remediation proceed when the agents run in dry run mode, because the node
+// wasn't rebooted and its workloads might still be running. The node is recovered when the SNR is deleted.
+func (r *SelfNodeRemediationReconciler) holdDryRunRemediation(node *v1.Node, snr *v1alpha1.SelfNodeRemediation) (ctrl.Result, error) {
+	if snr.DeletionTimestamp != nil {
+		return r.recoverNode(node, snr)
+	}
+
+	if wasRebooted, timeLeft := r.wasNodeRebooted(snr); !wasRebooted {
+		return ctrl.Result{RequeueAfter: timeLeft}, nil
+	}
+
+	r.logger.Info("dry run mode, not assuming the node was rebooted", "node name", node.Name)
+	events.NormalEvent(r.Recorder, snr, eventReasonDryRunRemediationHeld, "Remediation process - dry run mode, the node wasn't rebooted and its resources won't be deleted")
+	return ctrl.Result{}, nil
+}
+
 func (r *SelfNodeRemediationReconciler) waitForNodeRebooted(node *v1.Node, snr *v1alpha1.SelfNodeRemediation) (ctrl.Result, error) {
 	wasRebooted, timeLeft := r.wasNodeRebooted(snr)
 	if !wasRebooted {
@@ -604,30 +661,67 @@ func (r *SelfNodeRemediationReconciler) didIRebootMyself(snr *v1alpha1.SelfNodeR
 }
 
 // isNodeRebootCapable checks if the node is capable to reboot itself when it becomes unhealthy
-// this boils down to check if it has an assigned self node remediation pod, and the reboot-capable annotation
-func (r *SelfNodeRemediationReconciler) isNodeRebootCapable(node *v1.Node) bool {
+// this boils down to check if it has an assigned self node remediation pod, which reported that it can reboot the node
+func (r *SelfNodeRemediationReconciler) isNodeRebootCapable(ctx context.Context, node *v1.Node) bool {
 	//make sure that the unhealthy node has self node remediation pod on it which can reboot it
-	if _, err := utils.GetSelfNodeRemediationAgentPod(node.Name, r.Client); err != nil {
+	if err := r.checkAgentPodExists(node); err != nil {
 		r.logger.Error(err, "failed to get self node remediation agent pod resource")
 		return false
 	}
 
-	// if the unhealthy node has the self node remediation agent pod, but the is-reboot-capable annotation is unknown/false/doesn't exist
+	// if the unhealthy node has the self node remediation agent pod, but the agent didn't report that it can reboot the node,
 	// the node might not reboot, and we might end up in deleting a running node
-	if node.Annotations == nil || node.Annotations[utils.IsRebootCapableAnnotation] != "true" {
-		annVal := ""
-		if node.Annotations != nil {
-			annVal = node.Annotations[utils.IsRebootCapableAnnotation]
-		}
-
-		r.logger.Error(errors.New("node's isRebootCapable annotation is not `true`, which means the node might not reboot when we'll delete the node. Skipping remediation"),
-			"", "annotation value", annVal)
+	if err := r.checkRebootCapable(ctx, node); err != nil {
+		r.logger.Error(err, "node isn't reboot capable", "node name", node.Name)
 		return false
 	}
 
 	return true
 }
 
+func (r *SelfNodeRemediationReconciler) checkAgentPodExists(node *v1.Node) error {
+	_, err := utils.GetSelfNodeRemediationAgentPod(node.Name, r.Client)
+	return err
+}
+
+// checkRebootCapable returns an error if the agent status of the node reports that the agent can't reboot the node, or
+// that its watchdog self-test failed. Agents which didn't report their status yet are checked by the reboot capable
+// annotation. An outdated agent status is fine, because agents of unhealthy nodes often can't update it.
+func (r *SelfNodeRemediationReconciler) checkRebootCapable(ctx context.Context, node *v1.Node) error {
+	return CheckRebootCapable(ctx, r.Client, r.MyNamespace, node)
+}
+
+// CheckRebootCapable returns an error if the node isn't able to reboot, as reported by the agent status in the given
+// namespace, or by the node annotation if the agent didn't report its status yet
+func CheckRebootCapable(ctx context.Context, reader client.Reader, namespace string, node *v1.Node) error {
+	agentStatus := &v1alpha1.SelfNodeRemediationAgentStatus{}
+	if err := reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: node.Name}, agentStatus); err != nil {
+		if apiErrors.IsNotFound(err) {
+			return checkRebootCapableAnnotation(node)
+		}
+		return err
+	}
+
+	if !agentStatus.Status.RebootCapable {
+		return errors.New("the agent status reports that the node isn't reboot capable, which means the node might not reboot when we'll delete the node. Skipping remediation")
+	}
+	if selfTest := agentStatus.Status.SelfTest; selfTest != nil {
+		for _, check := range selfTest.Checks {
+			if check.Name == v1alpha1.WatchdogSelfTestCheck && !check.Passed {
+				return fmt.Errorf("the watchdog self-test of the agent failed: %s. Skipping remediation", check.Message)
+			}
+		}
+	}
+	return nil
+}
+
+func checkRebootCapableAnnotation(node *v1.Node) error {
+	if node.Annotations == nil || node.Annotations[utils.IsRebootCapableAnnotation] != "true" {
+		return errors.New("node's isRebootCapable annotation is not `true`, which means the node might not reboot when we'll delete the node. Skipping remediation")
+	}
+	return nil
+}
+
 func (r *SelfNodeRemediationReconciler) removeFinalizer(snr *v1alpha1.SelfNodeRemediation) error {
 	controllerutil.RemoveFinalizer(snr, SNRFinalizer)
 	if err := r.Client.Update(context.Background(), snr); err != nil {
@@ -808,7 +902,7 @@ func (r *SelfNodeRemediationReconciler) removeNoExecuteTaint(node *v1.Node) erro
 
 func (r *SelfNodeRemediationReconciler) isStoppedByNHC(snr *v1alpha1.SelfNodeRemediation) bool {
 	if snr != nil && snr.Annotations != nil && snr.DeletionTimestamp == nil {
-		_, isTimeoutIssued := snr.Annotations[nhcTimeOutAnnotation]
+		_, isTimeoutIssued := snr.Annotations[NhcTimeOutAnnotation]
 		return isTimeoutIssued
 	}
 	return false
@@ -18,8 +18,10 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -48,6 +50,9 @@ import (
 
 const (
 	lastChangedAnnotationKey = "snr.medik8s.io/force-deletion-revision"
+	// agentHealthPort is the port of the grpc health service of the agents. It's only used by the probes of the
+	// kubelet, so it isn't exposed on the host.
+	agentHealthPort = 8082
 )
 
 // SelfNodeRemediationConfigReconciler reconciles a SelfNodeRemediationConfig object
@@ -68,6 +73,8 @@ type SelfNodeRemediationConfigReconciler struct {
 //+kubebuilder:rbac:groups="security.openshift.io",resources=securitycontextconstraints,verbs=use,resourceNames=privileged
 //+kubebuilder:rbac:groups=machine.openshift.io,resources=machines,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=machine.openshift.io,resources=machines/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=core,resources=nodes/proxy,verbs=get
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get
 
 func (r *SelfNodeRemediationConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := r.Log.WithValues("selfnoderemediationconfig", req.NamespacedName)
@@ -92,7 +99,8 @@ func (r *SelfNodeRemediationConfigReconciler) Reconcile(ctx context.Context, req
 
 	r.RebootDurationCalculator.SetConfig(config)
 
-	if err := r.syncCerts(config); err != nil {
+	nextCertsCheck, err := r.syncCerts(ctx, config)
+	if err != nil {
 		logger.Error(err, "error syncing certs")
 		return ctrl.Result{}, err
 	}
@@ -109,7 +117,8 @@ func (r *SelfNodeRemediationConfigReconciler) Reconcile(ctx context.Context, req
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	// check the certs again for renewing them before they expire
+	return ctrl.Result{RequeueAfter: nextCertsCheck}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -144,6 +153,21 @@ func (r *SelfNodeRemediationConfigReconciler) syncConfigDaemonSet(ctx context.Co
 		watchdogPath = "/dev/watchdog"
 	}
 	data.Data["WatchdogPath"] = watchdogPath
+	preferredWatchdogDevices := ""
+	if len(snrConfig.Spec.PreferredWatchdogDevices) > 0 {
+		devicesJson, err := json.Marshal(snrConfig.Spec.PreferredWatchdogDevices)
+		if err != nil {
+			logger.Error(err, "Fail to marshal preferred watchdog devices")
+			return err
+		}
+		preferredWatchdogDevices = string(devicesJson)
+	}
+	data.Data["PreferredWatchdogDevices"] = strconv.Quote(preferredWatchdogDevices)
+	watchdogTimeoutSeconds := 0
+	if snrConfig.Spec.WatchdogTimeoutSeconds != nil {
+		watchdogTimeoutSeconds = *snrConfig.Spec.WatchdogTimeoutSeconds
+	}
+	data.Data["WatchdogTimeoutSeconds"] = watchdogTimeoutSeconds
 
 	data.Data["PeerApiServerTimeout"] = snrConfig.Spec.PeerApiServerTimeout.Nanoseconds()
 	data.Data["ApiCheckInterval"] = snrConfig.Spec.ApiCheckInterval.Nanoseconds()
@@ -153,8 +177,65 @@ func (r *SelfNodeRemediationConfigReconciler) syncConfigDaemonSet(ctx context.Co
 	data.Data["PeerRequestTimeout"] = snrConfig.Spec.PeerRequestTimeout.Nanoseconds()
 	data.Data["MaxApiErrorThreshold"] = snrConfig.Spec.MaxApiErrorThreshold
 	data.Data["EndpointHealthCheckUrl"] = snrConfig.Spec.EndpointHealthCheckUrl
+	endpointHealthChecks := ""
+	if len(snrConfig.Spec.EndpointHealthChecks) > 0 {
+		checksJson, err := json.Marshal(snrConfig.Spec.EndpointHealthChecks)
+		if err != nil {
+			logger.Error(err, "Fail to marshal endpoint health checks")
+			return err
+		}
+		endpointHealthChecks = string(checksJson)
+	}
+	data.Data["EndpointHealthChecks"] = strconv.Quote(endpointHealthChecks)
+	data.Data["EndpointHealthCheckPolicy"] = snrConfig.Spec.EndpointHealthCheckPolicy
+	data.Data["EndpointHealthCheckOnWorkers"] = fmt.Sprintf("\"%t\"", snrConfig.Spec.EndpointHealthCheckOnWorkers)
+	kubeletHealthCheckMode, kubeletHealthCheckPort := selfnoderemediationv1alpha1.AuthenticatedKubeletHealthCheckMode, 0
+	kubeletServingCAConfigMap, kubeletServingCAConfigMapKey := "", ""
+	if kubeletHealthCheck := snrConfig.Spec.KubeletHealthCheck; kubeletHealthCheck != nil {
+		if kubeletHealthCheck.Mode != "" {
+			kubeletHealthCheckMode = kubeletHealthCheck.Mode
+		}
+		kubeletHealthCheckPort = kubeletHealthCheck.Port
+		if caRef := kubeletHealthCheck.ServingCAConfigMap; caRef != nil {
+			kubeletServingCAConfigMap = fmt.Sprintf("%s/%s", caRef.Namespace, caRef.Name)
+			kubeletServingCAConfigMapKey = caRef.Key
+		}
+	}
+	data.Data["KubeletHealthCheckMode"] = kubeletHealthCheckMode
+	data.Data["KubeletHealthCheckPort"] = kubeletHealthCheckPort
+	data.Data["KubeletServingCAConfigMap"] = kubeletServingCAConfigMap
+	data.Data["KubeletServingCAConfigMapKey"] = kubeletServingCAConfigMapKey
 	data.Data["HostPort"] = snrConfig.Spec.HostPort
+	data.Data["HealthPort"] = agentHealthPort
+	// zero values are replaced with the defaults by the agents
+	peerHealthServer := selfnoderemediationv1alpha1.PeerHealthServer{}
+	if snrConfig.Spec.PeerHealthServer != nil {
+		peerHealthServer = *snrConfig.Spec.PeerHealthServer
+	}
+	data.Data["PeerHealthMaxConcurrentStreams"] = peerHealthServer.MaxConcurrentStreams
+	data.Data["PeerHealthMaxConcurrentRequests"] = peerHealthServer.MaxConcurrentRequests
+	data.Data["PeerHealthPeerRequestsPerSecond"] = peerHealthServer.PeerRequestsPerSecond
+	data.Data["PeerHealthPeerRequestBurst"] = peerHealthServer.PeerRequestBurst
+	peerHealthKeepaliveMinTime := int64(0)
+	if peerHealthServer.KeepaliveMinTime != nil {
+		peerHealthKeepaliveMinTime = peerHealthServer.KeepaliveMinTime.Nanoseconds()
+	}
+	data.Data["PeerHealthKeepaliveMinTime"] = peerHealthKeepaliveMinTime
 	data.Data["IsSoftwareRebootEnabled"] = fmt.Sprintf("\"%t\"", snrConfig.Spec.IsSoftwareRebootEnabled)
+	isAgentPrivileged := true
+	if snrConfig.Spec.IsAgentPrivileged != nil {
+		isAgentPrivileged = *snrConfig.Spec.IsAgentPrivileged
+	}
+	data.Data["IsAgentPrivileged"] = isAgentPrivileged
+	data.Data["GracefulRebootGracePeriod"] = reboot.GetGracefulRebootGracePeriod(snrConfig).Nanoseconds()
+	data.Data["DryRun"] = snrConfig.Spec.DryRun
+	data.Data["PeerCertificatesBackend"] = certificates.GetBackend(snrConfig.Spec.PeerCertificates)
+	externalCertificatesSecret := ""
+	if data.Data["PeerCertificatesBackend"] == selfnoderemediationv1alpha1.ExternalPeerCertificatesBackend {
+		externalCertificatesSecret = snrConfig.Spec.PeerCertificates.ExternalSecretName
+	}
+	data.Data["ExternalCertificatesSecret"] = externalCertificatesSecret
+	data.Data["ExternalCertificatesMountPath"] = certificates.ExternalCertsMountPath
 
 	objs, err := render.Dir(r.InstallFileFolder, &data)
 	if err != nil {
@@ -199,36 +280,31 @@ func (r *SelfNodeRemediationConfigReconciler) syncK8sResource(ctx context.Contex
 	return nil
 }
 
-func (r *SelfNodeRemediationConfigReconciler) syncCerts(cr *selfnoderemediationv1alpha1.SelfNodeRemediationConfig) error {
+// syncCerts maintains the CA bundle of the agents and the certificate of admin tools with the configured backend. The
+// agents request their certificates, which are signed by the CertificateSigningRequestReconciler. The self signed
+// backend creates its CA if it doesn't exist yet, and rotates it. It returns when the certs need to be checked again,
+// 0 if they don't.
+func (r *SelfNodeRemediationConfigReconciler) syncCerts(ctx context.Context, cr *selfnoderemediationv1alpha1.SelfNodeRemediationConfig) (time.Duration, error) {
 
-	r.Log.Info("Syncing certs")
-	// check if certs exists already
-	st := certificates.NewSecretCertStorage(r.Client, r.Log.WithName("SecretCertStorage"), cr.Namespace)
-	pem, _, _, err := st.GetCerts()
-	if err != nil && !errors.IsNotFound(err) {
-		r.Log.Error(err, "Failed to get cert secret")
-		return err
-	}
-	if pem != nil {
-		r.Log.Info("Cert secret already exists")
-		// TODO check validity: if expired, create new, restart daemonset!
-		return nil
+	if err := certificates.RemoveAgentAccess(ctx, r.Client, cr.Namespace); err != nil {
+		r.Log.Error(err, "Failed to remove agent access to node certs")
+		return 0, err
 	}
-	// create certs
-	r.Log.Info("Creating new certs")
-	ca, cert, key, err := certificates.CreateCerts()
-	if err != nil {
-		r.Log.Error(err, "Failed to create certs")
-		return err
+
+	issuer := certificates.NewCertIssuer(r.Client, r.Log.WithName("CertIssuer"), cr.Namespace, cr.Spec.PeerCertificates)
+	if issuer == nil {
+		// external certs are provided by the user
+		return 0, nil
 	}
-	// store certs
-	r.Log.Info("Storing certs in new secret")
-	err = st.StoreCerts(ca, cert, key)
+
+	r.Log.Info("Syncing certs", "backend", certificates.GetBackend(cr.Spec.PeerCertificates))
+	// agents reload rotated certs, so the daemonset doesn't need to be restarted
+	nextCheck, err := issuer.Sync(ctx)
 	if err != nil {
-		r.Log.Error(err, "Failed to store certs in secret")
-		return err
+		r.Log.Error(err, "Failed to sync certs")
+		return 0, err
 	}
-	return nil
+	return nextCheck, nil
 }
 
 func (r *SelfNodeRemediationConfigReconciler) updateDsTolerations(objs []*unstructured.Unstructured, tolerations []corev1.Toleration) error {
@@ -2,6 +2,12 @@ package testconfig
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"strconv"
 	"time"
 
@@ -9,6 +15,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	appsv1 "k8s.io/api/apps/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -19,6 +26,8 @@ import (
 
 	selfnoderemediationv1alpha1 "github.com/medik8s/self-node-remediation/api/v1alpha1"
 	"github.com/medik8s/self-node-remediation/controllers/tests/shared"
+	"github.com/medik8s/self-node-remediation/pkg/certificates"
+	"github.com/medik8s/self-node-remediation/pkg/peerhealth"
 )
 
 var _ = Describe("SNR Config Test", func() {
@@ -64,8 +73,19 @@ var _ = Describe("SNR Config Test", func() {
 	Context("DS installation", func() {
 		BeforeEach(func() {
 			config.Spec.WatchdogFilePath = "/dev/foo"
+			config.Spec.PreferredWatchdogDevices = []selfnoderemediationv1alpha1.WatchdogDeviceSelector{
+				{Driver: "iTCO_wdt"}, {Identity: "Software Watchdog"},
+			}
+			config.Spec.WatchdogTimeoutSeconds = pointer.Int(15)
 			config.Spec.SafeTimeToAssumeNodeRebootedSeconds = pointer.Int(123)
 			config.Spec.HostPort = 30111
+			config.Spec.EndpointHealthChecks = []selfnoderemediationv1alpha1.EndpointHealthCheck{
+				{Type: selfnoderemediationv1alpha1.TCPEndpointHealthCheck, Target: "10.0.0.1:443"},
+			}
+			config.Spec.KubeletHealthCheck = &selfnoderemediationv1alpha1.KubeletHealthCheck{
+				ServingCAConfigMap: &selfnoderemediationv1alpha1.ConfigMapKeyReference{Namespace: "openshift-config-managed", Name: "kubelet-serving-ca"},
+			}
+			config.Spec.PeerHealthServer = &selfnoderemediationv1alpha1.PeerHealthServer{PeerRequestsPerSecond: 2}
 		})
 
 		JustBeforeEach(func() {
@@ -91,13 +111,33 @@ var _ = Describe("SNR Config Test", func() {
 
 		It("Cert Secret should be created", func() {
 			Eventually(func(g Gomega) {
+				// the admin certs and the CA bundle of the agents are issued as well
 				_, _, _, err := certReader.GetCerts()
 				g.Expect(err).ShouldNot(HaveOccurred())
+				bundle := &corev1.ConfigMap{}
+				g.Expect(k8sClient.Get(context.Background(), client.ObjectKey{Namespace: shared.Namespace, Name: certificates.CABundleName}, bundle)).To(Succeed())
 				g.Expect(k8sClient.Get(context.Background(), dsKey, ds)).To(Succeed())
 			}, 15*time.Second, 250*time.Millisecond).Should(Succeed())
 
 		})
 
+		It("should deny certificate signing requests which weren't created by an agent", func() {
+			csr := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: "self-node-remediation-peer-"},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Request:    createCertificateRequest(shared.UnhealthyNodeName),
+					SignerName: certificates.SignerName,
+					Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageDigitalSignature, certificatesv1.UsageClientAuth},
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), csr)).To(Succeed())
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(csr), csr)).To(Succeed())
+				g.Expect(csr.Status.Conditions).To(ContainElement(HaveField("Type", certificatesv1.CertificateDenied)))
+				g.Expect(csr.Status.Certificate).To(BeEmpty())
+			}, 10*time.Second, 250*time.Millisecond).Should(Succeed())
+		})
+
 		It("Daemonset should be created", func() {
 			Eventually(func() error {
 				return k8sClient.Get(context.Background(), dsKey, ds)
@@ -109,14 +149,38 @@ var _ = Describe("SNR Config Test", func() {
 			Expect(container.Image).To(Equal(shared.DsDummyImageName))
 			envVars := getEnvVarMap(container.Env)
 			Expect(envVars["WATCHDOG_PATH"].Value).To(Equal(config.Spec.WatchdogFilePath))
+			Expect(envVars["WATCHDOG_TIMEOUT_SECONDS"].Value).To(Equal("15"))
+			Expect(envVars["PREFERRED_WATCHDOG_DEVICES"].Value).To(Equal(`[{"driver":"iTCO_wdt"},{"identity":"Software Watchdog"}]`))
+			Expect(envVars["END_POINT_HEALTH_CHECKS"].Value).To(Equal(`[{"type":"TCP","target":"10.0.0.1:443","timeout":"5s"}]`))
+			Expect(envVars["END_POINT_HEALTH_CHECK_POLICY"].Value).To(Equal(string(selfnoderemediationv1alpha1.AnyEndpointHealthCheckPolicy)))
+			Expect(envVars["KUBELET_HEALTH_CHECK_MODE"].Value).To(Equal(string(selfnoderemediationv1alpha1.AuthenticatedKubeletHealthCheckMode)))
+			Expect(envVars["KUBELET_SERVING_CA_CONFIGMAP"].Value).To(Equal("openshift-config-managed/kubelet-serving-ca"))
+			Expect(envVars["KUBELET_SERVING_CA_CONFIGMAP_KEY"].Value).To(Equal("ca-bundle.crt"))
+			Expect(envVars["MY_NODE_IP"].ValueFrom.FieldRef.FieldPath).To(Equal("status.hostIP"))
+			Expect(envVars["MY_POD_NAME"].ValueFrom.FieldRef.FieldPath).To(Equal("metadata.name"))
+			Expect(envVars["DRY_RUN"].Value).To(Equal("false"))
+			// the limits which aren't set have defaults in the CRD
+			Expect(envVars["PEER_HEALTH_MAX_CONCURRENT_STREAMS"].Value).To(Equal("10"))
+			Expect(envVars["PEER_HEALTH_PEER_REQUESTS_PER_SECOND"].Value).To(Equal("2"))
+			Expect(envVars["PEER_HEALTH_KEEPALIVE_MIN_TIME"].Value).To(Equal(strconv.Itoa(int(30 * time.Second))))
+			Expect(*container.SecurityContext.Privileged).To(BeTrue())
+			Expect(container.SecurityContext.Capabilities.Add).To(ConsistOf(corev1.Capability("SYS_BOOT"), corev1.Capability("SYS_MODULE"),
+				corev1.Capability("SYS_NICE")))
 
 			Expect(len(ds.OwnerReferences)).To(Equal(1))
 			Expect(ds.OwnerReferences[0].Name).To(Equal(config.Name))
 			Expect(ds.OwnerReferences[0].Kind).To(Equal("SelfNodeRemediationConfig"))
-			Expect(len(container.Ports)).To(BeNumerically("==", 1))
+			Expect(len(container.Ports)).To(BeNumerically("==", 2))
 			port := container.Ports[0]
 			Expect(port.ContainerPort).To(BeEquivalentTo(30111))
 			Expect(port.HostPort).To(BeEquivalentTo(30111))
+			// the health service is only used by the probes, and not exposed on the host
+			healthPort := container.Ports[1]
+			Expect(healthPort.HostPort).To(BeZero())
+			Expect(container.ReadinessProbe.GRPC.Port).To(Equal(healthPort.ContainerPort))
+			Expect(*container.ReadinessProbe.GRPC.Service).To(Equal(peerhealth.PeerHealth_ServiceDesc.ServiceName))
+			Expect(container.LivenessProbe.GRPC.Port).To(Equal(healthPort.ContainerPort))
+			Expect(*container.LivenessProbe.GRPC.Service).To(Equal(peerhealth.LivenessService))
 		})
 		When("Configuration has customized tolerations", func() {
 			var expectedToleration corev1.Toleration
@@ -401,3 +465,13 @@ func setSNRStatusDisabled(snr *selfnoderemediationv1alpha1.SelfNodeRemediation)
 		g.Expect(k8sClient.Status().Update(context.Background(), snrTmp)).To(Succeed())
 	}, 10*time.Second, 250*time.Millisecond).Should(Succeed())
 }
+
+func createCertificateRequest(nodeName string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ExpectWithOffset(1, err).ToNot(HaveOccurred())
+	request, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{Organization: []string{"medik8s"}, OrganizationalUnit: []string{"nodes"}, CommonName: nodeName},
+	}, key)
+	ExpectWithOffset(1, err).ToNot(HaveOccurred())
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: request})
+}
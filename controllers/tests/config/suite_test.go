@@ -106,6 +106,14 @@ var _ = BeforeSuite(func() {
 		Namespace:                shared.Namespace,
 		RebootDurationCalculator: shared.MockRebootDurationCalculator{},
 	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&controllers.CertificateSigningRequestReconciler{
+		Client:    k8sManager.GetClient(),
+		Log:       ctrl.Log.WithName("controllers").WithName("certificate-signing-request-controller"),
+		Namespace: shared.Namespace,
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
 
 	// peers need their own node on start
 	unhealthyNode = getNode(shared.UnhealthyNodeName)
@@ -119,7 +127,8 @@ var _ = BeforeSuite(func() {
 	err = k8sManager.Add(peers)
 	Expect(err).ToNot(HaveOccurred())
 
-	certReader = certificates.NewSecretCertStorage(k8sClient, ctrl.Log.WithName("SecretCertStorage"), shared.Namespace)
+	// the agents request their certificates, which needs tokens of their pods, so the admin certificate is used
+	certReader = certificates.NewAdminCertReader(k8sClient, shared.Namespace, nil)
 	apiConnectivityCheckConfig := &apicheck.ApiConnectivityCheckConfig{
 		Log:                ctrl.Log.WithName("api-check"),
 		MyNodeName:         shared.UnhealthyNodeName,
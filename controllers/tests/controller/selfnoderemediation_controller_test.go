@@ -149,6 +149,25 @@ var _ = Describe("SNR Controller", func() {
 					testNoFinalizer(snr)
 				})
 			})
+
+			Context("agent status reports that the node isn't reboot capable", func() {
+				BeforeEach(func() {
+					agentStatus := &v1alpha1.SelfNodeRemediationAgentStatus{
+						ObjectMeta: metav1.ObjectMeta{Namespace: shared.Namespace, Name: shared.UnhealthyNodeName},
+						Spec:       v1alpha1.SelfNodeRemediationAgentStatusSpec{NodeName: shared.UnhealthyNodeName},
+					}
+					Expect(k8sClient.Create(context.Background(), agentStatus)).To(Succeed())
+					DeferCleanup(func() {
+						Expect(k8sClient.Delete(context.Background(), agentStatus)).To(Succeed())
+					})
+					agentStatus.Status.RebootCapable = false
+					Expect(k8sClient.Status().Update(context.Background(), agentStatus)).To(Succeed())
+				})
+
+				It("snr should not have finalizers although is-reboot-capable annotation is true", func() {
+					testNoFinalizer(snr)
+				})
+			})
 		})
 	})
 
@@ -158,6 +177,193 @@ var _ = Describe("SNR Controller", func() {
 			isAdditionalSetupNeeded = true
 		})
 
+		Context("Control plane nodes", func() {
+			var peerSnr *v1alpha1.SelfNodeRemediation
+
+			BeforeEach(func() {
+				for _, nodeName := range []string{shared.UnhealthyNodeName, shared.PeerNodeName} {
+					node := getNode(nodeName)
+					node.Labels["node-role.kubernetes.io/control-plane"] = ""
+					Expect(k8sClient.Update(context.Background(), node)).To(Succeed())
+				}
+
+				// the peer control plane node is being remediated already, and it isn't Ready yet
+				peerSnr = &v1alpha1.SelfNodeRemediation{}
+				peerSnr.Name = shared.PeerNodeName
+				peerSnr.Namespace = snrNamespace
+				peerSnr.Finalizers = []string{controllers.SNRFinalizer}
+				createSNR(peerSnr, v1alpha1.ResourceDeletionRemediationStrategy)
+			})
+
+			JustBeforeEach(func() {
+				createSNR(snr, v1alpha1.ResourceDeletionRemediationStrategy)
+			})
+
+			It("should wait for the other control plane remediation", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(snr), snr)).To(Succeed())
+					g.Expect(meta.IsStatusConditionTrue(snr.Status.Conditions, string(v1alpha1.WaitingForControlPlaneRemediationConditionType))).To(BeTrue())
+				}, 10*time.Second, 250*time.Millisecond).Should(Succeed())
+				Consistently(func(g Gomega) {
+					g.Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(snr), snr)).To(Succeed())
+					g.Expect(snr.Finalizers).To(BeEmpty())
+				}, 3*time.Second, 250*time.Millisecond).Should(Succeed())
+				verifyEvent(v1.EventTypeNormal, "WaitingForControlPlaneRemediation", fmt.Sprintf("[remediation] Waiting for the remediation of control plane node %s to complete and for that node to be Ready", shared.PeerNodeName))
+
+				By("completing the other control plane remediation")
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(peerSnr), peerSnr)).To(Succeed())
+					g.Expect(removeFinalizers(peerSnr)).To(Succeed())
+				}, 5*time.Second, 250*time.Millisecond).Should(Succeed())
+				deleteSNR(peerSnr)
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(snr), snr)).To(Succeed())
+					g.Expect(meta.IsStatusConditionFalse(snr.Status.Conditions, string(v1alpha1.WaitingForControlPlaneRemediationConditionType))).To(BeTrue())
+					g.Expect(snr.Finalizers).To(ContainElement(controllers.SNRFinalizer))
+				}, 30*time.Second, 250*time.Millisecond).Should(Succeed())
+			})
+		})
+
+		Context("Dry run remediation", func() {
+			JustBeforeEach(func() {
+				snr.Spec.DryRun = true
+				createSNR(snr, v1alpha1.ResourceDeletionRemediationStrategy)
+			})
+
+			It("should report the planned remediation without remediating", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(snr), snr)).To(Succeed())
+					g.Expect(snr.Status.DryRunResult).ToNot(BeNil())
+				}, 10*time.Second, 250*time.Millisecond).Should(Succeed())
+
+				result := snr.Status.DryRunResult
+				Expect(result.Remediable).To(BeTrue())
+				Expect(result.Strategy).To(Equal(v1alpha1.ResourceDeletionRemediationStrategy))
+				Expect(result.Preconditions).To(HaveLen(6))
+				for _, precondition := range result.Preconditions {
+					Expect(precondition.Passed).To(BeTrue(), "precondition %s failed: %s", precondition.Name, precondition.Message)
+				}
+				Expect(result.Timeline).To(HaveLen(4))
+				Expect(result.Timeline[2].After.Duration).To(Equal(shared.CalculatedRebootDuration))
+
+				Consistently(func(g Gomega) {
+					g.Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(snr), snr)).To(Succeed())
+					g.Expect(snr.Finalizers).To(BeEmpty())
+					g.Expect(snr.Status.Phase).To(BeNil())
+					node := &v1.Node{}
+					g.Expect(k8sClient.Get(context.Background(), unhealthyNodeNamespacedName, node)).To(Succeed())
+					g.Expect(node.Spec.Unschedulable).To(BeFalse())
+					g.Expect(node.Spec.Taints).To(BeEmpty())
+				}, 3*time.Second, 250*time.Millisecond).Should(Succeed())
+			})
+
+			When("the node isn't reboot capable", func() {
+				BeforeEach(func() {
+					nodeRebootCapable = "false"
+				})
+
+				It("should report the failed precondition", func() {
+					Eventually(func(g Gomega) {
+						g.Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(snr), snr)).To(Succeed())
+						g.Expect(snr.Status.DryRunResult).ToNot(BeNil())
+						g.Expect(snr.Status.DryRunResult.Remediable).To(BeFalse())
+						g.Expect(snr.Status.DryRunResult.Preconditions).To(ContainElement(And(
+							HaveField("Name", "RebootCapable"),
+							HaveField("Passed", false),
+						)))
+					}, 10*time.Second, 250*time.Millisecond).Should(Succeed())
+				})
+			})
+		})
+
+		Context("Dry run mode", func() {
+			BeforeEach(func() {
+				snrConfig.Spec.DryRun = true
+			})
+
+			JustBeforeEach(func() {
+				createSNR(snr, v1alpha1.ResourceDeletionRemediationStrategy)
+			})
+
+			It("should not proceed after the node would have been rebooted", func() {
+				node := verifyNodeIsUnschedulable()
+				addUnschedulableTaint(node)
+				verifyTimeHasBeenRebootedExists(snr)
+
+				verifyEvent("Normal", "DryRunRemediationHeld", "Remediation process - dry run mode, the node wasn't rebooted and its resources won't be deleted")
+				Consistently(func(g Gomega) {
+					g.Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(snr), snr)).To(Succeed())
+					g.Expect(snr.Status.Phase).ToNot(BeNil())
+					g.Expect(*snr.Status.Phase).To(Equal("Pre-Reboot-Completed"))
+				}, 3*time.Second, 250*time.Millisecond).Should(Succeed())
+				verifySelfNodeRemediationPodExist()
+			})
+		})
+
+		Context("Remediation loop protection", func() {
+			var remediationHistory string
+
+			BeforeEach(func() {
+				snrConfig.Spec.RemediationLoopProtection = &v1alpha1.RemediationLoopProtection{
+					MaxRemediations: 1,
+					Window:          &metav1.Duration{Duration: time.Hour},
+				}
+				remediationHistory = ""
+			})
+
+			JustBeforeEach(func() {
+				if remediationHistory != "" {
+					node := &v1.Node{}
+					Expect(k8sClient.Get(context.Background(), unhealthyNodeNamespacedName, node)).To(Succeed())
+					patch := client.MergeFrom(node.DeepCopy())
+					node.Annotations[utils.RemediationHistoryAnnotation] = remediationHistory
+					Expect(k8sClient.Patch(context.Background(), node, patch)).To(Succeed())
+				}
+				createSNR(snr, v1alpha1.ResourceDeletionRemediationStrategy)
+			})
+
+			When("the node wasn't remediated recently", func() {
+				BeforeEach(func() {
+					remediationHistory = fmt.Sprintf(`[{"remediation":"old","time":"%s"}]`, time.Now().Add(-2*time.Hour).UTC().Format(time.RFC3339))
+				})
+
+				It("should remediate and record the remediation", func() {
+					Eventually(func(g Gomega) {
+						g.Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(snr), snr)).To(Succeed())
+						g.Expect(snr.Finalizers).To(ContainElement(controllers.SNRFinalizer))
+						node := &v1.Node{}
+						g.Expect(k8sClient.Get(context.Background(), unhealthyNodeNamespacedName, node)).To(Succeed())
+						g.Expect(node.Annotations[utils.RemediationHistoryAnnotation]).To(ContainSubstring(string(snr.UID)))
+						g.Expect(node.Annotations[utils.RemediationHistoryAnnotation]).ToNot(ContainSubstring(`"old"`))
+					}, 10*time.Second, 250*time.Millisecond).Should(Succeed())
+					Expect(controllers.IsRemediationLoopDetected(snr)).To(BeFalse())
+				})
+			})
+
+			When("the node was remediated too often", func() {
+				BeforeEach(func() {
+					remediationHistory = fmt.Sprintf(`[{"remediation":"recent","time":"%s"}]`, time.Now().Add(-10*time.Minute).UTC().Format(time.RFC3339))
+				})
+
+				It("should not reboot the node, and leave it cordoned", func() {
+					Eventually(func(g Gomega) {
+						g.Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(snr), snr)).To(Succeed())
+						g.Expect(controllers.IsRemediationLoopDetected(snr)).To(BeTrue())
+					}, 10*time.Second, 250*time.Millisecond).Should(Succeed())
+					verifyTypeConditions(snr, metav1.ConditionFalse, metav1.ConditionFalse, "RemediationSkippedLoopDetected")
+					verifyNodeIsUnschedulable()
+					verifyEvent(v1.EventTypeWarning, "RemediationLoopDetected",
+						fmt.Sprintf("Node %s was remediated 1 times within 1h0m0s, it won't be rebooted again and stays cordoned. Uncordon it manually after fixing it", shared.UnhealthyNodeName))
+
+					Consistently(func(g Gomega) {
+						g.Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(snr), snr)).To(Succeed())
+						g.Expect(snr.Finalizers).To(BeEmpty())
+						g.Expect(snr.Status.Phase).To(BeNil())
+					}, 3*time.Second, 250*time.Millisecond).Should(Succeed())
+				})
+			})
+		})
+
 		Context("Automatic strategy - ResourceDeletion selected", func() {
 
 			BeforeEach(func() {
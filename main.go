@@ -31,10 +31,13 @@ import (
 	"github.com/pkg/errors"
 	"go.uber.org/zap/zapcore"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	pkgruntime "k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -52,9 +55,12 @@ import (
 
 	selfnoderemediationv1alpha1 "github.com/medik8s/self-node-remediation/api/v1alpha1"
 	"github.com/medik8s/self-node-remediation/controllers"
+	"github.com/medik8s/self-node-remediation/pkg/agentready"
 	"github.com/medik8s/self-node-remediation/pkg/apicheck"
 	"github.com/medik8s/self-node-remediation/pkg/certificates"
 	"github.com/medik8s/self-node-remediation/pkg/controlplane"
+	"github.com/medik8s/self-node-remediation/pkg/decision"
+	"github.com/medik8s/self-node-remediation/pkg/endpointhealth"
 	"github.com/medik8s/self-node-remediation/pkg/peerhealth"
 	"github.com/medik8s/self-node-remediation/pkg/peers"
 	"github.com/medik8s/self-node-remediation/pkg/reboot"
@@ -68,7 +74,9 @@ import (
 
 const (
 	nodeNameEnvVar    = "MY_NODE_NAME"
+	podNameEnvVar     = "MY_POD_NAME"
 	machineAnnotation = "machine.openshift.io/machine"
+	agentDsName       = "self-node-remediation-ds"
 	WebhookCertDir    = "/apiserver.local.config/certificates"
 	WebhookCertName   = "apiserver.crt"
 	WebhookKeyName    = "apiserver.key"
@@ -210,6 +218,15 @@ func initSelfNodeRemediationManager(mgr manager.Manager) {
 		os.Exit(1)
 	}
 
+	if err := (&controllers.CertificateSigningRequestReconciler{
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("controllers").WithName("CertificateSigningRequest"),
+		Namespace: ns,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CertificateSigningRequest")
+		os.Exit(1)
+	}
+
 	snrConfigInit := snrconfighelper.New(mgr.GetClient(), ctrl.Log.WithName("default SelfNodeRemediationConfig"))
 	if err = mgr.Add(snrConfigInit); err != nil {
 		setupLog.Error(err, "failed to add config to the manager")
@@ -262,6 +279,19 @@ func getIntEnvVarOrDie(varName string) int {
 	return intVar
 }
 
+func getBoolEnvVarOrDie(varName string) bool {
+	varVal := os.Getenv(varName)
+	if varVal == "" {
+		return false
+	}
+	boolVar, err := strconv.ParseBool(varVal)
+	if err != nil {
+		setupLog.Error(err, "failed to convert env variable to bool", "var name", varName, "var value", varVal)
+		os.Exit(1)
+	}
+	return boolVar
+}
+
 func initSelfNodeRemediationAgent(mgr manager.Manager) {
 	setupLog.Info("Starting as a self node remediation agent that should run as part of the daemonset")
 
@@ -278,24 +308,45 @@ func initSelfNodeRemediationAgent(mgr manager.Manager) {
 		os.Exit(1)
 	}
 
-	wd, err := watchdog.NewLinux(ctrl.Log.WithName("watchdog"))
+	var machineName string
+	if machineName, err = getMachineName(mgr.GetAPIReader(), myNodeName); err != nil {
+		setupLog.Error(err, "error when trying to fetch machine name")
+		os.Exit(1)
+	}
+
+	wd, err := watchdog.NewLinux(ctrl.Log.WithName("watchdog"), mgr.GetEventRecorderFor("SelfNodeRemediation"), myNodeName,
+		newAgentExpected(mgr.GetAPIReader(), ns, os.Getenv(podNameEnvVar), myNodeName))
 	if err != nil {
 		setupLog.Error(err, "failed to init watchdog, using soft reboot")
 	}
 
-	wasWatchdogInitiated := false
-	watchdogTimeout := time.Duration(0)
 	if wd != nil {
 		if err = mgr.Add(wd); err != nil {
 			setupLog.Error(err, "failed to add watchdog to the manager")
 			os.Exit(1)
 		}
-		wasWatchdogInitiated = true
-		watchdogTimeout = wd.GetTimeout()
 	}
 
-	if err = utils.UpdateNodeAnnotations(wasWatchdogInitiated, watchdogTimeout, myNodeName, mgr); err != nil {
-		setupLog.Error(err, "failed to update node's annotation", "annotation", utils.IsRebootCapableAnnotation)
+	// the watchdog timeout is configured and verified when the watchdog is started by the manager,
+	// so the node annotations and the last reset reason can only be updated afterwards
+	if err = mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		if err := updateNodeAnnotations(ctx, wd, myNodeName, mgr); err != nil {
+			setupLog.Error(err, "failed to update node's annotation", "annotation", utils.IsRebootCapableAnnotation)
+			return err
+		}
+		if wd != nil {
+			findRemediations := func(ctx context.Context) ([]selfnoderemediationv1alpha1.SelfNodeRemediation, error) {
+				return controllers.GetMatchingSNRs(ctx, mgr.GetClient(), myNodeName, machineName, ctrl.Log.WithName("watchdog"))
+			}
+			if err := watchdog.ReportLastReset(ctx, wd, myNodeName, findRemediations, mgr.GetClient(),
+				mgr.GetEventRecorderFor("SelfNodeRemediation"), ctrl.Log.WithName("watchdog")); err != nil {
+				// only informational, don't fail
+				setupLog.Error(err, "failed to report last reset reason")
+			}
+		}
+		return nil
+	})); err != nil {
+		setupLog.Error(err, "failed to add node annotations updater to the manager")
 		os.Exit(1)
 	}
 
@@ -319,13 +370,41 @@ func initSelfNodeRemediationAgent(mgr manager.Manager) {
 
 	// it's fine when the watchdog is nil!
 	rebooter := reboot.NewWatchdogRebooter(wd, ctrl.Log.WithName("rebooter"))
+	if gracePeriod := getDurEnvVarOrDie(reboot.GracefulRebootGracePeriodEnvVar); gracePeriod > 0 {
+		rebooter = reboot.NewGracefulRebooter(wd, rebooter, gracePeriod, ctrl.Log.WithName("graceful-rebooter"))
+	}
+
+	// in dry run mode the full decision path runs, but reboots are only reported
+	apiCheckRebooter, remediationRebooter := rebooter, rebooter
+	if isDryRun := getBoolEnvVarOrDie(reboot.DryRunEnvVar); isDryRun {
+		setupLog.Info("dry run mode enabled, the node won't be rebooted")
+		apiCheckRebooter = reboot.NewDryRunRebooter(reboot.ApiConnectivityRebootReason, mgr.GetEventRecorderFor("SelfNodeRemediation"),
+			myNodeName, ctrl.Log.WithName("dry-run-rebooter"))
+		remediationRebooter = reboot.NewDryRunRebooter(reboot.RemediationRebootReason, mgr.GetEventRecorderFor("SelfNodeRemediation"),
+			myNodeName, ctrl.Log.WithName("dry-run-rebooter"))
+	}
 
 	// init certificate reader
-	certReader := certificates.NewSecretCertStorage(mgr.GetClient(), ctrl.Log.WithName("SecretCertStorage"), ns)
+	certsBackend := selfnoderemediationv1alpha1.PeerCertificatesBackend(os.Getenv(certificates.BackendEnvVar))
+	// the uncached reader only gets the CA bundle and the certificate signing requests of this agent, agents aren't
+	// allowed to list them
+	certReader := certificates.NewNodeCertReader(mgr.GetClient(), mgr.GetAPIReader(), ctrl.Log.WithName("certificates"),
+		ns, myNodeName, certsBackend)
+	if csrCertStorage, isCSR := certReader.(*certificates.CSRCertStorage); isCSR {
+		// requests the certificate of this node, and renews it
+		if err = mgr.Add(csrCertStorage); err != nil {
+			setupLog.Error(err, "failed to add certificate storage to the manager")
+			os.Exit(1)
+		}
+	}
 
-	var machineName string
-	if machineName, err = getMachineName(mgr.GetAPIReader(), myNodeName); err != nil {
-		setupLog.Error(err, "error when trying to fetch machine name")
+	endpointChecker, err := endpointhealth.NewCheckerFromEnv(ctrl.Log.WithName("endpoint-health"))
+	if err != nil {
+		setupLog.Error(err, "failed to init endpoint health checks")
+		os.Exit(1)
+	}
+	if err = mgr.Add(endpointChecker); err != nil {
+		setupLog.Error(err, "failed to add endpoint health checker to the manager")
 		os.Exit(1)
 	}
 
@@ -336,7 +415,7 @@ func initSelfNodeRemediationAgent(mgr manager.Manager) {
 		CheckInterval:             apiCheckInterval,
 		MaxErrorsThreshold:        maxErrorThreshold,
 		Peers:                     myPeers,
-		Rebooter:                  rebooter,
+		Rebooter:                  apiCheckRebooter,
 		Cfg:                       mgr.GetConfig(),
 		CertReader:                certReader,
 		ApiServerTimeout:          apiServerTimeout,
@@ -344,9 +423,16 @@ func initSelfNodeRemediationAgent(mgr manager.Manager) {
 		PeerRequestTimeout:        peerRequestTimeout,
 		PeerHealthPort:            peerHealthDefaultPort,
 		MaxTimeForNoPeersResponse: reboot.MaxTimeForNoPeersResponse,
+		EndpointChecker:           endpointChecker,
+		Recorder:                  mgr.GetEventRecorderFor("SelfNodeRemediation"),
+		RecordFile:                decision.DefaultRecordFile,
 	}
 
-	controlPlaneManager := controlplane.NewManager(myNodeName, mgr.GetClient())
+	controlPlaneManager, err := controlplane.NewManager(myNodeName, mgr.GetClient(), mgr.GetAPIReader(), mgr.GetConfig(), endpointChecker)
+	if err != nil {
+		setupLog.Error(err, "failed to init controlPlane remediation manager")
+		os.Exit(1)
+	}
 
 	if err = mgr.Add(controlPlaneManager); err != nil {
 		setupLog.Error(err, "failed to add controlPlane remediation manager to setup manager")
@@ -359,12 +445,36 @@ func initSelfNodeRemediationAgent(mgr manager.Manager) {
 		os.Exit(1)
 	}
 
+	softwareRebootEnabled, err := utils.IsSoftwareRebootEnabled()
+	if err != nil {
+		setupLog.Error(err, "failed to check if software reboot is enabled")
+		os.Exit(1)
+	}
+	agent := &agentready.Agent{
+		NodeName:              myNodeName,
+		ControlPlane:          controlPlaneManager,
+		Watchdog:              wd,
+		SoftwareRebootEnabled: softwareRebootEnabled,
+		Peers:                 myPeers,
+		PeerUpdateInterval:    peerUpdateInterval,
+		CertReader:            certReader,
+		ApiCheck:              apiChecker,
+	}
+	if err = mgr.Add(agentready.NewConditionReporter(mgr.GetClient(), myNodeName, agent, ctrl.Log.WithName("agent-ready"))); err != nil {
+		setupLog.Error(err, "failed to add agent ready condition reporter to the manager")
+		os.Exit(1)
+	}
+	if err = mgr.Add(agentready.NewStatusReporter(mgr.GetClient(), myNodeName, ns, version.Version, agent, ctrl.Log.WithName("agent-status"))); err != nil {
+		setupLog.Error(err, "failed to add agent status reporter to the manager")
+		os.Exit(1)
+	}
+
 	snrReconciler := &controllers.SelfNodeRemediationReconciler{
 		Client:      mgr.GetClient(),
 		Log:         ctrl.Log.WithName("controllers").WithName("SelfNodeRemediation"),
 		Scheme:      mgr.GetScheme(),
 		Recorder:    mgr.GetEventRecorderFor("SelfNodeRemediation"),
-		Rebooter:    rebooter,
+		Rebooter:    remediationRebooter,
 		MyNodeName:  myNodeName,
 		MyNamespace: ns,
 		IsAgent:     true,
@@ -376,8 +486,21 @@ func initSelfNodeRemediationAgent(mgr manager.Manager) {
 	}
 
 	setupLog.Info("init grpc server")
+	// the grpc server looks up the SNRs of its peers by their node and machine
+	if err = controllers.IndexSNRTargets(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		setupLog.Error(err, "failed to index SNR targets")
+		os.Exit(1)
+	}
 	// TODO make port configurable?
-	server, err := peerhealth.NewServer(mgr.GetClient(), mgr.GetAPIReader(), ctrl.Log.WithName("peerhealth").WithName("server"), peerHealthDefaultPort, certReader)
+	healthPort := getIntEnvVarOrDie(peerhealth.HealthPortEnvVar)
+	serverLimits := peerhealth.ServerLimits{
+		MaxConcurrentStreams:  getIntEnvVarOrDie(peerhealth.MaxConcurrentStreamsEnvVar),
+		MaxConcurrentRequests: getIntEnvVarOrDie(peerhealth.MaxConcurrentRequestsEnvVar),
+		PeerRequestsPerSecond: getIntEnvVarOrDie(peerhealth.PeerRequestsPerSecondEnvVar),
+		PeerRequestBurst:      getIntEnvVarOrDie(peerhealth.PeerRequestBurstEnvVar),
+		KeepaliveMinTime:      getDurEnvVarOrDie(peerhealth.KeepaliveMinTimeEnvVar),
+	}
+	server, err := peerhealth.NewServer(mgr.GetClient(), mgr.GetAPIReader(), ctrl.Log.WithName("peerhealth").WithName("server"), peerHealthDefaultPort, healthPort, serverLimits, certReader, controlPlaneManager, agent, agent)
 	if err != nil {
 		setupLog.Error(err, "failed to init grpc server")
 		os.Exit(1)
@@ -388,6 +511,64 @@ func initSelfNodeRemediationAgent(mgr manager.Manager) {
 	}
 }
 
+func updateNodeAnnotations(ctx context.Context, wd watchdog.Watchdog, nodeName string, mgr manager.Manager) error {
+	wasWatchdogInitiated := false
+	watchdogTimeout := time.Duration(0)
+	watchdogDevice := ""
+	if wd != nil {
+		// wait for the watchdog to be started
+		if err := wait.PollUntilContextCancel(ctx, time.Second, true, func(_ context.Context) (bool, error) {
+			return wd.Status() != watchdog.Disarmed, nil
+		}); err != nil {
+			return err
+		}
+		// on malfunction we fall back to software reboot
+		wasWatchdogInitiated = wd.Status() != watchdog.Malfunction
+		if wasWatchdogInitiated {
+			watchdogTimeout = wd.GetTimeout()
+			watchdogDevice = wd.DeviceInfo().AnnotationValue()
+		}
+	}
+	return utils.UpdateNodeAnnotations(wasWatchdogInitiated, watchdogTimeout, watchdogDevice, nodeName, mgr)
+}
+
+// newAgentExpected returns a check whether a new agent will be started on this node after this agent stopped, which
+// is only the case when this agent is replaced by a rollout of the agents' DaemonSet, and the DaemonSet still targets
+// this node. An evicted or deleted agent, or an agent on a node which isn't targeted anymore, isn't replaced.
+func newAgentExpected(reader client.Reader, ns, podName, nodeName string) watchdog.HandoffCheck {
+	return func(ctx context.Context) (bool, error) {
+		ds := &appsv1.DaemonSet{}
+		if err := reader.Get(ctx, client.ObjectKey{Namespace: ns, Name: agentDsName}, ds); err != nil {
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		if ds.DeletionTimestamp != nil {
+			return false, nil
+		}
+
+		pod := &corev1.Pod{}
+		if err := reader.Get(ctx, client.ObjectKey{Namespace: ns, Name: podName}, pod); err != nil {
+			return false, err
+		}
+		updateRevision, err := utils.GetDaemonSetUpdateRevision(ctx, reader, ds)
+		if err != nil {
+			return false, err
+		}
+		if pod.Labels[appsv1.DefaultDaemonSetUniqueLabelKey] == updateRevision {
+			// the agent is up-to-date, so it isn't stopped by a rollout
+			return false, nil
+		}
+
+		node := &corev1.Node{}
+		if err := reader.Get(ctx, client.ObjectKey{Name: nodeName}, node); err != nil {
+			return false, err
+		}
+		return utils.IsNodeTargetedByDaemonSet(ds, node), nil
+	}
+}
+
 func getMachineName(reader client.Reader, myNodeName string) (string, error) {
 	var machineName string
 	var err error
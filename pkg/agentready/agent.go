@@ -0,0 +1,180 @@
+// Package agentready reports whether the self node remediation agent is able to remediate its node, in the
+// SelfNodeRemediationAgentReady node condition and in the SelfNodeRemediationAgentStatus of the node.
+package agentready
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+	"github.com/medik8s/self-node-remediation/pkg/apicheck"
+	"github.com/medik8s/self-node-remediation/pkg/certificates"
+	"github.com/medik8s/self-node-remediation/pkg/decision"
+	"github.com/medik8s/self-node-remediation/pkg/peers"
+	"github.com/medik8s/self-node-remediation/pkg/watchdog"
+)
+
+// PeersStatus is implemented by peers.Peers
+type PeersStatus interface {
+	LastUpdateTime() time.Time
+	GetPeersAddresses(role peers.Role) []v1.PodIP
+}
+
+// ApiCheckStatus is implemented by apicheck.ApiConnectivityCheck
+type ApiCheckStatus interface {
+	LastResult() *apicheck.CheckResult
+	LastPeerResponseTime() time.Time
+	DecisionState() decision.State
+	ProbePeer() (string, error)
+}
+
+// ControlPlaneRole is implemented by controlplane.Manager
+type ControlPlaneRole interface {
+	IsControlPlane() bool
+}
+
+// Agent holds the components of the agent whose state is reported
+type Agent struct {
+	NodeName string
+	// ControlPlane might be nil
+	ControlPlane ControlPlaneRole
+	// Watchdog might be nil
+	Watchdog              watchdog.Watchdog
+	SoftwareRebootEnabled bool
+	Peers                 PeersStatus
+	PeerUpdateInterval    time.Duration
+	CertReader            certificates.CertStorageReader
+	ApiCheck              ApiCheckStatus
+}
+
+// checkReadiness runs all checks. The agent is ready if all of them passed, else the returned reason is the reason of
+// the first failed check. The message holds the results of all checks.
+func (a *Agent) checkReadiness(now time.Time) (bool, string, string) {
+	ready, readyReason := true, ReasonAgentReady
+	var messages []string
+	for _, check := range []func(time.Time) (string, string){a.checkWatchdog, a.checkPeers, a.checkCertificates, a.checkApi} {
+		reason, message := check(now)
+		messages = append(messages, message)
+		if reason != "" && ready {
+			ready, readyReason = false, reason
+		}
+	}
+	return ready, readyReason, strings.Join(messages, "; ")
+}
+
+// IsServing returns false and the reason if the agent can't protect its node, because the node can't be rebooted, the
+// peer lists weren't initialized yet, or the peer certificates can't be used. It's reported by the overall grpc health
+// service. Unlike the readiness condition, it doesn't change while the node is remediated.
+func (a *Agent) IsServing() (bool, string) {
+	if a.Watchdog == nil || a.Watchdog.Status() == watchdog.Malfunction {
+		if !a.SoftwareRebootEnabled {
+			return false, "No watchdog is available, and software reboot is disabled"
+		}
+	} else if a.Watchdog.Status() == watchdog.Disarmed {
+		return false, "The watchdog isn't started"
+	}
+	if a.Peers.LastUpdateTime().IsZero() {
+		return false, "The peer lists weren't updated yet"
+	}
+	if reason, message := a.checkCertificates(time.Now()); reason != "" {
+		return false, message
+	}
+	return true, "The agent is able to remediate its node"
+}
+
+// IsServingPeers returns false and the reason if the agent can't answer the health requests of peers, because the peer
+// certificates can't be used. It's reported by the grpc health service of the peer health service, which is checked by
+// the readiness probe of the agent. Agents which can't reboot their node report that in the readiness condition of the
+// node instead, so that they don't block rollouts of the agents while they still answer their peers.
+func (a *Agent) IsServingPeers() (bool, string) {
+	if reason, message := a.checkCertificates(time.Now()); reason != "" {
+		return false, message
+	}
+	return true, "The agent is able to answer the health requests of peers"
+}
+
+// checkWatchdog returns the reason and a message if the node can't be rebooted reliably
+func (a *Agent) checkWatchdog(now time.Time) (string, string) {
+	if a.Watchdog == nil || a.Watchdog.Status() == watchdog.Malfunction {
+		if a.SoftwareRebootEnabled {
+			return "", "No watchdog is available, software reboot is used"
+		}
+		return ReasonWatchdogUnavailable, "No watchdog is available, and software reboot is disabled"
+	}
+
+	switch a.Watchdog.Status() {
+	case watchdog.Disarmed:
+		return ReasonWatchdogNotStarted, "The watchdog isn't started"
+	case watchdog.Triggered, watchdog.HandedOff:
+		return ReasonWatchdogTriggered, "The watchdog isn't fed anymore, the node is going to be rebooted"
+	}
+	if sinceLastFood := now.Sub(a.Watchdog.LastFoodTime()); sinceLastFood > a.Watchdog.GetTimeout() {
+		return ReasonWatchdogNotFed, fmt.Sprintf("The watchdog wasn't fed within its timeout of %s", a.Watchdog.GetTimeout())
+	}
+	return "", "The watchdog is fed"
+}
+
+// checkPeers returns the reason and a message if the peer lists weren't updated recently
+func (a *Agent) checkPeers(now time.Time) (string, string) {
+	lastUpdate := a.Peers.LastUpdateTime()
+	if lastUpdate.IsZero() {
+		return ReasonPeersNotUpdated, "The peer lists weren't updated yet"
+	}
+	if now.Sub(lastUpdate) > maxMissedPeerUpdates*a.PeerUpdateInterval {
+		return ReasonPeersOutdated, fmt.Sprintf("The peer lists weren't updated since %s", lastUpdate.UTC().Format(time.RFC3339))
+	}
+	return "", "The peer lists are up to date"
+}
+
+// checkCertificates returns the reason and a message if the peer certificates can't be used
+func (a *Agent) checkCertificates(now time.Time) (string, string) {
+	if _, err := certificates.CheckValidity(a.CertReader, now); err != nil {
+		return ReasonCertificatesInvalid, fmt.Sprintf("The peer certificates are invalid: %v", err)
+	}
+	return "", "The peer certificates are valid"
+}
+
+// checkApi returns the reason and a message if the last API connectivity check considered the node unhealthy
+func (a *Agent) checkApi(time.Time) (string, string) {
+	result := a.ApiCheck.LastResult()
+	switch {
+	case result == nil:
+		return "", "The API connectivity check didn't finish yet"
+	case !result.Healthy:
+		return ReasonNodeConsideredUnhealthy, "The API connectivity check considered the node unhealthy"
+	case result.ApiError != "":
+		return "", "The API server isn't reachable, but peers didn't confirm that the node is unhealthy"
+	default:
+		return "", "The API server is reachable"
+	}
+}
+
+// runSelfTest checks the watchdog and the certificates, and that a peer answers a health request
+func (a *Agent) runSelfTest(now time.Time) *v1alpha1.SelfTestResult {
+	result := &v1alpha1.SelfTestResult{Time: metav1.Time{Time: now}, Passed: true}
+	addCheck := func(name string, passed bool, message string) {
+		result.Checks = append(result.Checks, v1alpha1.SelfTestCheck{Name: name, Passed: passed, Message: message})
+		result.Passed = result.Passed && passed
+	}
+
+	reason, message := a.checkWatchdog(now)
+	addCheck(v1alpha1.WatchdogSelfTestCheck, reason == "", message)
+
+	reason, message = a.checkCertificates(now)
+	addCheck(v1alpha1.CertificatesSelfTestCheck, reason == "", message)
+
+	address, err := a.ApiCheck.ProbePeer()
+	switch {
+	case err != nil:
+		addCheck(v1alpha1.PeerSelfTestCheck, false, err.Error())
+	case address == "":
+		addCheck(v1alpha1.PeerSelfTestCheck, true, "No peers were found")
+	default:
+		addCheck(v1alpha1.PeerSelfTestCheck, true, fmt.Sprintf("Peer %s answered the health request", address))
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package agentready
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/medik8s/self-node-remediation/pkg/certificates"
+	"github.com/medik8s/self-node-remediation/pkg/watchdog"
+)
+
+var _ = Describe("Agent serving status", func() {
+
+	var agent *Agent
+	var peers *fakePeers
+
+	BeforeEach(func() {
+		agent, peers, _ = newTestAgent()
+	})
+
+	It("should be serving when the agent can remediate its node", func() {
+		serving, _ := agent.IsServing()
+		Expect(serving).To(BeTrue())
+	})
+
+	It("should not be serving without watchdog and software reboot", func() {
+		agent.SoftwareRebootEnabled = false
+		serving, reason := agent.IsServing()
+		Expect(serving).To(BeFalse())
+		Expect(reason).To(ContainSubstring("software reboot is disabled"))
+	})
+
+	It("should be serving as long as the watchdog is armed", func() {
+		wd := watchdog.NewFake(true)
+		agent.Watchdog = wd
+		serving, reason := agent.IsServing()
+		Expect(serving).To(BeFalse())
+		Expect(reason).To(ContainSubstring("watchdog isn't started"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		go func() { _ = wd.Start(ctx) }()
+		Eventually(func() bool {
+			serving, _ := agent.IsServing()
+			return serving
+		}).Should(BeTrue())
+
+		By("not feeding the watchdog anymore, like during a remediation")
+		wd.Stop()
+		serving, _ = agent.IsServing()
+		Expect(serving).To(BeTrue())
+	})
+
+	It("should not be serving before the peer lists were initialized", func() {
+		peers.lastUpdate = time.Time{}
+		serving, reason := agent.IsServing()
+		Expect(serving).To(BeFalse())
+		Expect(reason).To(ContainSubstring("peer lists weren't updated"))
+
+		By("updating the peer lists a long time ago")
+		peers.lastUpdate = time.Now().Add(-time.Hour)
+		serving, _ = agent.IsServing()
+		Expect(serving).To(BeTrue())
+	})
+
+	It("should serve peers without watchdog and software reboot, and report it in the readiness condition", func() {
+		agent.SoftwareRebootEnabled = false
+		peers.lastUpdate = time.Time{}
+		serving, reason := agent.IsServingPeers()
+		Expect(serving).To(BeTrue(), reason)
+
+		ready, readyReason, _ := agent.checkReadiness(time.Now())
+		Expect(ready).To(BeFalse())
+		Expect(readyReason).To(Equal(ReasonWatchdogUnavailable))
+	})
+
+	It("should not serve peers with invalid certificates", func() {
+		agent.CertReader = &certificates.MemoryCertStorage{CaPem: certStorage.CaPem, CertPem: &bytes.Buffer{}, KeyPem: certStorage.KeyPem}
+		serving, reason := agent.IsServingPeers()
+		Expect(serving).To(BeFalse())
+		Expect(reason).To(ContainSubstring("certificates are invalid"))
+	})
+})
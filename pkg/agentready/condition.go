@@ -0,0 +1,119 @@
+package agentready
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// NodeConditionType is the type of the node condition maintained by the agent
+	NodeConditionType v1.NodeConditionType = "SelfNodeRemediationAgentReady"
+
+	// ReasonAgentReady is used when all checks passed
+	ReasonAgentReady              = "AgentReady"
+	ReasonWatchdogNotStarted      = "WatchdogNotStarted"
+	ReasonWatchdogUnavailable     = "WatchdogUnavailable"
+	ReasonWatchdogNotFed          = "WatchdogNotFed"
+	ReasonWatchdogTriggered       = "WatchdogTriggered"
+	ReasonPeersNotUpdated         = "PeersNotUpdated"
+	ReasonPeersOutdated           = "PeersOutdated"
+	ReasonCertificatesInvalid     = "CertificatesInvalid"
+	ReasonNodeConsideredUnhealthy = "NodeConsideredUnhealthy"
+
+	// checkInterval is how often the checks run, the condition is only updated when it changed though
+	checkInterval = 15 * time.Second
+	// heartbeatInterval is how often the condition is updated when it didn't change, like the kubelet does
+	heartbeatInterval = 5 * time.Minute
+	// maxMissedPeerUpdates is the number of peer update intervals without successful update after which the peer list
+	// is considered outdated
+	maxMissedPeerUpdates = 3
+)
+
+// ConditionReporter periodically checks whether the agent is able to remediate its node, and reports the result in the
+// SelfNodeRemediationAgentReady condition of the node
+type ConditionReporter struct {
+	client   client.Client
+	nodeName string
+	agent    *Agent
+	log      logr.Logger
+}
+
+// NewConditionReporter returns a new ConditionReporter
+func NewConditionReporter(c client.Client, nodeName string, agent *Agent, log logr.Logger) *ConditionReporter {
+	return &ConditionReporter{
+		client:   c,
+		nodeName: nodeName,
+		agent:    agent,
+		log:      log,
+	}
+}
+
+// Start updates the node condition until the given context is cancelled
+func (r *ConditionReporter) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := r.updateCondition(ctx, r.buildCondition(time.Now())); err != nil {
+			r.log.Error(err, "failed to update node condition", "condition", NodeConditionType)
+		}
+	}, checkInterval)
+	return nil
+}
+
+// buildCondition runs all checks. The condition is true if all of them passed, else its reason is the reason of the
+// first failed check. The message holds the results of all checks.
+func (r *ConditionReporter) buildCondition(now time.Time) v1.NodeCondition {
+	ready, reason, message := r.agent.checkReadiness(now)
+	condition := v1.NodeCondition{
+		Type:    NodeConditionType,
+		Status:  v1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	}
+	if !ready {
+		condition.Status = v1.ConditionFalse
+	}
+	return condition
+}
+
+// updateCondition sets the given condition on the node if it changed, or if its heartbeat is outdated
+func (r *ConditionReporter) updateCondition(ctx context.Context, condition v1.NodeCondition) error {
+	node := &v1.Node{}
+	if err := r.client.Get(ctx, client.ObjectKey{Name: r.nodeName}, node); err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	condition.LastHeartbeatTime = now
+	condition.LastTransitionTime = now
+	patch := client.StrategicMergeFrom(node.DeepCopy())
+	found := false
+	for i := range node.Status.Conditions {
+		existing := &node.Status.Conditions[i]
+		if existing.Type != NodeConditionType {
+			continue
+		}
+		found = true
+		if existing.Status == condition.Status {
+			if existing.Reason == condition.Reason && existing.Message == condition.Message &&
+				now.Sub(existing.LastHeartbeatTime.Time) < heartbeatInterval {
+				return nil
+			}
+			condition.LastTransitionTime = existing.LastTransitionTime
+		}
+		*existing = condition
+	}
+	if !found {
+		node.Status.Conditions = append(node.Status.Conditions, condition)
+	}
+
+	if condition.Status != v1.ConditionTrue {
+		r.log.Info("agent isn't ready to remediate the node", "reason", condition.Reason, "message", condition.Message)
+	}
+	return r.client.Status().Patch(ctx, node, patch)
+}
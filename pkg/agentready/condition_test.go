@@ -0,0 +1,114 @@
+package agentready
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/medik8s/self-node-remediation/pkg/apicheck"
+	"github.com/medik8s/self-node-remediation/pkg/watchdog"
+)
+
+var _ = Describe("Agent ready condition", func() {
+
+	const nodeName = "node-1"
+	var reporter *ConditionReporter
+	var agent *Agent
+	var peers *fakePeers
+	var apiCheck *fakeApiCheck
+	var k8sClient client.Client
+
+	BeforeEach(func() {
+		agent, peers, apiCheck = newTestAgent()
+		k8sClient = fake.NewClientBuilder().
+			WithObjects(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName}}).
+			WithStatusSubresource(&v1.Node{}).
+			Build()
+		reporter = NewConditionReporter(k8sClient, nodeName, agent, ctrl.Log.WithName("test"))
+	})
+
+	It("should be ready when all checks pass", func() {
+		condition := reporter.buildCondition(time.Now())
+		Expect(condition.Status).To(Equal(v1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(ReasonAgentReady))
+		Expect(condition.Message).To(ContainSubstring("software reboot is used"))
+	})
+
+	It("should not be ready without watchdog and software reboot", func() {
+		agent.SoftwareRebootEnabled = false
+		Expect(reporter.buildCondition(time.Now()).Reason).To(Equal(ReasonWatchdogUnavailable))
+	})
+
+	It("should check that the watchdog is fed", func() {
+		wd := watchdog.NewFake(true)
+		agent.Watchdog = wd
+		Expect(reporter.buildCondition(time.Now()).Reason).To(Equal(ReasonWatchdogNotStarted))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		go func() { _ = wd.Start(ctx) }()
+		Eventually(func() string {
+			return reporter.buildCondition(time.Now()).Reason
+		}).Should(Equal(ReasonAgentReady))
+
+		Expect(reporter.buildCondition(time.Now().Add(time.Minute)).Reason).To(Equal(ReasonWatchdogNotFed))
+	})
+
+	It("should not be ready with outdated peer lists", func() {
+		peers.lastUpdate = time.Time{}
+		Expect(reporter.buildCondition(time.Now()).Reason).To(Equal(ReasonPeersNotUpdated))
+
+		peers.lastUpdate = time.Now().Add(-5 * time.Minute)
+		Expect(reporter.buildCondition(time.Now()).Reason).To(Equal(ReasonPeersOutdated))
+	})
+
+	It("should not be ready with expired certificates", func() {
+		reason, _ := agent.checkCertificates(time.Now().AddDate(200, 0, 0))
+		Expect(reason).To(Equal(ReasonCertificatesInvalid))
+	})
+
+	It("should not be ready when the node is considered unhealthy", func() {
+		apiCheck.result = &apicheck.CheckResult{Time: time.Now(), ApiError: "timeout", Healthy: false}
+		Expect(reporter.buildCondition(time.Now()).Reason).To(Equal(ReasonNodeConsideredUnhealthy))
+	})
+
+	It("should update the node condition", func() {
+		getCondition := func() *v1.NodeCondition {
+			node := &v1.Node{}
+			ExpectWithOffset(1, k8sClient.Get(context.Background(), client.ObjectKey{Name: nodeName}, node)).To(Succeed())
+			for _, condition := range node.Status.Conditions {
+				if condition.Type == NodeConditionType {
+					return &condition
+				}
+			}
+			return nil
+		}
+
+		Expect(reporter.updateCondition(context.Background(), reporter.buildCondition(time.Now()))).To(Succeed())
+		condition := getCondition()
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(v1.ConditionTrue))
+		transition := condition.LastTransitionTime
+
+		apiCheck.result = &apicheck.CheckResult{Time: time.Now(), ApiError: "timeout", Healthy: true}
+		Expect(reporter.updateCondition(context.Background(), reporter.buildCondition(time.Now()))).To(Succeed())
+		condition = getCondition()
+		Expect(condition.Status).To(Equal(v1.ConditionTrue))
+		Expect(condition.Message).To(ContainSubstring("isn't reachable"))
+		Expect(condition.LastTransitionTime).To(Equal(transition))
+
+		agent.SoftwareRebootEnabled = false
+		Expect(reporter.updateCondition(context.Background(), reporter.buildCondition(time.Now()))).To(Succeed())
+		condition = getCondition()
+		Expect(condition.Status).To(Equal(v1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(ReasonWatchdogUnavailable))
+	})
+})
@@ -0,0 +1,121 @@
+package agentready
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/medik8s/self-node-remediation/pkg/apicheck"
+	"github.com/medik8s/self-node-remediation/pkg/decision"
+	"github.com/medik8s/self-node-remediation/pkg/peers"
+	"github.com/medik8s/self-node-remediation/pkg/watchdog"
+)
+
+// AgentState is a snapshot of the internal state of the agent, for troubleshooting
+type AgentState struct {
+	// Time is when the snapshot was taken
+	Time     time.Time `json:"time"`
+	NodeName string    `json:"nodeName"`
+	// IsControlPlane is true if the node is considered a control plane node
+	IsControlPlane bool       `json:"isControlPlane"`
+	Ready          ReadyState `json:"ready"`
+	ApiCheck       ApiState   `json:"apiCheck"`
+	Peers          PeersState `json:"peers"`
+	// SoftwareRebootEnabled is true if the node is rebooted by software when no watchdog is available
+	SoftwareRebootEnabled bool `json:"softwareRebootEnabled"`
+	// Watchdog isn't set if no watchdog is used
+	Watchdog *WatchdogState `json:"watchdog,omitempty"`
+}
+
+// ReadyState is the result of the checks which are reported in the SelfNodeRemediationAgentReady node condition
+type ReadyState struct {
+	Ready   bool   `json:"ready"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// ApiState is the state of the API connectivity check
+type ApiState struct {
+	// State is the state of the health decision. Its TimeOfLastPeerResponse is reset whenever the API server is
+	// reachable as well.
+	decision.State `json:",inline"`
+	// LastPeerResponseTime is when a peer answered a health request the last time
+	LastPeerResponseTime *time.Time `json:"lastPeerResponseTime,omitempty"`
+	// LastResult is the result of the last check
+	LastResult *apicheck.CheckResult `json:"lastResult,omitempty"`
+}
+
+// PeersState describes the peers known by the agent
+type PeersState struct {
+	Workers        []string   `json:"workers"`
+	ControlPlanes  []string   `json:"controlPlanes"`
+	LastUpdateTime *time.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// WatchdogState describes the watchdog and how it's fed
+type WatchdogState struct {
+	Status         string               `json:"status"`
+	Timeout        string               `json:"timeout"`
+	LastFoodTime   *time.Time           `json:"lastFoodTime,omitempty"`
+	Device         *watchdog.DeviceInfo `json:"device,omitempty"`
+	FeedInterval   string               `json:"feedInterval"`
+	LastFeedGap    string               `json:"lastFeedGap"`
+	MaxFeedGap     string               `json:"maxFeedGap"`
+	MeanFeedJitter string               `json:"meanFeedJitter"`
+	LateFeeds      int                  `json:"lateFeeds"`
+	// LastReset is the last reset of the node as reported by the watchdog device
+	LastReset *watchdog.ResetInfo `json:"lastReset,omitempty"`
+}
+
+// State returns a snapshot of the state of the agent
+func (a *Agent) State(now time.Time) AgentState {
+	ready, reason, message := a.checkReadiness(now)
+	state := AgentState{
+		Time:                  now,
+		NodeName:              a.NodeName,
+		IsControlPlane:        a.ControlPlane != nil && a.ControlPlane.IsControlPlane(),
+		Ready:                 ReadyState{Ready: ready, Reason: reason, Message: message},
+		ApiCheck:              ApiState{State: a.ApiCheck.DecisionState(), LastResult: a.ApiCheck.LastResult()},
+		Peers:                 PeersState{Workers: podIPs(a.Peers, peers.Worker), ControlPlanes: podIPs(a.Peers, peers.ControlPlane)},
+		SoftwareRebootEnabled: a.SoftwareRebootEnabled,
+	}
+	state.ApiCheck.LastPeerResponseTime = timeOrNil(a.ApiCheck.LastPeerResponseTime())
+	state.Peers.LastUpdateTime = timeOrNil(a.Peers.LastUpdateTime())
+
+	if wd := a.Watchdog; wd != nil {
+		feedStats := wd.FeedStats()
+		state.Watchdog = &WatchdogState{
+			Status:         wd.Status().String(),
+			Timeout:        wd.GetTimeout().String(),
+			LastFoodTime:   timeOrNil(wd.LastFoodTime()),
+			Device:         wd.DeviceInfo(),
+			FeedInterval:   feedStats.Interval.String(),
+			LastFeedGap:    feedStats.LastGap.String(),
+			MaxFeedGap:     feedStats.MaxGap.String(),
+			MeanFeedJitter: feedStats.MeanJitter.String(),
+			LateFeeds:      feedStats.LateFeeds,
+			LastReset:      wd.LastReset(),
+		}
+	}
+	return state
+}
+
+// AgentStateJson returns the current state of the agent serialized as JSON, it implements
+// peerhealth.AgentStateProvider
+func (a *Agent) AgentStateJson() ([]byte, error) {
+	return json.Marshal(a.State(time.Now()))
+}
+
+func podIPs(p PeersStatus, role peers.Role) []string {
+	ips := []string{}
+	for _, address := range p.GetPeersAddresses(role) {
+		ips = append(ips, address.IP)
+	}
+	return ips
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,78 @@
+package agentready
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/medik8s/self-node-remediation/pkg/decision"
+	"github.com/medik8s/self-node-remediation/pkg/watchdog"
+)
+
+var _ = Describe("Agent state", func() {
+
+	var agent *Agent
+	var peers *fakePeers
+	var apiCheck *fakeApiCheck
+
+	BeforeEach(func() {
+		agent, peers, apiCheck = newTestAgent()
+		agent.NodeName = "node-1"
+		peers.workers = []v1.PodIP{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}}
+		peers.controlPlanes = []v1.PodIP{{IP: "10.0.1.1"}}
+	})
+
+	It("should include the health decision state and the peers", func() {
+		lastPeerResponse := time.Now().Add(-time.Minute)
+		apiCheck.decisionState = decision.State{ErrorCount: 2, TimeOfLastPeerResponse: lastPeerResponse}
+		apiCheck.result.ApiError = "timeout"
+
+		state := agent.State(time.Now())
+		Expect(state.NodeName).To(Equal("node-1"))
+		Expect(state.IsControlPlane).To(BeFalse())
+		Expect(state.Ready.Ready).To(BeTrue())
+		Expect(state.ApiCheck.ErrorCount).To(Equal(2))
+		Expect(state.ApiCheck.LastPeerResponseTime).To(BeNil())
+		Expect(state.Peers.Workers).To(ConsistOf("10.0.0.1", "10.0.0.2"))
+		Expect(state.Peers.ControlPlanes).To(ConsistOf("10.0.1.1"))
+		Expect(state.Watchdog).To(BeNil())
+
+		stateJson, err := agent.AgentStateJson()
+		Expect(err).ToNot(HaveOccurred())
+		dumped := map[string]interface{}{}
+		Expect(json.Unmarshal(stateJson, &dumped)).To(Succeed())
+		Expect(dumped).To(HaveKeyWithValue("apiCheck", HaveKeyWithValue("errorCount", BeEquivalentTo(2))))
+		Expect(dumped).To(HaveKeyWithValue("apiCheck", HaveKeyWithValue("timeOfLastPeerResponse", Not(BeEmpty()))))
+		Expect(dumped).To(HaveKeyWithValue("apiCheck", HaveKeyWithValue("lastResult", HaveKeyWithValue("apiError", "timeout"))))
+	})
+
+	It("should include the control plane role", func() {
+		agent.ControlPlane = fakeControlPlaneRole(true)
+		Expect(agent.State(time.Now()).IsControlPlane).To(BeTrue())
+	})
+
+	It("should include the watchdog status", func() {
+		wd := watchdog.NewFake(true)
+		agent.Watchdog = wd
+		Expect(agent.State(time.Now()).Watchdog.Status).To(Equal("Disarmed"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		go func() { _ = wd.Start(ctx) }()
+		Eventually(func() string {
+			return agent.State(time.Now()).Watchdog.Status
+		}).Should(Equal("Armed"))
+		state := agent.State(time.Now())
+		Expect(state.Watchdog.LastFoodTime).ToNot(BeNil())
+		Expect(state.Watchdog.Timeout).ToNot(BeEmpty())
+	})
+})
+
+type fakeControlPlaneRole bool
+
+func (r fakeControlPlaneRole) IsControlPlane() bool { return bool(r) }
@@ -0,0 +1,142 @@
+package agentready
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+	"github.com/medik8s/self-node-remediation/pkg/peers"
+	"github.com/medik8s/self-node-remediation/pkg/watchdog"
+)
+
+const (
+	// statusUpdateInterval is how often the agent status is updated
+	statusUpdateInterval = time.Minute
+	// selfTestInterval is how often the self-test runs
+	selfTestInterval = 5 * time.Minute
+)
+
+// StatusReporter periodically writes the SelfNodeRemediationAgentStatus of the agent's node, including the result of a
+// self-test of the agent
+type StatusReporter struct {
+	client       client.Client
+	nodeName     string
+	namespace    string
+	agentVersion string
+	agent        *Agent
+	selfTest     *v1alpha1.SelfTestResult
+	log          logr.Logger
+}
+
+// NewStatusReporter returns a new StatusReporter, which writes the agent status into the given namespace
+func NewStatusReporter(c client.Client, nodeName string, namespace string, agentVersion string, agent *Agent, log logr.Logger) *StatusReporter {
+	return &StatusReporter{
+		client:       c,
+		nodeName:     nodeName,
+		namespace:    namespace,
+		agentVersion: agentVersion,
+		agent:        agent,
+		log:          log,
+	}
+}
+
+// Start updates the agent status until the given context is cancelled
+func (r *StatusReporter) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		now := time.Now()
+		// don't fail the self-test while the watchdog is being started
+		watchdogStarting := r.agent.Watchdog != nil && r.agent.Watchdog.Status() == watchdog.Disarmed
+		if !watchdogStarting && (r.selfTest == nil || now.Sub(r.selfTest.Time.Time) >= selfTestInterval) {
+			r.selfTest = r.agent.runSelfTest(now)
+			if !r.selfTest.Passed {
+				r.log.Info("self-test failed", "checks", r.selfTest.Checks)
+			}
+		}
+		if err := r.updateStatus(ctx, r.buildStatus(now)); err != nil {
+			r.log.Error(err, "failed to update agent status")
+		}
+	}, statusUpdateInterval)
+	return nil
+}
+
+func (r *StatusReporter) buildStatus(now time.Time) v1alpha1.SelfNodeRemediationAgentStatusStatus {
+	status := v1alpha1.SelfNodeRemediationAgentStatusStatus{
+		AgentVersion:          r.agentVersion,
+		SoftwareRebootEnabled: r.agent.SoftwareRebootEnabled,
+		RebootCapable:         r.agent.SoftwareRebootEnabled,
+		Peers: v1alpha1.AgentPeersStatus{
+			Workers:       len(r.agent.Peers.GetPeersAddresses(peers.Worker)),
+			ControlPlanes: len(r.agent.Peers.GetPeersAddresses(peers.ControlPlane)),
+		},
+		SelfTest:       r.selfTest,
+		LastUpdateTime: &metav1.Time{Time: now},
+	}
+	if lastPeerResponse := r.agent.ApiCheck.LastPeerResponseTime(); !lastPeerResponse.IsZero() {
+		status.Peers.LastSuccessfulRequestTime = &metav1.Time{Time: lastPeerResponse}
+	}
+
+	wd := r.agent.Watchdog
+	if wd != nil && wd.Status() != watchdog.Disarmed && wd.Status() != watchdog.Malfunction {
+		status.RebootCapable = true
+		status.Watchdog = &v1alpha1.AgentWatchdogStatus{
+			// always round up in case we have fractions of seconds, like the watchdog timeout annotation
+			TimeoutSeconds: int(math.Ceil(wd.GetTimeout().Seconds())),
+		}
+		if device := wd.DeviceInfo(); device != nil {
+			status.Watchdog.Path = device.Path
+			status.Watchdog.Identity = device.Identity
+			status.Watchdog.Driver = device.Driver
+			status.Watchdog.FirmwareVersion = device.FirmwareVersion
+		}
+	}
+	return status
+}
+
+// updateStatus creates the agent status if it doesn't exist yet, owned by the config, and updates its status
+func (r *StatusReporter) updateStatus(ctx context.Context, status v1alpha1.SelfNodeRemediationAgentStatusStatus) error {
+	agentStatus := &v1alpha1.SelfNodeRemediationAgentStatus{}
+	key := client.ObjectKey{Namespace: r.namespace, Name: r.nodeName}
+	if err := r.client.Get(ctx, key, agentStatus); err != nil {
+		if !apiErrors.IsNotFound(err) {
+			return err
+		}
+		if agentStatus, err = r.createAgentStatus(ctx); err != nil {
+			return err
+		}
+	}
+	agentStatus.Status = status
+	return r.client.Status().Update(ctx, agentStatus)
+}
+
+func (r *StatusReporter) createAgentStatus(ctx context.Context) (*v1alpha1.SelfNodeRemediationAgentStatus, error) {
+	agentStatus := &v1alpha1.SelfNodeRemediationAgentStatus{
+		ObjectMeta: metav1.ObjectMeta{Namespace: r.namespace, Name: r.nodeName},
+		Spec:       v1alpha1.SelfNodeRemediationAgentStatusSpec{NodeName: r.nodeName},
+	}
+
+	// the agent status is deleted together with the config
+	config := &v1alpha1.SelfNodeRemediationConfig{}
+	if err := r.client.Get(ctx, client.ObjectKey{Namespace: r.namespace, Name: v1alpha1.ConfigCRName}, config); err != nil {
+		if !apiErrors.IsNotFound(err) {
+			return nil, err
+		}
+		r.log.Info("config not found, creating agent status without owner")
+	} else if err := controllerutil.SetOwnerReference(config, agentStatus, r.client.Scheme()); err != nil {
+		return nil, err
+	}
+
+	r.log.Info("creating agent status", "name", r.nodeName)
+	if err := r.client.Create(ctx, agentStatus); err != nil {
+		return nil, err
+	}
+	return agentStatus, nil
+}
@@ -0,0 +1,89 @@
+package agentready
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+)
+
+var _ = Describe("Agent status", func() {
+
+	const (
+		nodeName  = "node-1"
+		namespace = "self-node-remediation"
+	)
+	var reporter *StatusReporter
+	var agent *Agent
+	var peers *fakePeers
+	var apiCheck *fakeApiCheck
+	var k8sClient client.Client
+
+	BeforeEach(func() {
+		agent, peers, apiCheck = newTestAgent()
+		peers.workers = []v1.PodIP{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}}
+		peers.controlPlanes = []v1.PodIP{{IP: "10.0.1.1"}}
+		apiCheck.probedPeer = "10.0.0.1"
+
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+		config := &v1alpha1.SelfNodeRemediationConfig{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: v1alpha1.ConfigCRName, UID: "config-uid"}}
+		k8sClient = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(config).
+			WithStatusSubresource(&v1alpha1.SelfNodeRemediationAgentStatus{}).
+			Build()
+		reporter = NewStatusReporter(k8sClient, nodeName, namespace, "v0.0.1", agent, ctrl.Log.WithName("test"))
+	})
+
+	It("should pass the self-test", func() {
+		result := agent.runSelfTest(time.Now())
+		Expect(result.Passed).To(BeTrue())
+		Expect(result.Checks).To(HaveLen(3))
+	})
+
+	It("should fail the self-test when no peer answers", func() {
+		apiCheck.probeErr = errors.New("peer 10.0.0.1 didn't answer the health request")
+		result := agent.runSelfTest(time.Now())
+		Expect(result.Passed).To(BeFalse())
+		Expect(result.Checks).To(ContainElement(v1alpha1.SelfTestCheck{Name: v1alpha1.PeerSelfTestCheck, Passed: false, Message: apiCheck.probeErr.Error()}))
+	})
+
+	It("should create the agent status owned by the config, and update it", func() {
+		reporter.selfTest = agent.runSelfTest(time.Now())
+		Expect(reporter.updateStatus(context.Background(), reporter.buildStatus(time.Now()))).To(Succeed())
+
+		agentStatus := &v1alpha1.SelfNodeRemediationAgentStatus{}
+		Expect(k8sClient.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: nodeName}, agentStatus)).To(Succeed())
+		Expect(agentStatus.Spec.NodeName).To(Equal(nodeName))
+		Expect(agentStatus.OwnerReferences).To(HaveLen(1))
+		Expect(agentStatus.OwnerReferences[0].Name).To(Equal(v1alpha1.ConfigCRName))
+		Expect(agentStatus.Status.AgentVersion).To(Equal("v0.0.1"))
+		Expect(agentStatus.Status.RebootCapable).To(BeTrue())
+		Expect(agentStatus.Status.Watchdog).To(BeNil())
+		Expect(agentStatus.Status.Peers.Workers).To(Equal(2))
+		Expect(agentStatus.Status.Peers.ControlPlanes).To(Equal(1))
+		Expect(agentStatus.Status.Peers.LastSuccessfulRequestTime).To(BeNil())
+		Expect(agentStatus.Status.SelfTest.Passed).To(BeTrue())
+
+		agent.SoftwareRebootEnabled = false
+		apiCheck.lastPeerResponse = time.Now()
+		Expect(reporter.updateStatus(context.Background(), reporter.buildStatus(time.Now()))).To(Succeed())
+		Expect(k8sClient.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: nodeName}, agentStatus)).To(Succeed())
+		Expect(agentStatus.Status.RebootCapable).To(BeFalse())
+		Expect(agentStatus.Status.Peers.LastSuccessfulRequestTime).ToNot(BeNil())
+	})
+})
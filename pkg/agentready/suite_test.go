@@ -0,0 +1,80 @@
+package agentready
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+	"github.com/medik8s/self-node-remediation/pkg/apicheck"
+	"github.com/medik8s/self-node-remediation/pkg/certificates"
+	"github.com/medik8s/self-node-remediation/pkg/decision"
+	"github.com/medik8s/self-node-remediation/pkg/peers"
+)
+
+var certStorage *certificates.MemoryCertStorage
+
+func TestAgentReady(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Agent Ready Suite")
+}
+
+var _ = BeforeSuite(func() {
+	logf.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
+
+	// creating certificates is slow, so they are shared by all tests
+	caPem, caKeyPem, err := certificates.CreateCA(v1alpha1.ECDSAPeerCertificatesKeyAlgorithm)
+	Expect(err).ToNot(HaveOccurred())
+	certPem, keyPem, err := certificates.CreateNodeCert(caPem, caKeyPem, "test-node")
+	Expect(err).ToNot(HaveOccurred())
+	certStorage = &certificates.MemoryCertStorage{CaPem: caPem, CertPem: certPem, KeyPem: keyPem}
+})
+
+type fakePeers struct {
+	lastUpdate             time.Time
+	workers, controlPlanes []v1.PodIP
+}
+
+func (p *fakePeers) LastUpdateTime() time.Time { return p.lastUpdate }
+
+func (p *fakePeers) GetPeersAddresses(role peers.Role) []v1.PodIP {
+	if role == peers.Worker {
+		return p.workers
+	}
+	return p.controlPlanes
+}
+
+type fakeApiCheck struct {
+	result           *apicheck.CheckResult
+	decisionState    decision.State
+	lastPeerResponse time.Time
+	probedPeer       string
+	probeErr         error
+}
+
+func (a *fakeApiCheck) LastResult() *apicheck.CheckResult { return a.result }
+
+func (a *fakeApiCheck) LastPeerResponseTime() time.Time { return a.lastPeerResponse }
+
+func (a *fakeApiCheck) DecisionState() decision.State { return a.decisionState }
+
+func (a *fakeApiCheck) ProbePeer() (string, error) { return a.probedPeer, a.probeErr }
+
+// newTestAgent returns an agent with software reboot enabled, whose checks pass
+func newTestAgent() (*Agent, *fakePeers, *fakeApiCheck) {
+	testPeers := &fakePeers{lastUpdate: time.Now()}
+	apiCheck := &fakeApiCheck{result: &apicheck.CheckResult{Time: time.Now(), Healthy: true}}
+	return &Agent{
+		SoftwareRebootEnabled: true,
+		Peers:                 testPeers,
+		PeerUpdateInterval:    time.Minute,
+		CertReader:            certStorage,
+		ApiCheck:              apiCheck,
+	}, testPeers, apiCheck
+}
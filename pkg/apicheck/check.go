@@ -8,31 +8,41 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
-	"google.golang.org/grpc/credentials"
+	"github.com/medik8s/common/pkg/events"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	selfNodeRemediation "github.com/medik8s/self-node-remediation/api"
 	"github.com/medik8s/self-node-remediation/pkg/certificates"
 	"github.com/medik8s/self-node-remediation/pkg/controlplane"
+	"github.com/medik8s/self-node-remediation/pkg/decision"
+	"github.com/medik8s/self-node-remediation/pkg/endpointhealth"
 	"github.com/medik8s/self-node-remediation/pkg/peerhealth"
 	"github.com/medik8s/self-node-remediation/pkg/peers"
 	"github.com/medik8s/self-node-remediation/pkg/reboot"
-	"github.com/medik8s/self-node-remediation/pkg/utils"
+)
+
+const (
+	eventReasonSelfFencingPostponed = "SelfFencingPostponed"
 )
 
 type ApiConnectivityCheck struct {
 	client.Reader
-	config                 *ApiConnectivityCheckConfig
-	errorCount             int
-	timeOfLastPeerResponse time.Time
-	clientCreds            credentials.TransportCredentials
-	mutex                  sync.Mutex
-	controlPlaneManager    *controlplane.Manager
+	config              *ApiConnectivityCheckConfig
+	decisionConfig      decision.Config
+	decisionState       decision.State
+	recorder            *decision.Recorder
+	lastResult          *CheckResult
+	lastPeerResponse    time.Time
+	clientCreds         *certificates.ClientCredentialsReloader
+	mutex               sync.Mutex
+	controlPlaneManager *controlplane.Manager
 }
 
 type ApiConnectivityCheckConfig struct {
@@ -50,14 +60,40 @@ type ApiConnectivityCheckConfig struct {
 	PeerRequestTimeout        time.Duration
 	PeerHealthPort            int
 	MaxTimeForNoPeersResponse time.Duration
+	EndpointChecker           *endpointhealth.Checker
+	Recorder                  record.EventRecorder
+	// RecordFile is the file the inputs of the health decisions are recorded to, nothing is recorded if it's empty
+	RecordFile string
+}
+
+// CheckResult is the result of an API connectivity check
+type CheckResult struct {
+	// Time is when the check started
+	Time time.Time `json:"time"`
+	// ApiError is empty if the API server was reachable
+	ApiError string `json:"apiError,omitempty"`
+	// Healthy is false if the node was considered unhealthy, and a reboot was triggered
+	Healthy bool `json:"healthy"`
+}
+
+// peerResponse is the health status reported by a peer
+type peerResponse struct {
+	status           selfNodeRemediation.HealthCheckResponseCode
+	etcdMemberStatus selfNodeRemediation.EtcdMemberStatusCode
 }
 
 func New(config *ApiConnectivityCheckConfig, controlPlaneManager *controlplane.Manager) *ApiConnectivityCheck {
 	return &ApiConnectivityCheck{
-		config:                 config,
-		mutex:                  sync.Mutex{},
-		controlPlaneManager:    controlPlaneManager,
-		timeOfLastPeerResponse: time.Now(),
+		config: config,
+		decisionConfig: decision.Config{
+			MaxErrorsThreshold:        config.MaxErrorsThreshold,
+			MaxTimeForNoPeersResponse: config.MaxTimeForNoPeersResponse,
+			EndpointChecksOnWorkers:   config.EndpointChecker != nil && config.EndpointChecker.IsEnabledOnWorkers(),
+		},
+		decisionState:       decision.State{TimeOfLastPeerResponse: time.Now()},
+		clientCreds:         certificates.NewClientCredentialsReloader(config.CertReader),
+		mutex:               sync.Mutex{},
+		controlPlaneManager: controlPlaneManager,
 	}
 }
 
@@ -69,134 +105,199 @@ func (c *ApiConnectivityCheck) Start(ctx context.Context) error {
 	}
 	restClient := cs.RESTClient()
 
+	if c.config.RecordFile != "" {
+		recorder, err := decision.NewRecorder(c.config.RecordFile, c.decisionConfig, c.decisionState)
+		if err != nil {
+			// recording is for troubleshooting only, don't prevent the health checks
+			c.config.Log.Error(err, "failed to open health decision record file, health decisions won't be recorded", "file", c.config.RecordFile)
+		} else {
+			c.recorder = recorder
+			defer recorder.Close()
+		}
+	}
+
 	wait.UntilWithContext(ctx, func(ctx context.Context) {
 
+		obs := decision.Observation{Time: time.Now()}
+
 		readerCtx, cancel := context.WithTimeout(ctx, c.config.ApiServerTimeout)
 		defer cancel()
 
 		result := restClient.Verb(http.MethodGet).RequestURI("/readyz?exclude=shutdown").Do(readerCtx)
-		failure := ""
 		if result.Error() != nil {
-			failure = fmt.Sprintf("api server readyz endpoint error: %v", result.Error())
+			obs.ApiError = fmt.Sprintf("api server readyz endpoint error: %v", result.Error())
 		} else {
 			statusCode := 0
 			result.StatusCode(&statusCode)
 			if statusCode != 200 {
-				failure = fmt.Sprintf("api server readyz endpoint status code: %v", statusCode)
+				obs.ApiError = fmt.Sprintf("api server readyz endpoint status code: %v", statusCode)
 			}
 		}
-		if failure != "" {
-			c.config.Log.Info(fmt.Sprintf("failed to check api server: %s", failure))
-			if isHealthy := c.isConsideredHealthy(); !isHealthy {
-				// we have a problem on this node
-				c.config.Log.Error(err, "we are unhealthy, triggering a reboot")
-				if err := c.config.Rebooter.Reboot(); err != nil {
-					c.config.Log.Error(err, "failed to trigger reboot")
-				}
-			} else {
-				c.config.Log.Info("peers did not confirm that we are unhealthy, ignoring error")
-			}
-			return
+		if obs.ApiError != "" {
+			c.config.Log.Info(fmt.Sprintf("failed to check api server: %s", obs.ApiError))
 		}
 
-		// reset error count after a successful API call
-		c.errorCount = 0
+		isHealthy := c.isConsideredHealthy(&obs)
+		c.setLastResult(CheckResult{Time: obs.Time, ApiError: obs.ApiError, Healthy: isHealthy})
+		if !isHealthy {
+			// we have a problem on this node
+			c.config.Log.Error(err, "we are unhealthy, triggering a reboot")
+			if err := c.config.Rebooter.Reboot(); err != nil {
+				c.config.Log.Error(err, "failed to trigger reboot")
+			}
+		} else if obs.ApiError != "" {
+			c.config.Log.Info("peers did not confirm that we are unhealthy, ignoring error")
+		}
 
 	}, c.config.CheckInterval)
 
 	return nil
 }
 
-// isConsideredHealthy keeps track of the number of errors reported, and when a certain amount of error occur within a certain
-// time, ask peers if this node is healthy. Returns if the node is considered to be healthy or not.
-func (c *ApiConnectivityCheck) isConsideredHealthy() bool {
-	workerPeersResponse := c.getWorkerPeersResponse()
-	isWorkerNode := c.controlPlaneManager == nil || !c.controlPlaneManager.IsControlPlane()
-	if isWorkerNode {
-		return workerPeersResponse.IsHealthy
-	} else {
-		return c.controlPlaneManager.IsControlPlaneHealthy(workerPeersResponse, c.canOtherControlPlanesBeReached())
-	}
+// LastResult returns the result of the last API connectivity check, nil if no check finished yet
+func (c *ApiConnectivityCheck) LastResult() *CheckResult {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.lastResult
+}
 
+// LastPeerResponseTime returns when a peer answered a health request the last time, zero if no peer answered yet
+func (c *ApiConnectivityCheck) LastPeerResponseTime() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.lastPeerResponse
 }
 
-func (c *ApiConnectivityCheck) getWorkerPeersResponse() peers.Response {
-	c.errorCount++
-	if c.errorCount < c.config.MaxErrorsThreshold {
-		c.config.Log.Info("Ignoring api-server error, error count below threshold", "current count", c.errorCount, "threshold", c.config.MaxErrorsThreshold)
-		return peers.Response{IsHealthy: true, Reason: peers.HealthyBecauseErrorsThresholdNotReached}
-	}
+// DecisionState returns the state of the health decision, like the number of consecutive API errors
+func (c *ApiConnectivityCheck) DecisionState() decision.State {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.decisionState
+}
 
-	c.config.Log.Info("Error count exceeds threshold, trying to ask other nodes if I'm healthy")
+// ProbePeer sends a health request to a single peer, preferring worker peers, and returns an error if it didn't answer.
+// It returns an empty address if there is no peer.
+func (c *ApiConnectivityCheck) ProbePeer() (string, error) {
 	peersToAsk := c.config.Peers.GetPeersAddresses(peers.Worker)
-	if peersToAsk == nil || len(peersToAsk) == 0 {
-		c.config.Log.Info("Peers list is empty and / or couldn't be retrieved from server, nothing we can do, so consider the node being healthy")
-		// TODO: maybe we need to check if this happens too much and reboot
-		return peers.Response{IsHealthy: true, Reason: peers.HealthyBecauseNoPeersWereFound}
+	if len(peersToAsk) == 0 {
+		peersToAsk = c.config.Peers.GetPeersAddresses(peers.ControlPlane)
+	}
+	if len(peersToAsk) == 0 {
+		return "", nil
 	}
 
-	apiErrorsResponsesSum := 0
-	nrAllPeers := len(peersToAsk)
-	// peersToAsk is being reduced at every iteration, iterate until no peers left to ask
-	for i := 0; len(peersToAsk) > 0; i++ {
-
-		batchSize := utils.GetNextBatchSize(nrAllPeers, len(peersToAsk))
-		chosenPeersIPs := c.popPeerIPs(&peersToAsk, batchSize)
-		healthyResponses, unhealthyResponses, apiErrorsResponses, _ := c.getHealthStatusFromPeers(chosenPeersIPs)
-		if healthyResponses+unhealthyResponses+apiErrorsResponses > 0 {
-			c.timeOfLastPeerResponse = time.Now()
-		}
+	address := c.popPeerIPs(&peersToAsk, 1)[0]
+	responseChan := make(chan peerResponse, 1)
+	c.getHealthStatusFromPeer(address, responseChan)
+	if response := <-responseChan; response.status == selfNodeRemediation.RequestFailed {
+		return address.IP, fmt.Errorf("peer %s didn't answer the health request", address.IP)
+	}
+	return address.IP, nil
+}
 
-		if healthyResponses > 0 {
-			c.config.Log.Info("Peer told me I'm healthy.")
-			c.errorCount = 0
-			return peers.Response{IsHealthy: true, Reason: peers.HealthyBecauseCRNotFound}
-		}
+func (c *ApiConnectivityCheck) setLastResult(result CheckResult) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lastResult = &result
+}
 
-		if unhealthyResponses > 0 {
-			c.config.Log.Info("Peer told me I'm unhealthy!")
-			return peers.Response{IsHealthy: false, Reason: peers.UnHealthyBecausePeersResponse}
+// isConsideredHealthy evaluates the health decision for the given observation. It gathers the inputs the decision
+// depends on, like peer responses and diagnostics, and records the observation together with the verdict.
+func (c *ApiConnectivityCheck) isConsideredHealthy(obs *decision.Observation) bool {
+	obs.IsControlPlane = c.controlPlaneManager != nil && c.controlPlaneManager.IsControlPlane()
+
+	var workerPeersToAsk []corev1.PodIP
+	result := decision.Evaluate(c.decisionConfig, c.decisionState, *obs)
+	for result.Need != nil {
+		switch result.Need.Type {
+		case decision.NeedWorkerPeers:
+			workerPeersToAsk = c.config.Peers.GetPeersAddresses(peers.Worker)
+			obs.WorkerPeers = &decision.WorkerPeers{Count: len(workerPeersToAsk)}
+		case decision.NeedWorkerPeerBatch:
+			chosenPeersIPs := c.popPeerIPs(&workerPeersToAsk, result.Need.BatchSize)
+			obs.WorkerPeers.Batches = append(obs.WorkerPeers.Batches, c.getHealthStatusFromPeers(chosenPeersIPs))
+		case decision.NeedControlPlanePeers:
+			status := c.getControlPlanePeersStatus()
+			obs.ControlPlanePeers = &status
+		case decision.NeedEndpointAccess:
+			accessLost := c.isEndpointAccessLost()
+			obs.EndpointAccessLost = &accessLost
+		case decision.NeedKubelet:
+			kubeletRunning := c.controlPlaneManager.IsKubeletServiceRunning()
+			obs.KubeletRunning = &kubeletRunning
+		case decision.NeedLocalEtcdMemberStatus:
+			etcdMemberStatus := c.controlPlaneManager.GetLocalEtcdMemberStatus(context.Background())
+			obs.LocalEtcdMemberStatus = &etcdMemberStatus
+		default:
+			c.config.Log.Error(fmt.Errorf("unexpected health decision input"), "can't provide health decision input, considering node healthy", "input", result.Need.Type)
+			return true
 		}
+		result = decision.Evaluate(c.decisionConfig, c.decisionState, *obs)
+	}
 
-		if apiErrorsResponses > 0 {
-			c.config.Log.Info("Peer can't access the api-server")
-			apiErrorsResponsesSum += apiErrorsResponses
-			// TODO: consider using [m|n]hc.spec.maxUnhealthy instead of 50%
-			if apiErrorsResponsesSum > nrAllPeers/2 { // already reached more than 50% of the peers and all of them returned api error
-				// assuming this is a control plane failure as others can't access api-server as well
-				c.config.Log.Info("More than 50% of the nodes couldn't access the api-server, assuming this is a control plane failure")
-				return peers.Response{IsHealthy: true, Reason: peers.HealthyBecauseMostPeersCantAccessAPIServer}
-			}
+	if obs.ApiError != "" {
+		c.config.Log.Info("health decision", "healthy", result.Verdict.Healthy, "reason", result.Verdict.Reason, "trace", result.Trace)
+	}
+	if c.recorder != nil && (obs.ApiError != "" || c.decisionState.ErrorCount > 0) {
+		// record the recovery as well, it resets the state
+		if err := c.recorder.Record(c.decisionState, *obs, result.Verdict); err != nil {
+			c.config.Log.Error(err, "failed to record health decision")
 		}
-
 	}
+	c.mutex.Lock()
+	c.decisionState = result.State
+	c.mutex.Unlock()
 
-	//we asked all peers
-	now := time.Now()
-	// MaxTimeForNoPeersResponse check prevents the node from being considered unhealthy in case of short network outages
-	if now.After(c.timeOfLastPeerResponse.Add(c.config.MaxTimeForNoPeersResponse)) {
-		c.config.Log.Error(fmt.Errorf("failed health check"), "Failed to get health status peers. Assuming unhealthy")
-		return peers.Response{IsHealthy: false, Reason: peers.UnHealthyBecauseNodeIsIsolated}
-	} else {
-		c.config.Log.Info("Ignoring no peers response error, time is below threshold for no peers response", "time without peers response (seconds)", now.Sub(c.timeOfLastPeerResponse).Seconds(), "threshold (seconds)", c.config.MaxTimeForNoPeersResponse.Seconds())
-		return peers.Response{IsHealthy: true, Reason: peers.HealthyBecauseNoPeersResponseNotReachedTimeout}
+	if result.Verdict.Reason == decision.ReasonSelfFencingPostponed {
+		c.config.Log.Info("postponing self fencing because rebooting would break etcd quorum")
+		c.recordEtcdQuorumEvent()
 	}
+	return result.Verdict.Healthy
+}
 
+func (c *ApiConnectivityCheck) isEndpointAccessLost() bool {
+	if c.controlPlaneManager != nil {
+		return c.controlPlaneManager.IsEndpointAccessLost()
+	}
+	return c.config.EndpointChecker != nil && c.config.EndpointChecker.IsAccessLost(context.Background())
 }
 
-func (c *ApiConnectivityCheck) canOtherControlPlanesBeReached() bool {
+// getControlPlanePeersStatus asks all control plane peers for their health status. Any response is an indication of
+// communication with a peer.
+func (c *ApiConnectivityCheck) getControlPlanePeersStatus() controlplane.ControlPlanePeersStatus {
 	peersToAsk := c.config.Peers.GetPeersAddresses(peers.ControlPlane)
 	numOfControlPlanePeers := len(peersToAsk)
 	if numOfControlPlanePeers == 0 {
 		c.config.Log.Info("Peers list is empty and / or couldn't be retrieved from server, other control planes can't be reached")
-		return false
+		return controlplane.ControlPlanePeersStatus{}
 	}
 
 	chosenPeersIPs := c.popPeerIPs(&peersToAsk, numOfControlPlanePeers)
-	healthyResponses, unhealthyResponses, apiErrorsResponses, _ := c.getHealthStatusFromPeers(chosenPeersIPs)
+	responsesChan := make(chan peerResponse, numOfControlPlanePeers)
+	for _, address := range chosenPeersIPs {
+		go c.getHealthStatusFromPeer(address, responsesChan)
+	}
 
-	// Any response is an indication of communication with a peer
-	return (healthyResponses + unhealthyResponses + apiErrorsResponses) > 0
+	status := controlplane.ControlPlanePeersStatus{Peers: numOfControlPlanePeers}
+	for i := 0; i < numOfControlPlanePeers; i++ {
+		response := <-responsesChan
+		if response.status == selfNodeRemediation.RequestFailed {
+			continue
+		}
+		status.Responses++
+		if response.etcdMemberStatus == selfNodeRemediation.EtcdMemberHealthy {
+			status.HealthyEtcdMembers++
+		}
+	}
+	return status
+}
+
+func (c *ApiConnectivityCheck) recordEtcdQuorumEvent() {
+	if c.config.Recorder == nil {
+		return
+	}
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: c.config.MyNodeName}}
+	events.WarningEvent(c.config.Recorder, node, eventReasonSelfFencingPostponed, "Self fencing was postponed because rebooting the node would break etcd quorum")
 }
 
 func (c *ApiConnectivityCheck) popPeerIPs(peersIPs *[]corev1.PodIP, count int) []corev1.PodIP {
@@ -226,9 +327,9 @@ func (c *ApiConnectivityCheck) popPeerIPs(peersIPs *[]corev1.PodIP, count int) [
 	return selectedIPs
 }
 
-func (c *ApiConnectivityCheck) getHealthStatusFromPeers(addresses []corev1.PodIP) (int, int, int, int) {
+func (c *ApiConnectivityCheck) getHealthStatusFromPeers(addresses []corev1.PodIP) decision.PeerBatch {
 	nrAddresses := len(addresses)
-	responsesChan := make(chan selfNodeRemediation.HealthCheckResponseCode, nrAddresses)
+	responsesChan := make(chan peerResponse, nrAddresses)
 
 	for _, address := range addresses {
 		go c.getHealthStatusFromPeer(address, responsesChan)
@@ -238,22 +339,24 @@ func (c *ApiConnectivityCheck) getHealthStatusFromPeers(addresses []corev1.PodIP
 }
 
 // getHealthStatusFromPeer issues a GET request to the specified IP and returns the result from the peer into the given channel
-func (c *ApiConnectivityCheck) getHealthStatusFromPeer(endpointIp corev1.PodIP, results chan<- selfNodeRemediation.HealthCheckResponseCode) {
+func (c *ApiConnectivityCheck) getHealthStatusFromPeer(endpointIp corev1.PodIP, results chan<- peerResponse) {
 
 	logger := c.config.Log.WithValues("IP", endpointIp.IP)
 	logger.Info("getting health status from peer")
 
-	if err := c.initClientCreds(); err != nil {
-		logger.Error(err, "failed to init client credentials")
-		results <- selfNodeRemediation.RequestFailed
+	// the credentials are reloaded when the certificates were rotated
+	clientCreds, err := c.clientCreds.Get()
+	if err != nil {
+		logger.Error(err, "failed to get client credentials")
+		results <- peerResponse{status: selfNodeRemediation.RequestFailed}
 		return
 	}
 
 	// TODO does this work with IPv6?
-	phClient, err := peerhealth.NewClient(fmt.Sprintf("%v:%v", endpointIp.IP, c.config.PeerHealthPort), c.config.PeerDialTimeout, c.config.Log.WithName("peerhealth client"), c.clientCreds)
+	phClient, err := peerhealth.NewClient(fmt.Sprintf("%v:%v", endpointIp.IP, c.config.PeerHealthPort), c.config.PeerDialTimeout, c.config.Log.WithName("peerhealth client"), clientCreds)
 	if err != nil {
 		logger.Error(err, "failed to init grpc client")
-		results <- selfNodeRemediation.RequestFailed
+		results <- peerResponse{status: selfNodeRemediation.RequestFailed}
 		return
 	}
 	defer phClient.Close()
@@ -265,56 +368,55 @@ func (c *ApiConnectivityCheck) getHealthStatusFromPeer(endpointIp corev1.PodIP,
 		NodeName:    c.config.MyNodeName,
 		MachineName: c.config.MyMachineName,
 	})
+	if peerhealth.IsBusy(err) {
+		// the peer can be reached, but it's answering too many requests, so it can't tell whether this node is healthy
+		logger.Info("peer is busy")
+		c.updateLastPeerResponse()
+		results <- peerResponse{status: selfNodeRemediation.PeerBusy}
+		return
+	}
 	if err != nil {
 		logger.Error(err, "failed to read health response from peer")
-		results <- selfNodeRemediation.RequestFailed
+		results <- peerResponse{status: selfNodeRemediation.RequestFailed}
 		return
 	}
 
-	logger.Info("got response from peer", "status", resp.Status)
+	logger.Info("got response from peer", "status", resp.Status, "etcd member status", resp.EtcdMemberStatus)
+	c.updateLastPeerResponse()
 
-	results <- selfNodeRemediation.HealthCheckResponseCode(resp.Status)
+	results <- peerResponse{
+		status:           selfNodeRemediation.HealthCheckResponseCode(resp.Status),
+		etcdMemberStatus: selfNodeRemediation.EtcdMemberStatusCode(resp.EtcdMemberStatus),
+	}
 	return
 }
 
-func (c *ApiConnectivityCheck) initClientCreds() error {
+func (c *ApiConnectivityCheck) updateLastPeerResponse() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	if c.clientCreds == nil {
-		clientCreds, err := certificates.GetClientCredentialsFromCerts(c.config.CertReader)
-		if err != nil {
-			return err
-		}
-		c.clientCreds = clientCreds
-	}
-	return nil
+	c.lastPeerResponse = time.Now()
 }
 
-func (c *ApiConnectivityCheck) sumPeersResponses(nodesBatchCount int, responsesChan chan selfNodeRemediation.HealthCheckResponseCode) (int, int, int, int) {
-	healthyResponses := 0
-	unhealthyResponses := 0
-	apiErrorsResponses := 0
-	noResponse := 0
-
+func (c *ApiConnectivityCheck) sumPeersResponses(nodesBatchCount int, responsesChan chan peerResponse) decision.PeerBatch {
+	batch := decision.PeerBatch{Size: nodesBatchCount}
 	for i := 0; i < nodesBatchCount; i++ {
-		response := <-responsesChan
+		response := (<-responsesChan).status
 		switch response {
 		case selfNodeRemediation.Unhealthy:
-			unhealthyResponses++
-			break
+			batch.Unhealthy++
 		case selfNodeRemediation.Healthy:
-			healthyResponses++
-			break
+			batch.Healthy++
 		case selfNodeRemediation.ApiError:
-			apiErrorsResponses++
-			break
+			batch.ApiErrors++
+		case selfNodeRemediation.PeerBusy:
+			batch.Busy++
 		case selfNodeRemediation.RequestFailed:
-			noResponse++
+			batch.NoResponse++
 		default:
 			c.config.Log.Error(fmt.Errorf("unexpected response"),
 				"Received unexpected value from peer while trying to retrieve health status", "value", response)
 		}
 	}
 
-	return healthyResponses, unhealthyResponses, apiErrorsResponses, noResponse
+	return batch
 }
@@ -0,0 +1,58 @@
+package apicheck
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	selfNodeRemediation "github.com/medik8s/self-node-remediation/api"
+	"github.com/medik8s/self-node-remediation/pkg/decision"
+	"github.com/medik8s/self-node-remediation/pkg/peers"
+)
+
+var _ = Describe("Peer responses", func() {
+
+	var c *ApiConnectivityCheck
+
+	BeforeEach(func() {
+		c = &ApiConnectivityCheck{config: &ApiConnectivityCheckConfig{Log: ctrl.Log.WithName("api-check")}}
+	})
+
+	sum := func(statuses ...selfNodeRemediation.HealthCheckResponseCode) decision.PeerBatch {
+		responsesChan := make(chan peerResponse, len(statuses))
+		for _, status := range statuses {
+			responsesChan <- peerResponse{status: status}
+		}
+		return c.sumPeersResponses(len(statuses), responsesChan)
+	}
+
+	It("should sum up the responses of a batch", func() {
+		Expect(sum(selfNodeRemediation.Healthy, selfNodeRemediation.Unhealthy, selfNodeRemediation.ApiError,
+			selfNodeRemediation.PeerBusy, selfNodeRemediation.RequestFailed)).To(Equal(decision.PeerBatch{
+			Size: 5, Healthy: 1, Unhealthy: 1, ApiErrors: 1, Busy: 1, NoResponse: 1,
+		}))
+	})
+
+	It("should not consider the node isolated when peers are busy", func() {
+		now := time.Now()
+		cfg := decision.Config{MaxErrorsThreshold: 1, MaxTimeForNoPeersResponse: 30 * time.Second}
+		state := decision.State{TimeOfLastPeerResponse: now.Add(-time.Minute)}
+		obs := decision.Observation{Time: now, ApiError: "timeout", WorkerPeers: &decision.WorkerPeers{
+			Count:   3,
+			Batches: []decision.PeerBatch{sum(selfNodeRemediation.PeerBusy, selfNodeRemediation.PeerBusy, selfNodeRemediation.RequestFailed)},
+		}}
+
+		result := decision.Evaluate(cfg, state, obs)
+		Expect(result.Need).To(BeNil())
+		Expect(result.Verdict).To(Equal(decision.Verdict{Healthy: true, Reason: string(peers.HealthyBecauseNoPeersResponseNotReachedTimeout)}))
+		Expect(result.State.TimeOfLastPeerResponse).To(Equal(now))
+
+		By("considering the node isolated when the peers can't be reached")
+		obs.WorkerPeers.Batches = []decision.PeerBatch{sum(selfNodeRemediation.RequestFailed, selfNodeRemediation.RequestFailed, selfNodeRemediation.RequestFailed)}
+		result = decision.Evaluate(cfg, state, obs)
+		Expect(result.Verdict).To(Equal(decision.Verdict{Healthy: false, Reason: string(peers.UnHealthyBecauseNodeIsIsolated)}))
+	})
+})
@@ -0,0 +1,13 @@
+package apicheck
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestApiCheck(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ApiCheck Suite")
+}
@@ -0,0 +1,33 @@
+package certificates
+
+import (
+	"context"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AgentServiceAccountName is the name of the service account of the agents
+	AgentServiceAccountName = "self-node-remediation-agent"
+	// agentAccessName is the name of the Role and RoleBinding which allowed the agents to read the secrets with the
+	// certificates of the nodes
+	agentAccessName = "self-node-remediation-agent-peer-certificates"
+)
+
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=delete
+
+// RemoveAgentAccess removes the Role which allowed the agents to read the secrets with the certificates of all nodes,
+// before they requested their certificates. The agents share a service account, so every agent could read the keys of
+// all nodes.
+func RemoveAgentAccess(ctx context.Context, c client.Client, namespace string) error {
+	objectMeta := metav1.ObjectMeta{Namespace: namespace, Name: agentAccessName}
+	for _, obj := range []client.Object{&rbacv1.RoleBinding{ObjectMeta: objectMeta}, &rbacv1.Role{ObjectMeta: objectMeta}} {
+		if err := c.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
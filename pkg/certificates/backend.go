@@ -0,0 +1,129 @@
+package certificates
+
+import (
+	"context"
+	"crypto/x509"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+	"github.com/medik8s/self-node-remediation/pkg/apply"
+)
+
+const (
+	// BackendEnvVar is the env var of the agents which holds the peer certificates backend
+	BackendEnvVar = "PEER_CERTIFICATES_BACKEND"
+	// ExternalCertsMountPath is the path the secret of the External backend is mounted to in the agents
+	ExternalCertsMountPath = "/etc/self-node-remediation/peer-certificates"
+
+	// adminSecretName is the name of the secret with the certificate of admin tools, and of the cert-manager Certificate
+	// it's issued by
+	adminSecretName = "self-node-remediation-admin-tls"
+)
+
+// CertIssuer issues the certificates of the agents, it's run by the operator
+type CertIssuer interface {
+	// Sync maintains the CA bundle of the agents, and the certificate of admin tools. It returns when it needs to run
+	// again, 0 if it doesn't need to run periodically.
+	Sync(ctx context.Context) (time.Duration, error)
+	// SignNodeCert issues the certificate of the given node for the given validated CertificateSigningRequest. It
+	// returns nil while the certificate is being issued.
+	SignNodeCert(ctx context.Context, csr *certificatesv1.CertificateSigningRequest, request *x509.CertificateRequest, nodeName string) ([]byte, error)
+}
+
+var _ CertIssuer = &SecretCertStorage{}
+var _ CertIssuer = &CertManagerIssuer{}
+
+// GetBackend returns the configured backend, SelfSigned by default
+func GetBackend(config *v1alpha1.PeerCertificates) v1alpha1.PeerCertificatesBackend {
+	if config == nil || config.Backend == "" {
+		return v1alpha1.SelfSignedPeerCertificatesBackend
+	}
+	return config.Backend
+}
+
+func getKeyAlgorithm(config *v1alpha1.PeerCertificates) v1alpha1.PeerCertificatesKeyAlgorithm {
+	if config == nil || config.KeyAlgorithm == "" {
+		return v1alpha1.RSAPeerCertificatesKeyAlgorithm
+	}
+	return config.KeyAlgorithm
+}
+
+// NewCertIssuer returns the issuer of the configured backend, nil for the External backend, which doesn't issue
+// certificates
+func NewCertIssuer(c client.Client, log logr.Logger, namespace string, config *v1alpha1.PeerCertificates) CertIssuer {
+	switch GetBackend(config) {
+	case v1alpha1.CertManagerPeerCertificatesBackend:
+		return NewCertManagerIssuer(c, log, namespace, *config.IssuerRef, getKeyAlgorithm(config))
+	case v1alpha1.ExternalPeerCertificatesBackend:
+		return nil
+	default:
+		s := NewSecretCertStorage(c, log, namespace)
+		s.keyAlgorithm = getKeyAlgorithm(config)
+		return s
+	}
+}
+
+// NewNodeCertReader returns the reader of the certificates of the agent of the given node. Unless they are provided
+// externally, the agent requests its certificate with a CertificateSigningRequest, so the returned CSRCertStorage needs
+// to be started.
+func NewNodeCertReader(c client.Client, reader client.Reader, log logr.Logger, namespace string, nodeName string, backend v1alpha1.PeerCertificatesBackend) CertStorageReader {
+	if backend == v1alpha1.ExternalPeerCertificatesBackend {
+		return NewFileCertStorage(ExternalCertsMountPath)
+	}
+	return NewCSRCertStorage(c, reader, log, namespace, nodeName)
+}
+
+// NewAdminCertReader returns the reader of an admin certificate, for tools like snrctl. It only needs access to the
+// admin secret, the CA keys are only read by the operator.
+func NewAdminCertReader(reader client.Reader, namespace string, config *v1alpha1.PeerCertificates) CertStorageReader {
+	if GetBackend(config) == v1alpha1.ExternalPeerCertificatesBackend {
+		return NewTLSSecretCertStorage(reader, namespace, config.ExternalSecretName)
+	}
+	return NewTLSSecretCertStorage(reader, namespace, adminSecretName)
+}
+
+// Sync creates the CA if it doesn't exist yet, rotates it, and issues the certificate of admin tools. The CA bundle
+// of the agents is updated to the CAs of all versions, and the CA of the active version.
+func (s *SecretCertStorage) Sync(ctx context.Context) (time.Duration, error) {
+	next, err := s.Rotate(time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if err := s.SyncAdminCert(); err != nil {
+		return 0, err
+	}
+	if err := s.syncCABundle(ctx); err != nil {
+		return 0, err
+	}
+	if err := s.removeNodeSecrets(ctx); err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+// syncCABundle creates or updates the ConfigMap with the CAs the agents trust, and the CA their certificates need
+// to be issued by
+func syncCABundle(ctx context.Context, c client.Client, namespace string, caPem, activeCAPem []byte) error {
+	bundle := &v1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: CABundleName},
+		Data:       map[string]string{caCertKey: string(caPem)},
+	}
+	if len(activeCAPem) > 0 {
+		bundle.Data[activeCACertKey] = string(activeCAPem)
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(bundle)
+	if err != nil {
+		return err
+	}
+	return apply.ApplyObject(ctx, c, &unstructured.Unstructured{Object: content})
+}
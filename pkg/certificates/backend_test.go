@@ -0,0 +1,178 @@
+package certificates
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+)
+
+var _ = Describe("Backends", func() {
+
+	var caPem, certPem, keyPem []byte
+
+	BeforeEach(func() {
+		ca, caKey, err := CreateCA(v1alpha1.ECDSAPeerCertificatesKeyAlgorithm)
+		Expect(err).ToNot(HaveOccurred())
+		cert, key, err := CreateNodeCert(ca, caKey, "node")
+		Expect(err).ToNot(HaveOccurred())
+		caPem, certPem, keyPem = ca.Bytes(), cert.Bytes(), key.Bytes()
+	})
+
+	It("should read certificates from TLS secrets", func() {
+		secret := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "tls-test"},
+			Type:       v1.SecretTypeTLS,
+			Data:       map[string][]byte{caCertKey: caPem, v1.TLSCertKey: certPem, v1.TLSPrivateKeyKey: keyPem},
+		}
+		Expect(k8sClient.Create(context.Background(), secret)).To(Succeed())
+
+		storage := NewTLSSecretCertStorage(k8sClient, "default", "tls-test")
+		Eventually(func(g Gomega) {
+			readCaPem, readCertPem, readKeyPem, err := storage.GetCerts()
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(readCaPem.Bytes()).To(Equal(caPem))
+			g.Expect(readCertPem.Bytes()).To(Equal(certPem))
+			g.Expect(readKeyPem.Bytes()).To(Equal(keyPem))
+		}).Should(Succeed())
+		_, err := GetServerCredentialsFromCerts(storage)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should read certificates from mounted TLS secrets", func() {
+		dir := GinkgoT().TempDir()
+		storage := NewFileCertStorage(dir)
+		_, _, _, err := storage.GetCerts()
+		Expect(err).To(HaveOccurred())
+
+		for name, data := range map[string][]byte{caCertKey: caPem, v1.TLSCertKey: certPem, v1.TLSPrivateKeyKey: keyPem} {
+			Expect(os.WriteFile(filepath.Join(dir, name), data, 0600)).To(Succeed())
+		}
+		readCaPem, readCertPem, readKeyPem, err := storage.GetCerts()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(readCaPem.Bytes()).To(Equal(caPem))
+		Expect(readCertPem.Bytes()).To(Equal(certPem))
+		Expect(readKeyPem.Bytes()).To(Equal(keyPem))
+		nodeName, isNodeCert, err := GetNodeName(storage)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isNodeCert).To(BeTrue())
+		Expect(nodeName).To(Equal("node"))
+	})
+
+	It("should sign node certificates with cert-manager CertificateRequests", func() {
+		// envtest doesn't have the cert-manager CRDs
+		c := fake.NewClientBuilder().Build()
+		config := &v1alpha1.PeerCertificates{
+			Backend:      v1alpha1.CertManagerPeerCertificatesBackend,
+			KeyAlgorithm: v1alpha1.ECDSAPeerCertificatesKeyAlgorithm,
+			IssuerRef:    &v1alpha1.CertManagerIssuerReference{Name: "peer-ca"},
+		}
+		issuer := NewCertIssuer(c, ctrl.Log.WithName("TestCertManager"), "default", config)
+		Expect(issuer).To(BeAssignableToTypeOf(&CertManagerIssuer{}))
+
+		By("creating the admin Certificate, and removing the Certificates of the nodes")
+		nodeCertificate := &unstructured.Unstructured{}
+		nodeCertificate.SetGroupVersionKind(certificateGVK)
+		nodeCertificate.SetNamespace("default")
+		nodeCertificate.SetName(certManagerNodePrefix + "node")
+		Expect(c.Create(context.Background(), nodeCertificate)).To(Succeed())
+		next, err := issuer.Sync(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(next).To(Equal(newVersionCheckInterval), "admin certificate isn't issued yet")
+		Expect(c.Get(context.Background(), client.ObjectKeyFromObject(nodeCertificate), nodeCertificate)).ToNot(Succeed())
+
+		admin := &unstructured.Unstructured{}
+		admin.SetGroupVersionKind(certificateGVK)
+		Expect(c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: adminSecretName}, admin)).To(Succeed())
+		nestedString := func(obj *unstructured.Unstructured, fields ...string) string {
+			value, _, err := unstructured.NestedString(obj.Object, fields...)
+			ExpectWithOffset(1, err).ToNot(HaveOccurred())
+			return value
+		}
+		Expect(nestedString(admin, "spec", "commonName")).To(Equal(adminCommonName))
+		Expect(nestedString(admin, "spec", "privateKey", "algorithm")).To(Equal("ECDSA"))
+		Expect(nestedString(admin, "spec", "issuerRef", "kind")).To(Equal("Issuer"))
+		organizationalUnits, _, err := unstructured.NestedStringSlice(admin.Object, "spec", "subject", "organizationalUnits")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(organizationalUnits).To(Equal([]string{adminsOrganizationalUnit}))
+
+		By("syncing the CA bundle with the CA of the admin certificate")
+		Expect(c.Create(context.Background(), &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: adminSecretName},
+			Data:       map[string][]byte{caCertKey: caPem},
+		})).To(Succeed())
+		next, err = issuer.Sync(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(next).To(Equal(caBundleCheckInterval))
+		bundle := &v1.ConfigMap{}
+		Expect(c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: CABundleName}, bundle)).To(Succeed())
+		Expect(bundle.Data).To(Equal(map[string]string{caCertKey: string(caPem)}))
+
+		By("creating a CertificateRequest, and returning its certificate when it's issued")
+		csr := &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "csr"},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Request: []byte("request"),
+				Usages:  []certificatesv1.KeyUsage{certificatesv1.UsageDigitalSignature},
+			},
+		}
+		certPem, err := issuer.SignNodeCert(context.Background(), csr, nil, "node")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(certPem).To(BeNil())
+		certificateRequest := &unstructured.Unstructured{}
+		certificateRequest.SetGroupVersionKind(certificateRequestGVK)
+		Expect(c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "csr"}, certificateRequest)).To(Succeed())
+		Expect(nestedString(certificateRequest, "spec", "request")).To(Equal(base64.StdEncoding.EncodeToString([]byte("request"))))
+		Expect(nestedString(certificateRequest, "spec", "issuerRef", "name")).To(Equal("peer-ca"))
+
+		Expect(unstructured.SetNestedSlice(certificateRequest.Object, []interface{}{
+			map[string]interface{}{"type": "Ready", "status": "False", "reason": "Pending"},
+		}, "status", "conditions")).To(Succeed())
+		Expect(c.Update(context.Background(), certificateRequest)).To(Succeed())
+		certPem, err = issuer.SignNodeCert(context.Background(), csr, nil, "node")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(certPem).To(BeNil())
+
+		Expect(unstructured.SetNestedSlice(certificateRequest.Object, []interface{}{
+			map[string]interface{}{"type": "Ready", "status": "True", "reason": "Issued"},
+		}, "status", "conditions")).To(Succeed())
+		Expect(unstructured.SetNestedField(certificateRequest.Object, base64.StdEncoding.EncodeToString([]byte("cert")), "status", "certificate")).To(Succeed())
+		Expect(c.Update(context.Background(), certificateRequest)).To(Succeed())
+		certPem, err = issuer.SignNodeCert(context.Background(), csr, nil, "node")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(certPem).To(Equal([]byte("cert")))
+		Expect(c.Get(context.Background(), client.ObjectKeyFromObject(certificateRequest), certificateRequest)).ToNot(Succeed(), "CertificateRequest should be removed")
+	})
+
+	It("should not issue external certificates", func() {
+		config := &v1alpha1.PeerCertificates{Backend: v1alpha1.ExternalPeerCertificatesBackend, ExternalSecretName: "external"}
+		Expect(NewCertIssuer(k8sClient, ctrl.Log, "default", config)).To(BeNil())
+		Expect(NewNodeCertReader(k8sClient, k8sClient, ctrl.Log, "default", "node", config.Backend)).To(Equal(NewFileCertStorage(ExternalCertsMountPath)))
+		Expect(NewAdminCertReader(k8sClient, "default", config)).To(Equal(NewTLSSecretCertStorage(k8sClient, "default", "external")))
+	})
+
+	It("should remove the access of the agents to the secrets of the nodes", func() {
+		objectMeta := metav1.ObjectMeta{Namespace: "default", Name: agentAccessName}
+		c := fake.NewClientBuilder().WithObjects(&rbacv1.Role{ObjectMeta: objectMeta}, &rbacv1.RoleBinding{ObjectMeta: objectMeta}).Build()
+		Expect(RemoveAgentAccess(context.Background(), c, "default")).To(Succeed())
+		Expect(c.Get(context.Background(), client.ObjectKeyFromObject(&rbacv1.Role{ObjectMeta: objectMeta}), &rbacv1.Role{})).ToNot(Succeed())
+		Expect(c.Get(context.Background(), client.ObjectKeyFromObject(&rbacv1.Role{ObjectMeta: objectMeta}), &rbacv1.RoleBinding{})).ToNot(Succeed())
+
+		By("succeeding when they were removed already")
+		Expect(RemoveAgentAccess(context.Background(), c, "default")).To(Succeed())
+	})
+})
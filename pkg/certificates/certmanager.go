@@ -0,0 +1,240 @@
+package certificates
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+	"github.com/medik8s/self-node-remediation/pkg/apply"
+)
+
+const (
+	// certManagerNodePrefix is the prefix of the names of the Certificates which were created for the nodes, before the
+	// agents requested their certificates
+	certManagerNodePrefix = "self-node-remediation-peer-tls-"
+	// caBundleCheckInterval is the time between two syncs of the CA bundle with the CA of the admin certificate
+	caBundleCheckInterval = 10 * time.Minute
+)
+
+var (
+	certificateGVK        = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+	certificateRequestGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "CertificateRequest"}
+)
+
+// CertManagerIssuer signs the CertificateSigningRequests of the agents with cert-manager CertificateRequests, and
+// creates a cert-manager Certificate for admin tools. The CA bundle of the agents is the CA of the admin certificate.
+type CertManagerIssuer struct {
+	client.Client
+	log          logr.Logger
+	namespace    string
+	issuerRef    v1alpha1.CertManagerIssuerReference
+	keyAlgorithm v1alpha1.PeerCertificatesKeyAlgorithm
+}
+
+//+kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=cert-manager.io,resources=certificaterequests,verbs=get;list;watch;create;delete
+
+func NewCertManagerIssuer(c client.Client, log logr.Logger, namespace string, issuerRef v1alpha1.CertManagerIssuerReference, keyAlgorithm v1alpha1.PeerCertificatesKeyAlgorithm) *CertManagerIssuer {
+	return &CertManagerIssuer{
+		Client:       c,
+		log:          log,
+		namespace:    namespace,
+		issuerRef:    issuerRef,
+		keyAlgorithm: keyAlgorithm,
+	}
+}
+
+// Sync creates or updates the Certificate of admin tools, and updates the CA bundle of the agents to its CA.
+// cert-manager renews the certificate, the CA bundle is checked periodically.
+func (i *CertManagerIssuer) Sync(ctx context.Context) (time.Duration, error) {
+	if err := apply.ApplyObject(ctx, i.Client, i.newCertificate(adminSecretName, adminCommonName, adminsOrganizationalUnit)); err != nil {
+		i.log.Error(err, "failed to sync admin Certificate")
+		return 0, err
+	}
+	if err := i.removeNodeCertificates(ctx); err != nil {
+		i.log.Error(err, "failed to remove node Certificates")
+		return 0, err
+	}
+
+	secret := &v1.Secret{}
+	if err := i.Get(ctx, client.ObjectKey{Namespace: i.namespace, Name: adminSecretName}, secret); err != nil {
+		if errors.IsNotFound(err) {
+			// not issued yet
+			return newVersionCheckInterval, nil
+		}
+		return 0, err
+	}
+	caPem := secret.Data[caCertKey]
+	if len(caPem) == 0 {
+		return newVersionCheckInterval, nil
+	}
+	if err := syncCABundle(ctx, i.Client, i.namespace, caPem, nil); err != nil {
+		i.log.Error(err, "failed to sync CA bundle")
+		return 0, err
+	}
+	return caBundleCheckInterval, nil
+}
+
+// SignNodeCert creates a CertificateRequest with the certificate request of the given CertificateSigningRequest, and
+// returns the certificate when cert-manager issued it
+func (i *CertManagerIssuer) SignNodeCert(ctx context.Context, csr *certificatesv1.CertificateSigningRequest, _ *x509.CertificateRequest, nodeName string) ([]byte, error) {
+	certificateRequest := &unstructured.Unstructured{}
+	certificateRequest.SetGroupVersionKind(certificateRequestGVK)
+	key := client.ObjectKey{Namespace: i.namespace, Name: csr.Name}
+	if err := i.Get(ctx, key, certificateRequest); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+		i.log.Info("Requesting node certs", "node", nodeName, "name", csr.Name)
+		return nil, i.Create(ctx, i.newCertificateRequest(csr))
+	}
+
+	for _, condition := range getConditions(certificateRequest) {
+		if condition["type"] != "Ready" {
+			continue
+		}
+		if condition["status"] == "True" {
+			break
+		}
+		if reason := condition["reason"]; reason == "Failed" || reason == "Denied" {
+			// a new CertificateRequest is created by the next try
+			if err := i.Delete(ctx, certificateRequest); err != nil && !errors.IsNotFound(err) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("CertificateRequest %s wasn't issued: %s", csr.Name, condition["message"])
+		}
+		return nil, nil
+	}
+	encodedCert, _, err := unstructured.NestedString(certificateRequest.Object, "status", "certificate")
+	if err != nil || encodedCert == "" {
+		return nil, err
+	}
+	certPem, err := base64.StdEncoding.DecodeString(encodedCert)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.Delete(ctx, certificateRequest); err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	return certPem, nil
+}
+
+func (i *CertManagerIssuer) newCertificateRequest(csr *certificatesv1.CertificateSigningRequest) *unstructured.Unstructured {
+	usages := []interface{}{}
+	for _, usage := range csr.Spec.Usages {
+		usages = append(usages, string(usage))
+	}
+	certificateRequest := &unstructured.Unstructured{}
+	certificateRequest.SetGroupVersionKind(certificateRequestGVK)
+	certificateRequest.SetNamespace(i.namespace)
+	certificateRequest.SetName(csr.Name)
+	certificateRequest.Object["spec"] = map[string]interface{}{
+		"request":   base64.StdEncoding.EncodeToString(csr.Spec.Request),
+		"isCA":      false,
+		"usages":    usages,
+		"issuerRef": i.issuerRefObject(),
+	}
+	return certificateRequest
+}
+
+func getConditions(obj *unstructured.Unstructured) []map[string]string {
+	list, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	var conditions []map[string]string
+	for _, item := range list {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		condition := map[string]string{}
+		for name, value := range fields {
+			if s, ok := value.(string); ok {
+				condition[name] = s
+			}
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions
+}
+
+// removeNodeCertificates removes the Certificates of the nodes and their secrets, which held the keys of the nodes
+// before the agents requested their certificates
+func (i *CertManagerIssuer) removeNodeCertificates(ctx context.Context) error {
+	certificates := &unstructured.UnstructuredList{}
+	certificates.SetGroupVersionKind(certificateGVK.GroupVersion().WithKind("CertificateList"))
+	if err := i.List(ctx, certificates, client.InNamespace(i.namespace)); err != nil {
+		return err
+	}
+	for j := range certificates.Items {
+		name := certificates.Items[j].GetName()
+		if !strings.HasPrefix(name, certManagerNodePrefix) {
+			continue
+		}
+		i.log.Info("Removing node Certificate", "name", name)
+		secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: i.namespace, Name: name}}
+		for _, obj := range []client.Object{&certificates.Items[j], secret} {
+			if err := i.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (i *CertManagerIssuer) newCertificate(name, commonName, organizationalUnit string) *unstructured.Unstructured {
+	keySize, usages := int64(2048), []interface{}{"digital signature", "key encipherment", "server auth", "client auth"}
+	if i.keyAlgorithm == v1alpha1.ECDSAPeerCertificatesKeyAlgorithm {
+		// key encipherment is only used by RSA key exchanges
+		keySize, usages = 256, []interface{}{"digital signature", "server auth", "client auth"}
+	}
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(certificateGVK)
+	certificate.SetNamespace(i.namespace)
+	certificate.SetName(name)
+	certificate.Object["spec"] = map[string]interface{}{
+		"secretName": name,
+		"commonName": commonName,
+		"subject": map[string]interface{}{
+			"organizations":       []interface{}{"medik8s"},
+			"organizationalUnits": []interface{}{organizationalUnit},
+		},
+		// clients verify the fixed IP instead of the real address of their peers
+		"ipAddresses": []interface{}{fixedCertIP.String()},
+		"usages":      usages,
+		"privateKey": map[string]interface{}{
+			"algorithm":      string(i.keyAlgorithm),
+			"size":           keySize,
+			"rotationPolicy": "Always",
+		},
+		"issuerRef": i.issuerRefObject(),
+	}
+	return certificate
+}
+
+func (i *CertManagerIssuer) issuerRefObject() map[string]interface{} {
+	issuerKind, issuerGroup := i.issuerRef.Kind, i.issuerRef.Group
+	if issuerKind == "" {
+		issuerKind = "Issuer"
+	}
+	if issuerGroup == "" {
+		issuerGroup = certificateGVK.Group
+	}
+	return map[string]interface{}{
+		"name":  i.issuerRef.Name,
+		"kind":  issuerKind,
+		"group": issuerGroup,
+	}
+}
@@ -2,14 +2,20 @@ package certificates
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"fmt"
 	"math/big"
 	"net"
 	"time"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
 )
 
 // this used in the server cert, and as servername override in the client, so the IP check always succeeds no matter
@@ -17,14 +23,29 @@ import (
 // TODO reconsider a better to deal with the IP check...?
 var fixedCertIP = net.IPv4(192, 0, 2, 1)
 
-func createCertTemplate(isCa bool) *x509.Certificate {
+const (
+	// certValidity is how long new certificates are valid, they are renewed before they expire
+	certValidity = 365 * 24 * time.Hour
+
+	// the kind of identity of a certificate is its organizational unit
+	nodesOrganizationalUnit  = "nodes"
+	adminsOrganizationalUnit = "admins"
+	adminCommonName          = "self-node-remediation-admin"
+)
+
+func createCertTemplate(isCa bool) (*x509.Certificate, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
 	cert := &x509.Certificate{
-		SerialNumber: big.NewInt(12345), // TODO randomize?
+		SerialNumber: serialNumber,
 		Subject: pkix.Name{
 			Organization: []string{"medik8s"},
 		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(10, 0, 0),
+		NotBefore:             now,
+		NotAfter:              now.Add(certValidity),
 		IsCA:                  isCa,
 		BasicConstraintsValid: isCa,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
@@ -35,18 +56,30 @@ func createCertTemplate(isCa bool) *x509.Certificate {
 	} else {
 		cert.IPAddresses = []net.IP{fixedCertIP}
 	}
-	return cert
+	return cert, nil
 }
 
-func createPrivKey() (*rsa.PrivateKey, error) {
+func createCaPrivKey(keyAlgorithm v1alpha1.PeerCertificatesKeyAlgorithm) (crypto.Signer, error) {
+	if keyAlgorithm == v1alpha1.ECDSAPeerCertificatesKeyAlgorithm {
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
 	return rsa.GenerateKey(rand.Reader, 4096)
 }
 
-func selfSign(cert *x509.Certificate, privKey *rsa.PrivateKey) ([]byte, error) {
-	return sign(cert, cert, &privKey.PublicKey, privKey)
+// createPrivKey creates the key of a node or admin certificate, with the algorithm of the given public key of the CA.
+// RSA keys are smaller than the CA key, because a key is created for every node.
+func createPrivKey(caPubKey crypto.PublicKey) (crypto.Signer, error) {
+	if _, isEcdsa := caPubKey.(*ecdsa.PublicKey); isEcdsa {
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	return rsa.GenerateKey(rand.Reader, 2048)
+}
+
+func selfSign(cert *x509.Certificate, privKey crypto.Signer) ([]byte, error) {
+	return sign(cert, cert, privKey.Public(), privKey)
 }
 
-func sign(cert *x509.Certificate, ca *x509.Certificate, certPubKey *rsa.PublicKey, caPrivKey *rsa.PrivateKey) ([]byte, error) {
+func sign(cert *x509.Certificate, ca *x509.Certificate, certPubKey crypto.PublicKey, caPrivKey crypto.Signer) ([]byte, error) {
 	return x509.CreateCertificate(rand.Reader, cert, ca, certPubKey, caPrivKey)
 }
 
@@ -57,11 +90,25 @@ func certToPEM(cert []byte) (*bytes.Buffer, error) {
 	})
 }
 
-func privKeyToPEM(privKey *rsa.PrivateKey) (*bytes.Buffer, error) {
-	return toPem(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privKey),
-	})
+func privKeyToPEM(privKey crypto.Signer) (*bytes.Buffer, error) {
+	switch key := privKey.(type) {
+	case *rsa.PrivateKey:
+		return toPem(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		})
+	case *ecdsa.PrivateKey:
+		keyBytes, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return toPem(&pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: keyBytes,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", privKey)
+	}
 }
 
 func toPem(block *pem.Block) (*bytes.Buffer, error) {
@@ -73,40 +120,162 @@ func toPem(block *pem.Block) (*bytes.Buffer, error) {
 	return buf, nil
 }
 
-func CreateCerts() (caCertPem, certPem, keyPem *bytes.Buffer, retErr error) {
-	// Create self signed CA certificate
-	caCert := createCertTemplate(true)
-	caKey, err := createPrivKey()
+// CreateCA creates a self signed CA with a key of the given algorithm, which signs the certificates of the nodes
+func CreateCA(keyAlgorithm v1alpha1.PeerCertificatesKeyAlgorithm) (caCertPem, caKeyPem *bytes.Buffer, retErr error) {
+	caCert, err := createCertTemplate(true)
+	if err != nil {
+		return nil, nil, err
+	}
+	caKey, err := createCaPrivKey(keyAlgorithm)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
 	caSignedBytes, err := selfSign(caCert, caKey)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, err
+	}
+	if caCertPem, err = certToPEM(caSignedBytes); err != nil {
+		return nil, nil, err
+	}
+	if caKeyPem, err = privKeyToPEM(caKey); err != nil {
+		return nil, nil, err
+	}
+	return
+}
+
+// CreateNodeCert creates the server / client certificate of the agent of the given node, signed by the given CA.
+// The node name is its identity.
+func CreateNodeCert(caCertPem, caKeyPem *bytes.Buffer, nodeName string) (certPem, keyPem *bytes.Buffer, retErr error) {
+	return createSignedCert(caCertPem, caKeyPem, nodeSubject(nodeName))
+}
+
+// signNodeCert creates the server / client certificate of the agent of the given node for the given public key, which
+// was created by the agent. The node name is its identity.
+func signNodeCert(caCertPem, caKeyPem *bytes.Buffer, nodeName string, pubKey crypto.PublicKey) (*bytes.Buffer, error) {
+	caCert, caKey, err := parseCA(caCertPem, caKeyPem)
+	if err != nil {
+		return nil, err
+	}
+	return signCert(caCert, caKey, nodeSubject(nodeName), pubKey)
+}
+
+func nodeSubject(nodeName string) pkix.Name {
+	return pkix.Name{
+		Organization:       []string{"medik8s"},
+		OrganizationalUnit: []string{nodesOrganizationalUnit},
+		CommonName:         nodeName,
+	}
+}
+
+// CreateAdminCert creates a client certificate for tools like snrctl, signed by the given CA. It has no node identity,
+// so it's only accepted for requests which aren't sent on behalf of a node.
+func CreateAdminCert(caCertPem, caKeyPem *bytes.Buffer) (certPem, keyPem *bytes.Buffer, retErr error) {
+	return createSignedCert(caCertPem, caKeyPem, pkix.Name{
+		Organization:       []string{"medik8s"},
+		OrganizationalUnit: []string{adminsOrganizationalUnit},
+		CommonName:         adminCommonName,
+	})
+}
+
+// NodeName returns the name of the node the given certificate was issued for, false if it isn't a node certificate
+func NodeName(cert *x509.Certificate) (string, bool) {
+	for _, unit := range cert.Subject.OrganizationalUnit {
+		if unit == nodesOrganizationalUnit && cert.Subject.CommonName != "" {
+			return cert.Subject.CommonName, true
+		}
+	}
+	return "", false
+}
+
+// IsAdmin returns true if the given certificate was issued for admin tools like snrctl
+func IsAdmin(cert *x509.Certificate) bool {
+	for _, unit := range cert.Subject.OrganizationalUnit {
+		if unit == adminsOrganizationalUnit {
+			return true
+		}
+	}
+	return false
+}
+
+// GetNodeName returns the name of the node the certificate of the given storage was issued for, false if it isn't a
+// node certificate, e.g. because it's shared by all nodes
+func GetNodeName(certReader CertStorageReader) (string, bool, error) {
+	_, certPem, _, err := certReader.GetCerts()
+	if err != nil {
+		return "", false, err
+	}
+	cert, err := parseCert(certPem.Bytes())
+	if err != nil {
+		return "", false, err
+	}
+	nodeName, isNodeCert := NodeName(cert)
+	return nodeName, isNodeCert, nil
+}
+
+func createSignedCert(caCertPem, caKeyPem *bytes.Buffer, subject pkix.Name) (certPem, keyPem *bytes.Buffer, retErr error) {
+	caCert, caKey, err := parseCA(caCertPem, caKeyPem)
+	if err != nil {
+		return nil, nil, err
 	}
-	caCertPem, err = certToPEM(caSignedBytes)
+	key, err := createPrivKey(caKey.Public())
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
+	if certPem, err = signCert(caCert, caKey, subject, key.Public()); err != nil {
+		return nil, nil, err
+	}
+	if keyPem, err = privKeyToPEM(key); err != nil {
+		return nil, nil, err
+	}
+	return
+}
 
-	// Create server / client certificate
-	cert := createCertTemplate(false)
-	key, err := createPrivKey()
+func signCert(caCert *x509.Certificate, caKey crypto.Signer, subject pkix.Name, pubKey crypto.PublicKey) (*bytes.Buffer, error) {
+	cert, err := createCertTemplate(false)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, err
+	}
+	cert.Subject = subject
+	// the certificate must not outlive its CA
+	if caCert.NotAfter.Before(cert.NotAfter) {
+		cert.NotAfter = caCert.NotAfter
 	}
-	certSignedBytes, err := sign(cert, caCert, &key.PublicKey, caKey)
+	certSignedBytes, err := sign(cert, caCert, pubKey, caKey)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, err
+	}
+	return certToPEM(certSignedBytes)
+}
+
+func parseCA(caCertPem, caKeyPem *bytes.Buffer) (*x509.Certificate, crypto.Signer, error) {
+	certBlock, _ := pem.Decode(caCertPem.Bytes())
+	if certBlock == nil {
+		return nil, nil, fmt.Errorf("failed to decode ca certificate")
 	}
-	certPem, err = certToPEM(certSignedBytes)
+	caCert, err := x509.ParseCertificate(certBlock.Bytes)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, fmt.Errorf("failed to parse ca certificate: %w", err)
+	}
+	keyBlock, _ := pem.Decode(caKeyPem.Bytes())
+	if keyBlock == nil {
+		return nil, nil, fmt.Errorf("failed to decode ca key")
+	}
+	var caKey crypto.Signer
+	if keyBlock.Type == "EC PRIVATE KEY" {
+		caKey, err = x509.ParseECPrivateKey(keyBlock.Bytes)
+	} else {
+		caKey, err = x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
 	}
-	keyPem, err = privKeyToPEM(key)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, fmt.Errorf("failed to parse ca key: %w", err)
 	}
+	return caCert, caKey, nil
+}
 
-	return
+// keyAlgorithm returns the algorithm of the key of the given certificate
+func keyAlgorithm(cert *x509.Certificate) v1alpha1.PeerCertificatesKeyAlgorithm {
+	if cert.PublicKeyAlgorithm == x509.ECDSA {
+		return v1alpha1.ECDSAPeerCertificatesKeyAlgorithm
+	}
+	return v1alpha1.RSAPeerCertificatesKeyAlgorithm
 }
@@ -1,27 +1,58 @@
 package certificates
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"sync"
+	"time"
 
 	"google.golang.org/grpc/credentials"
+
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
-const TLSMinVersion = tls.VersionTLS13
+const (
+	TLSMinVersion = tls.VersionTLS13
+	// certsPollInterval is the interval for checking if certificates can be read
+	certsPollInterval = time.Second
+)
 
+// WaitForCerts waits until the certificates of the given storage can be read, e.g. until the certificate which an
+// agent requested was issued
+func WaitForCerts(ctx context.Context, certReader CertStorageReader) error {
+	return wait.PollUntilContextCancel(ctx, certsPollInterval, true, func(context.Context) (bool, error) {
+		_, _, _, err := certReader.GetCerts()
+		return err == nil, nil
+	})
+}
+
+// GetServerCredentialsFromCerts returns the credentials of the peer health server. The certificates are reloaded for
+// every connection when they changed, so that rotated certificates are used without restarting the server.
 func GetServerCredentialsFromCerts(certReader CertStorageReader) (credentials.TransportCredentials, error) {
 
-	keyPair, pool, err := prepareCredentials(certReader)
-	if err != nil {
+	reloader := &credentialsReloader{certReader: certReader}
+	if _, _, _, err := reloader.load(); err != nil {
 		return nil, err
 	}
 
 	return credentials.NewTLS(&tls.Config{
-		Certificates: []tls.Certificate{*keyPair},
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-		ClientCAs:    pool,
-		MinVersion:   TLSMinVersion,
+		MinVersion: TLSMinVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			keyPair, pool, _, err := reloader.load()
+			if err != nil {
+				return nil, err
+			}
+			return &tls.Config{
+				Certificates: []tls.Certificate{*keyPair},
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				ClientCAs:    pool,
+				MinVersion:   TLSMinVersion,
+				NextProtos:   []string{"h2"},
+			}, nil
+		},
 	}), nil
 }
 
@@ -32,12 +63,88 @@ func GetClientCredentialsFromCerts(certReader CertStorageReader) (credentials.Tr
 		return nil, err
 	}
 
+	return newClientCredentials(keyPair, pool), nil
+}
+
+// ClientCredentialsReloader provides the credentials of peer health clients, which are rebuilt when the certificates
+// changed
+type ClientCredentialsReloader struct {
+	reloader credentialsReloader
+	creds    credentials.TransportCredentials
+	mutex    sync.Mutex
+}
+
+// NewClientCredentialsReloader returns a new ClientCredentialsReloader for the certificates of the given storage
+func NewClientCredentialsReloader(certReader CertStorageReader) *ClientCredentialsReloader {
+	return &ClientCredentialsReloader{
+		reloader: credentialsReloader{certReader: certReader},
+	}
+}
+
+// Get returns the credentials for the current certificates
+func (r *ClientCredentialsReloader) Get() (credentials.TransportCredentials, error) {
+	keyPair, pool, changed, err := r.reloader.load()
+	if err != nil {
+		return nil, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if changed || r.creds == nil {
+		r.creds = newClientCredentials(keyPair, pool)
+	}
+	return r.creds, nil
+}
+
+func newClientCredentials(keyPair *tls.Certificate, pool *x509.CertPool) credentials.TransportCredentials {
 	return credentials.NewTLS(&tls.Config{
 		Certificates: []tls.Certificate{*keyPair},
 		RootCAs:      pool,
 		ServerName:   fixedCertIP.String(),
 		MinVersion:   TLSMinVersion,
-	}), nil
+	})
+}
+
+// credentialsReloader keeps the parsed certificates of a storage, and parses them again when they changed
+type credentialsReloader struct {
+	certReader CertStorageReader
+	pems       [][]byte
+	keyPair    *tls.Certificate
+	pool       *x509.CertPool
+	mutex      sync.Mutex
+}
+
+// load returns the current certificates, and whether they changed since the last call
+func (r *credentialsReloader) load() (*tls.Certificate, *x509.CertPool, bool, error) {
+	caPem, certPem, keyPem, err := r.certReader.GetCerts()
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	pems := [][]byte{caPem.Bytes(), certPem.Bytes(), keyPem.Bytes()}
+	if r.keyPair != nil && equalPems(pems, r.pems) {
+		return r.keyPair, r.pool, false, nil
+	}
+
+	keyPair, pool, err := parseCredentials(caPem, certPem, keyPem)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	r.pems, r.keyPair, r.pool = pems, keyPair, pool
+	return keyPair, pool, true, nil
+}
+
+func equalPems(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
 }
 
 func prepareCredentials(certReader CertStorageReader) (*tls.Certificate, *x509.CertPool, error) {
@@ -45,7 +152,10 @@ func prepareCredentials(certReader CertStorageReader) (*tls.Certificate, *x509.C
 	if err != nil {
 		return nil, nil, err
 	}
+	return parseCredentials(caPem, certPem, keyPem)
+}
 
+func parseCredentials(caPem, certPem, keyPem *bytes.Buffer) (*tls.Certificate, *x509.CertPool, error) {
 	keyPair, err := tls.X509KeyPair(certPem.Bytes(), keyPem.Bytes())
 	if err != nil {
 		return nil, nil, err
@@ -0,0 +1,360 @@
+package certificates
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// SignerName is the signer of the CertificateSigningRequests the agents create for the certificates of their nodes
+	SignerName = "self-node-remediation.medik8s.io/peer"
+	// CABundleName is the name of the ConfigMap with the CAs of the peer certificates, which is maintained by the
+	// operator. It has no keys, so agents can read it.
+	CABundleName = "self-node-remediation-peer-ca"
+	// activeCACertKey is the key of the CA which issues new certificates in the CA bundle. It's only set by backends
+	// which rotate the CA, agents renew their certificates when they weren't issued by it.
+	activeCACertKey = "active-ca.crt"
+
+	// podNameExtraKey and podUIDExtraKey hold the pod a service account token is bound to in the extra info of the user,
+	// which the API server copies into CertificateSigningRequests
+	podNameExtraKey = "authentication.kubernetes.io/pod-name"
+	podUIDExtraKey  = "authentication.kubernetes.io/pod-uid"
+
+	csrNamePrefix = "self-node-remediation-peer-"
+	// csrCheckInterval is the time between two checks of the certificate of an agent. It's shorter than
+	// ActivationDelay, so that agents trust new CAs before they issue certificates.
+	csrCheckInterval = time.Minute
+	// csrPollInterval is the time between two checks of a pending CertificateSigningRequest
+	csrPollInterval = 2 * time.Second
+	// csrRetryInterval is the time until the next check after an error
+	csrRetryInterval = 10 * time.Second
+	// csrDeniedRetryInterval is the time until a new certificate is requested after a CertificateSigningRequest was
+	// denied, it's long for not flooding the cluster with requests which are denied again
+	csrDeniedRetryInterval = 10 * time.Minute
+	// csrMaxPendingAge is how long an agent waits for a CertificateSigningRequest, before it requests a new one
+	csrMaxPendingAge = time.Hour
+)
+
+var _ CertStorageReader = &CSRCertStorage{}
+
+// CSRCertStorage requests the certificate of the agent of a node with a CertificateSigningRequest, and renews it. The
+// key is created by the agent and only kept in memory, so it never leaves the node. The operator only signs requests
+// of the agent pod of the requested node, so agents can't get the certificates of other nodes.
+type CSRCertStorage struct {
+	client client.Client
+	// reader is uncached, agents can only get CertificateSigningRequests and the CA bundle
+	reader    client.Reader
+	log       logr.Logger
+	namespace string
+	nodeName  string
+
+	mutex   sync.Mutex
+	caPem   []byte
+	certPem []byte
+	keyPem  []byte
+
+	// pending is the CertificateSigningRequest which waits for being signed, and pendingKeyPem the key it's for. They
+	// are only used by the goroutine which runs Start.
+	pending       *certificatesv1.CertificateSigningRequest
+	pendingKeyPem []byte
+	pendingSince  time.Time
+}
+
+func NewCSRCertStorage(c client.Client, reader client.Reader, log logr.Logger, namespace string, nodeName string) *CSRCertStorage {
+	return &CSRCertStorage{
+		client:    c,
+		reader:    reader,
+		log:       log,
+		namespace: namespace,
+		nodeName:  nodeName,
+	}
+}
+
+func (s *CSRCertStorage) GetCerts() (caPem, certPem, keyPem *bytes.Buffer, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.certPem == nil {
+		return nil, nil, nil, fmt.Errorf("certificate of node %s wasn't issued yet", s.nodeName)
+	}
+	return bytes.NewBuffer(s.caPem), bytes.NewBuffer(s.certPem), bytes.NewBuffer(s.keyPem), nil
+}
+
+// Start requests the certificate of the node, and renews it until the context is cancelled
+func (s *CSRCertStorage) Start(ctx context.Context) error {
+	for {
+		next := s.sync(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(next):
+		}
+	}
+}
+
+// sync reads the CA bundle, and requests a new certificate if needed. It returns when it needs to run again.
+func (s *CSRCertStorage) sync(ctx context.Context) time.Duration {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeout)
+	defer cancel()
+
+	bundle := &v1.ConfigMap{}
+	if err := s.reader.Get(ctx, client.ObjectKey{Namespace: s.namespace, Name: CABundleName}, bundle); err != nil {
+		s.log.Error(err, "failed to get the CA bundle")
+		return csrRetryInterval
+	}
+	caPem := []byte(bundle.Data[caCertKey])
+	s.mutex.Lock()
+	s.caPem = caPem
+	certPem := s.certPem
+	s.mutex.Unlock()
+
+	if s.pending != nil {
+		return s.checkPending(ctx)
+	}
+	if !needsCert(certPem, caPem, []byte(bundle.Data[activeCACertKey]), time.Now()) {
+		return csrCheckInterval
+	}
+	if err := s.requestCert(ctx, caPem, []byte(bundle.Data[activeCACertKey])); err != nil {
+		s.log.Error(err, "failed to request the certificate of the node")
+		return csrRetryInterval
+	}
+	return csrPollInterval
+}
+
+// requestCert creates a key with the algorithm of the CA, and a CertificateSigningRequest for it
+func (s *CSRCertStorage) requestCert(ctx context.Context, caPem, activeCAPem []byte) error {
+	if len(activeCAPem) == 0 {
+		activeCAPem = caPem
+	}
+	ca, err := parseCert(activeCAPem)
+	if err != nil {
+		return fmt.Errorf("invalid CA bundle: %w", err)
+	}
+	key, err := createPrivKey(ca.PublicKey)
+	if err != nil {
+		return err
+	}
+	keyPem, err := privKeyToPEM(key)
+	if err != nil {
+		return err
+	}
+	request, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:     nodeSubject(s.nodeName),
+		IPAddresses: []net.IP{fixedCertIP},
+	}, key)
+	if err != nil {
+		return err
+	}
+	requestPem, err := toPem(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: request})
+	if err != nil {
+		return err
+	}
+	usages := []certificatesv1.KeyUsage{certificatesv1.UsageDigitalSignature, certificatesv1.UsageServerAuth, certificatesv1.UsageClientAuth}
+	if _, isRSA := key.(*rsa.PrivateKey); isRSA {
+		// key encipherment is only used by RSA key exchanges
+		usages = append(usages, certificatesv1.UsageKeyEncipherment)
+	}
+
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: csrNamePrefix},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request:    requestPem.Bytes(),
+			SignerName: SignerName,
+			Usages:     usages,
+		},
+	}
+	if err := s.client.Create(ctx, csr); err != nil {
+		return err
+	}
+	s.log.Info("requested the certificate of the node", "name", csr.Name)
+	s.pending, s.pendingKeyPem, s.pendingSince = csr, keyPem.Bytes(), time.Now()
+	return nil
+}
+
+// checkPending uses the certificate of the pending CertificateSigningRequest when it was issued, and drops the request
+// when it was denied, failed or took too long, so that a new certificate is requested
+func (s *CSRCertStorage) checkPending(ctx context.Context) time.Duration {
+	csr := &certificatesv1.CertificateSigningRequest{}
+	if err := s.reader.Get(ctx, client.ObjectKeyFromObject(s.pending), csr); err != nil {
+		if apierrors.IsNotFound(err) {
+			s.log.Info("certificate signing request was deleted, requesting a new one", "name", s.pending.Name)
+			s.pending, s.pendingKeyPem = nil, nil
+			return csrPollInterval
+		}
+		s.log.Error(err, "failed to get the certificate signing request", "name", s.pending.Name)
+		return csrRetryInterval
+	}
+	if condition := finishedCondition(csr); condition != nil && condition.Type != certificatesv1.CertificateApproved {
+		s.log.Info("certificate signing request wasn't signed", "name", csr.Name, "type", condition.Type,
+			"reason", condition.Reason, "message", condition.Message)
+		s.pending, s.pendingKeyPem = nil, nil
+		return csrDeniedRetryInterval
+	}
+	if len(csr.Status.Certificate) == 0 {
+		if time.Since(s.pendingSince) < csrMaxPendingAge {
+			return csrPollInterval
+		}
+		s.log.Info("certificate signing request is pending for too long, requesting a new one", "name", csr.Name)
+		s.pending, s.pendingKeyPem = nil, nil
+		return csrPollInterval
+	}
+
+	if _, err := tls.X509KeyPair(csr.Status.Certificate, s.pendingKeyPem); err != nil {
+		s.log.Error(err, "invalid certificate was issued", "name", csr.Name)
+		s.pending, s.pendingKeyPem = nil, nil
+		return csrDeniedRetryInterval
+	}
+	s.mutex.Lock()
+	s.certPem, s.keyPem = csr.Status.Certificate, s.pendingKeyPem
+	s.mutex.Unlock()
+	s.log.Info("received the certificate of the node", "name", csr.Name)
+	s.pending, s.pendingKeyPem = nil, nil
+	return csrCheckInterval
+}
+
+// needsCert returns true if there is no certificate, or if it needs to be renewed because it expires soon, isn't
+// trusted anymore, or wasn't issued by the active CA
+func needsCert(certPem, caPem, activeCAPem []byte, now time.Time) bool {
+	if certPem == nil {
+		return true
+	}
+	cert, err := parseCert(certPem)
+	if err != nil || !now.Before(getRenewalTime(cert)) {
+		return true
+	}
+	if !isIssuedBy(certPem, caPem, now) {
+		return true
+	}
+	return len(activeCAPem) > 0 && !isIssuedBy(certPem, activeCAPem, now)
+}
+
+// isIssuedBy returns true if the first certificate of the given chain is issued by one of the given CAs
+func isIssuedBy(chainPem, caPem []byte, now time.Time) bool {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPem) {
+		return false
+	}
+	intermediates := x509.NewCertPool()
+	intermediates.AppendCertsFromPEM(chainPem)
+	cert, err := parseCert(chainPem)
+	if err != nil {
+		return false
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err == nil
+}
+
+// finishedCondition returns the condition which finished the given CertificateSigningRequest, nil if it's pending.
+// Approved requests are only finished when they have a certificate.
+func finishedCondition(csr *certificatesv1.CertificateSigningRequest) *certificatesv1.CertificateSigningRequestCondition {
+	var approved *certificatesv1.CertificateSigningRequestCondition
+	for i, condition := range csr.Status.Conditions {
+		switch condition.Type {
+		case certificatesv1.CertificateDenied, certificatesv1.CertificateFailed:
+			return &csr.Status.Conditions[i]
+		case certificatesv1.CertificateApproved:
+			approved = &csr.Status.Conditions[i]
+		}
+	}
+	if approved != nil && len(csr.Status.Certificate) > 0 {
+		return approved
+	}
+	return nil
+}
+
+// IsPendingCSR returns true if the given CertificateSigningRequest needs to be signed by the operator
+func IsPendingCSR(csr *certificatesv1.CertificateSigningRequest) bool {
+	return csr.Spec.SignerName == SignerName && finishedCondition(csr) == nil
+}
+
+// IsApprovedCSR returns true if the given CertificateSigningRequest was approved
+func IsApprovedCSR(csr *certificatesv1.CertificateSigningRequest) bool {
+	for _, condition := range csr.Status.Conditions {
+		if condition.Type == certificatesv1.CertificateApproved && condition.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestingPodName returns the name of the pod whose service account token was used for creating the given
+// CertificateSigningRequest, empty if the token isn't bound to a pod
+func RequestingPodName(csr *certificatesv1.CertificateSigningRequest) string {
+	return extraValue(csr, podNameExtraKey)
+}
+
+// ValidateNodeCSR returns the certificate request of the given CertificateSigningRequest and the node it's for, or an
+// error if it wasn't created by the agent of that node. The agent is identified by the pod which its service account
+// token is bound to, so that agents can't request the certificates of other nodes.
+func ValidateNodeCSR(csr *certificatesv1.CertificateSigningRequest, namespace string, pod *v1.Pod) (*x509.CertificateRequest, string, error) {
+	if agentUser := fmt.Sprintf("system:serviceaccount:%s:%s", namespace, AgentServiceAccountName); csr.Spec.Username != agentUser {
+		return nil, "", fmt.Errorf("requested by %s instead of %s", csr.Spec.Username, agentUser)
+	}
+	if pod == nil || pod.UID == "" || string(pod.UID) != extraValue(csr, podUIDExtraKey) {
+		return nil, "", errors.New("requesting pod doesn't exist anymore")
+	}
+	nodeName := pod.Spec.NodeName
+	if nodeName == "" {
+		return nil, "", fmt.Errorf("requesting pod %s isn't scheduled", pod.Name)
+	}
+
+	block, _ := pem.Decode(csr.Spec.Request)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, "", errors.New("failed to decode certificate request")
+	}
+	request, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse certificate request: %w", err)
+	}
+	if err := request.CheckSignature(); err != nil {
+		return nil, "", fmt.Errorf("invalid signature of certificate request: %w", err)
+	}
+	if subject, expected := request.Subject.String(), nodeSubject(nodeName).String(); subject != expected {
+		return nil, "", fmt.Errorf("requested %s by the agent of node %s, expected %s", subject, nodeName, expected)
+	}
+	// the fixed IP is verified instead of the real address of the peers
+	if len(request.DNSNames) > 0 || len(request.EmailAddresses) > 0 || len(request.URIs) > 0 ||
+		len(request.IPAddresses) != 1 || !request.IPAddresses[0].Equal(fixedCertIP) {
+		return nil, "", errors.New("requested unexpected subject alternative names")
+	}
+	switch key := request.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+	case *rsa.PublicKey:
+		if key.N.BitLen() < 2048 {
+			return nil, "", fmt.Errorf("requested certificate for a RSA key of %d bits", key.N.BitLen())
+		}
+	default:
+		return nil, "", fmt.Errorf("requested certificate for unsupported key type %T", request.PublicKey)
+	}
+	return request, nodeName, nil
+}
+
+func extraValue(csr *certificatesv1.CertificateSigningRequest, key string) string {
+	if values := csr.Spec.Extra[key]; len(values) == 1 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,186 @@
+package certificates
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+)
+
+var _ = Describe("Certificate signing requests", func() {
+
+	var c client.Client
+	var issuer *SecretCertStorage
+	var agentPod *v1.Pod
+
+	BeforeEach(func() {
+		c = fake.NewClientBuilder().WithStatusSubresource(&certificatesv1.CertificateSigningRequest{}).Build()
+		issuer = NewSecretCertStorage(c, ctrl.Log.WithName("TestCSRIssuer"), "default")
+		issuer.keyAlgorithm = v1alpha1.ECDSAPeerCertificatesKeyAlgorithm
+		Expect(issuer.Sync(context.Background())).To(Equal(newVersionCheckInterval))
+		agentPod = &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "agent", UID: "agent-uid"},
+			Spec:       v1.PodSpec{NodeName: "node"},
+		}
+	})
+
+	// signPending signs the pending CertificateSigningRequests like the operator, after adding the user info of the
+	// agent pod on the given node like the API server
+	signPending := func(pod *v1.Pod) (signed int) {
+		csrs := &certificatesv1.CertificateSigningRequestList{}
+		ExpectWithOffset(1, c.List(context.Background(), csrs)).To(Succeed())
+		for i := range csrs.Items {
+			csr := &csrs.Items[i]
+			if !IsPendingCSR(csr) {
+				continue
+			}
+			csr.Spec.Username = "system:serviceaccount:default:" + AgentServiceAccountName
+			csr.Spec.Extra = map[string]certificatesv1.ExtraValue{podNameExtraKey: {pod.Name}, podUIDExtraKey: {string(pod.UID)}}
+			request, nodeName, err := ValidateNodeCSR(csr, "default", pod)
+			ExpectWithOffset(1, err).ToNot(HaveOccurred())
+			certPem, err := issuer.SignNodeCert(context.Background(), csr, request, nodeName)
+			ExpectWithOffset(1, err).ToNot(HaveOccurred())
+			csr.Status.Conditions = []certificatesv1.CertificateSigningRequestCondition{{Type: certificatesv1.CertificateApproved, Status: v1.ConditionTrue}}
+			csr.Status.Certificate = certPem
+			ExpectWithOffset(1, c.Status().Update(context.Background(), csr)).To(Succeed())
+			signed++
+		}
+		return
+	}
+
+	It("should request the certificate of the node, and renew it with a new CA", func() {
+		storage := NewCSRCertStorage(c, c, ctrl.Log.WithName("TestCSRStorage"), "default", "node")
+		_, _, _, err := storage.GetCerts()
+		Expect(err).To(HaveOccurred(), "certificate isn't issued yet")
+
+		By("requesting a certificate")
+		Expect(storage.sync(context.Background())).To(Equal(csrPollInterval))
+		Expect(storage.sync(context.Background())).To(Equal(csrPollInterval), "request is pending")
+		Expect(signPending(agentPod)).To(Equal(1))
+		Expect(storage.sync(context.Background())).To(Equal(csrCheckInterval))
+
+		nodeName, isNodeCert, err := GetNodeName(storage)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isNodeCert).To(BeTrue())
+		Expect(nodeName).To(Equal("node"))
+		_, err = GetServerCredentialsFromCerts(storage)
+		Expect(err).ToNot(HaveOccurred())
+
+		By("not requesting the certificate again")
+		Expect(storage.sync(context.Background())).To(Equal(csrCheckInterval))
+		Expect(signPending(agentPod)).To(BeZero())
+
+		By("requesting a new certificate when a new CA is active")
+		Expect(issuer.createVersion()).To(Succeed())
+		Expect(issuer.syncCABundle(context.Background())).To(Succeed())
+		_, oldCertPem, _, err := storage.GetCerts()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(storage.sync(context.Background())).To(Equal(csrPollInterval))
+		Expect(signPending(agentPod)).To(Equal(1))
+		Expect(storage.sync(context.Background())).To(Equal(csrCheckInterval))
+		caPem, newCertPem, _, err := storage.GetCerts()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(newCertPem.Bytes()).ToNot(Equal(oldCertPem.Bytes()))
+		Expect(isIssuedBy(newCertPem.Bytes(), caPem.Bytes(), time.Now())).To(BeTrue())
+		Expect(isIssuedBy(oldCertPem.Bytes(), caPem.Bytes(), time.Now())).To(BeTrue(), "old CA should still be trusted")
+	})
+
+	It("should request a new certificate when the request was denied", func() {
+		storage := NewCSRCertStorage(c, c, ctrl.Log.WithName("TestCSRStorage"), "default", "node")
+		Expect(storage.sync(context.Background())).To(Equal(csrPollInterval))
+		csrs := &certificatesv1.CertificateSigningRequestList{}
+		Expect(c.List(context.Background(), csrs)).To(Succeed())
+		Expect(csrs.Items).To(HaveLen(1))
+		csr := &csrs.Items[0]
+		csr.Status.Conditions = []certificatesv1.CertificateSigningRequestCondition{{Type: certificatesv1.CertificateDenied, Status: v1.ConditionTrue}}
+		Expect(c.Status().Update(context.Background(), csr)).To(Succeed())
+
+		Expect(storage.sync(context.Background())).To(Equal(csrDeniedRetryInterval))
+		Expect(storage.sync(context.Background())).To(Equal(csrPollInterval), "new request expected")
+		Expect(c.List(context.Background(), csrs)).To(Succeed())
+		Expect(csrs.Items).To(HaveLen(2))
+	})
+
+	Describe("validation", func() {
+
+		var csr *certificatesv1.CertificateSigningRequest
+
+		newCSR := func(subject pkix.Name, ipAddresses ...net.IP) *certificatesv1.CertificateSigningRequest {
+			key, err := createPrivKey(nil)
+			ExpectWithOffset(1, err).ToNot(HaveOccurred())
+			request, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: subject, IPAddresses: ipAddresses}, key)
+			ExpectWithOffset(1, err).ToNot(HaveOccurred())
+			return &certificatesv1.CertificateSigningRequest{
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Request:    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: request}),
+					SignerName: SignerName,
+					Username:   "system:serviceaccount:default:" + AgentServiceAccountName,
+					Extra:      map[string]certificatesv1.ExtraValue{podNameExtraKey: {"agent"}, podUIDExtraKey: {"agent-uid"}},
+				},
+			}
+		}
+
+		BeforeEach(func() {
+			csr = newCSR(nodeSubject("node"), fixedCertIP)
+		})
+
+		It("should accept requests of the agent of the node", func() {
+			request, nodeName, err := ValidateNodeCSR(csr, "default", agentPod)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(nodeName).To(Equal("node"))
+			Expect(request.Subject.CommonName).To(Equal("node"))
+		})
+
+		It("should reject requests for other nodes", func() {
+			agentPod.Spec.NodeName = "other-node"
+			_, _, err := ValidateNodeCSR(csr, "default", agentPod)
+			Expect(err).To(MatchError(ContainSubstring("by the agent of node other-node")))
+		})
+
+		It("should reject requests of other users", func() {
+			csr.Spec.Username = "system:serviceaccount:default:other"
+			_, _, err := ValidateNodeCSR(csr, "default", agentPod)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject requests of deleted pods, and of tokens which aren't bound to a pod", func() {
+			_, _, err := ValidateNodeCSR(csr, "default", &v1.Pod{})
+			Expect(err).To(HaveOccurred())
+
+			agentPod.UID = "recreated-agent-uid"
+			_, _, err = ValidateNodeCSR(csr, "default", agentPod)
+			Expect(err).To(HaveOccurred())
+
+			delete(csr.Spec.Extra, podUIDExtraKey)
+			Expect(RequestingPodName(csr)).To(Equal("agent"))
+			agentPod.UID = ""
+			_, _, err = ValidateNodeCSR(csr, "default", agentPod)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject requests with other identities or addresses", func() {
+			subject := nodeSubject("node")
+			subject.OrganizationalUnit = []string{adminsOrganizationalUnit}
+			_, _, err := ValidateNodeCSR(newCSR(subject, fixedCertIP), "default", agentPod)
+			Expect(err).To(HaveOccurred())
+
+			_, _, err = ValidateNodeCSR(newCSR(nodeSubject("node"), fixedCertIP, net.IPv4(10, 0, 0, 1)), "default", agentPod)
+			Expect(err).To(MatchError(ContainSubstring("subject alternative names")))
+		})
+	})
+})
@@ -0,0 +1,119 @@
+package certificates
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// ActivationDelay is how long the CA of a new version of the certificates is trusted before its certificate is
+	// used. It must be longer than the time agents need to read the new version.
+	ActivationDelay = 5 * time.Minute
+	// trustOverlap is how long old versions of the certificates are kept after a renewal, their CAs are trusted until
+	// they are removed
+	trustOverlap = 24 * time.Hour
+	// renewalFraction defines when certificates are renewed, when 1/renewalFraction of their validity is left
+	renewalFraction = 3
+	// maxRotationCheckInterval is the max time between two checks of the certificates
+	maxRotationCheckInterval = 12 * time.Hour
+	// newVersionCheckInterval is the time until the next check after a new version was created. The versions are
+	// read from the cache, so the CA bundle and the admin certificate might only be updated by the next check.
+	newVersionCheckInterval = 10 * time.Second
+)
+
+// Rotate creates the CA if it doesn't exist yet, and renews it before it expires. Old versions are removed when all
+// agents use the newest version. It returns when the certificates need to be checked again.
+func (s *SecretCertStorage) Rotate(now time.Time) (time.Duration, error) {
+	s.mutex.Lock()
+	versions, err := s.getVersions()
+	s.mutex.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(versions) == 0 {
+		s.log.Info("Creating new certs")
+		return newVersionCheckInterval, s.createVersion()
+	}
+
+	newest := versions[0]
+	if _, found := newest.secret.Data[caKeyPemKey]; !found {
+		s.log.Info("Renewing certs without CA key, for issuing node certs", "version", newest.version+1)
+		return newVersionCheckInterval, s.createVersion()
+	}
+	renewalTime := now
+	if caCert, err := parseCert(newest.secret.Data[caPemKey]); err != nil {
+		s.log.Error(err, "invalid certificates, renewing them", "version", newest.version)
+	} else if caKeyAlgorithm := keyAlgorithm(caCert); caKeyAlgorithm != s.keyAlgorithm {
+		s.log.Info("Renewing certs for changing the key algorithm", "from", caKeyAlgorithm, "to", s.keyAlgorithm)
+	} else {
+		renewalTime = getRenewalTime(caCert)
+	}
+	if !now.Before(renewalTime) {
+		s.log.Info("Renewing certs", "version", newest.version+1)
+		return newVersionCheckInterval, s.createVersion()
+	}
+
+	next := renewalTime.Sub(now)
+	if len(versions) > 1 {
+		removalTime := newest.secret.CreationTimestamp.Add(trustOverlap)
+		if !now.Before(removalTime) {
+			if err := s.removeVersions(versions[1:]); err != nil {
+				return 0, err
+			}
+		} else if removalTime.Sub(now) < next {
+			next = removalTime.Sub(now)
+		}
+		// the agents switch to certificates of the newest version when it's activated
+		if activationTime := newest.secret.CreationTimestamp.Add(ActivationDelay); now.Before(activationTime) && activationTime.Sub(now) < next {
+			next = activationTime.Sub(now)
+		}
+	}
+	if next > maxRotationCheckInterval {
+		next = maxRotationCheckInterval
+	}
+	return next, nil
+}
+
+func (s *SecretCertStorage) createVersion() error {
+	caPem, caKeyPem, err := CreateCA(s.keyAlgorithm)
+	if err != nil {
+		return fmt.Errorf("failed to create certs: %w", err)
+	}
+	return s.StoreCA(caPem, caKeyPem)
+}
+
+func (s *SecretCertStorage) removeVersions(versions []certVersion) error {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+	for _, version := range versions {
+		s.log.Info("Removing old certs", "version", version.version)
+		if err := s.Delete(ctx, version.secret); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// getRenewalTime returns when the given certificate needs to be renewed, which is when 1/renewalFraction of its
+// validity is left
+func getRenewalTime(cert *x509.Certificate) time.Time {
+	return cert.NotAfter.Add(-cert.NotAfter.Sub(cert.NotBefore) / renewalFraction)
+}
+
+func parseCert(certPem []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPem)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return cert, nil
+}
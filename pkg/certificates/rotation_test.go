@@ -0,0 +1,163 @@
+package certificates
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Rotation", func() {
+
+	const namespace = "rotation-test"
+	var store *SecretCertStorage
+
+	getVersions := func() []int {
+		store.mutex.Lock()
+		defer store.mutex.Unlock()
+		versions, err := store.getVersions()
+		ExpectWithOffset(1, err).ToNot(HaveOccurred())
+		var numbers []int
+		for _, version := range versions {
+			numbers = append(numbers, version.version)
+		}
+		return numbers
+	}
+
+	countPemBlocks := func(data []byte) int {
+		count := 0
+		for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+			count++
+		}
+		return count
+	}
+
+	// getAdminCerts issues the admin certificate, and reads it once it has the CAs of the given number of versions
+	getAdminCerts := func(caCount int) (caPem, certPem, keyPem *bytes.Buffer) {
+		EventuallyWithOffset(1, func(g Gomega) {
+			g.Expect(store.SyncAdminCert()).To(Succeed())
+			var err error
+			caPem, certPem, keyPem, err = NewAdminCertReader(k8sClient, namespace, nil).GetCerts()
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(countPemBlocks(caPem.Bytes())).To(Equal(caCount))
+		}).Should(Succeed())
+		return caPem, certPem, keyPem
+	}
+
+	BeforeEach(func() {
+		ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		Expect(client.IgnoreAlreadyExists(k8sClient.Create(context.Background(), ns))).To(Succeed())
+		store = NewSecretCertStorage(k8sClient, ctrl.Log.WithName("TestRotation"), namespace)
+	})
+
+	It("should create, renew and remove certificates", func() {
+		By("creating the first version")
+		_, err := store.Rotate(time.Now())
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(getVersions).Should(Equal([]int{0}))
+		_, certPem, keyPem := getAdminCerts(1)
+
+		By("not renewing valid certificates")
+		next, err := store.Rotate(time.Now())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(next).To(Equal(maxRotationCheckInterval))
+		Expect(getVersions()).To(Equal([]int{0}))
+
+		By("renewing certificates before they expire")
+		next, err = store.Rotate(time.Now().Add(certValidity * 3 / 4))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(next).To(Equal(newVersionCheckInterval))
+		Eventually(getVersions).Should(Equal([]int{1, 0}))
+		next, err = store.Rotate(time.Now())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(next).To(BeNumerically("<=", ActivationDelay), "expected a check when the new version is activated")
+
+		By("trusting both CAs, but using the old certificate until the new one is activated")
+		newCaPem, newCertPem, _ := getAdminCerts(2)
+		Expect(newCertPem.Bytes()).To(Equal(certPem.Bytes()))
+
+		store.mutex.Lock()
+		versions, err := store.getVersions()
+		store.mutex.Unlock()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(activeVersion(versions, time.Now().Add(ActivationDelay)).version).To(Equal(1))
+
+		By("renewing certificates without CA key")
+		legacy := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: versionSecretName(2)},
+			Data:       map[string][]byte{caPemKey: newCaPem.Bytes(), certPemKey: certPem.Bytes(), keyPemKey: keyPem.Bytes()},
+		}
+		Expect(k8sClient.Create(context.Background(), legacy)).To(Succeed())
+		Eventually(getVersions).Should(Equal([]int{2, 1, 0}))
+		next, err = store.Rotate(time.Now())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(next).To(Equal(newVersionCheckInterval))
+		Eventually(getVersions).Should(Equal([]int{3, 2, 1, 0}))
+
+		By("renewing certificates when the key algorithm changed")
+		store.keyAlgorithm = v1alpha1.ECDSAPeerCertificatesKeyAlgorithm
+		next, err = store.Rotate(time.Now())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(next).To(Equal(newVersionCheckInterval))
+		Eventually(getVersions).Should(Equal([]int{4, 3, 2, 1, 0}))
+		next, err = store.Rotate(time.Now())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(next).ToNot(Equal(newVersionCheckInterval))
+
+		By("removing the old certificates after the overlap")
+		_, err = store.Rotate(time.Now().Add(trustOverlap + time.Minute))
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(getVersions).Should(Equal([]int{4}))
+	})
+
+	It("should parse versions from secret names", func() {
+		for name, expected := range map[string]int{secretName: 0, secretName + "-1": 1, secretName + "-12": 12} {
+			version, ok := parseVersion(name)
+			Expect(ok).To(BeTrue(), name)
+			Expect(version).To(Equal(expected))
+			Expect(versionSecretName(version)).To(Equal(name))
+		}
+		for _, name := range []string{"other", secretName + "-", secretName + "-0", secretName + "-x"} {
+			_, ok := parseVersion(name)
+			Expect(ok).To(BeFalse(), name)
+		}
+	})
+})
+
+var _ = Describe("Credentials", func() {
+
+	It("should reload client credentials when the certificates changed", func() {
+		caPem, caKeyPem, err := CreateCA(v1alpha1.RSAPeerCertificatesKeyAlgorithm)
+		Expect(err).ToNot(HaveOccurred())
+		certPem, keyPem, err := CreateNodeCert(caPem, caKeyPem, "node")
+		Expect(err).ToNot(HaveOccurred())
+		storage := &MemoryCertStorage{CaPem: caPem, CertPem: certPem, KeyPem: keyPem}
+		reloader := NewClientCredentialsReloader(storage)
+
+		creds, err := reloader.Get()
+		Expect(err).ToNot(HaveOccurred())
+		sameCreds, err := reloader.Get()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sameCreds).To(BeIdenticalTo(creds))
+
+		newCaPem, newCaKeyPem, err := CreateCA(v1alpha1.RSAPeerCertificatesKeyAlgorithm)
+		Expect(err).ToNot(HaveOccurred())
+		newCertPem, newKeyPem, err := CreateNodeCert(newCaPem, newCaKeyPem, "node")
+		Expect(err).ToNot(HaveOccurred())
+		storage.CaPem = bytes.NewBuffer(append(newCaPem.Bytes(), caPem.Bytes()...))
+		storage.CertPem, storage.KeyPem = newCertPem, newKeyPem
+		newCreds, err := reloader.Get()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(newCreds).ToNot(BeIdenticalTo(creds))
+	})
+})
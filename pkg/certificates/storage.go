@@ -3,17 +3,25 @@ package certificates
 import (
 	"bytes"
 	"context"
+	"crypto/x509"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 
+	certificatesv1 "k8s.io/api/certificates/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
 )
 
 type CertStorageReader interface {
@@ -32,76 +40,108 @@ func (m *MemoryCertStorage) GetCerts() (caPem, certPem, keyPem *bytes.Buffer, er
 }
 
 const (
-	secretName = "self-node-remediation-certificates"
-	caPemKey   = "caPem"
+	// secretName is the name of the secret holding the first version of the certificates, later versions are stored
+	// in secrets named secretName-<version>
+	secretName  = "self-node-remediation-certificates"
+	caPemKey    = "caPem"
+	caKeyPemKey = "caKeyPem"
+	// certPemKey and keyPemKey hold the certificate shared by all nodes, which was used before node certificates were
+	// issued
 	certPemKey = "certPem"
 	keyPemKey  = "keyPem"
 
+	// NodeSecretLabel is the label with the node name on the secrets which held the certificates of a node, before
+	// the agents requested their certificates
+	NodeSecretLabel = "self-node-remediation.medik8s.io/peer-node"
+
 	apiTimeout = 10 * time.Second
 )
 
-var _ CertStorageReader = &SecretCertStorage{}
-
+// SecretCertStorage stores the CAs in versioned immutable secrets, and the certificates they issued for admin tools in
+// the admin secret. The admin secret also holds the CAs of all versions and the certificate of the active version in
+// the format of TLS secrets, so that admin tools read their certificates without access to the CA keys. The agents
+// request their certificates with CertificateSigningRequests, which are signed with the CA of the active version,
+// and trust the CAs of the CA bundle. The CA keys are only used by the operator.
 type SecretCertStorage struct {
 	client.Client
 	log       logr.Logger
 	namespace string
-	secret    *v1.Secret
-	mutex     sync.Mutex
+	// keyAlgorithm is the algorithm of the keys of new CAs
+	keyAlgorithm v1alpha1.PeerCertificatesKeyAlgorithm
+	mutex        sync.Mutex
+}
+
+// certVersion is a version of the certificates
+type certVersion struct {
+	version int
+	secret  *v1.Secret
 }
 
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch
 
+// NewSecretCertStorage returns the storage of the operator
 func NewSecretCertStorage(c client.Client, log logr.Logger, namespace string) *SecretCertStorage {
 	return &SecretCertStorage{
-		Client:    c,
-		log:       log,
-		namespace: namespace,
-		mutex:     sync.Mutex{},
+		Client:       c,
+		log:          log,
+		namespace:    namespace,
+		keyAlgorithm: v1alpha1.RSAPeerCertificatesKeyAlgorithm,
+		mutex:        sync.Mutex{},
 	}
 }
 
-func (s *SecretCertStorage) GetCerts() (caPem, certPem, keyPem *bytes.Buffer, err error) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+// caBundle returns the CAs of all given versions
+func caBundle(versions []certVersion) []byte {
+	caPem := &bytes.Buffer{}
+	for _, version := range versions {
+		caPem.Write(version.secret.Data[caPemKey])
+	}
+	return caPem.Bytes()
+}
 
-	if s.secret == nil {
-		certSecret := &v1.Secret{}
-		key := types.NamespacedName{
-			Namespace: s.namespace,
-			Name:      secretName,
+// activeIssuedCert returns the certificate issued by the active version, or by the newest older version when it was
+// issued after the active version was created
+func activeIssuedCert(versions []certVersion, active certVersion, data map[string][]byte) (certPem, keyPem []byte, found bool) {
+	for _, version := range versions {
+		if version.version > active.version {
+			continue
+		}
+		certKey, keyKey := versionCertKeys(version.version)
+		if certPem, keyPem = data[certKey], data[keyKey]; certPem != nil && keyPem != nil {
+			return certPem, keyPem, true
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
-		defer cancel()
-		if err := s.Get(ctx, key, certSecret); err != nil {
-			return nil, nil, nil, err
+		// versions created before node certificates were issued have a shared certificate
+		if certPem, keyPem = version.secret.Data[certPemKey], version.secret.Data[keyPemKey]; certPem != nil && keyPem != nil {
+			return certPem, keyPem, true
 		}
-		s.secret = certSecret
 	}
+	return nil, nil, false
+}
+
+// StoreCA stores the given CA as a new version
+func (s *SecretCertStorage) StoreCA(caPem, caKeyPem *bytes.Buffer) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	toBuffer := func(key string) *bytes.Buffer {
-		b := &bytes.Buffer{}
-		b.Write(s.secret.Data[key])
-		return b
+	versions, err := s.getVersions()
+	if err != nil {
+		return err
+	}
+	version := 0
+	if len(versions) > 0 {
+		version = versions[0].version + 1
 	}
-	caPem = toBuffer(caPemKey)
-	certPem = toBuffer(certPemKey)
-	keyPem = toBuffer(keyPemKey)
-	return
-}
 
-func (s *SecretCertStorage) StoreCerts(caPem, certPem, keyPem *bytes.Buffer) error {
 	secret := &v1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: s.namespace,
-			Name:      secretName,
+			Name:      versionSecretName(version),
 		},
 		Immutable: pointer.Bool(true),
-		Data:      nil,
-		StringData: map[string]string{
-			caPemKey:   caPem.String(),
-			certPemKey: certPem.String(),
-			keyPemKey:  keyPem.String(),
+		Data: map[string][]byte{
+			caPemKey:    caPem.Bytes(),
+			caKeyPemKey: caKeyPem.Bytes(),
 		},
 		Type: v1.SecretTypeOpaque,
 	}
@@ -109,7 +149,8 @@ func (s *SecretCertStorage) StoreCerts(caPem, certPem, keyPem *bytes.Buffer) err
 	defer cancel()
 	if err := s.Create(ctx, secret); err != nil {
 		if errors.IsAlreadyExists(err) {
-			s.log.Info("certificates already stored in secret")
+			// the versions were read from an outdated cache
+			s.log.Info("certificates already stored in secret", "version", version)
 			return nil
 		}
 		return err
@@ -117,3 +158,192 @@ func (s *SecretCertStorage) StoreCerts(caPem, certPem, keyPem *bytes.Buffer) err
 
 	return nil
 }
+
+// SyncAdminCert issues the certificate of admin tools like snrctl with the CA of every stored version which doesn't
+// have one yet, and drops the certificates of removed versions
+func (s *SecretCertStorage) SyncAdminCert() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	versions, err := s.getVersions()
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		// the versions were read from an outdated cache, the certificate is issued by the next sync
+		return nil
+	}
+	objectMeta := metav1.ObjectMeta{
+		Namespace: s.namespace,
+		Name:      adminSecretName,
+	}
+	return s.syncIssuedCerts(objectMeta, versions, func(caPem, caKeyPem *bytes.Buffer, version int) (*bytes.Buffer, *bytes.Buffer, error) {
+		s.log.Info("Issuing admin certs", "version", version)
+		return CreateAdminCert(caPem, caKeyPem)
+	})
+}
+
+// syncIssuedCerts updates the secret with the given metadata to the certificates issued by the CAs of the given
+// versions, and to the TLS keys with the CAs of all versions and the certificate of the active version
+func (s *SecretCertStorage) syncIssuedCerts(objectMeta metav1.ObjectMeta, versions []certVersion, issue func(caPem, caKeyPem *bytes.Buffer, version int) (*bytes.Buffer, *bytes.Buffer, error)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	secret := &v1.Secret{}
+	exists := true
+	if err := s.Get(ctx, client.ObjectKey{Namespace: objectMeta.Namespace, Name: objectMeta.Name}, secret); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		exists = false
+		secret = &v1.Secret{
+			ObjectMeta: objectMeta,
+			Type:       v1.SecretTypeOpaque,
+		}
+	}
+
+	data := map[string][]byte{}
+	for _, version := range versions {
+		caKeyPem, found := version.secret.Data[caKeyPemKey]
+		if !found {
+			continue
+		}
+		certKey, keyKey := versionCertKeys(version.version)
+		if certPem, keyPem := secret.Data[certKey], secret.Data[keyKey]; certPem != nil && keyPem != nil {
+			data[certKey], data[keyKey] = certPem, keyPem
+			continue
+		}
+		certPem, keyPem, err := issue(bytes.NewBuffer(version.secret.Data[caPemKey]), bytes.NewBuffer(caKeyPem), version.version)
+		if err != nil {
+			return err
+		}
+		data[certKey], data[keyKey] = certPem.Bytes(), keyPem.Bytes()
+	}
+	data[caCertKey] = caBundle(versions)
+	if certPem, keyPem, found := activeIssuedCert(versions, activeVersion(versions, time.Now()), data); found {
+		data[v1.TLSCertKey], data[v1.TLSPrivateKeyKey] = certPem, keyPem
+	}
+
+	if !exists {
+		secret.Data = data
+		return s.Create(ctx, secret)
+	}
+	if reflect.DeepEqual(secret.Data, data) {
+		return nil
+	}
+	secret.Data = data
+	return s.Update(ctx, secret)
+}
+
+// SignNodeCert issues the certificate of the given node with the CA of the active version. The certificate is only
+// issued when the active version has a CA key, versions without one are activated soon after the operator was updated.
+func (s *SecretCertStorage) SignNodeCert(_ context.Context, _ *certificatesv1.CertificateSigningRequest, request *x509.CertificateRequest, nodeName string) ([]byte, error) {
+	s.mutex.Lock()
+	versions, err := s.getVersions()
+	s.mutex.Unlock()
+	if err != nil || len(versions) == 0 {
+		// the CA is created by the next sync
+		return nil, err
+	}
+	active := activeVersion(versions, time.Now())
+	caKeyPem, found := active.secret.Data[caKeyPemKey]
+	if !found {
+		return nil, nil
+	}
+	s.log.Info("Issuing node certs", "node", nodeName, "version", active.version)
+	certPem, err := signNodeCert(bytes.NewBuffer(active.secret.Data[caPemKey]), bytes.NewBuffer(caKeyPem), nodeName, request.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return certPem.Bytes(), nil
+}
+
+// syncCABundle updates the CA bundle of the agents to the CAs of all versions, and the CA of the active version
+func (s *SecretCertStorage) syncCABundle(ctx context.Context) error {
+	s.mutex.Lock()
+	versions, err := s.getVersions()
+	s.mutex.Unlock()
+	if err != nil || len(versions) == 0 {
+		// the versions were read from an outdated cache, the bundle is synced by the next sync
+		return err
+	}
+	var activeCAPem []byte
+	if active := activeVersion(versions, time.Now()); active.secret.Data[caKeyPemKey] != nil {
+		activeCAPem = active.secret.Data[caPemKey]
+	}
+	return syncCABundle(ctx, s.Client, s.namespace, caBundle(versions), activeCAPem)
+}
+
+// removeNodeSecrets removes the secrets with the certificates and keys of the nodes, which were issued by the operator
+// before the agents requested their certificates
+func (s *SecretCertStorage) removeNodeSecrets(ctx context.Context) error {
+	secrets := &v1.SecretList{}
+	if err := s.List(ctx, secrets, client.InNamespace(s.namespace), client.HasLabels{NodeSecretLabel}); err != nil {
+		return err
+	}
+	for i := range secrets.Items {
+		s.log.Info("Removing node certs", "secret", secrets.Items[i].Name)
+		if err := s.Delete(ctx, &secrets.Items[i]); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func versionCertKeys(version int) (certKey, keyKey string) {
+	return fmt.Sprintf("%d.%s", version, certPemKey), fmt.Sprintf("%d.%s", version, keyPemKey)
+}
+
+// getVersions returns the stored versions of the certificates, the newest first
+func (s *SecretCertStorage) getVersions() ([]certVersion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+	secrets := &v1.SecretList{}
+	if err := s.List(ctx, secrets, client.InNamespace(s.namespace)); err != nil {
+		return nil, err
+	}
+
+	var versions []certVersion
+	for i := range secrets.Items {
+		if version, ok := parseVersion(secrets.Items[i].Name); ok {
+			versions = append(versions, certVersion{version: version, secret: &secrets.Items[i]})
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].version > versions[j].version
+	})
+	return versions, nil
+}
+
+// activeVersion returns the newest version whose CA is trusted by all agents, because it was stored at least
+// ActivationDelay ago. The oldest version is always active.
+func activeVersion(versions []certVersion, now time.Time) certVersion {
+	for _, version := range versions[:len(versions)-1] {
+		if now.Sub(version.secret.CreationTimestamp.Time) >= ActivationDelay {
+			return version
+		}
+	}
+	return versions[len(versions)-1]
+}
+
+func versionSecretName(version int) string {
+	if version == 0 {
+		return secretName
+	}
+	return fmt.Sprintf("%s-%d", secretName, version)
+}
+
+func parseVersion(name string) (int, bool) {
+	if name == secretName {
+		return 0, true
+	}
+	suffix, found := strings.CutPrefix(name, secretName+"-")
+	if !found {
+		return 0, false
+	}
+	version, err := strconv.Atoi(suffix)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
@@ -2,42 +2,89 @@ package certificates
 
 import (
 	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
 )
 
 var _ = Describe("Certificates", func() {
 
+	parseCert := func(data []byte) *x509.Certificate {
+		block, _ := pem.Decode(data)
+		ExpectWithOffset(1, block).ToNot(BeNil())
+		cert, err := x509.ParseCertificate(block.Bytes)
+		ExpectWithOffset(1, err).ToNot(HaveOccurred())
+		return cert
+	}
+
 	Describe("Storage", func() {
 
 		Describe("Secret", func() {
 
 			It("should create and get certificates via Secret", func() {
 
-				caData := "myCA"
-				certData := "myCert"
-				keyData := "myKey"
-
-				toBuffer := func(data string) *bytes.Buffer {
-					b := &bytes.Buffer{}
-					b.WriteString(data)
-					return b
-				}
+				caPem, caKeyPem, err := CreateCA(v1alpha1.RSAPeerCertificatesKeyAlgorithm)
+				Expect(err).ToNot(HaveOccurred())
 
 				store := NewSecretCertStorage(k8sClient, ctrl.Log.WithName("TestSecretCertStore"), "default")
-				Expect(store.StoreCerts(toBuffer(caData), toBuffer(certData), toBuffer(keyData))).ToNot(HaveOccurred())
+				Expect(store.StoreCA(caPem, caKeyPem)).ToNot(HaveOccurred())
+
+				By("issuing the admin certificate")
+				Expect(store.SyncAdminCert()).To(Succeed())
+				var caBuf, certBuf *bytes.Buffer
+				Eventually(func() error {
+					caBuf, certBuf, _, err = NewAdminCertReader(k8sClient, "default", nil).GetCerts()
+					return err
+				}).Should(Succeed())
+				Expect(caBuf.String()).To(Equal(caPem.String()), "caData doesn't equal")
+				_, isNodeCert := NodeName(parseCert(certBuf.Bytes()))
+				Expect(isNodeCert).To(BeFalse(), "admin cert expected")
 
-				caBuf, certBuf, keyBuf, err := store.GetCerts()
+				By("signing node certificates with the active CA")
+				key, err := createPrivKey(parseCert(caPem.Bytes()).PublicKey)
 				Expect(err).ToNot(HaveOccurred())
-				Expect(caBuf.String()).To(Equal(caData), "caData doesn't equal")
-				Expect(certBuf.String()).To(Equal(certData), "certData doesn't equal")
-				Expect(keyBuf.String()).To(Equal(keyData), "keyData doesn't equal")
+				request := &x509.CertificateRequest{PublicKey: key.Public()}
+				certPem, err := store.SignNodeCert(context.Background(), nil, request, "storage-test-node")
+				Expect(err).ToNot(HaveOccurred())
+				nodeName, isNodeCert := NodeName(parseCert(certPem))
+				Expect(isNodeCert).To(BeTrue())
+				Expect(nodeName).To(Equal("storage-test-node"))
+				Expect(parseCert(certPem).CheckSignatureFrom(parseCert(caPem.Bytes()))).To(Succeed())
 
-			})
+				By("syncing the CA bundle without keys")
+				Expect(store.syncCABundle(context.Background())).To(Succeed())
+				bundle := &v1.ConfigMap{}
+				Expect(k8sClient.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: CABundleName}, bundle)).To(Succeed())
+				Expect(bundle.Data).To(Equal(map[string]string{caCertKey: caPem.String(), activeCACertKey: caPem.String()}))
 
+				By("not storing the CA key in the admin secret")
+				secret := &v1.Secret{}
+				Expect(k8sClient.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: adminSecretName}, secret)).To(Succeed())
+				for _, data := range secret.Data {
+					Expect(string(data)).ToNot(ContainSubstring(caKeyPem.String()))
+				}
+
+				By("removing the node secrets of previous versions")
+				nodeSecret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "self-node-remediation-peer-storage-test-node",
+					Labels: map[string]string{NodeSecretLabel: "storage-test-node"}}}
+				Expect(k8sClient.Create(context.Background(), nodeSecret)).To(Succeed())
+				Eventually(func(g Gomega) {
+					g.Expect(store.removeNodeSecrets(context.Background())).To(Succeed())
+					err := k8sClient.Get(context.Background(), client.ObjectKeyFromObject(nodeSecret), nodeSecret)
+					g.Expect(errors.IsNotFound(err)).To(BeTrue())
+				}).Should(Succeed())
+			})
 		})
 	})
 })
@@ -0,0 +1,80 @@
+package certificates
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// caCertKey is the key of the CA in TLS secrets, as used by cert-manager
+const caCertKey = "ca.crt"
+
+var _ CertStorageReader = &TLSSecretCertStorage{}
+
+// TLSSecretCertStorage reads the certificates from a TLS secret with the tls.crt, tls.key and ca.crt keys, like the
+// secrets of cert-manager. The secret is read on every call, so renewed certificates are used without restarts. Agents
+// use an uncached reader, so that they only need access to their own secret instead of listing all secrets.
+type TLSSecretCertStorage struct {
+	reader    client.Reader
+	namespace string
+	name      string
+}
+
+func NewTLSSecretCertStorage(reader client.Reader, namespace string, name string) *TLSSecretCertStorage {
+	return &TLSSecretCertStorage{
+		reader:    reader,
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+func (s *TLSSecretCertStorage) GetCerts() (caPem, certPem, keyPem *bytes.Buffer, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+	secret := &v1.Secret{}
+	if err := s.reader.Get(ctx, client.ObjectKey{Namespace: s.namespace, Name: s.name}, secret); err != nil {
+		return nil, nil, nil, err
+	}
+	return toTLSBuffers(func(key string) ([]byte, error) {
+		data, found := secret.Data[key]
+		if !found {
+			return nil, fmt.Errorf("key %s not found in secret %s/%s", key, s.namespace, s.name)
+		}
+		return data, nil
+	})
+}
+
+var _ CertStorageReader = &FileCertStorage{}
+
+// FileCertStorage reads the certificates from the files of a mounted TLS secret. The files are read on every call,
+// because mounted secrets are updated in place.
+type FileCertStorage struct {
+	dir string
+}
+
+func NewFileCertStorage(dir string) *FileCertStorage {
+	return &FileCertStorage{dir: dir}
+}
+
+func (s *FileCertStorage) GetCerts() (caPem, certPem, keyPem *bytes.Buffer, err error) {
+	return toTLSBuffers(func(key string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(s.dir, key))
+	})
+}
+
+func toTLSBuffers(read func(key string) ([]byte, error)) (caPem, certPem, keyPem *bytes.Buffer, err error) {
+	var buffers []*bytes.Buffer
+	for _, key := range []string{caCertKey, v1.TLSCertKey, v1.TLSPrivateKeyKey} {
+		data, err := read(key)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		buffers = append(buffers, bytes.NewBuffer(data))
+	}
+	return buffers[0], buffers[1], buffers[2], nil
+}
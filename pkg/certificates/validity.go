@@ -0,0 +1,36 @@
+package certificates
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+)
+
+// CheckValidity returns an error if the stored certificates can't be used at the given time,
+// else it returns when the first of them expires
+func CheckValidity(certReader CertStorageReader, now time.Time) (time.Time, error) {
+	caPem, certPem, _, err := certReader.GetCerts()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get certificates: %w", err)
+	}
+
+	var expiry time.Time
+	for name, certPem := range map[string][]byte{"ca": caPem.Bytes(), "tls": certPem.Bytes()} {
+		block, _ := pem.Decode(certPem)
+		if block == nil {
+			return time.Time{}, fmt.Errorf("failed to decode %s certificate", name)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse %s certificate: %w", name, err)
+		}
+		if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+			return time.Time{}, fmt.Errorf("%s certificate is only valid from %s until %s", name, cert.NotBefore, cert.NotAfter)
+		}
+		if expiry.IsZero() || cert.NotAfter.Before(expiry) {
+			expiry = cert.NotAfter
+		}
+	}
+	return expiry, nil
+}
@@ -0,0 +1,73 @@
+package controlplane
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+
+	selfNodeRemediation "github.com/medik8s/self-node-remediation/api"
+)
+
+const (
+	etcdContainerName = "etcd"
+)
+
+// ControlPlanePeersStatus sums up the responses of the other control plane peers
+type ControlPlanePeersStatus struct {
+	// Peers is the number of known control plane peers
+	Peers int `json:"peers"`
+	// Responses is the number of control plane peers which responded
+	Responses int `json:"responses"`
+	// HealthyEtcdMembers is the number of control plane peers which reported a healthy etcd member
+	HealthyEtcdMembers int `json:"healthyEtcdMembers"`
+}
+
+// CanBeReached returns true if any control plane peer responded
+func (s ControlPlanePeersStatus) CanBeReached() bool {
+	return s.Responses > 0
+}
+
+// GetLocalEtcdMemberStatus returns the health of the etcd member running on this node.
+// The kubelet is asked for the etcd static pod, so this works without API server access.
+func (manager *Manager) GetLocalEtcdMemberStatus(ctx context.Context) selfNodeRemediation.EtcdMemberStatusCode {
+	if !manager.IsControlPlane() {
+		return selfNodeRemediation.NoEtcdMember
+	}
+
+	pods, err := manager.kubelet.getPods(ctx)
+	if err != nil {
+		manager.log.Error(err, "failed to get pods from kubelet, etcd member status is unknown")
+		return selfNodeRemediation.EtcdMemberUnknown
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !isEtcdPod(pod) {
+			continue
+		}
+		if pod.DeletionTimestamp == nil && isPodReady(pod) {
+			return selfNodeRemediation.EtcdMemberHealthy
+		}
+		return selfNodeRemediation.EtcdMemberUnhealthy
+	}
+	// e.g. external etcd
+	return selfNodeRemediation.NoEtcdMember
+}
+
+func isEtcdPod(pod *corev1.Pod) bool {
+	for _, container := range pod.Spec.Containers {
+		if container.Name == etcdContainerName {
+			return true
+		}
+	}
+	return false
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package controlplane
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	selfNodeRemediation "github.com/medik8s/self-node-remediation/api"
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+	"github.com/medik8s/self-node-remediation/pkg/peers"
+)
+
+var _ = Describe("Etcd member status", func() {
+
+	var manager *Manager
+	var kubeletPods *corev1.PodList
+
+	etcdPod := func(ready bool) corev1.Pod {
+		status := corev1.ConditionFalse
+		if ready {
+			status = corev1.ConditionTrue
+		}
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "etcd-master-0"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: etcdContainerName}}},
+			Status:     corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}},
+		}
+	}
+
+	BeforeEach(func() {
+		kubeletPods = &corev1.PodList{Items: []corev1.Pod{etcdPod(true)}}
+		kubelet := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Path).To(Equal(kubeletPodsPath))
+			Expect(json.NewEncoder(w).Encode(kubeletPods)).To(Succeed())
+		}))
+		DeferCleanup(kubelet.Close)
+
+		manager = &Manager{
+			nodeName: "master-0",
+			nodeRole: peers.ControlPlane,
+			kubelet: &kubeletProbe{
+				mode:       v1alpha1.ReadOnlyKubeletHealthCheckMode,
+				httpClient: kubelet.Client(),
+				log:        ctrl.Log.WithName("test"),
+			},
+			log: ctrl.Log.WithName("test"),
+		}
+		manager.kubelet.host, manager.kubelet.port = splitHostPort(kubelet.Listener.Addr().String())
+	})
+
+	Context("local etcd member status", func() {
+		It("should be healthy when the etcd pod is ready", func() {
+			Expect(manager.GetLocalEtcdMemberStatus(context.Background())).To(Equal(selfNodeRemediation.EtcdMemberHealthy))
+		})
+
+		It("should be unhealthy when the etcd pod isn't ready", func() {
+			kubeletPods.Items = []corev1.Pod{etcdPod(false)}
+			Expect(manager.GetLocalEtcdMemberStatus(context.Background())).To(Equal(selfNodeRemediation.EtcdMemberUnhealthy))
+		})
+
+		It("should report no member without etcd pod", func() {
+			kubeletPods.Items = nil
+			Expect(manager.GetLocalEtcdMemberStatus(context.Background())).To(Equal(selfNodeRemediation.NoEtcdMember))
+		})
+
+		It("should report no member on workers", func() {
+			manager.nodeRole = peers.Worker
+			Expect(manager.GetLocalEtcdMemberStatus(context.Background())).To(Equal(selfNodeRemediation.NoEtcdMember))
+		})
+	})
+})
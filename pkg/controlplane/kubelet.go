@@ -0,0 +1,256 @@
+package controlplane
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+)
+
+const (
+	KubeletHealthCheckModeEnvVar       = "KUBELET_HEALTH_CHECK_MODE"
+	KubeletHealthCheckPortEnvVar       = "KUBELET_HEALTH_CHECK_PORT"
+	KubeletServingCAConfigMapEnvVar    = "KUBELET_SERVING_CA_CONFIGMAP"
+	KubeletServingCAConfigMapKeyEnvVar = "KUBELET_SERVING_CA_CONFIGMAP_KEY"
+	NodeIPEnvVar                       = "MY_NODE_IP"
+
+	kubeletSecurePort   = 10250
+	kubeletReadOnlyPort = 10255
+	kubeletHealthzPath  = "/healthz"
+	kubeletPodsPath     = "/pods"
+	kubeletTimeout      = 5 * time.Second
+	defaultCAKey        = "ca-bundle.crt"
+)
+
+type kubeletStatus int
+
+const (
+	// kubeletDown means that the kubelet couldn't be reached, or it didn't present a trusted serving certificate
+	kubeletDown kubeletStatus = iota
+	// kubeletUnhealthy means that the kubelet is reachable but reported being unhealthy
+	kubeletUnhealthy
+	// kubeletUnauthorized means that the kubelet is reachable, but rejected the health request
+	kubeletUnauthorized
+	// kubeletHealthy means that the kubelet reported being healthy
+	kubeletHealthy
+)
+
+func (s kubeletStatus) String() string {
+	switch s {
+	case kubeletDown:
+		return "Down"
+	case kubeletUnhealthy:
+		return "Unhealthy"
+	case kubeletUnauthorized:
+		return "Unauthorized"
+	case kubeletHealthy:
+		return "Healthy"
+	default:
+		return "Unknown"
+	}
+}
+
+// kubeletProbe queries the /healthz endpoint of the kubelet running on this node.
+// The agent pods don't use the host network, so the kubelet is addressed with the node's host IP, which avoids
+// depending on node name DNS resolution.
+type kubeletProbe struct {
+	mode                   v1alpha1.KubeletHealthCheckMode
+	host                   string
+	port                   int
+	readOnlyPort           int
+	servingCAConfigMapName string
+	servingCAConfigMapNs   string
+	servingCAConfigMapKey  string
+	cfg                    *rest.Config
+	httpClient             *http.Client
+	log                    logr.Logger
+}
+
+func newKubeletProbeFromEnv(cfg *rest.Config, log logr.Logger) (*kubeletProbe, error) {
+	probe := &kubeletProbe{
+		mode:         v1alpha1.KubeletHealthCheckMode(os.Getenv(KubeletHealthCheckModeEnvVar)),
+		host:         os.Getenv(NodeIPEnvVar),
+		readOnlyPort: kubeletReadOnlyPort,
+		cfg:          cfg,
+		log:          log,
+	}
+
+	switch probe.mode {
+	case "":
+		probe.mode = v1alpha1.AuthenticatedKubeletHealthCheckMode
+	case v1alpha1.AuthenticatedKubeletHealthCheckMode, v1alpha1.ReadOnlyKubeletHealthCheckMode:
+	default:
+		return nil, fmt.Errorf("unsupported kubelet health check mode %q", probe.mode)
+	}
+
+	if portEnv := os.Getenv(KubeletHealthCheckPortEnvVar); portEnv != "" && portEnv != "0" {
+		port, err := strconv.Atoi(portEnv)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s env var: %w", KubeletHealthCheckPortEnvVar, err)
+		}
+		probe.port = port
+	} else if probe.mode == v1alpha1.ReadOnlyKubeletHealthCheckMode {
+		probe.port = kubeletReadOnlyPort
+	} else {
+		probe.port = kubeletSecurePort
+	}
+
+	if configMap := os.Getenv(KubeletServingCAConfigMapEnvVar); configMap != "" {
+		parts := strings.SplitN(configMap, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid %s env var, expected namespace/name: %s", KubeletServingCAConfigMapEnvVar, configMap)
+		}
+		probe.servingCAConfigMapNs, probe.servingCAConfigMapName = parts[0], parts[1]
+		probe.servingCAConfigMapKey = os.Getenv(KubeletServingCAConfigMapKeyEnvVar)
+		if probe.servingCAConfigMapKey == "" {
+			probe.servingCAConfigMapKey = defaultCAKey
+		}
+	}
+
+	return probe, nil
+}
+
+// init prepares the http client. It needs to run while the API server is still reachable, because the
+// kubelet serving CA might be read from a ConfigMap. In Authenticated mode the agent's service account token is
+// used for authentication, and the kubelet serving certificate is verified.
+func (p *kubeletProbe) init(ctx context.Context, reader client.Reader) error {
+	if p.host == "" {
+		return fmt.Errorf("%s env var is empty, can't address the kubelet", NodeIPEnvVar)
+	}
+
+	if p.mode == v1alpha1.ReadOnlyKubeletHealthCheckMode {
+		p.httpClient = &http.Client{Timeout: kubeletTimeout}
+		return nil
+	}
+
+	kubeletCfg := rest.CopyConfig(p.cfg)
+	kubeletCfg.Host = "https://" + net.JoinHostPort(p.host, strconv.Itoa(p.port))
+	kubeletCfg.APIPath = ""
+	kubeletCfg.Timeout = kubeletTimeout
+	kubeletCfg.TLSClientConfig.ServerName = ""
+	kubeletCfg.TLSClientConfig.Insecure = false
+
+	if p.servingCAConfigMapName != "" {
+		cm := &corev1.ConfigMap{}
+		key := client.ObjectKey{Namespace: p.servingCAConfigMapNs, Name: p.servingCAConfigMapName}
+		if err := reader.Get(ctx, key, cm); err != nil {
+			// the kubelet serving certificate might be signed by the API server's CA, so we still have a chance
+			p.log.Error(err, "failed to get kubelet serving CA ConfigMap, falling back to the API server CA", "configmap", key)
+		} else if caBundle := cm.Data[p.servingCAConfigMapKey]; caBundle == "" {
+			p.log.Error(fmt.Errorf("kubelet serving CA ConfigMap %s has no data for key %s", key, p.servingCAConfigMapKey),
+				"falling back to the API server CA")
+		} else {
+			kubeletCfg.TLSClientConfig.CAFile = ""
+			kubeletCfg.TLSClientConfig.CAData = []byte(caBundle)
+		}
+	}
+
+	httpClient, err := rest.HTTPClientFor(kubeletCfg)
+	if err != nil {
+		return fmt.Errorf("failed to create kubelet http client: %w", err)
+	}
+	p.httpClient = httpClient
+	return nil
+}
+
+func (p *kubeletProbe) url() string {
+	return p.urlFor(kubeletHealthzPath)
+}
+
+func (p *kubeletProbe) urlFor(path string) string {
+	scheme := "https"
+	if p.mode == v1alpha1.ReadOnlyKubeletHealthCheckMode {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(p.host, strconv.Itoa(p.port)), path)
+}
+
+// getPods returns the pods the kubelet is running, including static pods. This works without API server access.
+func (p *kubeletProbe) getPods(ctx context.Context) (*corev1.PodList, error) {
+	if p.httpClient == nil {
+		return nil, fmt.Errorf("kubelet health probe isn't initialized")
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, kubeletTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, p.urlFor(kubeletPodsPath), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d when listing kubelet pods", resp.StatusCode)
+	}
+
+	pods := &corev1.PodList{}
+	if err := json.NewDecoder(resp.Body).Decode(pods); err != nil {
+		return nil, fmt.Errorf("failed to decode kubelet pods: %w", err)
+	}
+	return pods, nil
+}
+
+// check queries the kubelet's /healthz endpoint and returns the kubelet's status
+func (p *kubeletProbe) check(ctx context.Context) kubeletStatus {
+	if p.httpClient == nil {
+		p.log.Info("kubelet health probe isn't initialized, considering kubelet down")
+		return kubeletDown
+	}
+	return p.checkURL(ctx, p.httpClient, p.url())
+}
+
+// checkReadOnly queries the /healthz endpoint of the kubelet's read-only port. It's used when the kubelet rejected the
+// request of the configured mode, because an unauthorized request doesn't tell whether the kubelet is healthy.
+func (p *kubeletProbe) checkReadOnly(ctx context.Context) kubeletStatus {
+	url := fmt.Sprintf("http://%s%s", net.JoinHostPort(p.host, strconv.Itoa(p.readOnlyPort)), kubeletHealthzPath)
+	return p.checkURL(ctx, &http.Client{Timeout: kubeletTimeout}, url)
+}
+
+func (p *kubeletProbe) checkURL(ctx context.Context, httpClient *http.Client, url string) kubeletStatus {
+	reqCtx, cancel := context.WithTimeout(ctx, kubeletTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, url, nil)
+	if err != nil {
+		p.log.Error(err, "failed to create kubelet health request", "url", url)
+		return kubeletDown
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		p.log.Error(err, "kubelet service is down", "url", url)
+		return kubeletDown
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	switch {
+	case resp.StatusCode == http.StatusOK && strings.TrimSpace(string(body)) == "ok":
+		return kubeletHealthy
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		p.log.Error(fmt.Errorf("kubelet rejected health request with status code %d", resp.StatusCode),
+			"kubelet is reachable but its health couldn't be verified", "url", url)
+		return kubeletUnauthorized
+	default:
+		p.log.Error(fmt.Errorf("kubelet health check failed with status code %d", resp.StatusCode),
+			"kubelet is reachable but unhealthy", "url", url, "response", string(body))
+		return kubeletUnhealthy
+	}
+}
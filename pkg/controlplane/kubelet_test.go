@@ -0,0 +1,160 @@
+package controlplane
+
+import (
+	"context"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+)
+
+var _ = Describe("Kubelet health probe", func() {
+
+	var kubelet *httptest.Server
+	var responseCode int
+	var responseBody string
+	var authHeader string
+
+	BeforeEach(func() {
+		responseCode, responseBody, authHeader = http.StatusOK, "ok", ""
+		kubelet = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader = r.Header.Get("Authorization")
+			if r.URL.Path != kubeletHealthzPath {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(responseCode)
+			_, _ = w.Write([]byte(responseBody))
+		}))
+		DeferCleanup(kubelet.Close)
+
+		host, port, err := net.SplitHostPort(kubelet.Listener.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+		setEnv(NodeIPEnvVar, host)
+		setEnv(KubeletHealthCheckPortEnvVar, port)
+	})
+
+	kubeletCA := func() string {
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: kubelet.Certificate().Raw}))
+	}
+
+	newInitializedProbe := func(cfg *rest.Config, objs ...corev1.ConfigMap) *kubeletProbe {
+		probe, err := newKubeletProbeFromEnv(cfg, ctrl.Log.WithName("test"))
+		Expect(err).ToNot(HaveOccurred())
+		builder := fake.NewClientBuilder()
+		for i := range objs {
+			builder.WithObjects(&objs[i])
+		}
+		Expect(probe.init(context.Background(), builder.Build())).To(Succeed())
+		return probe
+	}
+
+	It("should default to the authenticated mode", func() {
+		Expect(os.Unsetenv(KubeletHealthCheckPortEnvVar)).To(Succeed())
+		probe, err := newKubeletProbeFromEnv(&rest.Config{}, ctrl.Log.WithName("test"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(probe.mode).To(Equal(v1alpha1.AuthenticatedKubeletHealthCheckMode))
+		Expect(probe.port).To(Equal(kubeletSecurePort))
+	})
+
+	It("should reject an invalid mode", func() {
+		setEnv(KubeletHealthCheckModeEnvVar, "Insecure")
+		_, err := newKubeletProbeFromEnv(&rest.Config{}, ctrl.Log.WithName("test"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should authenticate and verify the serving CA", func() {
+		setEnv(KubeletServingCAConfigMapEnvVar, "test-ns/kubelet-ca")
+		cm := corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "kubelet-ca"},
+			Data:       map[string]string{defaultCAKey: kubeletCA()},
+		}
+		probe := newInitializedProbe(&rest.Config{BearerToken: "token"}, cm)
+
+		Expect(probe.check(context.Background())).To(Equal(kubeletHealthy))
+		Expect(authHeader).To(Equal("Bearer token"))
+
+		responseCode, responseBody = http.StatusInternalServerError, "[-]syncloop failed"
+		Expect(probe.check(context.Background())).To(Equal(kubeletUnhealthy))
+
+		responseCode, responseBody = http.StatusForbidden, ""
+		Expect(probe.check(context.Background())).To(Equal(kubeletUnauthorized))
+	})
+
+	It("should only consider a kubelet which rejects the health request running if its read-only port is healthy", func() {
+		setEnv(KubeletServingCAConfigMapEnvVar, "test-ns/kubelet-ca")
+		cm := corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "kubelet-ca"},
+			Data:       map[string]string{defaultCAKey: kubeletCA()},
+		}
+		probe := newInitializedProbe(&rest.Config{BearerToken: "token"}, cm)
+		manager := &Manager{kubelet: probe, log: ctrl.Log.WithName("test")}
+		responseCode, responseBody = http.StatusForbidden, ""
+
+		By("considering the kubelet not running without a read-only port")
+		unusedListener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		_, probe.readOnlyPort = splitHostPort(unusedListener.Addr().String())
+		Expect(unusedListener.Close()).To(Succeed())
+		Expect(manager.IsKubeletServiceRunning()).To(BeFalse())
+
+		By("considering the kubelet running when its read-only port is healthy")
+		readOnlyKubelet := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("ok"))
+		}))
+		DeferCleanup(readOnlyKubelet.Close)
+		_, probe.readOnlyPort = splitHostPort(readOnlyKubelet.Listener.Addr().String())
+		Expect(manager.IsKubeletServiceRunning()).To(BeTrue())
+	})
+
+	It("should consider an untrusted kubelet down", func() {
+		probe := newInitializedProbe(&rest.Config{BearerToken: "token"})
+		Expect(probe.check(context.Background())).To(Equal(kubeletDown))
+	})
+
+	It("should consider the kubelet down if it can't be reached", func() {
+		probe := newInitializedProbe(&rest.Config{TLSClientConfig: rest.TLSClientConfig{CAData: []byte(kubeletCA())}})
+		kubelet.Close()
+		Expect(probe.check(context.Background())).To(Equal(kubeletDown))
+	})
+
+	It("should query the read-only port without authentication", func() {
+		readOnlyKubelet := httptest.NewServer(kubelet.Config.Handler)
+		DeferCleanup(readOnlyKubelet.Close)
+		_, port, err := net.SplitHostPort(readOnlyKubelet.Listener.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+		setEnv(KubeletHealthCheckModeEnvVar, string(v1alpha1.ReadOnlyKubeletHealthCheckMode))
+		setEnv(KubeletHealthCheckPortEnvVar, port)
+
+		probe := newInitializedProbe(&rest.Config{BearerToken: "token"})
+		Expect(probe.url()).To(HavePrefix("http://"))
+		Expect(probe.check(context.Background())).To(Equal(kubeletHealthy))
+		Expect(authHeader).To(BeEmpty())
+	})
+})
+
+func setEnv(key, value string) {
+	Expect(os.Setenv(key, value)).To(Succeed())
+	DeferCleanup(func() { _ = os.Unsetenv(key) })
+}
+
+func splitHostPort(addr string) (string, int) {
+	host, port, err := net.SplitHostPort(addr)
+	Expect(err).ToNot(HaveOccurred())
+	portNumber, err := strconv.Atoi(port)
+	Expect(err).ToNot(HaveOccurred())
+	return host, portNumber
+}
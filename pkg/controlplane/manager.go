@@ -2,54 +2,56 @@ package controlplane
 
 import (
 	"context"
-	"crypto/tls"
-	"errors"
 	"fmt"
-	"net/http"
-	"os"
-	"time"
 
 	"github.com/go-logr/logr"
-	"github.com/go-ping/ping"
 	"github.com/medik8s/common/pkg/nodes"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	"github.com/medik8s/self-node-remediation/pkg/certificates"
+	"github.com/medik8s/self-node-remediation/pkg/endpointhealth"
 	"github.com/medik8s/self-node-remediation/pkg/peers"
 )
 
-const (
-	kubeletPort = "10250"
-)
-
 // Manager contains logic and info needed to fence and remediate controlplane nodes
 type Manager struct {
-	nodeName                     string
-	nodeRole                     peers.Role
-	endpointHealthCheckUrl       string
-	wasEndpointAccessibleAtStart bool
-	client                       client.Client
-	log                          logr.Logger
+	nodeName        string
+	nodeRole        peers.Role
+	endpointChecker *endpointhealth.Checker
+	kubelet         *kubeletProbe
+	client          client.Client
+	apiReader       client.Reader
+	log             logr.Logger
 }
 
-// NewManager inits a new Manager return nil if init fails
-func NewManager(nodeName string, myClient client.Client) *Manager {
-	return &Manager{
-		nodeName:                     nodeName,
-		endpointHealthCheckUrl:       os.Getenv("END_POINT_HEALTH_CHECK_URL"),
-		client:                       myClient,
-		wasEndpointAccessibleAtStart: false,
-		log:                          ctrl.Log.WithName("controlPlane").WithName("Manager"),
+// NewManager inits a new Manager, returns an error if the kubelet health check configuration is invalid
+func NewManager(nodeName string, myClient client.Client, apiReader client.Reader, cfg *rest.Config, endpointChecker *endpointhealth.Checker) (*Manager, error) {
+	log := ctrl.Log.WithName("controlPlane").WithName("Manager")
+	kubelet, err := newKubeletProbeFromEnv(cfg, log.WithName("kubelet"))
+	if err != nil {
+		return nil, err
 	}
+	return &Manager{
+		nodeName:        nodeName,
+		endpointChecker: endpointChecker,
+		kubelet:         kubelet,
+		client:          myClient,
+		apiReader:       apiReader,
+		log:             log,
+	}, nil
 }
 
-func (manager *Manager) Start(_ context.Context) error {
+func (manager *Manager) Start(ctx context.Context) error {
 	if err := manager.initializeManager(); err != nil {
 		return err
 	}
+	if err := manager.kubelet.init(ctx, manager.apiReader); err != nil {
+		// don't prevent the agent from starting, the kubelet will be considered down during diagnostics
+		manager.log.Error(err, "failed to initialize kubelet health check")
+	}
 	return nil
 }
 
@@ -57,41 +59,6 @@ func (manager *Manager) IsControlPlane() bool {
 	return manager.nodeRole == peers.ControlPlane
 }
 
-func (manager *Manager) IsControlPlaneHealthy(workerPeerResponse peers.Response, canOtherControlPlanesBeReached bool) bool {
-	switch workerPeerResponse.Reason {
-	//reported unhealthy by worker peers
-	case peers.UnHealthyBecausePeersResponse:
-		return false
-	case peers.UnHealthyBecauseNodeIsIsolated:
-		return canOtherControlPlanesBeReached
-	//reported healthy by worker peers
-	case peers.HealthyBecauseErrorsThresholdNotReached, peers.HealthyBecauseCRNotFound, peers.HealthyBecauseNoPeersResponseNotReachedTimeout:
-		return true
-	//controlPlane node has connection to most workers, we assume it's not isolated (or at least that the controlPlane node that does not have worker peers quorum will reboot)
-	case peers.HealthyBecauseMostPeersCantAccessAPIServer:
-		return manager.isDiagnosticsPassed()
-	case peers.HealthyBecauseNoPeersWereFound:
-		return manager.isDiagnosticsPassed() && canOtherControlPlanesBeReached
-
-	default:
-		errorText := "node is considered unhealthy by worker peers for an unknown reason"
-		manager.log.Error(errors.New(errorText), errorText, "reason", workerPeerResponse.Reason, "node name", manager.nodeName)
-		return false
-	}
-
-}
-
-func (manager *Manager) isDiagnosticsPassed() bool {
-	manager.log.Info("Starting control-plane node diagnostics")
-	if manager.isEndpointAccessLost() {
-		return false
-	} else if !manager.isKubeletServiceRunning() {
-		return false
-	}
-	manager.log.Info("Control-plane node diagnostics passed successfully")
-	return true
-}
-
 func wrapWithInitError(err error) error {
 	return fmt.Errorf("error initializing controlplane handler [%w]", err)
 }
@@ -108,8 +75,6 @@ func (manager *Manager) initializeManager() error {
 		return wrapWithInitError(err)
 	}
 	manager.setNodeRole(node)
-
-	manager.wasEndpointAccessibleAtStart = manager.isEndpointAccessible()
 	return nil
 }
 
@@ -121,54 +86,28 @@ func (manager *Manager) setNodeRole(node corev1.Node) {
 	}
 }
 
-func (manager *Manager) isEndpointAccessLost() bool {
-	if !manager.wasEndpointAccessibleAtStart {
+// IsEndpointAccessLost returns true if the endpoint health checks failed
+func (manager *Manager) IsEndpointAccessLost() bool {
+	if manager.endpointChecker == nil {
 		return false
 	}
-	return !manager.isEndpointAccessible()
+	return manager.endpointChecker.IsAccessLost(context.Background())
 }
 
-func (manager *Manager) isEndpointAccessible() bool {
-	if len(manager.endpointHealthCheckUrl) == 0 {
+// IsKubeletServiceRunning returns true if the kubelet is considered to be running
+func (manager *Manager) IsKubeletServiceRunning() bool {
+	status := manager.kubelet.check(context.Background())
+	manager.log.Info("kubelet health check finished", "status", status.String())
+	switch status {
+	case kubeletHealthy:
 		return true
-	}
-
-	pinger, err := ping.NewPinger(manager.endpointHealthCheckUrl)
-	if err != nil {
-		manager.log.Error(err, "could not access endpoint", "endpoint URL", manager.endpointHealthCheckUrl)
-		return false
-	}
-	pinger.Count = 3
-	pinger.Timeout = time.Second * 5
-
-	if err := pinger.Run(); err != nil {
-		manager.log.Error(err, "could not access endpoint", "endpoint URL", manager.endpointHealthCheckUrl)
-		return false
-	}
-	return true
-}
-
-func (manager *Manager) isKubeletServiceRunning() bool {
-	url := fmt.Sprintf("https://%s:%s/pods", manager.nodeName, kubeletPort)
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-			MinVersion:         certificates.TLSMinVersion,
-		},
-	}
-	httpClient := &http.Client{Transport: tr}
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		manager.log.Error(err, "failed to create a kubelet service request", "node name", manager.nodeName)
-		return false
-	}
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		manager.log.Error(err, "kubelet service is down", "node name", manager.nodeName)
+	case kubeletUnauthorized:
+		// the kubelet is up, but the agent isn't allowed to query its health, which doesn't prove that it's healthy. It's
+		// only considered running when its read-only port reports being healthy.
+		readOnlyStatus := manager.kubelet.checkReadOnly(context.Background())
+		manager.log.Info("kubelet rejected the health check, checked the read-only port instead", "status", readOnlyStatus.String())
+		return readOnlyStatus == kubeletHealthy
+	default:
 		return false
 	}
-	defer resp.Body.Close()
-	return true
 }
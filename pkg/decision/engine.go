@@ -0,0 +1,264 @@
+// Package decision decides whether this node is healthy, based on the observations of the API connectivity check.
+// The decision is a pure function of its config, the state of the previous round and the observations, so recorded
+// observations can be replayed offline.
+package decision
+
+import (
+	"fmt"
+
+	selfNodeRemediation "github.com/medik8s/self-node-remediation/api"
+	"github.com/medik8s/self-node-remediation/pkg/controlplane"
+	"github.com/medik8s/self-node-remediation/pkg/peers"
+	"github.com/medik8s/self-node-remediation/pkg/utils"
+)
+
+const (
+	ReasonApiServerReachable           = "API server is reachable, node is considered healthy"
+	ReasonEndpointAccessLostOnWorker   = "Endpoint health checks failed, worker node is considered unhealthy"
+	ReasonControlPlanePeersReachable   = "Other control plane nodes can be reached, node is considered healthy"
+	ReasonControlPlanePeersUnreachable = "No peers were found, and other control plane nodes can't be reached, node is considered unhealthy"
+	ReasonDiagnosticsFailed            = "Control plane node diagnostics failed, node is considered unhealthy"
+	ReasonUnknownWorkerPeersReason     = "Node is considered unhealthy by worker peers for an unknown reason"
+	ReasonSelfFencingPostponed         = "Self fencing was postponed because rebooting the node would break etcd quorum"
+)
+
+// evaluation holds the trace of a single Evaluate call
+type evaluation struct {
+	cfg   Config
+	state State
+	obs   Observation
+	trace []string
+}
+
+func (e *evaluation) explain(format string, args ...interface{}) {
+	e.trace = append(e.trace, fmt.Sprintf(format, args...))
+}
+
+// Evaluate decides whether the node is healthy. When the decision depends on an input which wasn't observed yet, the
+// returned Result only holds a Need for it. The caller is expected to add the input to the observation, and to call
+// Evaluate again, until a verdict is returned. The state of the verdict is the input for the next round.
+func Evaluate(cfg Config, state State, obs Observation) Result {
+	e := &evaluation{cfg: cfg, state: state, obs: obs}
+	verdict, need := e.evaluate()
+	if need != nil {
+		return Result{Need: need}
+	}
+	return Result{Verdict: verdict, State: e.state, Trace: e.trace}
+}
+
+func (e *evaluation) evaluate() (Verdict, *Need) {
+	if e.obs.ApiError == "" {
+		e.state.ErrorCount = 0
+		e.explain("API server is reachable")
+		return Verdict{Healthy: true, Reason: ReasonApiServerReachable}, nil
+	}
+	e.explain("API server check failed: %s", e.obs.ApiError)
+
+	workerPeersResponse, need := e.evaluateWorkerPeers()
+	if need != nil {
+		return Verdict{}, need
+	}
+	e.explain("worker peers response: %s", workerPeersResponse.Reason)
+
+	if !e.obs.IsControlPlane {
+		return e.evaluateWorker(workerPeersResponse)
+	}
+	return e.evaluateControlPlane(workerPeersResponse)
+}
+
+// evaluateWorkerPeers counts the API server errors, and when they reached the threshold, asks the worker peers
+// in batches whether this node is healthy
+func (e *evaluation) evaluateWorkerPeers() (peers.Response, *Need) {
+	e.state.ErrorCount++
+	if e.state.ErrorCount < e.cfg.MaxErrorsThreshold {
+		e.explain("error count %d is below threshold %d, not asking peers", e.state.ErrorCount, e.cfg.MaxErrorsThreshold)
+		return peers.Response{IsHealthy: true, Reason: peers.HealthyBecauseErrorsThresholdNotReached}, nil
+	}
+
+	workerPeers := e.obs.WorkerPeers
+	if workerPeers == nil {
+		return peers.Response{}, &Need{Type: NeedWorkerPeers}
+	}
+	e.explain("error count %d reached threshold %d, asking %d worker peers", e.state.ErrorCount, e.cfg.MaxErrorsThreshold, workerPeers.Count)
+	if workerPeers.Count == 0 {
+		return peers.Response{IsHealthy: true, Reason: peers.HealthyBecauseNoPeersWereFound}, nil
+	}
+
+	apiErrorsResponsesSum := 0
+	remaining := workerPeers.Count
+	for i, batch := range workerPeers.Batches {
+		e.explain("worker peers batch %d of %d peers: %d healthy, %d unhealthy, %d API errors, %d busy, %d without response",
+			i+1, batch.Size, batch.Healthy, batch.Unhealthy, batch.ApiErrors, batch.Busy, batch.NoResponse)
+		if batch.responses() > 0 {
+			e.state.TimeOfLastPeerResponse = e.obs.Time
+		}
+		if batch.Healthy > 0 {
+			e.state.ErrorCount = 0
+			return peers.Response{IsHealthy: true, Reason: peers.HealthyBecauseCRNotFound}, nil
+		}
+		if batch.Unhealthy > 0 {
+			return peers.Response{IsHealthy: false, Reason: peers.UnHealthyBecausePeersResponse}, nil
+		}
+		if batch.ApiErrors > 0 {
+			apiErrorsResponsesSum += batch.ApiErrors
+			// TODO: consider using [m|n]hc.spec.maxUnhealthy instead of 50%
+			if apiErrorsResponsesSum > workerPeers.Count/2 {
+				// assuming this is a control plane failure as others can't access api-server as well
+				e.explain("%d of %d worker peers can't access the API server", apiErrorsResponsesSum, workerPeers.Count)
+				return peers.Response{IsHealthy: true, Reason: peers.HealthyBecauseMostPeersCantAccessAPIServer}, nil
+			}
+		}
+		remaining -= batch.Size
+	}
+	if remaining > 0 {
+		return peers.Response{}, &Need{Type: NeedWorkerPeerBatch, BatchSize: utils.GetNextBatchSize(workerPeers.Count, remaining)}
+	}
+
+	// we asked all peers
+	// MaxTimeForNoPeersResponse check prevents the node from being considered unhealthy in case of short network outages
+	timeWithoutPeersResponse := e.obs.Time.Sub(e.state.TimeOfLastPeerResponse)
+	e.explain("no peer responded for %s, threshold is %s", timeWithoutPeersResponse, e.cfg.MaxTimeForNoPeersResponse)
+	if timeWithoutPeersResponse > e.cfg.MaxTimeForNoPeersResponse {
+		return peers.Response{IsHealthy: false, Reason: peers.UnHealthyBecauseNodeIsIsolated}, nil
+	}
+	return peers.Response{IsHealthy: true, Reason: peers.HealthyBecauseNoPeersResponseNotReachedTimeout}, nil
+}
+
+func (e *evaluation) evaluateWorker(workerPeersResponse peers.Response) (Verdict, *Need) {
+	verdict := Verdict{Healthy: workerPeersResponse.IsHealthy, Reason: string(workerPeersResponse.Reason)}
+	if !workerPeersResponse.IsHealthy || !e.cfg.EndpointChecksOnWorkers {
+		return verdict, nil
+	}
+
+	// peers couldn't confirm that this node is healthy, so check the endpoints
+	switch workerPeersResponse.Reason {
+	case peers.HealthyBecauseMostPeersCantAccessAPIServer, peers.HealthyBecauseNoPeersWereFound:
+		if e.obs.EndpointAccessLost == nil {
+			return Verdict{}, &Need{Type: NeedEndpointAccess}
+		}
+		e.explain("endpoint access lost: %t", *e.obs.EndpointAccessLost)
+		if *e.obs.EndpointAccessLost {
+			return Verdict{Healthy: false, Reason: ReasonEndpointAccessLostOnWorker}, nil
+		}
+	}
+	return verdict, nil
+}
+
+func (e *evaluation) evaluateControlPlane(workerPeersResponse peers.Response) (Verdict, *Need) {
+	controlPlanePeers := e.obs.ControlPlanePeers
+	if controlPlanePeers == nil {
+		return Verdict{}, &Need{Type: NeedControlPlanePeers}
+	}
+	e.explain("%d of %d control plane peers responded, %d of them have a healthy etcd member",
+		controlPlanePeers.Responses, controlPlanePeers.Peers, controlPlanePeers.HealthyEtcdMembers)
+
+	verdict, need := e.evaluateControlPlaneHealth(workerPeersResponse, controlPlanePeers.CanBeReached())
+	if need != nil || verdict.Healthy {
+		return verdict, need
+	}
+
+	canSelfFence, need := e.canSelfFenceWithoutBreakingEtcdQuorum(workerPeersResponse, *controlPlanePeers)
+	if need != nil {
+		return Verdict{}, need
+	}
+	if !canSelfFence {
+		return Verdict{Healthy: true, Reason: ReasonSelfFencingPostponed}, nil
+	}
+	return verdict, nil
+}
+
+func (e *evaluation) evaluateControlPlaneHealth(workerPeersResponse peers.Response, canOtherControlPlanesBeReached bool) (Verdict, *Need) {
+	workerVerdict := Verdict{Healthy: workerPeersResponse.IsHealthy, Reason: string(workerPeersResponse.Reason)}
+	switch workerPeersResponse.Reason {
+	//reported unhealthy by worker peers
+	case peers.UnHealthyBecausePeersResponse:
+		return workerVerdict, nil
+	case peers.UnHealthyBecauseNodeIsIsolated:
+		if canOtherControlPlanesBeReached {
+			return Verdict{Healthy: true, Reason: ReasonControlPlanePeersReachable}, nil
+		}
+		return workerVerdict, nil
+	//reported healthy by worker peers
+	case peers.HealthyBecauseErrorsThresholdNotReached, peers.HealthyBecauseCRNotFound, peers.HealthyBecauseNoPeersResponseNotReachedTimeout:
+		return workerVerdict, nil
+	//controlPlane node has connection to most workers, we assume it's not isolated (or at least that the controlPlane node that does not have worker peers quorum will reboot)
+	case peers.HealthyBecauseMostPeersCantAccessAPIServer:
+		if passed, need := e.isDiagnosticsPassed(); need != nil || !passed {
+			return Verdict{Healthy: false, Reason: ReasonDiagnosticsFailed}, need
+		}
+		return workerVerdict, nil
+	case peers.HealthyBecauseNoPeersWereFound:
+		if passed, need := e.isDiagnosticsPassed(); need != nil || !passed {
+			return Verdict{Healthy: false, Reason: ReasonDiagnosticsFailed}, need
+		}
+		if !canOtherControlPlanesBeReached {
+			return Verdict{Healthy: false, Reason: ReasonControlPlanePeersUnreachable}, nil
+		}
+		return workerVerdict, nil
+	default:
+		return Verdict{Healthy: false, Reason: ReasonUnknownWorkerPeersReason}, nil
+	}
+}
+
+func (e *evaluation) isDiagnosticsPassed() (bool, *Need) {
+	if e.obs.EndpointAccessLost == nil {
+		return false, &Need{Type: NeedEndpointAccess}
+	}
+	e.explain("control plane diagnostics: endpoint access lost: %t", *e.obs.EndpointAccessLost)
+	if *e.obs.EndpointAccessLost {
+		return false, nil
+	}
+	if e.obs.KubeletRunning == nil {
+		return false, &Need{Type: NeedKubelet}
+	}
+	e.explain("control plane diagnostics: kubelet running: %t", *e.obs.KubeletRunning)
+	return *e.obs.KubeletRunning, nil
+}
+
+// canSelfFenceWithoutBreakingEtcdQuorum returns false if rebooting this node would drop the etcd cluster below quorum.
+// Nodes which are fully isolated always self fence, because they can't know the state of the other members. Nodes
+// which peers reported unhealthy always self fence as well, because they are remediated, and the manager assumes
+// that they were rebooted once the safe time passed.
+func (e *evaluation) canSelfFenceWithoutBreakingEtcdQuorum(workerPeersResponse peers.Response, controlPlanePeers controlplane.ControlPlanePeersStatus) (bool, *Need) {
+	if workerPeersResponse.Reason == peers.UnHealthyBecausePeersResponse {
+		e.explain("node is remediated, ignoring etcd quorum")
+		return true, nil
+	}
+	if workerPeersResponse.Reason == peers.UnHealthyBecauseNodeIsIsolated && !controlPlanePeers.CanBeReached() {
+		e.explain("node is fully isolated, ignoring etcd quorum")
+		return true, nil
+	}
+	if controlPlanePeers.Peers == 0 {
+		e.explain("single etcd member, there is no quorum to preserve")
+		return true, nil
+	}
+
+	if e.obs.LocalEtcdMemberStatus == nil {
+		return false, &Need{Type: NeedLocalEtcdMemberStatus}
+	}
+	localStatus := *e.obs.LocalEtcdMemberStatus
+	if localStatus == selfNodeRemediation.NoEtcdMember {
+		e.explain("node doesn't run an etcd member")
+		return true, nil
+	}
+
+	members := controlPlanePeers.Peers + 1
+	quorum := members/2 + 1
+	healthyMembers := controlPlanePeers.HealthyEtcdMembers
+	// be conservative and assume that a local member with unknown status is healthy
+	if localStatus != selfNodeRemediation.EtcdMemberUnhealthy {
+		healthyMembers++
+	}
+	e.explain("%d etcd members, quorum is %d, %d healthy members, local member is %s", members, quorum, healthyMembers, localStatus)
+
+	if healthyMembers < quorum {
+		e.explain("etcd quorum is already lost, rebooting this node won't make it worse")
+		return true, nil
+	}
+	if localStatus == selfNodeRemediation.EtcdMemberUnhealthy || healthyMembers-1 >= quorum {
+		e.explain("etcd quorum is preserved when rebooting this node")
+		return true, nil
+	}
+	e.explain("rebooting this node would break etcd quorum")
+	return false, nil
+}
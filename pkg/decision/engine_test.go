@@ -0,0 +1,228 @@
+package decision
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	selfNodeRemediation "github.com/medik8s/self-node-remediation/api"
+	"github.com/medik8s/self-node-remediation/pkg/controlplane"
+	"github.com/medik8s/self-node-remediation/pkg/peers"
+)
+
+var _ = Describe("Evaluate", func() {
+
+	var cfg Config
+	var state State
+	var obs Observation
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		cfg = Config{MaxErrorsThreshold: 3, MaxTimeForNoPeersResponse: 30 * time.Second}
+		state = State{ErrorCount: 2, TimeOfLastPeerResponse: now.Add(-time.Minute)}
+		obs = Observation{Time: now, ApiError: "api server readyz endpoint error: timeout"}
+	})
+
+	boolPtr := func(b bool) *bool { return &b }
+	etcdStatus := func(status selfNodeRemediation.EtcdMemberStatusCode) *selfNodeRemediation.EtcdMemberStatusCode {
+		return &status
+	}
+
+	expectNeed := func(needType NeedType) {
+		result := Evaluate(cfg, state, obs)
+		ExpectWithOffset(1, result.Need).ToNot(BeNil())
+		ExpectWithOffset(1, result.Need.Type).To(Equal(needType))
+	}
+	expectVerdict := func(healthy bool, reason string) Result {
+		result := Evaluate(cfg, state, obs)
+		ExpectWithOffset(1, result.Need).To(BeNil())
+		ExpectWithOffset(1, result.Verdict).To(Equal(Verdict{Healthy: healthy, Reason: reason}))
+		ExpectWithOffset(1, result.Trace).ToNot(BeEmpty())
+		return result
+	}
+
+	It("should be healthy and reset the error count when the API server is reachable", func() {
+		obs.ApiError = ""
+		result := expectVerdict(true, ReasonApiServerReachable)
+		Expect(result.State.ErrorCount).To(BeZero())
+	})
+
+	It("should be healthy without asking peers below the errors threshold", func() {
+		state.ErrorCount = 0
+		result := expectVerdict(true, string(peers.HealthyBecauseErrorsThresholdNotReached))
+		Expect(result.State.ErrorCount).To(Equal(1))
+	})
+
+	It("should not change the given state", func() {
+		Evaluate(cfg, state, obs)
+		Expect(state.ErrorCount).To(Equal(2))
+	})
+
+	Context("worker peers", func() {
+		It("should ask for the worker peers and their batches", func() {
+			expectNeed(NeedWorkerPeers)
+
+			obs.WorkerPeers = &WorkerPeers{Count: 10}
+			result := Evaluate(cfg, state, obs)
+			Expect(result.Need).To(Equal(&Need{Type: NeedWorkerPeerBatch, BatchSize: 3}))
+
+			obs.WorkerPeers.Batches = []PeerBatch{{Size: 3, NoResponse: 3}}
+			result = Evaluate(cfg, state, obs)
+			Expect(result.Need).To(Equal(&Need{Type: NeedWorkerPeerBatch, BatchSize: 3}))
+		})
+
+		It("should be healthy when no peers were found", func() {
+			obs.WorkerPeers = &WorkerPeers{}
+			expectVerdict(true, string(peers.HealthyBecauseNoPeersWereFound))
+		})
+
+		It("should be healthy and reset the error count when a peer didn't find a remediation", func() {
+			obs.WorkerPeers = &WorkerPeers{Count: 3, Batches: []PeerBatch{{Size: 3, Healthy: 1, NoResponse: 2}}}
+			result := expectVerdict(true, string(peers.HealthyBecauseCRNotFound))
+			Expect(result.State.ErrorCount).To(BeZero())
+			Expect(result.State.TimeOfLastPeerResponse).To(Equal(now))
+		})
+
+		It("should be unhealthy when a peer found a remediation", func() {
+			obs.WorkerPeers = &WorkerPeers{Count: 3, Batches: []PeerBatch{{Size: 3, Unhealthy: 1, ApiErrors: 2}}}
+			expectVerdict(false, string(peers.UnHealthyBecausePeersResponse))
+		})
+
+		It("should be healthy when most peers can't access the API server", func() {
+			obs.WorkerPeers = &WorkerPeers{Count: 3, Batches: []PeerBatch{{Size: 3, ApiErrors: 2, NoResponse: 1}}}
+			expectVerdict(true, string(peers.HealthyBecauseMostPeersCantAccessAPIServer))
+		})
+
+		It("should be unhealthy when no peer responded for too long", func() {
+			obs.WorkerPeers = &WorkerPeers{Count: 3, Batches: []PeerBatch{{Size: 3, NoResponse: 3}}}
+			expectVerdict(false, string(peers.UnHealthyBecauseNodeIsIsolated))
+		})
+
+		It("should be healthy when peers responded recently", func() {
+			state.TimeOfLastPeerResponse = now.Add(-10 * time.Second)
+			obs.WorkerPeers = &WorkerPeers{Count: 3, Batches: []PeerBatch{{Size: 3, NoResponse: 3}}}
+			expectVerdict(true, string(peers.HealthyBecauseNoPeersResponseNotReachedTimeout))
+		})
+
+		It("should not be isolated when peers are busy", func() {
+			obs.WorkerPeers = &WorkerPeers{Count: 3, Batches: []PeerBatch{{Size: 3, Busy: 2, NoResponse: 1}}}
+			result := expectVerdict(true, string(peers.HealthyBecauseNoPeersResponseNotReachedTimeout))
+			Expect(result.State.TimeOfLastPeerResponse).To(Equal(now))
+		})
+
+		It("should check the endpoints on workers when configured", func() {
+			cfg.EndpointChecksOnWorkers = true
+			obs.WorkerPeers = &WorkerPeers{}
+			expectNeed(NeedEndpointAccess)
+
+			obs.EndpointAccessLost = boolPtr(true)
+			expectVerdict(false, ReasonEndpointAccessLostOnWorker)
+
+			obs.EndpointAccessLost = boolPtr(false)
+			expectVerdict(true, string(peers.HealthyBecauseNoPeersWereFound))
+		})
+	})
+
+	Context("control plane", func() {
+		BeforeEach(func() {
+			obs.IsControlPlane = true
+			obs.LocalEtcdMemberStatus = etcdStatus(selfNodeRemediation.EtcdMemberHealthy)
+		})
+
+		It("should ask for the control plane peers", func() {
+			obs.WorkerPeers = &WorkerPeers{Count: 3, Batches: []PeerBatch{{Size: 3, Healthy: 1}}}
+			expectNeed(NeedControlPlanePeers)
+		})
+
+		It("should be healthy when isolated from workers, but other control plane nodes can be reached", func() {
+			obs.WorkerPeers = &WorkerPeers{Count: 3, Batches: []PeerBatch{{Size: 3, NoResponse: 3}}}
+			obs.ControlPlanePeers = &controlplane.ControlPlanePeersStatus{Peers: 2, Responses: 1}
+			expectVerdict(true, ReasonControlPlanePeersReachable)
+		})
+
+		It("should run diagnostics when most peers can't access the API server", func() {
+			obs.WorkerPeers = &WorkerPeers{Count: 3, Batches: []PeerBatch{{Size: 3, ApiErrors: 3}}}
+			obs.ControlPlanePeers = &controlplane.ControlPlanePeersStatus{Peers: 2, Responses: 2, HealthyEtcdMembers: 2}
+			expectNeed(NeedEndpointAccess)
+
+			obs.EndpointAccessLost = boolPtr(false)
+			expectNeed(NeedKubelet)
+
+			obs.KubeletRunning = boolPtr(true)
+			expectVerdict(true, string(peers.HealthyBecauseMostPeersCantAccessAPIServer))
+
+			obs.KubeletRunning = boolPtr(false)
+			expectVerdict(false, ReasonDiagnosticsFailed)
+		})
+
+		It("should be unhealthy when no peers were found and other control plane nodes can't be reached", func() {
+			obs.WorkerPeers = &WorkerPeers{}
+			obs.ControlPlanePeers = &controlplane.ControlPlanePeersStatus{Peers: 2}
+			obs.EndpointAccessLost = boolPtr(false)
+			obs.KubeletRunning = boolPtr(true)
+			expectVerdict(false, ReasonControlPlanePeersUnreachable)
+		})
+
+		Context("self fencing", func() {
+			// the API server can't be reached by most peers, and the diagnostics of this node fail
+			unhealthyObservation := func(controlPlanePeers controlplane.ControlPlanePeersStatus) {
+				obs.WorkerPeers = &WorkerPeers{Count: 3, Batches: []PeerBatch{{Size: 3, ApiErrors: 3}}}
+				obs.ControlPlanePeers = &controlPlanePeers
+				obs.EndpointAccessLost = boolPtr(false)
+				obs.KubeletRunning = boolPtr(false)
+			}
+
+			It("should be postponed when rebooting would break quorum", func() {
+				// 3 members, 1 healthy peer: rebooting would leave 1 of 3 members
+				unhealthyObservation(controlplane.ControlPlanePeersStatus{Peers: 2, Responses: 2, HealthyEtcdMembers: 1})
+				expectVerdict(true, ReasonSelfFencingPostponed)
+			})
+
+			It("should be allowed when quorum is preserved", func() {
+				unhealthyObservation(controlplane.ControlPlanePeersStatus{Peers: 2, Responses: 2, HealthyEtcdMembers: 2})
+				expectVerdict(false, ReasonDiagnosticsFailed)
+			})
+
+			It("should be allowed when the node is remediated, even if quorum is at risk", func() {
+				// an SNR exists for the node, so the manager assumes that it reboots
+				obs.WorkerPeers = &WorkerPeers{Count: 3, Batches: []PeerBatch{{Size: 3, Unhealthy: 1}}}
+				obs.ControlPlanePeers = &controlplane.ControlPlanePeersStatus{Peers: 2, Responses: 2, HealthyEtcdMembers: 1}
+				expectVerdict(false, string(peers.UnHealthyBecausePeersResponse))
+			})
+
+			It("should be allowed when the local member is unhealthy", func() {
+				obs.LocalEtcdMemberStatus = etcdStatus(selfNodeRemediation.EtcdMemberUnhealthy)
+				unhealthyObservation(controlplane.ControlPlanePeersStatus{Peers: 2, Responses: 2, HealthyEtcdMembers: 1})
+				expectVerdict(false, ReasonDiagnosticsFailed)
+			})
+
+			It("should be allowed when quorum is already lost", func() {
+				unhealthyObservation(controlplane.ControlPlanePeersStatus{Peers: 4, Responses: 1, HealthyEtcdMembers: 1})
+				expectVerdict(false, ReasonDiagnosticsFailed)
+			})
+
+			It("should be allowed without local etcd member", func() {
+				obs.LocalEtcdMemberStatus = etcdStatus(selfNodeRemediation.NoEtcdMember)
+				unhealthyObservation(controlplane.ControlPlanePeersStatus{Peers: 2, Responses: 2, HealthyEtcdMembers: 1})
+				expectVerdict(false, ReasonDiagnosticsFailed)
+			})
+
+			It("should be allowed when the node is fully isolated", func() {
+				obs.LocalEtcdMemberStatus = nil
+				obs.WorkerPeers = &WorkerPeers{Count: 3, Batches: []PeerBatch{{Size: 3, NoResponse: 3}}}
+				obs.ControlPlanePeers = &controlplane.ControlPlanePeersStatus{Peers: 2}
+				expectVerdict(false, string(peers.UnHealthyBecauseNodeIsIsolated))
+			})
+
+			It("should be postponed when the local member status is unknown", func() {
+				obs.LocalEtcdMemberStatus = nil
+				unhealthyObservation(controlplane.ControlPlanePeersStatus{Peers: 2, Responses: 2, HealthyEtcdMembers: 1})
+				expectNeed(NeedLocalEtcdMemberStatus)
+
+				obs.LocalEtcdMemberStatus = etcdStatus(selfNodeRemediation.EtcdMemberUnknown)
+				expectVerdict(true, ReasonSelfFencingPostponed)
+			})
+		})
+	})
+})
@@ -0,0 +1,198 @@
+package decision
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// DefaultRecordFile is where the agents record the inputs of their health decisions
+	DefaultRecordFile = "/var/log/self-node-remediation/health-decisions.jsonl"
+
+	// maxRecordFileSize is the size after which the record file is rotated. A single rotated file is kept.
+	maxRecordFileSize = 10 * 1024 * 1024
+)
+
+// Start is recorded when recording starts, it holds everything needed for replaying the following observations
+type Start struct {
+	Config Config `json:"config"`
+	State  State  `json:"state"`
+}
+
+// Record is a single line of a record file. Exactly one of its fields is set.
+type Record struct {
+	Start       *Start       `json:"start,omitempty"`
+	Observation *Observation `json:"observation,omitempty"`
+	// Verdict is the verdict which was reached for the preceding observation
+	Verdict *Verdict `json:"verdict,omitempty"`
+}
+
+// Recorder writes the observations and verdicts of health decisions to a file, one JSON record per line
+type Recorder struct {
+	path   string
+	config Config
+	file   *os.File
+	size   int64
+	mutex  sync.Mutex
+}
+
+// NewRecorder opens the given record file for appending, and records a Start with the given config and initial state
+func NewRecorder(path string, config Config, state State) (*Recorder, error) {
+	r := &Recorder{path: path, config: config}
+	if err := r.open(state); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Recorder) open(state State) error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to create record directory: %w", err)
+	}
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open record file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat record file: %w", err)
+	}
+	r.file, r.size = file, info.Size()
+	return r.write(Record{Start: &Start{Config: r.config, State: state}})
+}
+
+// Record writes the given observation and the verdict which was reached for it. The state is the state the
+// observation was evaluated with, it's needed for starting over after the file was rotated.
+// Unhealthy verdicts are synced to disk, because the node is going to be rebooted.
+func (r *Recorder) Record(state State, obs Observation, verdict Verdict) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.size >= maxRecordFileSize {
+		if err := r.rotate(state); err != nil {
+			return err
+		}
+	}
+	if err := r.write(Record{Observation: &obs}); err != nil {
+		return err
+	}
+	if err := r.write(Record{Verdict: &verdict}); err != nil {
+		return err
+	}
+	if !verdict.Healthy {
+		return r.file.Sync()
+	}
+	return nil
+}
+
+func (r *Recorder) rotate(state State) error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close record file: %w", err)
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate record file: %w", err)
+	}
+	return r.open(state)
+}
+
+func (r *Recorder) write(record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+	n, err := r.file.Write(append(line, '\n'))
+	r.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return nil
+}
+
+// Close closes the record file
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.file.Close()
+}
+
+// ReadRecords reads the records of a record file
+func ReadRecords(reader io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to parse record in line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read records: %w", err)
+	}
+	return records, nil
+}
+
+// ReadRecordFile reads the records of the given record file
+func ReadRecordFile(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadRecords(file)
+}
+
+// Replayed is the outcome of replaying a recorded observation
+type Replayed struct {
+	Observation Observation
+	// Recorded is the verdict which was recorded for the observation, if any
+	Recorded *Verdict
+	// Result is the result of evaluating the observation again
+	Result Result
+}
+
+// Replay evaluates the recorded observations again, in order. The state is carried over from one observation to the
+// next one, and reset on every recorded Start. When a config is given, it's used instead of the recorded one, which
+// allows to check how the node would have decided with another configuration.
+func Replay(records []Record, config *Config) ([]Replayed, error) {
+	var replayed []Replayed
+	var cfg Config
+	var state State
+	started := false
+	for i, record := range records {
+		switch {
+		case record.Start != nil:
+			cfg, state, started = record.Start.Config, record.Start.State, true
+			if config != nil {
+				cfg = *config
+			}
+		case record.Observation != nil:
+			if !started {
+				return nil, fmt.Errorf("record %d: observation without preceding start", i)
+			}
+			result := Evaluate(cfg, state, *record.Observation)
+			if result.Need != nil {
+				return nil, fmt.Errorf("record %d: observation lacks %s input", i, result.Need.Type)
+			}
+			state = result.State
+			replayed = append(replayed, Replayed{Observation: *record.Observation, Result: result})
+		case record.Verdict != nil:
+			if len(replayed) == 0 || replayed[len(replayed)-1].Recorded != nil {
+				return nil, fmt.Errorf("record %d: verdict without preceding observation", i)
+			}
+			verdict := *record.Verdict
+			replayed[len(replayed)-1].Recorded = &verdict
+		}
+	}
+	return replayed, nil
+}
@@ -0,0 +1,86 @@
+package decision
+
+import (
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/medik8s/self-node-remediation/pkg/peers"
+)
+
+var _ = Describe("Replay", func() {
+
+	replayFile := func(name string, cfg *Config) []Replayed {
+		records, err := ReadRecordFile(filepath.Join("testdata", name))
+		ExpectWithOffset(1, err).ToNot(HaveOccurred())
+		replayed, err := Replay(records, cfg)
+		ExpectWithOffset(1, err).ToNot(HaveOccurred())
+		ExpectWithOffset(1, replayed).ToNot(BeEmpty())
+		return replayed
+	}
+
+	DescribeTable("recorded incidents should reach the recorded verdicts",
+		func(name string, lastVerdictHealthy bool) {
+			replayed := replayFile(name, nil)
+			for i, r := range replayed {
+				Expect(r.Recorded).ToNot(BeNil(), "observation %d has no recorded verdict", i)
+				Expect(r.Result.Verdict).To(Equal(*r.Recorded), "observation %d, trace: %v", i, r.Result.Trace)
+			}
+			Expect(replayed[len(replayed)-1].Result.Verdict.Healthy).To(Equal(lastVerdictHealthy))
+		},
+		Entry("isolated worker", "isolated-worker.jsonl", false),
+		Entry("API server outage", "api-server-outage.jsonl", true),
+		Entry("control plane etcd quorum", "control-plane-etcd-quorum.jsonl", false),
+	)
+
+	It("should replay an incident with another config", func() {
+		cfg := Config{MaxErrorsThreshold: 3, MaxTimeForNoPeersResponse: time.Minute}
+		replayed := replayFile("isolated-worker.jsonl", &cfg)
+		last := replayed[len(replayed)-1]
+		Expect(last.Recorded.Healthy).To(BeFalse())
+		Expect(last.Result.Verdict).To(Equal(Verdict{Healthy: true, Reason: string(peers.HealthyBecauseNoPeersResponseNotReachedTimeout)}))
+	})
+
+	It("should replay what was recorded", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "decisions", "health-decisions.jsonl")
+		cfg := Config{MaxErrorsThreshold: 1, MaxTimeForNoPeersResponse: 30 * time.Second}
+		state := State{TimeOfLastPeerResponse: time.Now()}
+		recorder, err := NewRecorder(path, cfg, state)
+		Expect(err).ToNot(HaveOccurred())
+
+		observations := []Observation{
+			{Time: state.TimeOfLastPeerResponse.Add(10 * time.Second), ApiError: "timeout", WorkerPeers: &WorkerPeers{Count: 3, Batches: []PeerBatch{{Size: 3, NoResponse: 3}}}},
+			{Time: state.TimeOfLastPeerResponse.Add(40 * time.Second), ApiError: "timeout", WorkerPeers: &WorkerPeers{Count: 3, Batches: []PeerBatch{{Size: 3, NoResponse: 3}}}},
+		}
+		for _, obs := range observations {
+			result := Evaluate(cfg, state, obs)
+			Expect(result.Need).To(BeNil())
+			Expect(recorder.Record(state, obs, result.Verdict)).To(Succeed())
+			state = result.State
+		}
+		Expect(recorder.Close()).To(Succeed())
+
+		records, err := ReadRecordFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(records).To(HaveLen(5))
+		Expect(records[0].Start).ToNot(BeNil())
+
+		replayed, err := Replay(records, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(replayed).To(HaveLen(2))
+		Expect(replayed[0].Result.Verdict.Healthy).To(BeTrue())
+		Expect(replayed[1].Result.Verdict).To(Equal(*replayed[1].Recorded))
+		Expect(replayed[1].Result.Verdict.Healthy).To(BeFalse())
+	})
+
+	It("should fail on observations which lack inputs", func() {
+		records := []Record{
+			{Start: &Start{Config: Config{MaxErrorsThreshold: 1}}},
+			{Observation: &Observation{ApiError: "timeout"}},
+		}
+		_, err := Replay(records, nil)
+		Expect(err).To(MatchError(ContainSubstring(string(NeedWorkerPeers))))
+	})
+})
@@ -0,0 +1,13 @@
+package decision
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDecision(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Decision Suite")
+}
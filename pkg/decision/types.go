@@ -0,0 +1,109 @@
+package decision
+
+import (
+	"time"
+
+	selfNodeRemediation "github.com/medik8s/self-node-remediation/api"
+	"github.com/medik8s/self-node-remediation/pkg/controlplane"
+)
+
+// Config holds the tunables of the health decision
+type Config struct {
+	// MaxErrorsThreshold is the number of consecutive API server errors after which peers are asked
+	MaxErrorsThreshold int `json:"maxErrorsThreshold"`
+	// MaxTimeForNoPeersResponse is the time without any peer response after which the node is considered isolated
+	MaxTimeForNoPeersResponse time.Duration `json:"maxTimeForNoPeersResponse"`
+	// EndpointChecksOnWorkers indicates whether the endpoint health checks are used on worker nodes as well
+	EndpointChecksOnWorkers bool `json:"endpointChecksOnWorkers"`
+}
+
+// State is carried over from one health check round to the next one
+type State struct {
+	// ErrorCount is the number of consecutive API server errors
+	ErrorCount int `json:"errorCount"`
+	// TimeOfLastPeerResponse is when a peer responded the last time
+	TimeOfLastPeerResponse time.Time `json:"timeOfLastPeerResponse"`
+}
+
+// PeerBatch sums up the responses of a batch of peers which were asked at the same time
+type PeerBatch struct {
+	Size       int `json:"size"`
+	Healthy    int `json:"healthy"`
+	Unhealthy  int `json:"unhealthy"`
+	ApiErrors  int `json:"apiErrors"`
+	NoResponse int `json:"noResponse"`
+	// Busy is the number of peers which rejected the request because of their limits. They didn't answer, but they
+	// could be reached, so they don't count towards the isolation of the node.
+	Busy int `json:"busy,omitempty"`
+}
+
+func (b PeerBatch) responses() int {
+	return b.Healthy + b.Unhealthy + b.ApiErrors + b.Busy
+}
+
+// WorkerPeers holds the worker peers which were asked, batch by batch
+type WorkerPeers struct {
+	// Count is the number of known worker peers
+	Count   int         `json:"count"`
+	Batches []PeerBatch `json:"batches,omitempty"`
+}
+
+// Observation holds the inputs of a single health check round. Inputs which are nil weren't observed yet, the
+// health decision asks for them with a Need when it depends on them.
+type Observation struct {
+	// Time is when the round started
+	Time time.Time `json:"time"`
+	// ApiError is empty if the API server was reachable
+	ApiError string `json:"apiError,omitempty"`
+	// IsControlPlane is true if the node is a control plane node
+	IsControlPlane bool `json:"isControlPlane"`
+
+	WorkerPeers           *WorkerPeers                              `json:"workerPeers,omitempty"`
+	ControlPlanePeers     *controlplane.ControlPlanePeersStatus     `json:"controlPlanePeers,omitempty"`
+	EndpointAccessLost    *bool                                     `json:"endpointAccessLost,omitempty"`
+	KubeletRunning        *bool                                     `json:"kubeletRunning,omitempty"`
+	LocalEtcdMemberStatus *selfNodeRemediation.EtcdMemberStatusCode `json:"localEtcdMemberStatus,omitempty"`
+}
+
+// NeedType is an input the health decision depends on, and which wasn't observed yet
+type NeedType string
+
+const (
+	// NeedWorkerPeers asks for the number of known worker peers
+	NeedWorkerPeers NeedType = "WorkerPeers"
+	// NeedWorkerPeerBatch asks for the responses of the next batch of worker peers
+	NeedWorkerPeerBatch NeedType = "WorkerPeerBatch"
+	// NeedControlPlanePeers asks for the responses of all control plane peers
+	NeedControlPlanePeers NeedType = "ControlPlanePeers"
+	// NeedEndpointAccess asks whether the node lost access to the health check endpoints
+	NeedEndpointAccess NeedType = "EndpointAccess"
+	// NeedKubelet asks whether the kubelet is running
+	NeedKubelet NeedType = "Kubelet"
+	// NeedLocalEtcdMemberStatus asks for the health of the local etcd member
+	NeedLocalEtcdMemberStatus NeedType = "LocalEtcdMemberStatus"
+)
+
+// Need describes an input the health decision depends on
+type Need struct {
+	Type NeedType
+	// BatchSize is the number of worker peers to ask, for NeedWorkerPeerBatch
+	BatchSize int
+}
+
+// Verdict is the outcome of a health decision
+type Verdict struct {
+	Healthy bool   `json:"healthy"`
+	Reason  string `json:"reason"`
+}
+
+// Result is either a Verdict, or a Need for another input
+type Result struct {
+	// Need is set when the decision depends on an input which wasn't observed yet. The other fields are unset then.
+	Need *Need
+	// Verdict is the outcome of the decision
+	Verdict Verdict
+	// State is the state for the next round
+	State State
+	// Trace explains how the verdict was reached
+	Trace []string
+}
@@ -0,0 +1,140 @@
+package endpointhealth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/go-logr/logr"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+)
+
+const (
+	EndpointHealthCheckUrlEnvVar       = "END_POINT_HEALTH_CHECK_URL"
+	EndpointHealthChecksEnvVar         = "END_POINT_HEALTH_CHECKS"
+	EndpointHealthCheckPolicyEnvVar    = "END_POINT_HEALTH_CHECK_POLICY"
+	EndpointHealthCheckOnWorkersEnvVar = "END_POINT_HEALTH_CHECK_ON_WORKERS"
+)
+
+// Checker runs the configured endpoint probes and combines their results according to the configured policy
+type Checker struct {
+	probes               []Probe
+	policy               v1alpha1.EndpointHealthCheckPolicyType
+	onWorkers            bool
+	wasAccessibleAtStart bool
+	mutex                sync.Mutex
+	log                  logr.Logger
+}
+
+// NewChecker returns a new Checker for the given probes
+func NewChecker(probes []Probe, policy v1alpha1.EndpointHealthCheckPolicyType, onWorkers bool, log logr.Logger) *Checker {
+	if policy == "" {
+		policy = v1alpha1.AnyEndpointHealthCheckPolicy
+	}
+	return &Checker{
+		probes:    probes,
+		policy:    policy,
+		onWorkers: onWorkers,
+		log:       log,
+	}
+}
+
+// NewCheckerFromEnv returns a new Checker configured by the env vars which are rendered into the agent daemonset
+func NewCheckerFromEnv(log logr.Logger) (*Checker, error) {
+	var checks []v1alpha1.EndpointHealthCheck
+	if checksJson := os.Getenv(EndpointHealthChecksEnvVar); checksJson != "" {
+		if err := json.Unmarshal([]byte(checksJson), &checks); err != nil {
+			return nil, fmt.Errorf("failed to parse %s env var: %w", EndpointHealthChecksEnvVar, err)
+		}
+	}
+	// keep supporting the deprecated single ICMP url
+	if url := os.Getenv(EndpointHealthCheckUrlEnvVar); url != "" {
+		checks = append(checks, v1alpha1.EndpointHealthCheck{Type: v1alpha1.ICMPEndpointHealthCheck, Target: url})
+	}
+
+	probes := make([]Probe, 0, len(checks))
+	for _, check := range checks {
+		probe, err := NewProbe(check)
+		if err != nil {
+			return nil, err
+		}
+		probes = append(probes, probe)
+	}
+
+	onWorkers := false
+	if onWorkersEnv := os.Getenv(EndpointHealthCheckOnWorkersEnvVar); onWorkersEnv != "" {
+		var err error
+		if onWorkers, err = strconv.ParseBool(onWorkersEnv); err != nil {
+			return nil, fmt.Errorf("failed to parse %s env var: %w", EndpointHealthCheckOnWorkersEnvVar, err)
+		}
+	}
+
+	policy := v1alpha1.EndpointHealthCheckPolicyType(os.Getenv(EndpointHealthCheckPolicyEnvVar))
+	switch policy {
+	case "", v1alpha1.AnyEndpointHealthCheckPolicy, v1alpha1.AllEndpointHealthCheckPolicy:
+	default:
+		return nil, fmt.Errorf("unsupported endpoint health check policy %q", policy)
+	}
+
+	return NewChecker(probes, policy, onWorkers, log), nil
+}
+
+// Start implements Runnable for usage by manager.
+// It records whether the endpoints were accessible when the agent started.
+func (c *Checker) Start(ctx context.Context) error {
+	isAccessible := c.IsAccessible(ctx)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.wasAccessibleAtStart = isAccessible
+	if c.IsEnabled() {
+		c.log.Info("endpoint health checks initialized", "probes", len(c.probes), "policy", c.policy, "accessible at start", isAccessible)
+	}
+	return nil
+}
+
+// IsEnabled returns true if at least one probe is configured
+func (c *Checker) IsEnabled() bool {
+	return len(c.probes) > 0
+}
+
+// IsEnabledOnWorkers returns true if the probes should be used by agents running on worker nodes as well
+func (c *Checker) IsEnabledOnWorkers() bool {
+	return c.IsEnabled() && c.onWorkers
+}
+
+// IsAccessible runs the probes and returns whether the endpoints are considered accessible according to the policy.
+// It returns true when no probe is configured.
+func (c *Checker) IsAccessible(ctx context.Context) bool {
+	if !c.IsEnabled() {
+		return true
+	}
+
+	succeeded := 0
+	for _, probe := range c.probes {
+		if err := probe.Check(ctx); err != nil {
+			c.log.Error(err, "could not access endpoint", "probe", probe.String())
+			continue
+		}
+		succeeded++
+	}
+
+	if c.policy == v1alpha1.AllEndpointHealthCheckPolicy {
+		return succeeded == len(c.probes)
+	}
+	return succeeded > 0
+}
+
+// IsAccessLost returns true if the endpoints were accessible when the agent started, but aren't accessible anymore
+func (c *Checker) IsAccessLost(ctx context.Context) bool {
+	c.mutex.Lock()
+	wasAccessibleAtStart := c.wasAccessibleAtStart
+	c.mutex.Unlock()
+	if !wasAccessibleAtStart {
+		return false
+	}
+	return !c.IsAccessible(ctx)
+}
@@ -0,0 +1,144 @@
+package endpointhealth
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+)
+
+var _ = Describe("Endpoint health checks", func() {
+
+	var httpServer *httptest.Server
+	var tcpListener net.Listener
+
+	BeforeEach(func() {
+		httpServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/healthz" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		DeferCleanup(httpServer.Close)
+
+		var err error
+		tcpListener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(func() {
+			// might be closed by the test already
+			_ = tcpListener.Close()
+		})
+	})
+
+	newProbe := func(check v1alpha1.EndpointHealthCheck) Probe {
+		probe, err := NewProbe(check)
+		Expect(err).ToNot(HaveOccurred())
+		return probe
+	}
+
+	Describe("Probes", func() {
+		It("TCP probe should succeed on open port", func() {
+			probe := newProbe(v1alpha1.EndpointHealthCheck{Type: v1alpha1.TCPEndpointHealthCheck, Target: tcpListener.Addr().String()})
+			Expect(probe.Check(context.Background())).To(Succeed())
+		})
+
+		It("TCP probe should fail on closed port", func() {
+			addr := tcpListener.Addr().String()
+			Expect(tcpListener.Close()).To(Succeed())
+			probe := newProbe(v1alpha1.EndpointHealthCheck{Type: v1alpha1.TCPEndpointHealthCheck, Target: addr})
+			Expect(probe.Check(context.Background())).ToNot(Succeed())
+		})
+
+		It("TCP probe should reject target without port", func() {
+			_, err := NewProbe(v1alpha1.EndpointHealthCheck{Type: v1alpha1.TCPEndpointHealthCheck, Target: "127.0.0.1"})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("HTTP probe should verify the status code", func() {
+			probe := newProbe(v1alpha1.EndpointHealthCheck{Type: v1alpha1.HTTPEndpointHealthCheck, Target: httpServer.URL + "/healthz"})
+			Expect(probe.Check(context.Background())).To(Succeed())
+
+			probe = newProbe(v1alpha1.EndpointHealthCheck{Type: v1alpha1.HTTPEndpointHealthCheck, Target: httpServer.URL + "/other"})
+			Expect(probe.Check(context.Background())).ToNot(Succeed())
+
+			probe = newProbe(v1alpha1.EndpointHealthCheck{Type: v1alpha1.HTTPEndpointHealthCheck, Target: httpServer.URL + "/other", ExpectedStatusCode: http.StatusServiceUnavailable})
+			Expect(probe.Check(context.Background())).To(Succeed())
+		})
+	})
+
+	Describe("Checker", func() {
+		var goodProbe, badProbe Probe
+
+		BeforeEach(func() {
+			goodProbe = newProbe(v1alpha1.EndpointHealthCheck{Type: v1alpha1.HTTPEndpointHealthCheck, Target: httpServer.URL + "/healthz"})
+			badProbe = newProbe(v1alpha1.EndpointHealthCheck{Type: v1alpha1.HTTPEndpointHealthCheck, Target: httpServer.URL + "/other"})
+		})
+
+		It("should be accessible without probes", func() {
+			checker := NewChecker(nil, "", false, ctrl.Log.WithName("test"))
+			Expect(checker.IsEnabled()).To(BeFalse())
+			Expect(checker.IsAccessible(context.Background())).To(BeTrue())
+		})
+
+		It("should combine results with the Any policy", func() {
+			checker := NewChecker([]Probe{badProbe, goodProbe}, v1alpha1.AnyEndpointHealthCheckPolicy, false, ctrl.Log.WithName("test"))
+			Expect(checker.IsAccessible(context.Background())).To(BeTrue())
+			checker = NewChecker([]Probe{badProbe, badProbe}, v1alpha1.AnyEndpointHealthCheckPolicy, false, ctrl.Log.WithName("test"))
+			Expect(checker.IsAccessible(context.Background())).To(BeFalse())
+		})
+
+		It("should combine results with the All policy", func() {
+			checker := NewChecker([]Probe{badProbe, goodProbe}, v1alpha1.AllEndpointHealthCheckPolicy, false, ctrl.Log.WithName("test"))
+			Expect(checker.IsAccessible(context.Background())).To(BeFalse())
+			checker = NewChecker([]Probe{goodProbe, goodProbe}, v1alpha1.AllEndpointHealthCheckPolicy, false, ctrl.Log.WithName("test"))
+			Expect(checker.IsAccessible(context.Background())).To(BeTrue())
+		})
+
+		It("should only report lost access if endpoints were accessible at start", func() {
+			checker := NewChecker([]Probe{badProbe}, v1alpha1.AnyEndpointHealthCheckPolicy, false, ctrl.Log.WithName("test"))
+			Expect(checker.Start(context.Background())).To(Succeed())
+			Expect(checker.IsAccessLost(context.Background())).To(BeFalse())
+
+			checker = NewChecker([]Probe{goodProbe}, v1alpha1.AnyEndpointHealthCheckPolicy, false, ctrl.Log.WithName("test"))
+			Expect(checker.Start(context.Background())).To(Succeed())
+			Expect(checker.IsAccessLost(context.Background())).To(BeFalse())
+			httpServer.Close()
+			Expect(checker.IsAccessLost(context.Background())).To(BeTrue())
+		})
+
+		It("should be configured from env vars", func() {
+			checksJson := `[{"type":"TCP","target":"` + tcpListener.Addr().String() + `"},{"type":"HTTP","target":"` + httpServer.URL + `/healthz"}]`
+			Expect(os.Setenv(EndpointHealthChecksEnvVar, checksJson)).To(Succeed())
+			Expect(os.Setenv(EndpointHealthCheckPolicyEnvVar, string(v1alpha1.AllEndpointHealthCheckPolicy))).To(Succeed())
+			Expect(os.Setenv(EndpointHealthCheckOnWorkersEnvVar, "true")).To(Succeed())
+			DeferCleanup(func() {
+				_ = os.Unsetenv(EndpointHealthChecksEnvVar)
+				_ = os.Unsetenv(EndpointHealthCheckPolicyEnvVar)
+				_ = os.Unsetenv(EndpointHealthCheckOnWorkersEnvVar)
+			})
+
+			checker, err := NewCheckerFromEnv(ctrl.Log.WithName("test"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(checker.probes).To(HaveLen(2))
+			Expect(checker.IsEnabledOnWorkers()).To(BeTrue())
+			Expect(checker.IsAccessible(context.Background())).To(BeTrue())
+		})
+
+		It("should reject an invalid policy", func() {
+			Expect(os.Setenv(EndpointHealthCheckPolicyEnvVar, "Some")).To(Succeed())
+			DeferCleanup(func() { _ = os.Unsetenv(EndpointHealthCheckPolicyEnvVar) })
+			_, err := NewCheckerFromEnv(ctrl.Log.WithName("test"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("unsupported endpoint health check policy"))
+		})
+	})
+})
@@ -0,0 +1,138 @@
+package endpointhealth
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-ping/ping"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+)
+
+const (
+	defaultProbeTimeout       = 5 * time.Second
+	defaultExpectedStatusCode = http.StatusOK
+	icmpPingCount             = 3
+)
+
+// Probe checks whether a single endpoint can be reached
+type Probe interface {
+	// Check returns an error if the endpoint couldn't be reached
+	Check(ctx context.Context) error
+	// String describes the probe, used for logging
+	String() string
+}
+
+// NewProbe creates a Probe for the given EndpointHealthCheck
+func NewProbe(check v1alpha1.EndpointHealthCheck) (Probe, error) {
+	timeout := defaultProbeTimeout
+	if check.Timeout != nil && check.Timeout.Duration > 0 {
+		timeout = check.Timeout.Duration
+	}
+
+	switch check.Type {
+	case v1alpha1.ICMPEndpointHealthCheck:
+		return &icmpProbe{target: check.Target, timeout: timeout}, nil
+	case v1alpha1.TCPEndpointHealthCheck:
+		if _, _, err := net.SplitHostPort(check.Target); err != nil {
+			return nil, fmt.Errorf("invalid TCP endpoint health check target %q: %w", check.Target, err)
+		}
+		return &tcpProbe{target: check.Target, timeout: timeout}, nil
+	case v1alpha1.HTTPEndpointHealthCheck, v1alpha1.HTTPSEndpointHealthCheck:
+		expectedStatusCode := check.ExpectedStatusCode
+		if expectedStatusCode == 0 {
+			expectedStatusCode = defaultExpectedStatusCode
+		}
+		return &httpProbe{target: check.Target, expectedStatusCode: expectedStatusCode, timeout: timeout}, nil
+	default:
+		return nil, fmt.Errorf("unsupported endpoint health check type %q", check.Type)
+	}
+}
+
+// icmpProbe pings the target
+type icmpProbe struct {
+	target  string
+	timeout time.Duration
+}
+
+func (p *icmpProbe) Check(_ context.Context) error {
+	pinger, err := ping.NewPinger(p.target)
+	if err != nil {
+		return err
+	}
+	pinger.Count = icmpPingCount
+	pinger.Timeout = p.timeout
+
+	if err := pinger.Run(); err != nil {
+		return err
+	}
+	if pinger.Statistics().PacketsRecv == 0 {
+		return fmt.Errorf("no ICMP echo reply received from %s", p.target)
+	}
+	return nil
+}
+
+func (p *icmpProbe) String() string {
+	return fmt.Sprintf("%s %s", v1alpha1.ICMPEndpointHealthCheck, p.target)
+}
+
+// tcpProbe opens a TCP connection to the target
+type tcpProbe struct {
+	target  string
+	timeout time.Duration
+}
+
+func (p *tcpProbe) Check(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: p.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.target)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (p *tcpProbe) String() string {
+	return fmt.Sprintf("%s %s", v1alpha1.TCPEndpointHealthCheck, p.target)
+}
+
+// httpProbe sends a GET request to the target and verifies the response status code
+type httpProbe struct {
+	target             string
+	expectedStatusCode int
+	timeout            time.Duration
+}
+
+func (p *httpProbe) Check(ctx context.Context) error {
+	reqCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, p.target, nil)
+	if err != nil {
+		return err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	// don't reuse connections, we want to know if a new connection can be established
+	transport.DisableKeepAlives = true
+	httpClient := &http.Client{Transport: transport}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != p.expectedStatusCode {
+		return fmt.Errorf("unexpected status code %d from %s, expected %d", resp.StatusCode, p.target, p.expectedStatusCode)
+	}
+	return nil
+}
+
+func (p *httpProbe) String() string {
+	return fmt.Sprintf("GET %s", p.target)
+}
@@ -12,6 +12,7 @@ import (
 
 type Client struct {
 	PeerHealthClient
+	AgentAdminClient
 	conn *grpc.ClientConn
 }
 
@@ -39,6 +40,7 @@ func NewClient(serverAddr string, peerDialTimeout time.Duration, log logr.Logger
 	}
 	return &Client{
 		PeerHealthClient: NewPeerHealthClient(conn),
+		AgentAdminClient: NewAgentAdminClient(conn),
 		conn:             conn,
 	}, nil
 }
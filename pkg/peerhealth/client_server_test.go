@@ -1,16 +1,24 @@
 package peerhealth
 
 import (
+	"bytes"
 	"context"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/medik8s/self-node-remediation/api"
 	"github.com/medik8s/self-node-remediation/api/v1alpha1"
@@ -22,6 +30,25 @@ var _ = Describe("Checking health using grpc client and server", func() {
 	var phServer *Server
 	var cancel context.CancelFunc
 	var phClient *Client
+	var caPem, caKeyPem *bytes.Buffer
+
+	// newClient returns a client authenticated with a certificate of the given node, or with an admin certificate
+	// if the node name is empty
+	newClient := func(requesterNodeName string) *Client {
+		var certPem, keyPem *bytes.Buffer
+		var err error
+		if requesterNodeName == "" {
+			certPem, keyPem, err = certificates.CreateAdminCert(caPem, caKeyPem)
+		} else {
+			certPem, keyPem, err = certificates.CreateNodeCert(caPem, caKeyPem, requesterNodeName)
+		}
+		ExpectWithOffset(1, err).ToNot(HaveOccurred())
+		clientCreds, err := certificates.GetClientCredentialsFromCerts(&certificates.MemoryCertStorage{CaPem: caPem, CertPem: certPem, KeyPem: keyPem})
+		ExpectWithOffset(1, err).ToNot(HaveOccurred())
+		c, err := NewClient("127.0.0.1:9000", 5*time.Second, ctrl.Log.WithName("peerhealth test").WithName("phClient"), clientCreds)
+		ExpectWithOffset(1, err).ToNot(HaveOccurred())
+		return c
+	}
 
 	BeforeEach(func() {
 
@@ -37,7 +64,9 @@ var _ = Describe("Checking health using grpc client and server", func() {
 		}
 
 		By("Creating certificates")
-		caPem, certPem, keyPem, err := certificates.CreateCerts()
+		caPem, caKeyPem, err = certificates.CreateCA(v1alpha1.ECDSAPeerCertificatesKeyAlgorithm)
+		Expect(err).ToNot(HaveOccurred())
+		certPem, keyPem, err := certificates.CreateNodeCert(caPem, caKeyPem, "server-node")
 		Expect(err).ToNot(HaveOccurred())
 
 		By("Creating test memory cert storage")
@@ -48,7 +77,7 @@ var _ = Describe("Checking health using grpc client and server", func() {
 		}
 
 		By("Creating server")
-		phServer, err = NewServer(k8sClient, reader, ctrl.Log.WithName("peerhealth test").WithName("phServer"), 9000, certReader)
+		phServer, err = NewServer(k8sClient, reader, ctrl.Log.WithName("peerhealth test").WithName("phServer"), 9000, 9001, ServerLimits{}, certReader, nil, &fakeAgentStateProvider{}, nil)
 		Expect(err).ToNot(HaveOccurred())
 
 		By("Starting server")
@@ -58,13 +87,8 @@ var _ = Describe("Checking health using grpc client and server", func() {
 			phServer.Start(ctx)
 		}()
 
-		By("Creating client credentials")
-		clientCreds, err := certificates.GetClientCredentialsFromCerts(certReader)
-		Expect(err).ToNot(HaveOccurred())
-
 		By("Creating client")
-		phClient, err = NewClient("127.0.0.1:9000", 5*time.Second, ctrl.Log.WithName("peerhealth test").WithName("phClient"), clientCreds)
-		Expect(err).ToNot(HaveOccurred())
+		phClient = newClient(nodeName)
 
 	})
 
@@ -103,6 +127,9 @@ var _ = Describe("Checking health using grpc client and server", func() {
 			}
 			err := k8sClient.Create(context.Background(), snr)
 			Expect(err).ToNot(HaveOccurred())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(context.Background(), snr)).To(Succeed())
+			})
 
 		})
 
@@ -122,4 +149,178 @@ var _ = Describe("Checking health using grpc client and server", func() {
 
 	})
 
+	Describe("for a control plane node waiting for the remediation of another control plane node", func() {
+
+		BeforeEach(func() {
+			By("creating a waiting SNR")
+			snr := &v1alpha1.SelfNodeRemediation{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      nodeName,
+					Namespace: "default",
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), snr)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(context.Background(), snr)).To(Succeed())
+			})
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), client.ObjectKeyFromObject(snr), snr); err != nil {
+					return err
+				}
+				meta.SetStatusCondition(&snr.Status.Conditions, metav1.Condition{
+					Type:   string(v1alpha1.WaitingForControlPlaneRemediationConditionType),
+					Status: metav1.ConditionTrue,
+					Reason: "ControlPlaneRemediationInProgress",
+				})
+				return k8sClient.Status().Update(context.Background(), snr)
+			}, time.Second*5, time.Millisecond*250).Should(Succeed())
+		})
+
+		It("should return healthy", func() {
+
+			By("calling isHealthy")
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer (cancel)()
+			Eventually(func() bool {
+				resp, err := phClient.IsHealthy(ctx, &HealthRequest{
+					NodeName: nodeName,
+				})
+				return err == nil && api.HealthCheckResponseCode(resp.Status) == api.Healthy
+			}, time.Second*5, time.Millisecond*250).Should(BeTrue())
+		})
+	})
+
+	Describe("for a healthy node while another node is remediated", func() {
+
+		BeforeEach(func() {
+			By("creating a SNR of another node")
+			snr := &v1alpha1.SelfNodeRemediation{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "other-node",
+					Namespace: "default",
+					OwnerReferences: []metav1.OwnerReference{
+						{Name: "Dummy", Kind: "NodeHealthCheck", APIVersion: "Dummy", UID: "Dummy"},
+					},
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), snr)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(context.Background(), snr)).To(Succeed())
+			})
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), client.ObjectKeyFromObject(snr), &v1alpha1.SelfNodeRemediation{})
+			}, time.Second*5, time.Millisecond*250).Should(Succeed())
+		})
+
+		It("should return healthy", func() {
+
+			By("calling isHealthy")
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer (cancel)()
+			Eventually(func() bool {
+				resp, err := phClient.IsHealthy(ctx, &HealthRequest{
+					NodeName: nodeName,
+				})
+				return err == nil && api.HealthCheckResponseCode(resp.Status) == api.Healthy
+			}, time.Second*5, time.Millisecond*250).Should(BeTrue())
+
+		})
+	})
+
+	Describe("for a peer which exceeds its rate limit", func() {
+		It("should answer that the server is busy", func() {
+
+			By("calling isHealthy more often than the burst allows")
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer (cancel)()
+			busyResponses := 0
+			for i := 0; i < 3*defaultPeerRequestBurst; i++ {
+				_, err := phClient.IsHealthy(ctx, &HealthRequest{
+					NodeName: nodeName,
+				})
+				if IsBusy(err) {
+					busyResponses++
+				} else {
+					Expect(err).ToNot(HaveOccurred())
+				}
+			}
+			Expect(busyResponses).To(BeNumerically(">", 0))
+
+		})
+	})
+
+	Describe("for a requester which isn't the node", func() {
+		It("should reject the health request of another node", func() {
+			otherClient := newClient("other-node")
+			defer otherClient.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer (cancel)()
+			_, err := otherClient.IsHealthy(ctx, &HealthRequest{
+				NodeName: nodeName,
+			})
+			Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+		})
+
+		It("should reject the health request of an admin", func() {
+			adminClient := newClient("")
+			defer adminClient.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer (cancel)()
+			_, err := adminClient.IsHealthy(ctx, &HealthRequest{
+				NodeName: nodeName,
+			})
+			Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+		})
+	})
+
+	Describe("for the health service", func() {
+		It("should be serving with and without TLS", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer (cancel)()
+
+			By("checking the health on the peer health port")
+			healthClient := healthpb.NewHealthClient(phClient.conn)
+			Eventually(func() healthpb.HealthCheckResponse_ServingStatus {
+				resp, err := healthClient.Check(ctx, &healthpb.HealthCheckRequest{})
+				if err != nil {
+					return healthpb.HealthCheckResponse_UNKNOWN
+				}
+				return resp.Status
+			}, time.Second*5, time.Millisecond*250).Should(Equal(healthpb.HealthCheckResponse_SERVING))
+
+			By("checking the liveness on the health port, like the kubelet does")
+			conn, err := grpc.DialContext(ctx, "127.0.0.1:9001", grpc.WithTransportCredentials(insecure.NewCredentials()))
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+			resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: LivenessService})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Status).To(Equal(healthpb.HealthCheckResponse_SERVING))
+		})
+	})
+
+	Describe("for the agent state", func() {
+		It("should return the state as JSON", func() {
+			adminClient := newClient("")
+			defer adminClient.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer (cancel)()
+			resp, err := adminClient.GetAgentState(ctx, &AgentStateRequest{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.State).To(MatchJSON(`{"nodeName": "` + nodeName + `"}`))
+		})
+
+		It("should reject the request of a node", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer (cancel)()
+			_, err := phClient.GetAgentState(ctx, &AgentStateRequest{})
+			Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+		})
+	})
+
 })
+
+type fakeAgentStateProvider struct{}
+
+func (p *fakeAgentStateProvider) AgentStateJson() ([]byte, error) {
+	return []byte(`{"nodeName":"` + nodeName + `"}`), nil
+}
@@ -0,0 +1,60 @@
+package peerhealth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	selfNodeRemediationApis "github.com/medik8s/self-node-remediation/api"
+)
+
+const (
+	// etcdMemberStatusRefreshAge is the age after which a requested etcd member status is refreshed in the background
+	etcdMemberStatusRefreshAge = 5 * time.Second
+	// etcdMemberStatusMaxAge is how long an etcd member status is reported, older statuses are reported as unknown
+	etcdMemberStatusMaxAge = 30 * time.Second
+	// etcdMemberStatusTimeout is the max time for getting the etcd member status from the kubelet
+	etcdMemberStatusTimeout = 5 * time.Second
+)
+
+// etcdMemberStatusCache answers health requests with the last known status of the local etcd member, so that requests
+// never wait for the kubelet. The status is refreshed by a single background check when it was requested, and is
+// older than etcdMemberStatusRefreshAge.
+type etcdMemberStatusCache struct {
+	getter     EtcdMemberStatusGetter
+	mutex      sync.Mutex
+	status     selfNodeRemediationApis.EtcdMemberStatusCode
+	updated    time.Time
+	refreshing bool
+}
+
+func newEtcdMemberStatusCache(getter EtcdMemberStatusGetter) *etcdMemberStatusCache {
+	return &etcdMemberStatusCache{getter: getter}
+}
+
+// get returns the last known etcd member status, or unknown if there is none within etcdMemberStatusMaxAge
+func (c *etcdMemberStatusCache) get() selfNodeRemediationApis.EtcdMemberStatusCode {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	age := time.Since(c.updated)
+	if age >= etcdMemberStatusRefreshAge && !c.refreshing {
+		c.refreshing = true
+		go c.refresh()
+	}
+	if c.updated.IsZero() || age >= etcdMemberStatusMaxAge {
+		return selfNodeRemediationApis.EtcdMemberUnknown
+	}
+	return c.status
+}
+
+func (c *etcdMemberStatusCache) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdMemberStatusTimeout)
+	defer cancel()
+	status := c.getter.GetLocalEtcdMemberStatus(ctx)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.status = status
+	c.updated = time.Now()
+	c.refreshing = false
+}
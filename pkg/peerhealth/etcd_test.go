@@ -0,0 +1,59 @@
+package peerhealth
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	selfNodeRemediationApis "github.com/medik8s/self-node-remediation/api"
+)
+
+var _ = Describe("Etcd member status cache", func() {
+
+	It("should answer without waiting for the kubelet, and refresh in the background", func() {
+		getter := &fakeEtcdStatusGetter{release: make(chan struct{})}
+		cache := newEtcdMemberStatusCache(getter)
+
+		By("answering unknown until the first status was received")
+		for i := 0; i < 3; i++ {
+			Expect(cache.get()).To(Equal(selfNodeRemediationApis.EtcdMemberUnknown))
+		}
+		Eventually(getter.calls.Load).Should(BeEquivalentTo(1))
+		close(getter.release)
+		Eventually(cache.get).Should(Equal(selfNodeRemediationApis.EtcdMemberHealthy))
+
+		By("reusing recent statuses")
+		Consistently(getter.calls.Load, 100*time.Millisecond).Should(BeEquivalentTo(1))
+
+		By("refreshing old statuses, and answering unknown for outdated ones")
+		cache.mutex.Lock()
+		cache.updated = time.Now().Add(-etcdMemberStatusRefreshAge)
+		cache.mutex.Unlock()
+		Expect(cache.get()).To(Equal(selfNodeRemediationApis.EtcdMemberHealthy))
+		Eventually(getter.calls.Load).Should(BeEquivalentTo(2))
+
+		Eventually(func() bool {
+			cache.mutex.Lock()
+			defer cache.mutex.Unlock()
+			return cache.refreshing
+		}).Should(BeFalse())
+		cache.mutex.Lock()
+		cache.updated = time.Now().Add(-etcdMemberStatusMaxAge)
+		cache.mutex.Unlock()
+		Expect(cache.get()).To(Equal(selfNodeRemediationApis.EtcdMemberUnknown))
+	})
+})
+
+type fakeEtcdStatusGetter struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (g *fakeEtcdStatusGetter) GetLocalEtcdMemberStatus(_ context.Context) selfNodeRemediationApis.EtcdMemberStatusCode {
+	g.calls.Add(1)
+	<-g.release
+	return selfNodeRemediationApis.EtcdMemberHealthy
+}
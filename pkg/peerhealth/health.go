@@ -0,0 +1,77 @@
+package peerhealth
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// HealthPortEnvVar is the env var holding the port of the health service for the probes of the agent
+	HealthPortEnvVar = "HEALTH_PORT"
+	// LivenessService is the name of the health service checked by the liveness probe. It's serving as long as the
+	// server is running, so that agents which can't protect their node aren't restarted.
+	LivenessService = "liveness"
+
+	// servingStatusInterval is how often the serving status is updated
+	servingStatusInterval = 5 * time.Second
+)
+
+// ServingStatusProvider returns the serving status of the agent
+type ServingStatusProvider interface {
+	// IsServing returns whether the agent is able to protect its node, and the reason if it isn't
+	IsServing() (bool, string)
+	// IsServingPeers returns whether the agent is able to answer the health requests of peers, and the reason if it isn't
+	IsServingPeers() (bool, string)
+}
+
+// newHealthServer returns the standard grpc health service, with the liveness service serving, and the overall and
+// peer health services not serving until the serving status was updated
+func newHealthServer() *health.Server {
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(LivenessService, healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthServer.SetServingStatus(PeerHealth_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	return healthServer
+}
+
+// updateServingStatus updates the serving status of the overall and peer health services, until the given context is
+// done. The overall service reports whether the agent is able to protect its node, the peer health service, which is
+// checked by the readiness probe, whether it's able to answer its peers.
+func (s *Server) updateServingStatus(ctx context.Context) {
+	ticker := time.NewTicker(servingStatusInterval)
+	defer ticker.Stop()
+	wasServing, wasServingPeers := false, false
+	for {
+		serving, reason, servingPeers, peersReason := true, "", true, ""
+		if s.servingStatusProvider != nil {
+			serving, reason = s.servingStatusProvider.IsServing()
+			servingPeers, peersReason = s.servingStatusProvider.IsServingPeers()
+		}
+		if serving != wasServing {
+			s.log.Info("updating serving status", "serving", serving, "reason", reason)
+			wasServing = serving
+		}
+		if servingPeers != wasServingPeers {
+			s.log.Info("updating peer health serving status", "serving", servingPeers, "reason", peersReason)
+			wasServingPeers = servingPeers
+		}
+		s.health.SetServingStatus("", toServingStatus(serving))
+		s.health.SetServingStatus(PeerHealth_ServiceDesc.ServiceName, toServingStatus(servingPeers))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func toServingStatus(serving bool) healthpb.HealthCheckResponse_ServingStatus {
+	if serving {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
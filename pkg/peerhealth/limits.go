@@ -0,0 +1,193 @@
+package peerhealth
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/medik8s/self-node-remediation/pkg/certificates"
+)
+
+const (
+	// MaxConcurrentStreamsEnvVar is the env var holding the max number of concurrent requests on a single connection
+	MaxConcurrentStreamsEnvVar = "PEER_HEALTH_MAX_CONCURRENT_STREAMS"
+	// MaxConcurrentRequestsEnvVar is the env var holding the max number of requests handled at the same time
+	MaxConcurrentRequestsEnvVar = "PEER_HEALTH_MAX_CONCURRENT_REQUESTS"
+	// PeerRequestsPerSecondEnvVar is the env var holding the rate of requests accepted from each peer
+	PeerRequestsPerSecondEnvVar = "PEER_HEALTH_PEER_REQUESTS_PER_SECOND"
+	// PeerRequestBurstEnvVar is the env var holding the number of requests a peer can send at once
+	PeerRequestBurstEnvVar = "PEER_HEALTH_PEER_REQUEST_BURST"
+	// KeepaliveMinTimeEnvVar is the env var holding the min time between the keepalive pings of a peer
+	KeepaliveMinTimeEnvVar = "PEER_HEALTH_KEEPALIVE_MIN_TIME"
+
+	defaultMaxConcurrentStreams  = 10
+	defaultMaxConcurrentRequests = 20
+	defaultPeerRequestsPerSecond = 1
+	defaultPeerRequestBurst      = 5
+	defaultKeepaliveMinTime      = 30 * time.Second
+
+	// maxConnectionIdle is how long connections without requests are kept open. Agents create a connection for each
+	// request, so idle connections are only a waste of file descriptors.
+	maxConnectionIdle = time.Minute
+	// peerLimiterIdleTime is how long the token bucket of a peer is kept after its last request. It must be longer
+	// than the time a bucket needs to be refilled, otherwise peers could bypass the rate limit.
+	peerLimiterIdleTime = 5 * time.Minute
+
+	// busyMessage is the message of the Unavailable status the server answers with when a request exceeds the limits
+	busyMessage = "peer health server is busy"
+)
+
+// ServerLimits limits the load which the requests of peers put on the server. Zero values use the defaults.
+type ServerLimits struct {
+	// MaxConcurrentStreams is the max number of concurrent requests on a single connection
+	MaxConcurrentStreams int
+	// MaxConcurrentRequests is the max number of requests handled at the same time, over all connections
+	MaxConcurrentRequests int
+	// PeerRequestsPerSecond is the rate of requests accepted from each peer
+	PeerRequestsPerSecond int
+	// PeerRequestBurst is the number of requests a peer can send at once
+	PeerRequestBurst int
+	// KeepaliveMinTime is the min time between the keepalive pings of a peer, connections of peers which ping more
+	// often are closed
+	KeepaliveMinTime time.Duration
+}
+
+func (l ServerLimits) withDefaults() ServerLimits {
+	if l.MaxConcurrentStreams <= 0 {
+		l.MaxConcurrentStreams = defaultMaxConcurrentStreams
+	}
+	if l.MaxConcurrentRequests <= 0 {
+		l.MaxConcurrentRequests = defaultMaxConcurrentRequests
+	}
+	if l.PeerRequestsPerSecond <= 0 {
+		l.PeerRequestsPerSecond = defaultPeerRequestsPerSecond
+	}
+	if l.PeerRequestBurst <= 0 {
+		l.PeerRequestBurst = defaultPeerRequestBurst
+	}
+	if l.KeepaliveMinTime <= 0 {
+		l.KeepaliveMinTime = defaultKeepaliveMinTime
+	}
+	return l
+}
+
+// serverOptions returns the options which enforce the connection limits
+func (l ServerLimits) serverOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.MaxConcurrentStreams(uint32(l.MaxConcurrentStreams)),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime: l.KeepaliveMinTime,
+		}),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionIdle: maxConnectionIdle,
+		}),
+	}
+}
+
+// IsBusy returns true if the given error is the answer of a server which rejected the request because of its limits.
+// The peer didn't answer the request, but it isn't broken either.
+func IsBusy(err error) bool {
+	s, ok := status.FromError(err)
+	return ok && s.Code() == codes.Unavailable && s.Message() == busyMessage
+}
+
+// admission rejects requests which exceed the limits of the server. Requests aren't queued, so that peers get a fast
+// answer and can ask other peers instead.
+type admission struct {
+	limits    ServerLimits
+	log       logr.Logger
+	inflight  chan struct{}
+	mutex     sync.Mutex
+	peers     map[string]*peerLimiter
+	lastPrune time.Time
+}
+
+// peerLimiter is the token bucket of a single peer
+type peerLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newAdmission(limits ServerLimits, log logr.Logger) *admission {
+	return &admission{
+		limits:   limits,
+		log:      log,
+		inflight: make(chan struct{}, limits.MaxConcurrentRequests),
+		peers:    map[string]*peerLimiter{},
+	}
+}
+
+// unaryInterceptor is a grpc.UnaryServerInterceptor which admits requests within the limits
+func (a *admission) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	requester := peerKey(ctx)
+	if !a.allow(requester, time.Now()) {
+		a.log.Info("rejecting request, peer exceeded its rate limit", "peer", requester, "method", info.FullMethod)
+		return nil, status.Error(codes.Unavailable, busyMessage)
+	}
+	select {
+	case a.inflight <- struct{}{}:
+		defer func() { <-a.inflight }()
+	default:
+		a.log.Info("rejecting request, too many concurrent requests", "peer", requester, "method", info.FullMethod)
+		return nil, status.Error(codes.Unavailable, busyMessage)
+	}
+	return handler(ctx, req)
+}
+
+// allow takes a token from the bucket of the given peer, and returns false if it's empty
+func (a *admission) allow(requester string, now time.Time) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if now.Sub(a.lastPrune) > peerLimiterIdleTime {
+		for address, p := range a.peers {
+			if now.Sub(p.lastSeen) > peerLimiterIdleTime {
+				delete(a.peers, address)
+			}
+		}
+		a.lastPrune = now
+	}
+
+	p, found := a.peers[requester]
+	if !found {
+		p = &peerLimiter{limiter: rate.NewLimiter(rate.Limit(a.limits.PeerRequestsPerSecond), a.limits.PeerRequestBurst)}
+		a.peers[requester] = p
+	}
+	p.lastSeen = now
+	return p.limiter.AllowN(now, 1)
+}
+
+// peerKey identifies the peer which sent the request for rate limiting. Agents are identified by the node name of
+// their verified certificate, so that the limit can't be bypassed by changing the source IP, and agents behind the same
+// IP don't share a limit. Requesters without a node certificate, like admin tools or agents sharing a certificate, are
+// identified by their IP.
+func peerKey(ctx context.Context) string {
+	if cert, err := requesterCert(ctx); err == nil {
+		if nodeName, ok := certificates.NodeName(cert); ok {
+			return "node/" + nodeName
+		}
+	}
+	return peerAddress(ctx)
+}
+
+// peerAddress returns the IP of the peer which sent the request, it identifies the peer for rate limiting
+func peerAddress(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
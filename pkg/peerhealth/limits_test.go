@@ -0,0 +1,100 @@
+package peerhealth
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+	"github.com/medik8s/self-node-remediation/pkg/certificates"
+)
+
+var _ = Describe("Admission of peer health requests", func() {
+
+	var a *admission
+	info := &grpc.UnaryServerInfo{FullMethod: "/peerhealth.PeerHealth/IsHealthy"}
+
+	BeforeEach(func() {
+		a = newAdmission(ServerLimits{MaxConcurrentRequests: 1, PeerRequestsPerSecond: 1, PeerRequestBurst: 2}.withDefaults(), ctrl.Log.WithName("admission test"))
+	})
+
+	It("should limit the requests of each peer with a token bucket", func() {
+		now := time.Now()
+		Expect(a.allow("192.0.2.10", now)).To(BeTrue())
+		Expect(a.allow("192.0.2.10", now)).To(BeTrue())
+		Expect(a.allow("192.0.2.10", now)).To(BeFalse(), "the burst should be used up")
+		Expect(a.allow("192.0.2.11", now)).To(BeTrue(), "other peers should have their own bucket")
+		Expect(a.allow("192.0.2.10", now.Add(time.Second))).To(BeTrue(), "the bucket should be refilled")
+	})
+
+	It("should forget idle peers", func() {
+		now := time.Now()
+		Expect(a.allow("192.0.2.10", now)).To(BeTrue())
+		Expect(a.allow("192.0.2.11", now.Add(peerLimiterIdleTime+time.Second))).To(BeTrue())
+		Expect(a.peers).To(HaveLen(1))
+		Expect(a.peers).To(HaveKey("192.0.2.11"))
+	})
+
+	It("should identify peers by their node certificate", func() {
+		caPem, caKeyPem, err := certificates.CreateCA(v1alpha1.RSAPeerCertificatesKeyAlgorithm)
+		Expect(err).ToNot(HaveOccurred())
+		peerContext := func(certPem *bytes.Buffer, ip string) context.Context {
+			block, _ := pem.Decode(certPem.Bytes())
+			cert, err := x509.ParseCertificate(block.Bytes)
+			ExpectWithOffset(1, err).ToNot(HaveOccurred())
+			return peer.NewContext(context.Background(), &peer.Peer{
+				Addr:     &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000},
+				AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}},
+			})
+		}
+
+		nodeCertPem, _, err := certificates.CreateNodeCert(caPem, caKeyPem, "node-a")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(peerKey(peerContext(nodeCertPem, "192.0.2.10"))).To(Equal("node/node-a"))
+		Expect(peerKey(peerContext(nodeCertPem, "192.0.2.11"))).To(Equal("node/node-a"), "the IP shouldn't matter")
+
+		adminCertPem, _, err := certificates.CreateAdminCert(caPem, caKeyPem)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(peerKey(peerContext(adminCertPem, "192.0.2.10"))).To(Equal("192.0.2.10"))
+	})
+
+	It("should reject requests beyond the max concurrent requests immediately", func() {
+		a = newAdmission(ServerLimits{MaxConcurrentRequests: 1, PeerRequestBurst: 10}.withDefaults(), ctrl.Log.WithName("admission test"))
+		started, release := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			_, err := a.unaryInterceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+				close(started)
+				<-release
+				return nil, nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+		}()
+		Eventually(started).Should(BeClosed())
+
+		_, err := a.unaryInterceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+			Fail("the handler must not be called")
+			return nil, nil
+		})
+		Expect(IsBusy(err)).To(BeTrue())
+
+		close(release)
+		Eventually(func() error {
+			_, err := a.unaryInterceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+				return nil, nil
+			})
+			return err
+		}).Should(Succeed())
+	})
+})
@@ -0,0 +1,72 @@
+package peerhealth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+)
+
+// apiLivenessMaxAge is how long a successful API check is reused for answering health requests
+const apiLivenessMaxAge = time.Second
+
+// apiLiveness checks whether the API server is reachable, without using the cache. Concurrent health requests share
+// a check, and successful checks are reused for apiLivenessMaxAge, so that many peers asking at once don't multiply
+// the API requests.
+type apiLiveness struct {
+	reader      client.Reader
+	mutex       sync.Mutex
+	lastSuccess time.Time
+	running     *livenessCheck
+}
+
+// livenessCheck is a running check, done is closed when it finished
+type livenessCheck struct {
+	done chan struct{}
+	err  error
+}
+
+func newApiLiveness(reader client.Reader) *apiLiveness {
+	return &apiLiveness{reader: reader}
+}
+
+// check returns nil when the API server was reachable within apiLivenessMaxAge, or when it's reachable now
+func (l *apiLiveness) check(ctx context.Context) error {
+	l.mutex.Lock()
+	if time.Since(l.lastSuccess) < apiLivenessMaxAge {
+		l.mutex.Unlock()
+		return nil
+	}
+	running := l.running
+	if running == nil {
+		running = &livenessCheck{done: make(chan struct{})}
+		l.running = running
+		go l.run(running)
+	}
+	l.mutex.Unlock()
+
+	select {
+	case <-running.done:
+		return running.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *apiLiveness) run(running *livenessCheck) {
+	ctx, cancel := context.WithTimeout(context.Background(), apiServerTimeout)
+	defer cancel()
+	// a single SNR needs the same permissions as the SNR lookup, but is cheap for the API server
+	running.err = l.reader.List(ctx, &v1alpha1.SelfNodeRemediationList{}, client.Limit(1))
+
+	l.mutex.Lock()
+	if running.err == nil {
+		l.lastSuccess = time.Now()
+	}
+	l.running = nil
+	l.mutex.Unlock()
+	close(running.done)
+}
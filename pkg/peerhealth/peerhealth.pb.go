@@ -82,6 +82,8 @@ type HealthResponse struct {
 	unknownFields protoimpl.UnknownFields
 
 	Status int32 `protobuf:"varint,1,opt,name=status,proto3" json:"status,omitempty"`
+	// the health of the etcd member running on the responding node, only set by control plane peers
+	EtcdMemberStatus int32 `protobuf:"varint,2,opt,name=etcdMemberStatus,proto3" json:"etcdMemberStatus,omitempty"`
 }
 
 func (x *HealthResponse) Reset() {
@@ -123,6 +125,99 @@ func (x *HealthResponse) GetStatus() int32 {
 	return 0
 }
 
+func (x *HealthResponse) GetEtcdMemberStatus() int32 {
+	if x != nil {
+		return x.EtcdMemberStatus
+	}
+	return 0
+}
+
+type AgentStateRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *AgentStateRequest) Reset() {
+	*x = AgentStateRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_peerhealth_peerhealth_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AgentStateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentStateRequest) ProtoMessage() {}
+
+func (x *AgentStateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_peerhealth_peerhealth_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentStateRequest.ProtoReflect.Descriptor instead.
+func (*AgentStateRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_peerhealth_peerhealth_proto_rawDescGZIP(), []int{2}
+}
+
+type AgentStateResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// the state of the agent, serialized as JSON
+	State string `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+}
+
+func (x *AgentStateResponse) Reset() {
+	*x = AgentStateResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_peerhealth_peerhealth_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AgentStateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentStateResponse) ProtoMessage() {}
+
+func (x *AgentStateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_peerhealth_peerhealth_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentStateResponse.ProtoReflect.Descriptor instead.
+func (*AgentStateResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_peerhealth_peerhealth_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AgentStateResponse) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
 var File_pkg_peerhealth_peerhealth_proto protoreflect.FileDescriptor
 
 var file_pkg_peerhealth_peerhealth_proto_rawDesc = []byte{
@@ -134,19 +229,34 @@ var file_pkg_peerhealth_peerhealth_proto_rawDesc = []byte{
 	0x0a, 0x08, 0x6e, 0x6f, 0x64, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
 	0x52, 0x08, 0x6e, 0x6f, 0x64, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x6d, 0x61,
 	0x63, 0x68, 0x69, 0x6e, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x0b, 0x6d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x22, 0x28, 0x0a, 0x0e,
+	0x0b, 0x6d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x22, 0x54, 0x0a, 0x0e,
 	0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x16,
 	0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06,
-	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x32, 0x72, 0x0a, 0x0a, 0x50, 0x65, 0x65, 0x72, 0x48, 0x65,
-	0x61, 0x6c, 0x74, 0x68, 0x12, 0x64, 0x0a, 0x09, 0x49, 0x73, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68,
-	0x79, 0x12, 0x29, 0x2e, 0x73, 0x65, 0x6c, 0x66, 0x6e, 0x6f, 0x64, 0x65, 0x72, 0x65, 0x6d, 0x65,
-	0x64, 0x69, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x2e, 0x48,
-	0x65, 0x61, 0x6c, 0x74, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2a, 0x2e, 0x73,
-	0x65, 0x6c, 0x66, 0x6e, 0x6f, 0x64, 0x65, 0x72, 0x65, 0x6d, 0x65, 0x64, 0x69, 0x61, 0x74, 0x69,
-	0x6f, 0x6e, 0x2e, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x2e, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68,
-	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x42, 0x10, 0x5a, 0x0e, 0x70, 0x6b,
-	0x67, 0x2f, 0x70, 0x65, 0x65, 0x72, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x62, 0x06, 0x70, 0x72,
-	0x6f, 0x74, 0x6f, 0x33,
+	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x2a, 0x0a, 0x10, 0x65, 0x74, 0x63, 0x64, 0x4d, 0x65,
+	0x6d, 0x62, 0x65, 0x72, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x10, 0x65, 0x74, 0x63, 0x64, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x53, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x22, 0x13, 0x0a, 0x11, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x2a, 0x0a, 0x12, 0x41, 0x67, 0x65, 0x6e, 0x74,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x14, 0x0a,
+	0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x73, 0x74,
+	0x61, 0x74, 0x65, 0x32, 0x72, 0x0a, 0x0a, 0x50, 0x65, 0x65, 0x72, 0x48, 0x65, 0x61, 0x6c, 0x74,
+	0x68, 0x12, 0x64, 0x0a, 0x09, 0x49, 0x73, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x79, 0x12, 0x29,
+	0x2e, 0x73, 0x65, 0x6c, 0x66, 0x6e, 0x6f, 0x64, 0x65, 0x72, 0x65, 0x6d, 0x65, 0x64, 0x69, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x2e, 0x48, 0x65, 0x61, 0x6c,
+	0x74, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2a, 0x2e, 0x73, 0x65, 0x6c, 0x66,
+	0x6e, 0x6f, 0x64, 0x65, 0x72, 0x65, 0x6d, 0x65, 0x64, 0x69, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e,
+	0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x2e, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x32, 0x7e, 0x0a, 0x0a, 0x41, 0x67, 0x65, 0x6e, 0x74,
+	0x41, 0x64, 0x6d, 0x69, 0x6e, 0x12, 0x70, 0x0a, 0x0d, 0x47, 0x65, 0x74, 0x41, 0x67, 0x65, 0x6e,
+	0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x2d, 0x2e, 0x73, 0x65, 0x6c, 0x66, 0x6e, 0x6f, 0x64,
+	0x65, 0x72, 0x65, 0x6d, 0x65, 0x64, 0x69, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x68, 0x65, 0x61,
+	0x6c, 0x74, 0x68, 0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2e, 0x2e, 0x73, 0x65, 0x6c, 0x66, 0x6e, 0x6f, 0x64, 0x65,
+	0x72, 0x65, 0x6d, 0x65, 0x64, 0x69, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x68, 0x65, 0x61, 0x6c,
+	0x74, 0x68, 0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x42, 0x10, 0x5a, 0x0e, 0x70, 0x6b, 0x67, 0x2f, 0x70,
+	0x65, 0x65, 0x72, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
 }
 
 var (
@@ -161,16 +271,20 @@ func file_pkg_peerhealth_peerhealth_proto_rawDescGZIP() []byte {
 	return file_pkg_peerhealth_peerhealth_proto_rawDescData
 }
 
-var file_pkg_peerhealth_peerhealth_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_pkg_peerhealth_peerhealth_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_pkg_peerhealth_peerhealth_proto_goTypes = []interface{}{
-	(*HealthRequest)(nil),  // 0: selfnoderemediation.health.HealthRequest
-	(*HealthResponse)(nil), // 1: selfnoderemediation.health.HealthResponse
+	(*HealthRequest)(nil),      // 0: selfnoderemediation.health.HealthRequest
+	(*HealthResponse)(nil),     // 1: selfnoderemediation.health.HealthResponse
+	(*AgentStateRequest)(nil),  // 2: selfnoderemediation.health.AgentStateRequest
+	(*AgentStateResponse)(nil), // 3: selfnoderemediation.health.AgentStateResponse
 }
 var file_pkg_peerhealth_peerhealth_proto_depIdxs = []int32{
 	0, // 0: selfnoderemediation.health.PeerHealth.IsHealthy:input_type -> selfnoderemediation.health.HealthRequest
-	1, // 1: selfnoderemediation.health.PeerHealth.IsHealthy:output_type -> selfnoderemediation.health.HealthResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
+	2, // 1: selfnoderemediation.health.AgentAdmin.GetAgentState:input_type -> selfnoderemediation.health.AgentStateRequest
+	1, // 2: selfnoderemediation.health.PeerHealth.IsHealthy:output_type -> selfnoderemediation.health.HealthResponse
+	3, // 3: selfnoderemediation.health.AgentAdmin.GetAgentState:output_type -> selfnoderemediation.health.AgentStateResponse
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -206,6 +320,30 @@ func file_pkg_peerhealth_peerhealth_proto_init() {
 				return nil
 			}
 		}
+		file_pkg_peerhealth_peerhealth_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AgentStateRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_peerhealth_peerhealth_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AgentStateResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -213,9 +351,9 @@ func file_pkg_peerhealth_peerhealth_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_pkg_peerhealth_peerhealth_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   4,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   2,
 		},
 		GoTypes:           file_pkg_peerhealth_peerhealth_proto_goTypes,
 		DependencyIndexes: file_pkg_peerhealth_peerhealth_proto_depIdxs,
@@ -100,3 +100,91 @@ var PeerHealth_ServiceDesc = grpc.ServiceDesc{
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/peerhealth/peerhealth.proto",
 }
+
+// AgentAdminClient is the client API for AgentAdmin service.
+//
+// AgentAdmin is used for troubleshooting the agent.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AgentAdminClient interface {
+	GetAgentState(ctx context.Context, in *AgentStateRequest, opts ...grpc.CallOption) (*AgentStateResponse, error)
+}
+
+type agentAdminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAgentAdminClient(cc grpc.ClientConnInterface) AgentAdminClient {
+	return &agentAdminClient{cc}
+}
+
+func (c *agentAdminClient) GetAgentState(ctx context.Context, in *AgentStateRequest, opts ...grpc.CallOption) (*AgentStateResponse, error) {
+	out := new(AgentStateResponse)
+	err := c.cc.Invoke(ctx, "/selfnoderemediation.health.AgentAdmin/GetAgentState", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AgentAdminServer is the server API for AgentAdmin service.
+// All implementations must embed UnimplementedAgentAdminServer
+// for forward compatibility
+type AgentAdminServer interface {
+	GetAgentState(context.Context, *AgentStateRequest) (*AgentStateResponse, error)
+	mustEmbedUnimplementedAgentAdminServer()
+}
+
+// UnimplementedAgentAdminServer must be embedded to have forward compatible implementations.
+type UnimplementedAgentAdminServer struct {
+}
+
+func (UnimplementedAgentAdminServer) GetAgentState(context.Context, *AgentStateRequest) (*AgentStateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAgentState not implemented")
+}
+func (UnimplementedAgentAdminServer) mustEmbedUnimplementedAgentAdminServer() {}
+
+// UnsafeAgentAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AgentAdminServer will
+// result in compilation errors.
+type UnsafeAgentAdminServer interface {
+	mustEmbedUnimplementedAgentAdminServer()
+}
+
+func RegisterAgentAdminServer(s grpc.ServiceRegistrar, srv AgentAdminServer) {
+	s.RegisterService(&AgentAdmin_ServiceDesc, srv)
+}
+
+func _AgentAdmin_GetAgentState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AgentStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentAdminServer).GetAgentState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/selfnoderemediation.health.AgentAdmin/GetAgentState",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentAdminServer).GetAgentState(ctx, req.(*AgentStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AgentAdmin_ServiceDesc is the grpc.ServiceDesc for AgentAdmin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AgentAdmin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "selfnoderemediation.health.AgentAdmin",
+	HandlerType: (*AgentAdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetAgentState",
+			Handler:    _AgentAdmin_GetAgentState_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "pkg/peerhealth/peerhealth.proto",
+}
@@ -2,12 +2,19 @@ package peerhealth
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
 	"net"
 	"time"
 
 	"github.com/go-logr/logr"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -40,29 +47,69 @@ var (
 	}
 )
 
+// EtcdMemberStatusGetter returns the health of the etcd member running on this node
+type EtcdMemberStatusGetter interface {
+	GetLocalEtcdMemberStatus(ctx context.Context) selfNodeRemediationApis.EtcdMemberStatusCode
+}
+
+// AgentStateProvider returns the state of the agent serialized as JSON, for troubleshooting
+type AgentStateProvider interface {
+	AgentStateJson() ([]byte, error)
+}
+
 type Server struct {
 	UnimplementedPeerHealthServer
-	c          client.Client
-	reader     client.Reader
-	log        logr.Logger
-	certReader certificates.CertStorageReader
-	port       int
+	UnimplementedAgentAdminServer
+	c                     client.Client
+	reader                client.Reader
+	log                   logr.Logger
+	certReader            certificates.CertStorageReader
+	port                  int
+	etcdMemberStatus      *etcdMemberStatusCache
+	agentStateProvider    AgentStateProvider
+	apiLiveness           *apiLiveness
+	limits                ServerLimits
+	admission             *admission
+	healthPort            int
+	servingStatusProvider ServingStatusProvider
+	health                *health.Server
 }
 
-// NewServer returns a new Server
-func NewServer(c client.Client, reader client.Reader, log logr.Logger, port int, certReader certificates.CertStorageReader) (*Server, error) {
+// NewServer returns a new Server. The etcdStatusGetter is optional, without it the etcd member status isn't reported.
+// The agentStateProvider is optional as well, without it the agent state isn't available. Zero limits use the defaults.
+// The health service is additionally served without TLS on the healthPort, unless it's 0. Without servingStatusProvider,
+// the health service is always serving.
+func NewServer(c client.Client, reader client.Reader, log logr.Logger, port int, healthPort int, limits ServerLimits, certReader certificates.CertStorageReader, etcdStatusGetter EtcdMemberStatusGetter, agentStateProvider AgentStateProvider, servingStatusProvider ServingStatusProvider) (*Server, error) {
+	limits = limits.withDefaults()
+	var etcdMemberStatus *etcdMemberStatusCache
+	if etcdStatusGetter != nil {
+		etcdMemberStatus = newEtcdMemberStatusCache(etcdStatusGetter)
+	}
 	return &Server{
-		c:          c,
-		reader:     reader,
-		log:        log,
-		certReader: certReader,
-		port:       port,
+		c:                     c,
+		reader:                reader,
+		log:                   log,
+		certReader:            certReader,
+		port:                  port,
+		etcdMemberStatus:      etcdMemberStatus,
+		agentStateProvider:    agentStateProvider,
+		apiLiveness:           newApiLiveness(reader),
+		limits:                limits,
+		admission:             newAdmission(limits, log),
+		healthPort:            healthPort,
+		servingStatusProvider: servingStatusProvider,
+		health:                newHealthServer(),
 	}, nil
 }
 
 // Start implements Runnable for usage by manager
 func (s *Server) Start(ctx context.Context) error {
 
+	// the certificate of this node might not be issued yet
+	if err := certificates.WaitForCerts(ctx, s.certReader); err != nil {
+		// stopped while waiting
+		return nil
+	}
 	serverCreds, err := certificates.GetServerCredentialsFromCerts(s.certReader)
 	if err != nil {
 		s.log.Error(err, "failed to get server credentials")
@@ -78,24 +125,64 @@ func (s *Server) Start(ctx context.Context) error {
 	opts := []grpc.ServerOption{
 		grpc.ConnectionTimeout(connectionTimeout),
 		grpc.Creds(serverCreds),
+		// requests beyond the limits are rejected before they reach the handlers
+		grpc.ChainUnaryInterceptor(s.admission.unaryInterceptor),
 	}
+	opts = append(opts, s.limits.serverOptions()...)
 	grpcServer := grpc.NewServer(opts...)
 	RegisterPeerHealthServer(grpcServer, s)
+	RegisterAgentAdminServer(grpcServer, s)
+	healthpb.RegisterHealthServer(grpcServer, s.health)
 
-	errChan := make(chan error)
+	errChan := make(chan error, 2)
 	go func() {
 		if err := grpcServer.Serve(lis); err != nil {
 			errChan <- err
 		}
 	}()
 
+	// the kubelet doesn't support TLS for grpc probes, so the health service is served without TLS on its own port
+	var healthGrpcServer *grpc.Server
+	if s.healthPort > 0 {
+		healthLis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.healthPort))
+		if err != nil {
+			s.log.Error(err, "failed to listen for health checks")
+			grpcServer.Stop()
+			return err
+		}
+		healthGrpcServer = grpc.NewServer(s.limits.serverOptions()...)
+		healthpb.RegisterHealthServer(healthGrpcServer, s.health)
+		go func() {
+			if err := healthGrpcServer.Serve(healthLis); err != nil {
+				errChan <- err
+			}
+		}()
+	}
+
+	if s.etcdMemberStatus != nil {
+		// get the etcd member status before the first health request
+		s.etcdMemberStatus.get()
+	}
+
+	statusCtx, cancelStatus := context.WithCancel(ctx)
+	defer cancelStatus()
+	go s.updateServingStatus(statusCtx)
+
 	s.log.Info("peer health server started")
 
 	select {
 	case err := <-errChan:
+		grpcServer.Stop()
+		if healthGrpcServer != nil {
+			healthGrpcServer.Stop()
+		}
 		return err
 	case <-ctx.Done():
+		s.health.Shutdown()
 		grpcServer.Stop()
+		if healthGrpcServer != nil {
+			healthGrpcServer.Stop()
+		}
 	}
 	return nil
 }
@@ -108,32 +195,113 @@ func (s *Server) IsHealthy(ctx context.Context, request *HealthRequest) (*Health
 	if nodeName == "" {
 		return nil, fmt.Errorf("empty node name in HealthRequest")
 	}
+	if err := s.authorizeRequester(ctx, nodeName); err != nil {
+		s.log.Info("rejecting health request", "node", nodeName, "reason", err.Error())
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
 
 	apiCtx, cancelFunc := context.WithTimeout(ctx, apiServerTimeout)
 	defer cancelFunc()
 
-	// list snrs from all ns
-	// don't use cache, because this also tests API server connectivity!
-	snrs := &v1alpha1.SelfNodeRemediationList{}
-	if err := s.reader.List(apiCtx, snrs); err != nil {
-		s.log.Error(err, "api error, failed to list snrs")
-		return toResponse(selfNodeRemediationApis.ApiError)
+	// the API connectivity is checked separately, because the SNRs are looked up in the cache
+	if err := s.apiLiveness.check(apiCtx); err != nil {
+		s.log.Error(err, "api error, API server isn't reachable")
+		return s.toResponse(selfNodeRemediationApis.ApiError)
+	}
+	snrs, err := controllers.GetMatchingSNRs(apiCtx, s.c, nodeName, request.GetMachineName(), s.log)
+	if err != nil {
+		s.log.Error(err, "failed to get matching snrs")
+		return s.toResponse(selfNodeRemediationApis.ApiError)
 	}
 
 	// return healthy only if no snr matches that node
-	for i := range snrs.Items {
-		snrMatches, _, err := controllers.IsSNRMatching(ctx, s.c, &snrs.Items[i], nodeName, request.GetMachineName(), s.log)
-		if err != nil {
-			s.log.Error(err, "failed to check if SNR matches node")
+	for i := range snrs {
+		if snrs[i].Spec.DryRun || controllers.IsRemediationLoopDetected(&snrs[i]) || controllers.IsWaitingForControlPlaneRemediation(&snrs[i]) {
+			// dry runs don't remediate, nodes which were remediated too often must not reboot themselves again, and
+			// control plane nodes wait until the remediation of another control plane node completed
 			continue
 		}
-		if snrMatches {
-			s.log.Info("found matching SNR, node is unhealthy", "node", nodeName, "machine", request.MachineName)
-			return toResponse(selfNodeRemediationApis.Unhealthy)
-		}
+		s.log.Info("found matching SNR, node is unhealthy", "node", nodeName, "machine", request.MachineName)
+		return s.toResponse(selfNodeRemediationApis.Unhealthy)
 	}
 	s.log.Info("no matching SNR found, node is considered healthy", "node", nodeName, "machine", request.MachineName)
-	return toResponse(selfNodeRemediationApis.Healthy)
+	return s.toResponse(selfNodeRemediationApis.Healthy)
+}
+
+// GetAgentState returns the state of the agent. It's only served to admin tools, because the state describes the node
+// and its configuration.
+func (s *Server) GetAgentState(ctx context.Context, _ *AgentStateRequest) (*AgentStateResponse, error) {
+	if err := s.authorizeAdmin(ctx); err != nil {
+		s.log.Info("rejecting agent state request", "reason", err.Error())
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if s.agentStateProvider == nil {
+		return nil, status.Error(codes.Unavailable, "agent state isn't available")
+	}
+	state, err := s.agentStateProvider.AgentStateJson()
+	if err != nil {
+		s.log.Error(err, "failed to get agent state")
+		return nil, status.Errorf(codes.Internal, "failed to get agent state: %v", err)
+	}
+	return &AgentStateResponse{State: string(state)}, nil
+}
+
+// authorizeRequester checks that the request was sent by the agent of the given node, which is identified by the node
+// certificate it was authenticated with. This prevents agents from asking for the health of other nodes.
+// When the agents share a certificate, e.g. with the External certificates backend, requesters can't be identified,
+// so all requesters with a certificate signed by the CA are accepted.
+func (s *Server) authorizeRequester(ctx context.Context, nodeName string) error {
+	if _, hasNodeCert, err := certificates.GetNodeName(s.certReader); err != nil {
+		return fmt.Errorf("failed to get own certificate: %w", err)
+	} else if !hasNodeCert {
+		return nil
+	}
+
+	cert, err := requesterCert(ctx)
+	if err != nil {
+		return err
+	}
+	requester, ok := certificates.NodeName(cert)
+	if !ok {
+		return fmt.Errorf("requester has no node certificate")
+	}
+	if requester != nodeName {
+		return fmt.Errorf("requester %s isn't node %s", requester, nodeName)
+	}
+	return nil
+}
+
+// authorizeAdmin checks that the request was sent by an admin tool, which is identified by the admin certificate it
+// was authenticated with. Like for authorizeRequester, all requesters with a certificate signed by the CA are accepted
+// when the agents share a certificate.
+func (s *Server) authorizeAdmin(ctx context.Context) error {
+	if _, hasNodeCert, err := certificates.GetNodeName(s.certReader); err != nil {
+		return fmt.Errorf("failed to get own certificate: %w", err)
+	} else if !hasNodeCert {
+		return nil
+	}
+
+	cert, err := requesterCert(ctx)
+	if err != nil {
+		return err
+	}
+	if !certificates.IsAdmin(cert) {
+		return fmt.Errorf("requester has no admin certificate")
+	}
+	return nil
+}
+
+// requesterCert returns the verified client certificate the request was authenticated with
+func requesterCert(ctx context.Context) (*x509.Certificate, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("unknown requester")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil, fmt.Errorf("requester isn't authenticated")
+	}
+	return tlsInfo.State.VerifiedChains[0][0], nil
 }
 
 func (s *Server) getNode(ctx context.Context, nodeName string) (*corev1.Node, error) {
@@ -148,8 +316,13 @@ func (s *Server) getNode(ctx context.Context, nodeName string) (*corev1.Node, er
 	return node, nil
 }
 
-func toResponse(status selfNodeRemediationApis.HealthCheckResponseCode) (*HealthResponse, error) {
+func (s *Server) toResponse(status selfNodeRemediationApis.HealthCheckResponseCode) (*HealthResponse, error) {
+	etcdMemberStatus := selfNodeRemediationApis.EtcdMemberUnknown
+	if s.etcdMemberStatus != nil {
+		etcdMemberStatus = s.etcdMemberStatus.get()
+	}
 	return &HealthResponse{
-		Status: int32(status),
+		Status:           int32(status),
+		EtcdMemberStatus: int32(etcdMemberStatus),
 	}, nil
 }
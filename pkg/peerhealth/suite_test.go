@@ -86,6 +86,10 @@ var _ = BeforeSuite(func() {
 	err = snrReconciler.SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
+	// the peer health server looks up SNRs with this index
+	err = controllers.IndexSNRTargets(context.Background(), k8sManager.GetFieldIndexer())
+	Expect(err).ToNot(HaveOccurred())
+
 	var ctx context.Context
 	ctx, cancelFunc = context.WithCancel(ctrl.SetupSignalHandler())
 	go func() {
@@ -38,6 +38,7 @@ type Peers struct {
 	mutex                                            sync.Mutex
 	apiServerTimeout                                 time.Duration
 	workerPeersAddresses, controlPlanePeersAddresses []v1.PodIP
+	lastUpdateTime                                   time.Time
 }
 
 func New(myNodeName string, peerUpdateInterval time.Duration, reader client.Reader, log logr.Logger, apiServerTimeout time.Duration) *Peers {
@@ -150,9 +151,17 @@ func (p *Peers) updatePeers(ctx context.Context, getSelector func() labels.Selec
 		}
 	}
 	setAddresses(addresses)
+	p.lastUpdateTime = time.Now()
 	return nil
 }
 
+// LastUpdateTime returns when a peer list was updated successfully the last time
+func (p *Peers) LastUpdateTime() time.Time {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.lastUpdateTime
+}
+
 func (p *Peers) GetPeersAddresses(role Role) []v1.PodIP {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
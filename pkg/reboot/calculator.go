@@ -20,6 +20,8 @@ import (
 
 const (
 	MaxTimeForNoPeersResponse = 30 * time.Second
+	// defaultGracefulRebootGracePeriod is used when graceful reboots are enabled without a grace period
+	defaultGracefulRebootGracePeriod = 60 * time.Second
 )
 
 type Calculator interface {
@@ -129,12 +131,26 @@ func (r *calculator) calculateMinimumRebootDuration(ctx context.Context, watchdo
 	// 3. trigger the reboot
 	// a) watchdog timeout ...
 	rebootDuration := watchdogTimeout
-	// b) ... plus some buffer for actually rebooting
+	// b) ... plus the grace period in case of graceful reboots, during which the watchdog is still fed ...
+	rebootDuration += GetGracefulRebootGracePeriod(r.snrConfig)
+	// c) ... plus some buffer for actually rebooting
 	rebootDuration += 30 * time.Second
 
 	return apiCheckDuration + peerRequestsDuration + rebootDuration, nil
 }
 
+// GetGracefulRebootGracePeriod returns the grace period of graceful reboots, or 0 if graceful reboots are disabled
+func GetGracefulRebootGracePeriod(config *v1alpha1.SelfNodeRemediationConfig) time.Duration {
+	gracefulReboot := config.Spec.GracefulReboot
+	if gracefulReboot == nil {
+		return 0
+	}
+	if gracefulReboot.GracePeriod == nil {
+		return defaultGracefulRebootGracePeriod
+	}
+	return gracefulReboot.GracePeriod.Duration
+}
+
 func (r *calculator) calcNumOfBatches(k8sClient client.Client, ctx context.Context) (int, error) {
 
 	// get all worker nodes
@@ -117,6 +117,25 @@ var _ = Describe("Calculator tests", func() {
 			}, "15s", "200ms").Should(Equal(time.Duration(expectedRebootDurationSeconds) * time.Second))
 		})
 	})
+
+	Context("with graceful reboot, 2 peers, and 10s watchdog timeout", func() {
+		BeforeEach(func() {
+			snrConfig.Spec.GracefulReboot = &v1alpha1.GracefulReboot{GracePeriod: &metav1.Duration{Duration: 45 * time.Second}}
+			watchdogTimeoutSeconds = 10
+			nrOfPeers = 2
+			// 3 * (15 + 5) (API server)
+			// + 30 (MaxTimeForNoPeersResponse)
+			// + 10 (Watchdog)
+			// + 45 (Graceful reboot)
+			// + 30
+			expectedRebootDurationSeconds = 175
+		})
+		It("GetRebootTime should return correct value", func() {
+			Eventually(func() (time.Duration, error) {
+				return calculator.GetRebootDuration(context.Background(), unhealthyNode)
+			}, "15s", "200ms").Should(Equal(time.Duration(expectedRebootDurationSeconds) * time.Second))
+		})
+	})
 })
 
 func getNode(name string) *v1.Node {
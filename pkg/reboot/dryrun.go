@@ -0,0 +1,75 @@
+package reboot
+
+import (
+	"github.com/go-logr/logr"
+	"github.com/medik8s/common/pkg/events"
+	"github.com/prometheus/client_golang/prometheus"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// DryRunEnvVar is the env var which enables the dry run mode of the agents
+	DryRunEnvVar = "DRY_RUN"
+
+	// ApiConnectivityRebootReason is used for reboots triggered by the api connectivity check
+	ApiConnectivityRebootReason = "ApiConnectivityCheck"
+	// RemediationRebootReason is used for reboots triggered by a SelfNodeRemediation of the node
+	RemediationRebootReason = "SelfNodeRemediation"
+
+	eventReasonWouldHaveRebooted = "WouldHaveRebooted"
+)
+
+var (
+	rebootReasonMessages = map[string]string{
+		ApiConnectivityRebootReason: "the API server isn't reachable, and peers didn't confirm that the node is healthy",
+		RemediationRebootReason:     "the node is being remediated by a SelfNodeRemediation",
+	}
+
+	dryRunReboots = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "self_node_remediation_dry_run_reboots_total",
+		Help: "Number of reboots which were skipped because the agent runs in dry run mode",
+	}, []string{"reason"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(dryRunReboots)
+}
+
+var _ Rebooter = &dryRunRebooter{}
+
+// dryRunRebooter never reboots, it only reports that the node would have been rebooted
+type dryRunRebooter struct {
+	reason   string
+	recorder record.EventRecorder
+	nodeName string
+	log      logr.Logger
+}
+
+// NewDryRunRebooter returns a Rebooter which emits a WouldHaveRebooted event on the given node and increases the
+// dry run reboots metric, instead of rebooting. The reason is one of the RebootReason constants.
+func NewDryRunRebooter(reason string, recorder record.EventRecorder, nodeName string, log logr.Logger) Rebooter {
+	return &dryRunRebooter{
+		reason:   reason,
+		recorder: recorder,
+		nodeName: nodeName,
+		log:      log,
+	}
+}
+
+func (r *dryRunRebooter) Reboot() error {
+	message, exists := rebootReasonMessages[r.reason]
+	if !exists {
+		message = r.reason
+	}
+	r.log.Info("dry run mode, skipping reboot", "reason", r.reason)
+	dryRunReboots.WithLabelValues(r.reason).Inc()
+	// use the node name as UID, like the kubelet does, the node might not be readable anymore
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: r.nodeName, UID: types.UID(r.nodeName)}}
+	events.WarningEventf(r.recorder, node, eventReasonWouldHaveRebooted, "Dry run, would have rebooted: %s", message)
+	return nil
+}
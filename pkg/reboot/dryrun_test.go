@@ -0,0 +1,36 @@
+package reboot
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Dry run rebooter", func() {
+
+	var recorder *record.FakeRecorder
+
+	BeforeEach(func() {
+		recorder = record.NewFakeRecorder(10)
+	})
+
+	dryRunRebootsCount := func(reason string) float64 {
+		metric := &dto.Metric{}
+		ExpectWithOffset(1, dryRunReboots.WithLabelValues(reason).Write(metric)).To(Succeed())
+		return metric.GetCounter().GetValue()
+	}
+
+	It("should report the reboot instead of rebooting", func() {
+		countBefore := dryRunRebootsCount(ApiConnectivityRebootReason)
+
+		rebooter := NewDryRunRebooter(ApiConnectivityRebootReason, recorder, "node1", ctrl.Log.WithName("dry run rebooter"))
+		Expect(rebooter.Reboot()).To(Succeed())
+
+		Expect(dryRunRebootsCount(ApiConnectivityRebootReason)).To(Equal(countBefore + 1))
+		Expect(recorder.Events).To(Receive(Equal("Warning WouldHaveRebooted [remediation] Dry run, would have rebooted: " +
+			"the API server isn't reachable, and peers didn't confirm that the node is healthy")))
+	})
+})
@@ -0,0 +1,95 @@
+package reboot
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/sys/unix"
+
+	"github.com/medik8s/self-node-remediation/pkg/watchdog"
+)
+
+const (
+	// GracefulRebootGracePeriodEnvVar is the env var holding the grace period of graceful reboots, 0 disables them
+	GracefulRebootGracePeriodEnvVar = "GRACEFUL_REBOOT_GRACE_PERIOD"
+
+	// systemdRebootSignal makes systemd start reboot.target, like "systemctl reboot" does.
+	// It's SIGRTMIN+5, with SIGRTMIN being 34 for systemd, because glibc reserves 2 real time signals.
+	systemdRebootSignal = unix.Signal(34 + 5)
+	initCommPath        = "/proc/1/comm"
+)
+
+var _ Rebooter = &gracefulRebooter{}
+
+// gracefulRebooter asks systemd to reboot the node, and falls back to another rebooter if the node wasn't rebooted
+// within the grace period. The watchdog is still fed during the grace period, and won't be disarmed in case the agent
+// is stopped during shutdown. Talking to systemd needs the host PID namespace, but no host binaries or D-Bus access.
+type gracefulRebooter struct {
+	wd            watchdog.Watchdog
+	fallback      Rebooter
+	gracePeriod   time.Duration
+	log           logr.Logger
+	requestReboot func() error
+	mutex         sync.Mutex
+	startTime     time.Time
+}
+
+func NewGracefulRebooter(wd watchdog.Watchdog, fallback Rebooter, gracePeriod time.Duration, log logr.Logger) Rebooter {
+	r := &gracefulRebooter{
+		wd:          wd,
+		fallback:    fallback,
+		gracePeriod: gracePeriod,
+		log:         log,
+	}
+	r.requestReboot = r.requestSystemdReboot
+	return r
+}
+
+func (r *gracefulRebooter) Reboot() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.startTime.IsZero() {
+		if elapsed := time.Since(r.startTime); elapsed < r.gracePeriod {
+			r.log.Info("graceful reboot in progress, waiting for it", "elapsed", elapsed.Round(time.Second), "grace period", r.gracePeriod)
+			return nil
+		}
+		return r.fallback.Reboot()
+	}
+
+	r.startTime = time.Now()
+	if r.wd != nil {
+		// protect the node in case the shutdown hangs after the agent was stopped
+		r.wd.KeepArmed()
+	}
+	if err := r.requestReboot(); err != nil {
+		r.log.Error(err, "failed to request graceful reboot, falling back")
+		return r.fallback.Reboot()
+	}
+	r.log.Info("requested graceful reboot", "grace period", r.gracePeriod)
+
+	time.AfterFunc(r.gracePeriod, func() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.log.Info("node wasn't rebooted gracefully within the grace period, falling back", "grace period", r.gracePeriod)
+		if err := r.fallback.Reboot(); err != nil {
+			r.log.Error(err, "failed to reboot")
+		}
+	})
+	return nil
+}
+
+func (r *gracefulRebooter) requestSystemdReboot() error {
+	comm, err := os.ReadFile(initCommPath)
+	if err != nil {
+		return fmt.Errorf("failed to get init process: %w", err)
+	}
+	if init := strings.TrimSpace(string(comm)); init != "systemd" {
+		return fmt.Errorf("init process %q isn't systemd, or the agent isn't running in the host PID namespace", init)
+	}
+	return unix.Kill(1, systemdRebootSignal)
+}
@@ -0,0 +1,61 @@
+package reboot
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+type countingRebooter struct {
+	reboots int
+}
+
+func (c *countingRebooter) Reboot() error {
+	c.reboots++
+	return nil
+}
+
+var _ = Describe("Graceful rebooter", func() {
+
+	var (
+		rebooter       *gracefulRebooter
+		fallback       *countingRebooter
+		rebootRequests int
+		requestErr     error
+	)
+
+	BeforeEach(func() {
+		fallback = &countingRebooter{}
+		rebootRequests, requestErr = 0, nil
+		rebooter = NewGracefulRebooter(nil, fallback, time.Second, ctrl.Log.WithName("graceful rebooter")).(*gracefulRebooter)
+		rebooter.requestReboot = func() error {
+			rebootRequests++
+			return requestErr
+		}
+	})
+
+	getFallbackReboots := func() int {
+		rebooter.mutex.Lock()
+		defer rebooter.mutex.Unlock()
+		return fallback.reboots
+	}
+
+	It("should fall back after the grace period", func() {
+		Expect(rebooter.Reboot()).To(Succeed())
+		Expect(rebooter.Reboot()).To(Succeed())
+		Expect(rebootRequests).To(Equal(1))
+		Expect(getFallbackReboots()).To(BeZero())
+
+		Eventually(getFallbackReboots, 3*time.Second).Should(Equal(1))
+	})
+
+	It("should fall back immediately if the reboot request fails", func() {
+		requestErr = errors.New("not systemd")
+		Expect(rebooter.Reboot()).To(Succeed())
+		Expect(getFallbackReboots()).To(Equal(1))
+	})
+})
@@ -2,7 +2,6 @@ package reboot
 
 import (
 	"errors"
-	"os/exec"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -31,7 +30,7 @@ func NewWatchdogRebooter(wd watchdog.Watchdog, log logr.Logger) Rebooter {
 		wd:  wd,
 		log: log,
 	}
-	wdRebooter.softwareRebootHook = wdRebooter.softwareReboot
+	wdRebooter.softwareRebootHook = NewSystemRebooter(log).Reboot
 	return wdRebooter
 }
 
@@ -56,6 +55,9 @@ func (r *watchdogRebooter) Reboot() error {
 	case watchdog.Disarmed:
 		r.log.Info("watchdog failed to start, trying software reboot")
 		return r.softwareRebootHook()
+	case watchdog.HandedOff:
+		r.log.Info("watchdog was handed off to the next agent, trying software reboot")
+		return r.softwareRebootHook()
 	case watchdog.Armed:
 		// we stop feeding the watchdog for a reboot
 		r.wd.Stop()
@@ -68,19 +70,6 @@ func (r *watchdogRebooter) Reboot() error {
 	}
 }
 
-// softwareReboot performs software reboot by running systemctl reboot
-func (r *watchdogRebooter) softwareReboot() error {
-	r.log.Info("about to try software reboot")
-	// privileged:true required to run this
-	rebootCmd := exec.Command("/usr/bin/nsenter", "-m/proc/1/ns/mnt", "/bin/bash", "-c", "echo b > /proc/sysrq-trigger")
-
-	if err := rebootCmd.Run(); err != nil {
-		r.log.Error(err, "failed to run reboot command")
-		// TODO retry because of this?
-	}
-	return nil
-}
-
 func (r *watchdogRebooter) isWatchdogRebootStuck() bool {
 	lastFoodTime := r.wd.LastFoodTime()
 	timeElapsedSinceLastFeed := time.Now().Sub(lastFoodTime)
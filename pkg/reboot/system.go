@@ -0,0 +1,87 @@
+package reboot
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	sysrqTriggerPath = "/proc/sysrq-trigger"
+	sysrqRemount     = "u"
+	sysrqReboot      = "b"
+	// syncTimeout limits the time for syncing file systems, which might hang on unhealthy storage
+	syncTimeout = 10 * time.Second
+	// remountDuration is the time given to the kernel for the asynchronous emergency remount
+	remountDuration = time.Second
+)
+
+var _ Rebooter = &systemRebooter{}
+
+// systemRebooter reboots the node with the reboot syscall, which needs the CAP_SYS_BOOT capability, and the host
+// PID namespace for rebooting the node instead of the container. In case of failure it falls back to the sysrq trigger,
+// which is only writable in privileged containers. Neither needs any binaries of the host.
+type systemRebooter struct {
+	log          logr.Logger
+	sync         func()
+	reboot       func(cmd int) error
+	sysrqTrigger string
+}
+
+func NewSystemRebooter(log logr.Logger) Rebooter {
+	return &systemRebooter{
+		log:          log,
+		sync:         unix.Sync,
+		reboot:       unix.Reboot,
+		sysrqTrigger: sysrqTriggerPath,
+	}
+}
+
+// Reboot syncs and remounts file systems read only, and reboots the node immediately
+func (r *systemRebooter) Reboot() error {
+	r.log.Info("about to try software reboot")
+	r.syncFileSystems()
+	r.remountFileSystems()
+
+	err := r.reboot(unix.LINUX_REBOOT_CMD_RESTART)
+	if err == nil {
+		// usually doesn't return on success
+		return nil
+	}
+	r.log.Error(err, "failed to reboot with reboot syscall, trying sysrq trigger")
+	if err := r.triggerSysrq(sysrqReboot); err != nil {
+		r.log.Error(err, "failed to reboot with sysrq trigger")
+		return fmt.Errorf("failed to reboot: %w", err)
+	}
+	return nil
+}
+
+func (r *systemRebooter) syncFileSystems() {
+	done := make(chan struct{})
+	go func() {
+		r.sync()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(syncTimeout):
+		r.log.Info("syncing file systems timed out, continuing with reboot", "timeout", syncTimeout)
+	}
+}
+
+// remountFileSystems remounts all file systems read only, which prevents corruption of file systems which can't be
+// synced. This is best effort, because the sysrq trigger isn't writable in unprivileged containers.
+func (r *systemRebooter) remountFileSystems() {
+	if err := r.triggerSysrq(sysrqRemount); err != nil {
+		r.log.Error(err, "failed to remount file systems read only, continuing with reboot")
+		return
+	}
+	time.Sleep(remountDuration)
+}
+
+func (r *systemRebooter) triggerSysrq(command string) error {
+	return os.WriteFile(r.sysrqTrigger, []byte(command), 0200)
+}
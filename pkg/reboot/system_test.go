@@ -0,0 +1,57 @@
+package reboot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("System rebooter", func() {
+
+	var (
+		rebooter     *systemRebooter
+		synced       bool
+		rebootCmd    int
+		rebootErr    error
+		sysrqTrigger string
+	)
+
+	BeforeEach(func() {
+		synced, rebootCmd, rebootErr = false, 0, nil
+		sysrqTrigger = filepath.Join(GinkgoT().TempDir(), "sysrq-trigger")
+		Expect(os.WriteFile(sysrqTrigger, nil, 0600)).To(Succeed())
+		rebooter = &systemRebooter{
+			log:  ctrl.Log.WithName("system rebooter"),
+			sync: func() { synced = true },
+			reboot: func(cmd int) error {
+				rebootCmd = cmd
+				return rebootErr
+			},
+			sysrqTrigger: sysrqTrigger,
+		}
+	})
+
+	It("should sync, remount and reboot", func() {
+		Expect(rebooter.Reboot()).To(Succeed())
+		Expect(synced).To(BeTrue())
+		Expect(rebootCmd).ToNot(BeZero())
+		Expect(os.ReadFile(sysrqTrigger)).To(BeEquivalentTo(sysrqRemount))
+	})
+
+	It("should fall back to the sysrq trigger", func() {
+		rebootErr = errors.New("not permitted")
+		Expect(rebooter.Reboot()).To(Succeed())
+		Expect(os.ReadFile(sysrqTrigger)).To(BeEquivalentTo(sysrqReboot))
+	})
+
+	It("should fail if both reboot methods fail", func() {
+		rebootErr = errors.New("not permitted")
+		rebooter.sysrqTrigger = filepath.Join(sysrqTrigger, "missing")
+		Expect(rebooter.Reboot()).ToNot(Succeed())
+	})
+})
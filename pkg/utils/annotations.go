@@ -19,11 +19,17 @@ const (
 	IsRebootCapableAnnotation = "is-reboot-capable.self-node-remediation.medik8s.io"
 	// WatchdogTimeoutSecondsAnnotation value is the key name for the node's annotation that will hold the watchdog timeout in seconds
 	WatchdogTimeoutSecondsAnnotation = "self-node-remediation.medik8s.io/watchdog-timeout"
-	IsSoftwareRebootEnabledEnvVar    = "IS_SOFTWARE_REBOOT_ENABLED"
+	// WatchdogDeviceAnnotation value is the key name for the node's annotation that will hold info about the used watchdog device
+	WatchdogDeviceAnnotation = "self-node-remediation.medik8s.io/watchdog-device"
+	// LastResetReasonAnnotation value is the key name for the node's annotation that will hold the reason of the last reset as reported by the watchdog
+	LastResetReasonAnnotation = "self-node-remediation.medik8s.io/last-reset-reason"
+	// RemediationHistoryAnnotation value is the key name for the node's annotation that will hold the recent remediations of the node
+	RemediationHistoryAnnotation  = "self-node-remediation.medik8s.io/remediation-history"
+	IsSoftwareRebootEnabledEnvVar = "IS_SOFTWARE_REBOOT_ENABLED"
 )
 
-// UpdateNodeAnnotations updates the is-reboot-capable and watchdog timeout node annotations
-func UpdateNodeAnnotations(watchdogInitiated bool, watchdogTimeout time.Duration, nodeName string, mgr manager.Manager) error {
+// UpdateNodeAnnotations updates the is-reboot-capable, watchdog timeout and watchdog device node annotations
+func UpdateNodeAnnotations(watchdogInitiated bool, watchdogTimeout time.Duration, watchdogDevice string, nodeName string, mgr manager.Manager) error {
 	node := &v1.Node{}
 	key := client.ObjectKey{
 		Name: nodeName,
@@ -56,6 +62,13 @@ func UpdateNodeAnnotations(watchdogInitiated bool, watchdogTimeout time.Duration
 	intTimeout := int(math.Ceil(watchdogTimeout.Seconds()))
 	node.Annotations[WatchdogTimeoutSecondsAnnotation] = strconv.Itoa(intTimeout)
 
+	// Report which watchdog device is used, for troubleshooting only
+	if watchdogDevice != "" {
+		node.Annotations[WatchdogDeviceAnnotation] = watchdogDevice
+	} else {
+		delete(node.Annotations, WatchdogDeviceAnnotation)
+	}
+
 	if err := mgr.GetClient().Update(context.Background(), node); err != nil {
 		return errors.Wrapf(err, "failed to add node annotation to node: "+node.Name)
 	}
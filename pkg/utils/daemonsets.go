@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetDaemonSetUpdateRevision returns the hash of the latest revision of the given DaemonSet, which is the value of the
+// controller-revision-hash label of its up-to-date pods. The DaemonSet status doesn't contain it, so it's taken from
+// the ControllerRevisions of the DaemonSet, like the DaemonSet controller does.
+func GetDaemonSetUpdateRevision(ctx context.Context, reader client.Reader, ds *appsv1.DaemonSet) (string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(ds.Spec.Selector)
+	if err != nil {
+		return "", err
+	}
+	revisions := &appsv1.ControllerRevisionList{}
+	if err := reader.List(ctx, revisions, client.InNamespace(ds.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return "", err
+	}
+
+	var latest *appsv1.ControllerRevision
+	for i := range revisions.Items {
+		revision := &revisions.Items[i]
+		if owner := metav1.GetControllerOf(revision); owner == nil || owner.UID != ds.UID {
+			continue
+		}
+		if latest == nil || revision.Revision > latest.Revision {
+			latest = revision
+		}
+	}
+	if latest == nil {
+		return "", fmt.Errorf("no revision found for daemonset %s/%s", ds.Namespace, ds.Name)
+	}
+	return latest.Labels[appsv1.DefaultDaemonSetUniqueLabelKey], nil
+}
+
+// IsNodeTargetedByDaemonSet checks if the DaemonSet runs a pod on the given node, based on the node selector, the
+// required node affinity and the tolerations of its pod template.
+func IsNodeTargetedByDaemonSet(ds *appsv1.DaemonSet, node *v1.Node) bool {
+	spec := ds.Spec.Template.Spec
+	if !labels.SelectorFromSet(spec.NodeSelector).Matches(labels.Set(node.Labels)) {
+		return false
+	}
+	if affinity := spec.Affinity; affinity != nil && affinity.NodeAffinity != nil {
+		if required := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution; required != nil && !matchesNodeSelectorTerms(required.NodeSelectorTerms, node) {
+			return false
+		}
+	}
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == v1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		if !toleratesTaint(spec.Tolerations, taint) {
+			return false
+		}
+	}
+	return true
+}
+
+func toleratesTaint(tolerations []v1.Toleration, taint *v1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesNodeSelectorTerms checks if the node matches any of the given terms
+func matchesNodeSelectorTerms(terms []v1.NodeSelectorTerm, node *v1.Node) bool {
+	for _, term := range terms {
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			// an empty term matches no nodes
+			continue
+		}
+		if matchesNodeSelectorRequirements(term.MatchExpressions, node.Labels) &&
+			matchesNodeSelectorRequirements(term.MatchFields, map[string]string{"metadata.name": node.Name}) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesNodeSelectorRequirements checks if the given values match all requirements
+func matchesNodeSelectorRequirements(requirements []v1.NodeSelectorRequirement, values map[string]string) bool {
+	for _, requirement := range requirements {
+		value, exists := values[requirement.Key]
+		switch requirement.Operator {
+		case v1.NodeSelectorOpIn:
+			if !exists || !slices.Contains(requirement.Values, value) {
+				return false
+			}
+		case v1.NodeSelectorOpNotIn:
+			if exists && slices.Contains(requirement.Values, value) {
+				return false
+			}
+		case v1.NodeSelectorOpExists:
+			if !exists {
+				return false
+			}
+		case v1.NodeSelectorOpDoesNotExist:
+			if exists {
+				return false
+			}
+		case v1.NodeSelectorOpGt, v1.NodeSelectorOpLt:
+			if !exists || len(requirement.Values) != 1 {
+				return false
+			}
+			actual, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return false
+			}
+			expected, err := strconv.ParseInt(requirement.Values[0], 10, 64)
+			if err != nil {
+				return false
+			}
+			if requirement.Operator == v1.NodeSelectorOpGt && actual <= expected ||
+				requirement.Operator == v1.NodeSelectorOpLt && actual >= expected {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Utils/DaemonSets tests", func() {
+
+	var ds *appsv1.DaemonSet
+
+	BeforeEach(func() {
+		ds = &appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ds", UID: "ds-uid"},
+			Spec: appsv1.DaemonSetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "agent"}},
+			},
+		}
+	})
+
+	It("should return the hash of the latest revision of the daemonset", func() {
+		newRevision := func(name, hash, ownerUID string, revision int64) *appsv1.ControllerRevision {
+			return &appsv1.ControllerRevision{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "default",
+					Name:      name,
+					Labels:    map[string]string{"app": "agent", appsv1.DefaultDaemonSetUniqueLabelKey: hash},
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: "apps/v1", Kind: "DaemonSet", Name: "ds", UID: types.UID(ownerUID), Controller: pointer.Bool(true),
+					}},
+				},
+				Revision: revision,
+			}
+		}
+		reader := fake.NewClientBuilder().WithObjects(
+			newRevision("ds-old", "old-hash", "ds-uid", 1),
+			newRevision("ds-new", "new-hash", "ds-uid", 2),
+			newRevision("other", "other-hash", "other-uid", 5),
+		).Build()
+
+		Expect(GetDaemonSetUpdateRevision(context.Background(), reader, ds)).To(Equal("new-hash"))
+
+		By("failing without revisions")
+		_, err := GetDaemonSetUpdateRevision(context.Background(), fake.NewClientBuilder().Build(), ds)
+		Expect(err).To(HaveOccurred())
+	})
+
+	DescribeTable("Targeted nodes", func(podSpec v1.PodSpec, node *v1.Node, expected bool) {
+		ds.Spec.Template.Spec = podSpec
+		Expect(IsNodeTargetedByDaemonSet(ds, node)).To(Equal(expected))
+	},
+		Entry("any node", v1.PodSpec{}, newNode(nil), true),
+		Entry("node matching the node selector", v1.PodSpec{NodeSelector: map[string]string{"role": "worker"}},
+			newNode(map[string]string{"role": "worker"}), true),
+		Entry("node not matching the node selector", v1.PodSpec{NodeSelector: map[string]string{"role": "worker"}},
+			newNode(map[string]string{"role": "master"}), false),
+		Entry("node matching the required node affinity", v1.PodSpec{Affinity: newNodeAffinity(
+			v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{{Key: "role", Operator: v1.NodeSelectorOpIn, Values: []string{"master"}}}},
+			v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{{Key: "cpus", Operator: v1.NodeSelectorOpGt, Values: []string{"4"}}}},
+		)}, newNode(map[string]string{"role": "worker", "cpus": "8"}), true),
+		Entry("node not matching the required node affinity", v1.PodSpec{Affinity: newNodeAffinity(
+			v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{{Key: "role", Operator: v1.NodeSelectorOpNotIn, Values: []string{"worker"}}}},
+			v1.NodeSelectorTerm{MatchFields: []v1.NodeSelectorRequirement{{Key: "metadata.name", Operator: v1.NodeSelectorOpIn, Values: []string{"other-node"}}}},
+		)}, newNode(map[string]string{"role": "worker"}), false),
+		Entry("node with tolerated taints", v1.PodSpec{Tolerations: []v1.Toleration{{Key: "node-role.kubernetes.io/master", Operator: v1.TolerationOpExists}}},
+			newNode(nil, v1.Taint{Key: "node-role.kubernetes.io/master", Effect: v1.TaintEffectNoSchedule}, v1.Taint{Key: "other", Effect: v1.TaintEffectPreferNoSchedule}), true),
+		Entry("node with a taint which isn't tolerated", v1.PodSpec{},
+			newNode(nil, v1.Taint{Key: "other", Effect: v1.TaintEffectNoExecute}), false),
+	)
+
+})
+
+func newNode(labels map[string]string, taints ...v1.Taint) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node", Labels: labels},
+		Spec:       v1.NodeSpec{Taints: taints},
+	}
+}
+
+func newNodeAffinity(terms ...v1.NodeSelectorTerm) *v1.Affinity {
+	return &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{NodeSelectorTerms: terms},
+	}}
+}
@@ -0,0 +1,177 @@
+package watchdog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/medik8s/common/pkg/events"
+	"github.com/pkg/errors"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+	"github.com/medik8s/self-node-remediation/pkg/utils"
+)
+
+// ResetReason is the reason of the last reset of the node, as reported by the watchdog device
+type ResetReason string
+
+// bootstatus flags, see include/uapi/linux/watchdog.h
+const (
+	bootStatusOverheat   = 0x1
+	bootStatusFanFault   = 0x2
+	bootStatusExtern1    = 0x4
+	bootStatusExtern2    = 0x8
+	bootStatusPowerUnder = 0x10
+	bootStatusCardReset  = 0x20
+	bootStatusPowerOver  = 0x40
+)
+
+const (
+	// ResetReasonWatchdog means that the watchdog device reset the node because it wasn't fed in time
+	ResetReasonWatchdog ResetReason = "Watchdog"
+	// ResetReasonOverheat means that the node was reset because of overheating
+	ResetReasonOverheat ResetReason = "Overheat"
+	// ResetReasonFanFault means that the node was reset because of a fan failure
+	ResetReasonFanFault ResetReason = "FanFault"
+	// ResetReasonExternal means that the node was reset by an external relay
+	ResetReasonExternal ResetReason = "External"
+	// ResetReasonPowerFault means that the node was reset because of a power bad or power over voltage
+	ResetReasonPowerFault ResetReason = "PowerFault"
+	// ResetReasonOther means that the watchdog device didn't cause the last reset, e.g. in case of a software reboot
+	// or a power cycle. Note that softdog can't tell that it caused the last reset, so it always reports this reason.
+	ResetReasonOther ResetReason = "Other"
+	// ResetReasonUnknown means that the boot status of the watchdog device couldn't be read
+	ResetReasonUnknown ResetReason = "Unknown"
+
+	eventReasonLastResetReason = "LastResetReason"
+)
+
+// bootIDFile holds the random ID of the current boot of the node, it's a var for tests
+var bootIDFile = "/proc/sys/kernel/random/boot_id"
+
+// RemediationLookup returns the SelfNodeRemediations of the node
+type RemediationLookup func(ctx context.Context) ([]v1alpha1.SelfNodeRemediation, error)
+
+// ResetInfo describes the last reset of the node as reported by the watchdog device when it was started
+type ResetInfo struct {
+	// Reason is the reason of the last reset
+	Reason ResetReason `json:"reason"`
+	// BootStatus are the raw bootstatus flags reported by the watchdog device
+	BootStatus int `json:"bootStatus"`
+	// Remediation is the namespaced name of the SelfNodeRemediation which existed for the node when it came back
+	Remediation string `json:"remediation,omitempty"`
+	// BootID is the ID of the boot the reset was reported in, so that it's only reported once per boot
+	BootID string `json:"bootID,omitempty"`
+}
+
+func newResetInfo(bootStatus int, err error) *ResetInfo {
+	if err != nil {
+		return &ResetInfo{Reason: ResetReasonUnknown}
+	}
+	info := &ResetInfo{BootStatus: bootStatus}
+	switch {
+	case bootStatus&bootStatusCardReset != 0:
+		info.Reason = ResetReasonWatchdog
+	case bootStatus&bootStatusOverheat != 0:
+		info.Reason = ResetReasonOverheat
+	case bootStatus&bootStatusFanFault != 0:
+		info.Reason = ResetReasonFanFault
+	case bootStatus&(bootStatusExtern1|bootStatusExtern2) != 0:
+		info.Reason = ResetReasonExternal
+	case bootStatus&(bootStatusPowerUnder|bootStatusPowerOver) != 0:
+		info.Reason = ResetReasonPowerFault
+	default:
+		info.Reason = ResetReasonOther
+	}
+	return info
+}
+
+// ReportLastReset emits an event on the node about the last reset as reported by the watchdog, and records it in the
+// node's last reset reason annotation. If a SelfNodeRemediation for the node exists, it is linked, and gets the
+// event as well. The reset is only reported once per boot, restarted agents don't report it again.
+func ReportLastReset(ctx context.Context, wd Watchdog, nodeName string, findRemediations RemediationLookup, c client.Client, recorder record.EventRecorder, log logr.Logger) error {
+	resetInfo := wd.LastReset()
+	if resetInfo == nil {
+		// watchdog wasn't started
+		return nil
+	}
+
+	node := &v1.Node{}
+	if err := c.Get(ctx, client.ObjectKey{Name: nodeName}, node); err != nil {
+		return errors.Wrapf(err, "failed to get node %s", nodeName)
+	}
+
+	bootID, err := readBootID()
+	if err != nil {
+		// still report the reset reason, at the risk of reporting it again after agent restarts
+		log.Error(err, "failed to read boot id")
+	} else if reported := node.Annotations[utils.LastResetReasonAnnotation]; reported != "" {
+		reportedInfo := &ResetInfo{}
+		if err := json.Unmarshal([]byte(reported), reportedInfo); err == nil && reportedInfo.BootID == bootID {
+			log.Info("last reset reason was already reported in this boot", "boot id", bootID)
+			return nil
+		}
+	}
+	resetInfo.BootID = bootID
+
+	snr, err := findRemediation(ctx, findRemediations)
+	if err != nil {
+		// still report the reset reason
+		log.Error(err, "failed to look up remediation of the node")
+	}
+	if snr != nil {
+		resetInfo.Remediation = client.ObjectKeyFromObject(snr).String()
+	}
+
+	value, err := json.Marshal(resetInfo)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal last reset info")
+	}
+	patch := client.MergeFrom(node.DeepCopy())
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[utils.LastResetReasonAnnotation] = string(value)
+	if err := c.Patch(ctx, node, patch); err != nil {
+		return errors.Wrapf(err, "failed to add last reset reason annotation to node %s", nodeName)
+	}
+
+	message := fmt.Sprintf("Last reset reason reported by watchdog device %s: %s (boot status %#x)",
+		wd.DeviceInfo().Path, resetInfo.Reason, resetInfo.BootStatus)
+	if snr != nil {
+		message = fmt.Sprintf("%s, remediation: %s", message, resetInfo.Remediation)
+		events.NormalEvent(recorder, snr, eventReasonLastResetReason, message)
+	}
+	events.NormalEvent(recorder, node, eventReasonLastResetReason, message)
+	log.Info("reported last reset reason", "reason", resetInfo.Reason, "boot status", resetInfo.BootStatus, "remediation", resetInfo.Remediation)
+	return nil
+}
+
+// findRemediation returns the SelfNodeRemediation of the node which isn't a dry run
+func findRemediation(ctx context.Context, findRemediations RemediationLookup) (*v1alpha1.SelfNodeRemediation, error) {
+	snrs, err := findRemediations(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to look up self node remediations")
+	}
+	for i := range snrs {
+		if !snrs[i].Spec.DryRun {
+			return &snrs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func readBootID() (string, error) {
+	bootID, err := os.ReadFile(bootIDFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(bootID)), nil
+}
@@ -0,0 +1,131 @@
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+	"github.com/medik8s/self-node-remediation/pkg/utils"
+)
+
+var _ = Describe("Watchdog boot status", func() {
+
+	DescribeTable("reset reason",
+		func(bootStatus int, err error, expectedReason ResetReason) {
+			Expect(newResetInfo(bootStatus, err).Reason).To(Equal(expectedReason))
+		},
+		Entry("watchdog reset", bootStatusCardReset, nil, ResetReasonWatchdog),
+		Entry("watchdog reset wins", bootStatusCardReset|bootStatusOverheat, nil, ResetReasonWatchdog),
+		Entry("overheat", bootStatusOverheat, nil, ResetReasonOverheat),
+		Entry("fan fault", bootStatusFanFault, nil, ResetReasonFanFault),
+		Entry("external", bootStatusExtern2, nil, ResetReasonExternal),
+		Entry("power fault", bootStatusPowerUnder, nil, ResetReasonPowerFault),
+		Entry("no watchdog reset", 0, nil, ResetReasonOther),
+		Entry("unknown", 0, errors.New("not supported"), ResetReasonUnknown),
+	)
+
+	Context("sysfs", func() {
+
+		var origSysfsFolder string
+
+		BeforeEach(func() {
+			origSysfsFolder = watchdogSysfsFolder
+			watchdogSysfsFolder = GinkgoT().TempDir()
+			DeferCleanup(func() {
+				watchdogSysfsFolder = origSysfsFolder
+			})
+
+			deviceFolder := filepath.Join(watchdogSysfsFolder, "watchdog0")
+			Expect(os.MkdirAll(deviceFolder, 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(deviceFolder, "bootstatus"), []byte("32\n"), 0644)).To(Succeed())
+		})
+
+		It("should read the boot status", func() {
+			bootStatus, err := readSysfsBootStatus("/dev/watchdog0")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bootStatus).To(Equal(bootStatusCardReset))
+
+			bootStatus, err = readSysfsBootStatus("/dev/watchdog")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bootStatus).To(Equal(bootStatusCardReset))
+		})
+
+		It("should fail for unknown devices", func() {
+			_, err := readSysfsBootStatus("/dev/watchdog1")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("report", func() {
+
+		var wd Watchdog
+		var c client.Client
+		var recorder *record.FakeRecorder
+		var snrs []v1alpha1.SelfNodeRemediation
+
+		findRemediations := func(_ context.Context) ([]v1alpha1.SelfNodeRemediation, error) {
+			return snrs, nil
+		}
+
+		setBootID := func(bootID string) {
+			ExpectWithOffset(1, os.WriteFile(bootIDFile, []byte(bootID+"\n"), 0644)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			origBootIDFile := bootIDFile
+			bootIDFile = filepath.Join(GinkgoT().TempDir(), "boot_id")
+			DeferCleanup(func() {
+				bootIDFile = origBootIDFile
+			})
+
+			wd = NewFake(true)
+			ctx, cancel := context.WithCancel(context.Background())
+			DeferCleanup(cancel)
+			go func() { _ = wd.Start(ctx) }()
+			Eventually(wd.LastReset).ShouldNot(BeNil())
+
+			c = fake.NewClientBuilder().WithObjects(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}).Build()
+			recorder = record.NewFakeRecorder(10)
+			snrs = []v1alpha1.SelfNodeRemediation{
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "dry-run"}, Spec: v1alpha1.SelfNodeRemediationSpec{DryRun: true}},
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machine"}},
+			}
+		})
+
+		It("should report the last reset once per boot", func() {
+			setBootID("boot-1")
+			Expect(ReportLastReset(context.Background(), wd, "node", findRemediations, c, recorder, ctrl.Log)).To(Succeed())
+			node := &v1.Node{}
+			Expect(c.Get(context.Background(), client.ObjectKey{Name: "node"}, node)).To(Succeed())
+			Expect(node.Annotations[utils.LastResetReasonAnnotation]).To(ContainSubstring(`"remediation":"default/machine"`))
+			Expect(node.Annotations[utils.LastResetReasonAnnotation]).To(ContainSubstring(`"bootID":"boot-1"`))
+			// the node and the remediation get the event
+			Expect(recorder.Events).To(HaveLen(2))
+
+			By("not reporting it again when the agent restarts")
+			Expect(ReportLastReset(context.Background(), wd, "node", findRemediations, c, recorder, ctrl.Log)).To(Succeed())
+			Expect(recorder.Events).To(HaveLen(2))
+
+			By("reporting it after the next boot")
+			setBootID("boot-2")
+			snrs = nil
+			Expect(ReportLastReset(context.Background(), wd, "node", findRemediations, c, recorder, ctrl.Log)).To(Succeed())
+			Expect(recorder.Events).To(HaveLen(3))
+			Expect(c.Get(context.Background(), client.ObjectKey{Name: "node"}, node)).To(Succeed())
+			Expect(node.Annotations[utils.LastResetReasonAnnotation]).To(ContainSubstring(`"bootID":"boot-2"`))
+			Expect(node.Annotations[utils.LastResetReasonAnnotation]).ToNot(ContainSubstring("remediation"))
+		})
+	})
+})
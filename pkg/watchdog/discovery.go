@@ -0,0 +1,171 @@
+package watchdog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+)
+
+const (
+	softdogDriver   = "softdog"
+	softdogIdentity = "Software Watchdog"
+)
+
+var (
+	watchdogSysfsFolder = "/sys/class/watchdog"
+)
+
+// DeviceInfo describes a watchdog device as discovered in sysfs
+type DeviceInfo struct {
+	// Path is the path of the device file, e.g. /dev/watchdog1
+	Path string `json:"path"`
+	// Identity is the identity reported by the device
+	Identity string `json:"identity,omitempty"`
+	// Driver is the name of the kernel driver of the device
+	Driver string `json:"driver,omitempty"`
+	// FirmwareVersion is the firmware version reported by the device
+	FirmwareVersion uint32 `json:"firmwareVersion,omitempty"`
+	// TimeoutSeconds is the device's timeout as reported in sysfs
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+	// NoWayOut is true if the device can't be disarmed once it was started
+	NoWayOut bool `json:"nowayout"`
+}
+
+// AnnotationValue returns the device info serialized for usage as node annotation value
+func (d *DeviceInfo) AnnotationValue() string {
+	if d == nil {
+		return ""
+	}
+	value, err := json.Marshal(d)
+	if err != nil {
+		// can't happen with the given fields
+		return d.Path
+	}
+	return string(value)
+}
+
+func (d *DeviceInfo) matches(selector v1alpha1.WatchdogDeviceSelector) bool {
+	if selector.Identity == "" && selector.Driver == "" {
+		return false
+	}
+	if selector.Identity != "" && selector.Identity != d.Identity {
+		return false
+	}
+	if selector.Driver != "" && selector.Driver != d.Driver {
+		return false
+	}
+	return true
+}
+
+// discoverDevices returns all watchdog devices found in sysfs, ordered by name
+func discoverDevices() ([]DeviceInfo, error) {
+	entries, err := os.ReadDir(watchdogSysfsFolder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list watchdog sysfs folder %s: %w", watchdogSysfsFolder, err)
+	}
+
+	var devices []DeviceInfo
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), watchdogPrefix) {
+			continue
+		}
+		devices = append(devices, readDeviceInfo(entry.Name()))
+	}
+	return devices, nil
+}
+
+// readDeviceInfo reads the sysfs attributes of the given watchdog, missing attributes are ignored
+func readDeviceInfo(name string) DeviceInfo {
+	deviceFolder := filepath.Join(watchdogSysfsFolder, name)
+	device := DeviceInfo{
+		Path:     filepath.Join(watchdogsFolder, name),
+		Identity: readSysfsAttribute(deviceFolder, "identity"),
+	}
+	if timeout, err := strconv.Atoi(readSysfsAttribute(deviceFolder, "timeout")); err == nil {
+		device.TimeoutSeconds = timeout
+	}
+	device.NoWayOut = readSysfsAttribute(deviceFolder, "nowayout") == "1"
+
+	if driverLink, err := os.Readlink(filepath.Join(deviceFolder, "device", "driver")); err == nil {
+		device.Driver = filepath.Base(driverLink)
+	} else if device.Identity == softdogIdentity {
+		// softdog has no parent device, so there is no driver link
+		device.Driver = softdogDriver
+	}
+	return device
+}
+
+// readSysfsBootStatus returns the bootstatus flags of the given device file from sysfs
+func readSysfsBootStatus(path string) (int, error) {
+	bootStatus := readSysfsAttribute(filepath.Join(watchdogSysfsFolder, sysfsName(path)), "bootstatus")
+	if bootStatus == "" {
+		return 0, fmt.Errorf("no bootstatus in sysfs for watchdog %s", path)
+	}
+	return strconv.Atoi(bootStatus)
+}
+
+func readSysfsAttribute(deviceFolder, attribute string) string {
+	value, err := os.ReadFile(filepath.Join(deviceFolder, attribute))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(value))
+}
+
+// selectDevice returns the device matching the first possible selector, or nil if no device matches
+func selectDevice(devices []DeviceInfo, selectors []v1alpha1.WatchdogDeviceSelector) *DeviceInfo {
+	for _, selector := range selectors {
+		for i := range devices {
+			if devices[i].matches(selector) {
+				return &devices[i]
+			}
+		}
+	}
+	return nil
+}
+
+// sysfsName returns the sysfs name of the given device file path, resolving the /dev/watchdog alias of watchdog0
+func sysfsName(path string) string {
+	name := filepath.Base(path)
+	if name == watchdogPrefix {
+		name = watchdogPrefix + "0"
+	}
+	return name
+}
+
+// findDevice returns the device with the given device file path
+func findDevice(devices []DeviceInfo, path string) *DeviceInfo {
+	name := sysfsName(path)
+	for i := range devices {
+		if filepath.Base(devices[i].Path) == name {
+			return &devices[i]
+		}
+	}
+	return nil
+}
+
+func selectsSoftdog(selectors []v1alpha1.WatchdogDeviceSelector) bool {
+	softdog := DeviceInfo{Identity: softdogIdentity, Driver: softdogDriver}
+	for _, selector := range selectors {
+		if softdog.matches(selector) {
+			return true
+		}
+	}
+	return false
+}
+
+func parsePreferredDevices(preferredDevicesJson string) ([]v1alpha1.WatchdogDeviceSelector, error) {
+	if preferredDevicesJson == "" {
+		return nil, nil
+	}
+	var selectors []v1alpha1.WatchdogDeviceSelector
+	if err := json.Unmarshal([]byte(preferredDevicesJson), &selectors); err != nil {
+		return nil, fmt.Errorf("failed to parse preferred watchdog devices: %w", err)
+	}
+	return selectors, nil
+}
@@ -0,0 +1,114 @@
+package watchdog
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
+)
+
+var _ = Describe("Watchdog discovery", func() {
+
+	var origSysfsFolder string
+
+	BeforeEach(func() {
+		origSysfsFolder = watchdogSysfsFolder
+		watchdogSysfsFolder = GinkgoT().TempDir()
+		DeferCleanup(func() {
+			watchdogSysfsFolder = origSysfsFolder
+		})
+	})
+
+	addDevice := func(name, identity, driver, timeout, nowayout string) {
+		deviceFolder := filepath.Join(watchdogSysfsFolder, name)
+		Expect(os.MkdirAll(deviceFolder, 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(deviceFolder, "identity"), []byte(identity+"\n"), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(deviceFolder, "timeout"), []byte(timeout+"\n"), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(deviceFolder, "nowayout"), []byte(nowayout+"\n"), 0644)).To(Succeed())
+		if driver != "" {
+			parentFolder := filepath.Join(deviceFolder, "device")
+			Expect(os.MkdirAll(parentFolder, 0755)).To(Succeed())
+			Expect(os.Symlink(filepath.Join("/sys/bus/platform/drivers", driver), filepath.Join(parentFolder, "driver"))).To(Succeed())
+		}
+	}
+
+	BeforeEach(func() {
+		addDevice("watchdog0", "iTCO_wdt", "iTCO_wdt", "30", "0")
+		addDevice("watchdog1", softdogIdentity, "", "60", "1")
+		// not a watchdog
+		Expect(os.MkdirAll(filepath.Join(watchdogSysfsFolder, "other"), 0755)).To(Succeed())
+	})
+
+	It("should discover all devices", func() {
+		devices, err := discoverDevices()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(devices).To(Equal([]DeviceInfo{
+			{Path: "/dev/watchdog0", Identity: "iTCO_wdt", Driver: "iTCO_wdt", TimeoutSeconds: 30},
+			{Path: "/dev/watchdog1", Identity: softdogIdentity, Driver: softdogDriver, TimeoutSeconds: 60, NoWayOut: true},
+		}))
+	})
+
+	It("should fail without sysfs folder", func() {
+		watchdogSysfsFolder = filepath.Join(watchdogSysfsFolder, "missing")
+		_, err := discoverDevices()
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("selecting a device", func() {
+
+		var devices []DeviceInfo
+
+		BeforeEach(func() {
+			var err error
+			devices, err = discoverDevices()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		DescribeTable("by preference",
+			func(selectors []v1alpha1.WatchdogDeviceSelector, expectedPath string) {
+				device := selectDevice(devices, selectors)
+				if expectedPath == "" {
+					Expect(device).To(BeNil())
+					return
+				}
+				Expect(device).ToNot(BeNil())
+				Expect(device.Path).To(Equal(expectedPath))
+			},
+			Entry("by driver", []v1alpha1.WatchdogDeviceSelector{{Driver: softdogDriver}}, "/dev/watchdog1"),
+			Entry("by identity", []v1alpha1.WatchdogDeviceSelector{{Identity: "iTCO_wdt"}}, "/dev/watchdog0"),
+			Entry("by identity and driver", []v1alpha1.WatchdogDeviceSelector{{Identity: "iTCO_wdt", Driver: softdogDriver}}, ""),
+			Entry("first matching preference wins",
+				[]v1alpha1.WatchdogDeviceSelector{{Driver: "ipmi_watchdog"}, {Driver: softdogDriver}, {Driver: "iTCO_wdt"}}, "/dev/watchdog1"),
+			Entry("no match", []v1alpha1.WatchdogDeviceSelector{{Driver: "ipmi_watchdog"}}, ""),
+			Entry("empty selector", []v1alpha1.WatchdogDeviceSelector{{}}, ""),
+		)
+
+		It("should find a device by path", func() {
+			Expect(findDevice(devices, "/dev/watchdog1").Identity).To(Equal(softdogIdentity))
+			Expect(findDevice(devices, "/dev/watchdog").Identity).To(Equal("iTCO_wdt"))
+			Expect(findDevice(devices, "/dev/watchdog2")).To(BeNil())
+		})
+	})
+
+	It("should detect softdog selectors", func() {
+		Expect(selectsSoftdog([]v1alpha1.WatchdogDeviceSelector{{Driver: "iTCO_wdt"}, {Driver: softdogDriver}})).To(BeTrue())
+		Expect(selectsSoftdog([]v1alpha1.WatchdogDeviceSelector{{Identity: softdogIdentity}})).To(BeTrue())
+		Expect(selectsSoftdog([]v1alpha1.WatchdogDeviceSelector{{Driver: "iTCO_wdt"}})).To(BeFalse())
+	})
+
+	It("should parse preferred devices", func() {
+		selectors, err := parsePreferredDevices(`[{"driver":"iTCO_wdt"},{"identity":"Software Watchdog"}]`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(selectors).To(Equal([]v1alpha1.WatchdogDeviceSelector{{Driver: "iTCO_wdt"}, {Identity: softdogIdentity}}))
+
+		selectors, err = parsePreferredDevices("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(selectors).To(BeEmpty())
+
+		_, err = parsePreferredDevices("invalid")
+		Expect(err).To(HaveOccurred())
+	})
+})
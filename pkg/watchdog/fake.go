@@ -18,7 +18,7 @@ type fakeWatchdogImpl struct {
 
 func NewFake(isStartSuccessful bool) Watchdog {
 	fakeWDImpl := &fakeWatchdogImpl{IsStartSuccessful: isStartSuccessful}
-	return newSynced(ctrl.Log.WithName("fake watchdog"), fakeWDImpl)
+	return newSynced(ctrl.Log.WithName("fake watchdog"), fakeWDImpl, nil, "", nil)
 }
 
 func (f *fakeWatchdogImpl) start() (*time.Duration, error) {
@@ -36,3 +36,15 @@ func (f *fakeWatchdogImpl) feed() error {
 func (f *fakeWatchdogImpl) disarm() error {
 	return nil
 }
+
+func (f *fakeWatchdogImpl) handOff() error {
+	return nil
+}
+
+func (f *fakeWatchdogImpl) deviceInfo() *DeviceInfo {
+	return &DeviceInfo{Path: "/dev/fake-watchdog", Identity: "fake watchdog", TimeoutSeconds: int(fakeTimeout.Seconds())}
+}
+
+func (f *fakeWatchdogImpl) bootStatus() (int, error) {
+	return 0, nil
+}
@@ -0,0 +1,20 @@
+package watchdog
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// feederPriority is the real time priority of the feeder thread, it only needs to preempt normal threads
+const feederPriority = 1
+
+// setFeederThreadPriority sets real time scheduling for the calling thread, which needs to be locked
+func setFeederThreadPriority() error {
+	param := struct{ priority int32 }{priority: feederPriority}
+	_, _, errNo := unix.Syscall(unix.SYS_SCHED_SETSCHEDULER, uintptr(unix.Gettid()), uintptr(unix.SCHED_FIFO), uintptr(unsafe.Pointer(&param)))
+	if errNo != 0 {
+		return errNo
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package watchdog
+
+import "errors"
+
+func setFeederThreadPriority() error {
+	return errors.New("real time scheduling is only supported on linux")
+}
@@ -0,0 +1,131 @@
+package watchdog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	handoffLockFile  = "watchdog.lock"
+	handoffStateFile = "watchdog-handoff.json"
+	lockPollInterval = time.Second
+	// handoffDeadlineMargin is the time before the deadline of a handed off watchdog, from which on the next agent
+	// stops waiting for the lock, and feeds the watchdog as soon as it opened the device
+	handoffDeadlineMargin = 10 * time.Second
+)
+
+var (
+	// handoffFolder is a host path folder shared by all agents running on the node
+	handoffFolder = "/var/run/self-node-remediation"
+	// lockTimeout is the max time to wait for the previous agent to release the watchdog
+	lockTimeout = 90 * time.Second
+	// handoffStartupBudget is the min watchdog timeout for handing off the watchdog, the next agent needs to be
+	// started and take over the watchdog within it. Shorter timeouts would reboot the node during a rollout.
+	handoffStartupBudget = 30 * time.Second
+)
+
+// HandoffCheck returns whether a new agent is expected to take over the watchdog after this agent stopped,
+// e.g. during a rollout of the agents.
+type HandoffCheck func(ctx context.Context) (bool, error)
+
+// handoffState is written by an agent which left the watchdog armed for the next agent
+type handoffState struct {
+	// Device is the path of the watchdog device
+	Device string `json:"device"`
+	// FedAt is the time of the final feed
+	FedAt time.Time `json:"fedAt"`
+	// TimeoutSeconds is the watchdog timeout, the next agent needs to feed the watchdog before it expires
+	TimeoutSeconds int `json:"timeoutSeconds"`
+	// NoWayOut is true if the watchdog couldn't be disarmed
+	NoWayOut bool `json:"nowayout"`
+}
+
+func (s *handoffState) deadline() time.Time {
+	return s.FedAt.Add(time.Duration(s.TimeoutSeconds) * time.Second)
+}
+
+// handoffLockTimeout returns how long to wait for the lock. A watchdog which was handed off already must be taken over
+// before its deadline, so the wait is limited to the time which is left.
+func handoffLockTimeout(state *handoffState) time.Duration {
+	if state == nil {
+		return lockTimeout
+	}
+	timeout := time.Until(state.deadline()) - handoffDeadlineMargin
+	if timeout < 0 {
+		return 0
+	}
+	if timeout > lockTimeout {
+		return lockTimeout
+	}
+	return timeout
+}
+
+// acquireLock waits until no other agent on this node uses the watchdog, for at most the given timeout. The lock is
+// held until the agent exits, and released by the kernel even if the agent crashes.
+func acquireLock(timeout time.Duration, log logr.Logger) (*os.File, error) {
+	if err := os.MkdirAll(handoffFolder, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create watchdog handoff folder %s: %w", handoffFolder, err)
+	}
+	lockPath := filepath.Join(handoffFolder, handoffLockFile)
+	lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open watchdog lock %s: %w", lockPath, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err = unix.Flock(int(lock.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+		if err == nil {
+			return lock, nil
+		}
+		if err != unix.EWOULDBLOCK || time.Now().After(deadline) {
+			lock.Close()
+			return nil, fmt.Errorf("failed to lock watchdog %s: %w", lockPath, err)
+		}
+		log.Info("waiting for the previous agent to release the watchdog", "lock", lockPath)
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// readHandoffState returns the state left by the previous agent, or nil if it didn't hand off the watchdog
+func readHandoffState() (*handoffState, error) {
+	data, err := os.ReadFile(filepath.Join(handoffFolder, handoffStateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read watchdog handoff state: %w", err)
+	}
+	state := &handoffState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse watchdog handoff state: %w", err)
+	}
+	return state, nil
+}
+
+func writeHandoffState(state *handoffState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal watchdog handoff state: %w", err)
+	}
+	// write atomically, the next agent might already wait for it
+	tmpPath := filepath.Join(handoffFolder, handoffStateFile+".tmp")
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write watchdog handoff state: %w", err)
+	}
+	return os.Rename(tmpPath, filepath.Join(handoffFolder, handoffStateFile))
+}
+
+func removeHandoffState() error {
+	if err := os.Remove(filepath.Join(handoffFolder, handoffStateFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove watchdog handoff state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Watchdog handoff", func() {
+
+	BeforeEach(func() {
+		origHandoffFolder, origLockTimeout, origStartupBudget := handoffFolder, lockTimeout, handoffStartupBudget
+		handoffFolder = GinkgoT().TempDir()
+		lockTimeout = 2 * lockPollInterval
+		DeferCleanup(func() {
+			handoffFolder, lockTimeout, handoffStartupBudget = origHandoffFolder, origLockTimeout, origStartupBudget
+		})
+	})
+
+	It("should lock the watchdog for a single agent", func() {
+		lock, err := acquireLock(lockTimeout, ctrl.Log)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = acquireLock(lockTimeout, ctrl.Log)
+		Expect(err).To(HaveOccurred())
+
+		Expect(lock.Close()).To(Succeed())
+		lock, err = acquireLock(lockTimeout, ctrl.Log)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lock.Close()).To(Succeed())
+	})
+
+	It("should persist the handoff state", func() {
+		state, err := readHandoffState()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state).To(BeNil())
+
+		fedAt := time.Now().Truncate(time.Second)
+		Expect(writeHandoffState(&handoffState{Device: "/dev/watchdog1", FedAt: fedAt, TimeoutSeconds: 30, NoWayOut: true})).To(Succeed())
+		state, err = readHandoffState()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state.Device).To(Equal("/dev/watchdog1"))
+		Expect(state.FedAt.Equal(fedAt)).To(BeTrue())
+		Expect(state.NoWayOut).To(BeTrue())
+		Expect(state.deadline()).To(Equal(state.FedAt.Add(30 * time.Second)))
+
+		Expect(removeHandoffState()).To(Succeed())
+		state, err = readHandoffState()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state).To(BeNil())
+		Expect(removeHandoffState()).To(Succeed())
+	})
+
+	It("should limit the lock timeout to the handoff deadline", func() {
+		Expect(handoffLockTimeout(nil)).To(Equal(lockTimeout))
+		state := &handoffState{FedAt: time.Now(), TimeoutSeconds: 3600}
+		Expect(handoffLockTimeout(state)).To(Equal(lockTimeout))
+		state.TimeoutSeconds = int((handoffDeadlineMargin + lockTimeout/2).Seconds())
+		Expect(handoffLockTimeout(state)).To(BeNumerically("~", lockTimeout/2, time.Second))
+		state.FedAt = time.Now().Add(-time.Hour)
+		Expect(handoffLockTimeout(state)).To(BeZero())
+	})
+
+	DescribeTable("shutdown",
+		func(handoffCheck HandoffCheck, startupBudget time.Duration, expectedStatus watchdogStatus) {
+			handoffStartupBudget = startupBudget
+			swd := newSynced(ctrl.Log, &fakeWatchdogImpl{IsStartSuccessful: true}, nil, "", handoffCheck)
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				Expect(swd.Start(ctx)).To(Succeed())
+			}()
+			Eventually(swd.Status).Should(Equal(Armed))
+
+			cancel()
+			Eventually(done).Should(BeClosed())
+			Expect(swd.Status()).To(Equal(expectedStatus))
+		},
+		Entry("without check", nil, fakeTimeout, Disarmed),
+		Entry("with new agent", HandoffCheck(func(context.Context) (bool, error) { return true, nil }), fakeTimeout, HandedOff),
+		Entry("with new agent and short timeout", HandoffCheck(func(context.Context) (bool, error) { return true, nil }), 2*fakeTimeout, Disarmed),
+		Entry("without new agent", HandoffCheck(func(context.Context) (bool, error) { return false, nil }), fakeTimeout, Disarmed),
+		Entry("with failed check", HandoffCheck(func(context.Context) (bool, error) { return false, errors.New("test") }), fakeTimeout, Disarmed),
+	)
+
+	It("should keep feeding the watchdog while checking for a new agent", func() {
+		handoffStartupBudget = fakeTimeout
+		checking, release := make(chan struct{}), make(chan struct{})
+		swd := newSynced(ctrl.Log, &fakeWatchdogImpl{IsStartSuccessful: true}, nil, "", func(context.Context) (bool, error) {
+			close(checking)
+			<-release
+			return true, nil
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			Expect(swd.Start(ctx)).To(Succeed())
+		}()
+		Eventually(swd.Status).Should(Equal(Armed))
+
+		cancel()
+		Eventually(checking).Should(BeClosed())
+		lastFoodTime := swd.LastFoodTime()
+		Eventually(swd.LastFoodTime).Should(BeTemporally(">", lastFoodTime))
+		Expect(swd.Status()).To(Equal(Armed))
+
+		close(release)
+		Eventually(done).Should(BeClosed())
+		Expect(swd.Status()).To(Equal(HandedOff))
+	})
+
+	It("should continue feeding a watchdog which was taken over", func() {
+		swd := newSynced(ctrl.Log, &fakeWatchdogImpl{IsStartSuccessful: true}, nil, "", nil)
+		Expect(swd.takeOver()).To(Succeed())
+		Expect(swd.Status()).To(Equal(Armed))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			Expect(swd.Start(ctx)).To(Succeed())
+		}()
+		Consistently(swd.Status, time.Second).Should(Equal(Armed))
+		Expect(swd.LastFoodTime()).To(BeTemporally("~", time.Now(), time.Second))
+
+		cancel()
+		Eventually(done).Should(BeClosed())
+		Expect(swd.Status()).To(Equal(Disarmed))
+	})
+})
@@ -17,6 +17,14 @@ type Watchdog interface {
 	GetTimeout() time.Duration
 	// LastFoodTime return the last time the watchdog was fed
 	LastFoodTime() time.Time
+	// DeviceInfo returns info about the used watchdog device
+	DeviceInfo() *DeviceInfo
+	// LastReset returns the last reset of the node as reported by the watchdog device, nil if the watchdog wasn't started
+	LastReset() *ResetInfo
+	// FeedStats returns the latency of feeding the watchdog
+	FeedStats() FeedStats
+	// KeepArmed prevents disarming the watchdog when the agent is stopped, e.g. during a graceful reboot
+	KeepArmed()
 }
 
 // watchdogImpl is the internal interface providing the implementation specific methods of a watchdog
@@ -24,4 +32,7 @@ type watchdogImpl interface {
 	start() (*time.Duration, error)
 	feed() error
 	disarm() error
+	handOff() error
+	deviceInfo() *DeviceInfo
+	bootStatus() (int, error)
 }
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -14,11 +15,19 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	. "golang.org/x/sys/unix"
+
+	"k8s.io/client-go/tools/record"
+
+	"github.com/medik8s/self-node-remediation/api/v1alpha1"
 )
 
 const (
-	watchdogsFolder = "/dev"
-	watchdogPrefix  = "watchdog"
+	watchdogsFolder                = "/dev"
+	watchdogPrefix                 = "watchdog"
+	preferredWatchdogDevicesEnvVar = "PREFERRED_WATCHDOG_DEVICES"
+	watchdogTimeoutSecondsEnvVar   = "WATCHDOG_TIMEOUT_SECONDS"
+	modulesFolder                  = "/lib/modules"
+	modulesSysfsFolder             = "/sys/module"
 )
 
 var (
@@ -33,9 +42,16 @@ var _ watchdogImpl = &linuxWatchdog{}
 
 // linuxWatchdog provides the linux specific implementation of the watchdogImpl interface
 type linuxWatchdog struct {
-	fd   int
-	info *watchdogInfo
-	log  logr.Logger
+	fd     int
+	info   *watchdogInfo
+	device *DeviceInfo
+	// timeoutSeconds is the timeout to configure on start, 0 keeps the device's current timeout
+	timeoutSeconds int
+	// lock prevents other agents on the node from using the watchdog, it is held until the agent exits
+	lock *os.File
+	// handoffDeadline is when the watchdog handed off by the previous agent expires, it's zero if there is none
+	handoffDeadline time.Time
+	log             logr.Logger
 }
 
 type watchdogInfo struct {
@@ -44,9 +60,61 @@ type watchdogInfo struct {
 	identity        [32]byte
 }
 
-func enableSoftdog() error {
-	enableSoftdogCmd := exec.Command("/usr/bin/nsenter", "-m/proc/1/ns/mnt", "modprobe", "softdog")
-	return enableSoftdogCmd.Run()
+// enableSoftdog loads the softdog module, with the given timeout as soft_margin if it is set.
+// In case the module is loaded already, the parameter has no effect, and the timeout is set on start.
+func enableSoftdog(timeoutSeconds int) error {
+	if _, err := os.Stat(filepath.Join(modulesSysfsFolder, softdogDriver)); err == nil {
+		// loaded or built in
+		return nil
+	}
+
+	params := ""
+	if timeoutSeconds > 0 {
+		params = fmt.Sprintf("soft_margin=%d", timeoutSeconds)
+	}
+	loadErr := loadModule(softdogDriver, params)
+	if loadErr == nil {
+		return nil
+	}
+
+	// fall back to the host's modprobe, which needs a privileged container
+	args := []string{"-m/proc/1/ns/mnt", "modprobe", softdogDriver}
+	if params != "" {
+		args = append(args, params)
+	}
+	if err := exec.Command("/usr/bin/nsenter", args...).Run(); err != nil {
+		return errors.Wrapf(err, "failed to load module with modprobe of the host after loading it directly failed: %v", loadErr)
+	}
+	return nil
+}
+
+// loadModule loads the given watchdog module from the host's modules folder, which needs the CAP_SYS_MODULE capability
+func loadModule(name, params string) error {
+	uname := Utsname{}
+	if err := Uname(&uname); err != nil {
+		return errors.Wrap(err, "failed to get kernel release")
+	}
+	release := ByteSliceToString(uname.Release[:])
+
+	basePath := filepath.Join(modulesFolder, release, "kernel", "drivers", "watchdog", name+".ko")
+	for _, extension := range []string{"", ".xz", ".zst", ".gz"} {
+		file, err := os.Open(basePath + extension)
+		if err != nil {
+			continue
+		}
+		flags := 0
+		if extension != "" {
+			// needs kernel support for decompressing modules
+			flags = MODULE_INIT_COMPRESSED_FILE
+		}
+		err = FinitModule(int(file.Fd()), params, flags)
+		file.Close()
+		if err != nil && err != EEXIST {
+			return errors.Wrapf(err, "failed to load module %s", file.Name())
+		}
+		return nil
+	}
+	return fmt.Errorf("module %s not found in %s", name, filepath.Dir(basePath))
 }
 
 func checkWatchdogExists(watchdogFilePath string) error {
@@ -59,7 +127,9 @@ func checkWatchdogExists(watchdogFilePath string) error {
 	return nil
 }
 
-func NewLinux(log logr.Logger) (Watchdog, error) {
+// NewLinux returns the watchdog of the given node, late feeds are reported with events by the given recorder.
+// The handoffCheck decides on shutdown whether the watchdog is left armed for the next agent, or disarmed.
+func NewLinux(log logr.Logger, recorder record.EventRecorder, nodeName string, handoffCheck HandoffCheck) (Watchdog, error) {
 	mutex.Lock()
 	if linuxWatchDogInstantiated {
 		mutex.Unlock()
@@ -69,37 +139,197 @@ func NewLinux(log logr.Logger) (Watchdog, error) {
 	linuxWatchDogInstantiated = true
 	mutex.Unlock()
 
-	if err := checkWatchdogExists(watchdogDevice); err != nil {
-		log.Error(err, "watchdog file path couldn't be accessed")
-		log.Info("trying to enable softdog")
+	// wait for the previous agent to exit, watchdog devices can only be opened once. In case it handed off the
+	// watchdog already, don't wait beyond the deadline of the watchdog.
+	state, err := readHandoffState()
+	if err != nil {
+		log.Error(err, "ignoring watchdog handoff state")
+	}
+	lock, err := acquireLock(handoffLockTimeout(state), log)
+	if err != nil {
+		// opening the device will fail in case it's still in use
+		log.Error(err, "failed to lock watchdog, continuing without lock")
+	}
 
-		if err := enableSoftdog(); err != nil {
-			log.Error(err, "failed to enable softdog")
-			return nil, err
-		}
+	timeoutSeconds, err := getTimeoutSecondsFromEnv()
+	if err != nil {
+		log.Error(err, "ignoring watchdog timeout, using the device's timeout")
+	}
 
-		newWatchdogDevice, err := getLastModifiedWatchdog(log)
+	preferredDevices, err := parsePreferredDevices(os.Getenv(preferredWatchdogDevicesEnvVar))
+	if err != nil {
+		log.Error(err, "ignoring preferred watchdog devices")
+	}
 
-		if err != nil {
-			log.Error(err, "failed to find softdog path")
+	var device *DeviceInfo
+	if len(preferredDevices) > 0 {
+		device = findPreferredDevice(preferredDevices, timeoutSeconds, log)
+	}
+
+	if device == nil {
+		if device, err = findConfiguredDevice(timeoutSeconds, log); err != nil {
 			return nil, err
 		}
+	}
 
-		log.Info("auto detected softdog path", "path", newWatchdogDevice)
+	watchdogDevice = device.Path
+	log.Info("selected watchdog device", "path", device.Path, "identity", device.Identity, "driver", device.Driver,
+		"timeout seconds", device.TimeoutSeconds, "nowayout", device.NoWayOut)
 
-		if err := checkWatchdogExists(newWatchdogDevice); err != nil {
-			log.Error(err, "softdog file path couldn't be accessed")
-			return nil, err
+	wd := &linuxWatchdog{
+		device:         device,
+		timeoutSeconds: timeoutSeconds,
+		lock:           lock,
+		log:            log,
+	}
+
+	swd := newSynced(log, wd, recorder, nodeName, handoffCheck)
+	takeOverHandedOffWatchdog(swd, wd, log)
+	return swd, nil
+}
+
+// takeOverHandedOffWatchdog arms the watchdog immediately if it might be running already, because it was handed off
+// by the previous agent, or because it has nowayout set. Otherwise the node might be rebooted before the manager
+// starts the watchdog.
+func takeOverHandedOffWatchdog(swd *synchronizedWatchdog, wd *linuxWatchdog, log logr.Logger) {
+	device := wd.device
+	state, err := readHandoffState()
+	if err != nil {
+		log.Error(err, "ignoring watchdog handoff state")
+	}
+	if state == nil && !device.NoWayOut {
+		return
+	}
+
+	if state != nil {
+		log.Info("taking over watchdog from the previous agent", "device", state.Device, "fed at", state.FedAt,
+			"remaining time", time.Until(state.deadline()).Round(time.Second))
+		if state.Device != device.Path {
+			// the previous agent used another device, which would reboot the node
+			if state.NoWayOut {
+				log.Error(fmt.Errorf("watchdog device %s has nowayout set", state.Device),
+					"previous agent handed off a different watchdog device, which can't be disarmed, node will reboot")
+			} else if err := disarmDevice(state.Device); err != nil {
+				log.Error(err, "failed to disarm watchdog device handed off by the previous agent", "device", state.Device)
+			}
+		} else {
+			wd.handoffDeadline = state.deadline()
 		}
+	} else {
+		log.Info("watchdog has nowayout set, taking it over immediately")
+	}
 
-		watchdogDevice = newWatchdogDevice
+	if err := swd.takeOver(); err != nil {
+		log.Error(err, "failed to take over watchdog, will retry on start")
+	}
+	if err := removeHandoffState(); err != nil {
+		log.Error(err, "failed to remove watchdog handoff state")
 	}
+}
 
-	wd := &linuxWatchdog{
-		log: log,
+// disarmDevice disarms the given device, which isn't used by this agent
+func disarmDevice(path string) error {
+	fd, err := Open(path, O_WRONLY, 0644)
+	if err != nil {
+		return err
 	}
+	if _, err := Write(fd, []byte("V")); err != nil {
+		_ = Close(fd)
+		return err
+	}
+	return Close(fd)
+}
 
-	return newSynced(log, wd), nil
+func getTimeoutSecondsFromEnv() (int, error) {
+	timeoutEnv := os.Getenv(watchdogTimeoutSecondsEnvVar)
+	if timeoutEnv == "" {
+		return 0, nil
+	}
+	timeoutSeconds, err := strconv.Atoi(timeoutEnv)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse %s env var", watchdogTimeoutSecondsEnvVar)
+	}
+	if timeoutSeconds < 0 {
+		return 0, fmt.Errorf("invalid negative watchdog timeout %d", timeoutSeconds)
+	}
+	return timeoutSeconds, nil
+}
+
+// findPreferredDevice returns the device matching the first possible preference, or nil if there is none.
+// The softdog module is loaded in case softdog is preferred but doesn't exist yet.
+func findPreferredDevice(preferredDevices []v1alpha1.WatchdogDeviceSelector, timeoutSeconds int, log logr.Logger) *DeviceInfo {
+	devices, err := discoverDevices()
+	if err != nil {
+		log.Error(err, "failed to discover watchdog devices")
+	}
+	if device := selectDevice(devices, preferredDevices); device != nil {
+		return device
+	}
+
+	if !selectsSoftdog(preferredDevices) {
+		log.Info("no preferred watchdog device found", "preferred devices", preferredDevices)
+		return nil
+	}
+
+	log.Info("no preferred watchdog device found, trying to enable softdog")
+	if err := enableSoftdog(timeoutSeconds); err != nil {
+		log.Error(err, "failed to enable softdog")
+		return nil
+	}
+	if devices, err = discoverDevices(); err != nil {
+		log.Error(err, "failed to discover watchdog devices")
+		return nil
+	}
+	return selectDevice(devices, preferredDevices)
+}
+
+// findConfiguredDevice returns the device configured by the watchdog path, or falls back to softdog if it doesn't exist
+func findConfiguredDevice(timeoutSeconds int, log logr.Logger) (*DeviceInfo, error) {
+	if err := checkWatchdogExists(watchdogDevice); err == nil {
+		return describeDevice(watchdogDevice, log), nil
+	} else {
+		log.Error(err, "watchdog file path couldn't be accessed")
+	}
+
+	log.Info("trying to enable softdog")
+	if err := enableSoftdog(timeoutSeconds); err != nil {
+		log.Error(err, "failed to enable softdog")
+		return nil, err
+	}
+
+	softdogSelector := []v1alpha1.WatchdogDeviceSelector{{Driver: softdogDriver}}
+	if devices, err := discoverDevices(); err != nil {
+		log.Error(err, "failed to discover watchdog devices, falling back to the last modified device")
+	} else if device := selectDevice(devices, softdogSelector); device != nil {
+		log.Info("auto detected softdog path", "path", device.Path)
+		return device, checkWatchdogExists(device.Path)
+	}
+
+	newWatchdogDevice, err := getLastModifiedWatchdog(log)
+	if err != nil {
+		log.Error(err, "failed to find softdog path")
+		return nil, err
+	}
+
+	log.Info("auto detected softdog path", "path", newWatchdogDevice)
+
+	if err := checkWatchdogExists(newWatchdogDevice); err != nil {
+		log.Error(err, "softdog file path couldn't be accessed")
+		return nil, err
+	}
+	return describeDevice(newWatchdogDevice, log), nil
+}
+
+// describeDevice returns the sysfs info of the given device file, or only its path if sysfs isn't available
+func describeDevice(path string, log logr.Logger) *DeviceInfo {
+	devices, err := discoverDevices()
+	if err != nil {
+		log.Error(err, "failed to discover watchdog devices")
+	} else if device := findDevice(devices, path); device != nil {
+		device.Path = path
+		return device
+	}
+	return &DeviceInfo{Path: path}
 }
 
 // this func returns watchdog path with the latest modification time assuming that
@@ -149,9 +379,37 @@ func (wd *linuxWatchdog) start() (*time.Duration, error) {
 	}
 
 	wd.fd = wdFd
+	// a handed off watchdog might expire while it's configured, so feed it first if its deadline is close
+	if !wd.handoffDeadline.IsZero() && time.Until(wd.handoffDeadline) < handoffDeadlineMargin {
+		wd.log.Info("feeding handed off watchdog immediately", "deadline", wd.handoffDeadline)
+		if err := wd.feed(); err != nil {
+			wd.log.Error(err, "failed to feed handed off watchdog")
+		}
+	}
+	wd.handoffDeadline = time.Time{}
 	wd.info = getInfo(wdFd)
+	if wd.info != nil {
+		wd.device.FirmwareVersion = wd.info.firmwareVersion
+		if wd.device.Identity == "" {
+			wd.device.Identity = strings.TrimRight(string(wd.info.identity[:]), "\x00")
+		}
+	}
+
+	if wd.timeoutSeconds > 0 {
+		if err := wd.setTimeout(wd.timeoutSeconds); err != nil {
+			// the device's current timeout is still valid, and will be reported
+			wd.log.Error(err, "failed to set watchdog timeout, using the device's timeout", "requested timeout seconds", wd.timeoutSeconds)
+		}
+	}
 
 	timeout, err := wd.getTimeout()
+	if err != nil && wd.device.NoWayOut && wd.device.TimeoutSeconds > 0 {
+		// disarming doesn't work with nowayout, so keep feeding with the timeout reported by sysfs
+		wd.log.Error(err, "failed to get timeout of watchdog with nowayout set, using the timeout from sysfs",
+			"timeout seconds", wd.device.TimeoutSeconds)
+		sysfsTimeout := time.Duration(wd.device.TimeoutSeconds) * time.Second
+		timeout, err = &sysfsTimeout, nil
+	}
 	if err != nil {
 		// no feeding without timeout, so disarm
 		_ = wd.disarm()
@@ -159,9 +417,23 @@ func (wd *linuxWatchdog) start() (*time.Duration, error) {
 		wd.log.Error(err, fmt.Sprintf("failed to get timeout of watchdog, disarmed: %s", watchdogDevice))
 		return nil, err
 	}
+
+	// devices might round or clamp the requested timeout, so verify what was accepted
+	if wd.timeoutSeconds > 0 && *timeout != time.Duration(wd.timeoutSeconds)*time.Second {
+		wd.log.Info("watchdog device didn't accept the requested timeout", "requested timeout seconds", wd.timeoutSeconds,
+			"accepted timeout seconds", timeout.Seconds())
+	}
+	wd.device.TimeoutSeconds = int(timeout.Seconds())
 	return timeout, nil
 }
 
+func (wd *linuxWatchdog) setTimeout(timeoutSeconds int) error {
+	if wd.info != nil && wd.info.options&WDIOF_SETTIMEOUT == 0 {
+		return fmt.Errorf("watchdog device %s doesn't support setting the timeout", watchdogDevice)
+	}
+	return IoctlSetPointerInt(wd.fd, WDIOC_SETTIMEOUT, timeoutSeconds)
+}
+
 func (wd *linuxWatchdog) getTimeout() (*time.Duration, error) {
 	timeout, err := IoctlGetInt(wd.fd, WDIOC_GETTIMEOUT)
 	if err != nil {
@@ -171,6 +443,22 @@ func (wd *linuxWatchdog) getTimeout() (*time.Duration, error) {
 	return &timeoutDuration, nil
 }
 
+func (wd *linuxWatchdog) deviceInfo() *DeviceInfo {
+	return wd.device
+}
+
+// bootStatus returns the bootstatus flags of the device, read by ioctl if the device was opened, or from sysfs otherwise
+func (wd *linuxWatchdog) bootStatus() (int, error) {
+	if wd.fd > 0 {
+		if status, err := IoctlGetInt(wd.fd, WDIOC_GETBOOTSTATUS); err == nil {
+			return status, nil
+		} else {
+			wd.log.Error(err, "failed to get boot status of watchdog, trying sysfs")
+		}
+	}
+	return readSysfsBootStatus(wd.device.Path)
+}
+
 func (wd *linuxWatchdog) feed() error {
 	food := []byte("a")
 	_, err := Write(wd.fd, food)
@@ -178,6 +466,25 @@ func (wd *linuxWatchdog) feed() error {
 	return err
 }
 
+// handOff feeds the watchdog a final time, and closes it without disarming, so that the node stays protected until
+// the next agent takes over
+func (wd *linuxWatchdog) handOff() error {
+	if err := wd.feed(); err != nil {
+		return err
+	}
+	state := &handoffState{
+		Device:         wd.device.Path,
+		FedAt:          time.Now(),
+		TimeoutSeconds: wd.device.TimeoutSeconds,
+		NoWayOut:       wd.device.NoWayOut,
+	}
+	if err := writeHandoffState(state); err != nil {
+		// the next agent still takes over the watchdog on start
+		wd.log.Error(err, "failed to write watchdog handoff state")
+	}
+	return Close(wd.fd)
+}
+
 // Disarm closes the LinuxWatchdog without triggering reboots, even if the LinuxWatchdog will not be fed any more
 func (wd *linuxWatchdog) disarm() error {
 	b := []byte("V") // "V" is a special char for signaling LinuxWatchdog disarm
@@ -0,0 +1,31 @@
+package watchdog
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Linux watchdog", func() {
+
+	DescribeTable("timeout from env",
+		func(env string, expectedTimeoutSeconds int, expectErr bool) {
+			Expect(os.Setenv(watchdogTimeoutSecondsEnvVar, env)).To(Succeed())
+			DeferCleanup(os.Unsetenv, watchdogTimeoutSecondsEnvVar)
+
+			timeoutSeconds, err := getTimeoutSecondsFromEnv()
+			if expectErr {
+				Expect(err).To(HaveOccurred())
+			} else {
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(timeoutSeconds).To(Equal(expectedTimeoutSeconds))
+		},
+		Entry("not set", "", 0, false),
+		Entry("disabled", "0", 0, false),
+		Entry("valid", "15", 15, false),
+		Entry("negative", "-1", 0, true),
+		Entry("invalid", "foo", 0, true),
+	)
+})
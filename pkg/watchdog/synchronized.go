@@ -2,13 +2,19 @@ package watchdog
 
 import (
 	"context"
+	"fmt"
+	"runtime"
 	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/medik8s/common/pkg/events"
 	"github.com/pkg/errors"
 
-	"k8s.io/apimachinery/pkg/util/wait"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/medik8s/self-node-remediation/pkg/utils"
 )
@@ -20,10 +26,66 @@ const (
 	Armed
 	Triggered
 	Malfunction
+	// HandedOff means that the watchdog was left armed for the next agent
+	HandedOff
 )
 
 type watchdogStatus uint8
 
+func (s watchdogStatus) String() string {
+	switch s {
+	case Disarmed:
+		return "Disarmed"
+	case Armed:
+		return "Armed"
+	case Triggered:
+		return "Triggered"
+	case Malfunction:
+		return "Malfunction"
+	case HandedOff:
+		return "HandedOff"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(s))
+	}
+}
+
+const (
+	eventReasonWatchdogFeedDelayed = "WatchdogFeedDelayed"
+	eventReasonWatchdogNoWayOut    = "WatchdogNoWayOut"
+	handoffCheckTimeout            = 5 * time.Second
+)
+
+// FeedStats describes the latency of feeding the watchdog
+type FeedStats struct {
+	// Interval is the planned time between two feeds
+	Interval time.Duration
+	// LastGap is the time between the last two successful feeds
+	LastGap time.Duration
+	// MaxGap is the longest time between two successful feeds
+	MaxGap time.Duration
+	// MeanJitter is the mean deviation of the time between two successful feeds from the interval
+	MeanJitter time.Duration
+	// LateFeeds is the number of feeds which happened after more than half of the watchdog timeout
+	LateFeeds int
+
+	totalJitter time.Duration
+	gaps        int
+}
+
+func (s *FeedStats) add(gap time.Duration) {
+	jitter := gap - s.Interval
+	if jitter < 0 {
+		jitter = -jitter
+	}
+	s.LastGap = gap
+	if gap > s.MaxGap {
+		s.MaxGap = gap
+	}
+	s.totalJitter += jitter
+	s.gaps++
+	s.MeanJitter = s.totalJitter / time.Duration(s.gaps)
+}
+
 // synchronizedWatchdog implements the Watchdog interface with synchronized calls of the implementation specific methods
 type synchronizedWatchdog struct {
 	impl         watchdogImpl
@@ -32,24 +94,97 @@ type synchronizedWatchdog struct {
 	stop         context.CancelFunc
 	mutex        sync.Mutex
 	lastFoodTime time.Time
+	lastReset    *ResetInfo
+	feedStats    FeedStats
+	// ioMutex serializes the calls of the implementation, which access the device. The mutex only guards the state, and
+	// is never held while accessing the device or calling the API, so that it doesn't delay the real time feeder.
+	ioMutex sync.Mutex
+	// takenOver is true if the watchdog was armed before it was started
+	takenOver bool
+	// keepArmed is true if the watchdog must not be disarmed on shutdown
+	keepArmed bool
+	// recorder is used for warning about late feeds on the node, it is optional
+	recorder record.EventRecorder
+	nodeName string
+	// handoffCheck decides whether the watchdog is handed off to the next agent on shutdown, it is optional
+	handoffCheck HandoffCheck
 	log          logr.Logger
 }
 
-func newSynced(log logr.Logger, impl watchdogImpl) *synchronizedWatchdog {
+func newSynced(log logr.Logger, impl watchdogImpl, recorder record.EventRecorder, nodeName string, handoffCheck HandoffCheck) *synchronizedWatchdog {
 	return &synchronizedWatchdog{
-		impl:   impl,
-		log:    log,
-		status: Disarmed,
+		impl:         impl,
+		log:          log,
+		status:       Disarmed,
+		recorder:     recorder,
+		nodeName:     nodeName,
+		handoffCheck: handoffCheck,
 	}
 }
 
 func (swd *synchronizedWatchdog) Start(ctx context.Context) error {
+	if armed, err := swd.start(); err != nil || !armed {
+		return err
+	}
+
+	<-ctx.Done()
+
+	// pod is being stopped
+	swd.shutdown()
+	return nil
+}
+
+// start arms the watchdog, unless it was taken over already, and returns whether it needs to be fed
+func (swd *synchronizedWatchdog) start() (bool, error) {
+	swd.ioMutex.Lock()
+	defer swd.ioMutex.Unlock()
+	swd.mutex.Lock()
+	takenOver, status := swd.takenOver, swd.status
+	swd.takenOver = false
+	swd.mutex.Unlock()
+
+	if takenOver {
+		// already armed and fed since the agent started
+		swd.log.Info("continuing to feed the watchdog taken over from the previous agent")
+		return true, nil
+	}
+	if status != Disarmed {
+		return false, errors.New("watchdog was started more than once. This is likely to be caused by being added to a manager multiple times")
+	}
+	if err := swd.arm(); err != nil {
+		return false, err
+	}
+	return swd.Status() != Malfunction, nil
+}
+
+// takeOver arms the watchdog before the manager starts it, in case it might still be running because it was handed
+// off by the previous agent, or because it can't be disarmed.
+func (swd *synchronizedWatchdog) takeOver() error {
+	swd.ioMutex.Lock()
+	defer swd.ioMutex.Unlock()
+	if err := swd.arm(); err != nil {
+		return err
+	}
 	swd.mutex.Lock()
 	defer swd.mutex.Unlock()
-	if swd.status != Disarmed {
-		return errors.New("watchdog was started more than once. This is likely to be caused by being added to a manager multiple times")
+	if swd.status != Armed {
+		// try again on start
+		swd.status = Disarmed
+		return errors.New("failed to take over watchdog")
 	}
+	swd.takenOver = true
+	return nil
+}
+
+// arm starts the watchdog and feeding it, or sets the Malfunction status if it can't be started and software reboot
+// is enabled. Needs to be called with the ioMutex locked.
+func (swd *synchronizedWatchdog) arm() error {
 	timeout, startErr := swd.impl.start()
+	// the boot status is available even if the watchdog can't be used
+	lastReset := newResetInfo(swd.impl.bootStatus())
+	swd.mutex.Lock()
+	defer swd.mutex.Unlock()
+	swd.lastReset = lastReset
 	if startErr != nil {
 		//In case can't use software reboot return an error
 		if isSoftwareRebootEnabled, err := utils.IsSoftwareRebootEnabled(); err != nil || !isSoftwareRebootEnabled {
@@ -63,44 +198,157 @@ func (swd *synchronizedWatchdog) Start(ctx context.Context) error {
 	swd.timeout = *timeout
 	swd.status = Armed
 	swd.log.Info("watchdog started")
-	swd.mutex.Unlock()
 
 	feedCtx, cancel := context.WithCancel(context.Background())
 	swd.stop = cancel
+	swd.feedStats = FeedStats{Interval: swd.timeout / 3}
 	// feed until stopped
-	go wait.NonSlidingUntilWithContext(feedCtx, func(feedCtx context.Context) {
-		swd.mutex.Lock()
-		defer swd.mutex.Unlock()
-		// prevent feeding of a disarmed watchdog in case the context isn't cancelled yet
-		if swd.status != Armed {
-			return
+	go swd.runFeeder(feedCtx, swd.feedStats.Interval)
+	return nil
+}
+
+// shutdown hands off the watchdog to the next agent, or disarms it if no agent is expected anymore, or if its timeout
+// is too short for the next agent to take it over.
+// A watchdog with nowayout set can't be disarmed, and a watchdog which should be kept armed mustn't be disarmed,
+// so they are always handed off. The handoff check calls the API, so it's done without holding the mutex, which would
+// block feeding the watchdog meanwhile.
+func (swd *synchronizedWatchdog) shutdown() {
+	swd.mutex.Lock()
+	if swd.status != Armed {
+		swd.mutex.Unlock()
+		return
+	}
+	handOff, timeout := swd.keepArmed, swd.timeout
+	swd.mutex.Unlock()
+
+	if !handOff && swd.handoffCheck != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), handoffCheckTimeout)
+		defer cancel()
+		var err error
+		if handOff, err = swd.handoffCheck(ctx); err != nil {
+			// don't risk an unexpected reboot
+			swd.log.Error(err, "failed to check if the watchdog can be handed off, disarming it")
 		}
-		if err := swd.impl.feed(); err != nil {
-			swd.log.Error(err, "failed to feed watchdog!")
-		} else {
-			swd.lastFoodTime = time.Now()
+		if handOff && timeout < handoffStartupBudget {
+			// the next agent likely can't take over the watchdog in time
+			swd.log.Info("watchdog timeout is too short for handing off the watchdog, disarming it", "timeout", timeout,
+				"min timeout", handoffStartupBudget)
+			handOff = false
 		}
-	}, swd.timeout/3)
-
-	<-ctx.Done()
+	}
 
-	// pod is being stopped, disarm!
+	swd.ioMutex.Lock()
+	defer swd.ioMutex.Unlock()
 	swd.mutex.Lock()
-	if swd.status == Armed {
+	status := swd.status
+	handOff = handOff || swd.keepArmed
+	swd.mutex.Unlock()
+	if status != Armed {
+		// triggered while checking
+		return
+	}
+
+	noWayOut := swd.impl.deviceInfo().NoWayOut
+	if !handOff && !noWayOut {
 		if err := swd.impl.disarm(); err != nil {
 			swd.log.Error(err, "failed to disarm watchdog!")
 		} else {
 			swd.log.Info("disarmed watchdog")
 			// we can stop feeding after disarm
 			swd.stop()
-			swd.status = Disarmed
+			swd.setStatus(Disarmed)
 		}
+		return
 	}
 
-	return nil
+	// the final feed gives the next agent the full timeout for taking over
+	if err := swd.impl.handOff(); err != nil {
+		swd.log.Error(err, "failed to hand off watchdog!")
+		return
+	}
+	swd.stop()
+	swd.setStatus(HandedOff)
+	if handOff {
+		swd.log.Info("handed off watchdog to the next agent", "timeout", swd.timeout)
+		return
+	}
+	swd.log.Info("watchdog has nowayout set and can't be disarmed, the node will be rebooted unless a new agent takes it over", "timeout", swd.timeout)
+	if swd.recorder != nil {
+		events.WarningEventf(swd.recorder, swd.nodeRef(), eventReasonWatchdogNoWayOut,
+			"Watchdog has nowayout set and can't be disarmed, the node will be rebooted within %s unless a new agent takes it over", swd.timeout)
+	}
+}
+
+// runFeeder feeds the watchdog in the given interval until the context is cancelled.
+// In order to not be delayed by a busy or throttled agent, it runs on a dedicated OS thread with real time priority.
+func (swd *synchronizedWatchdog) runFeeder(ctx context.Context, interval time.Duration) {
+	// the thread is never unlocked, so that it is terminated together with the feeder, instead of being reused
+	// with real time priority
+	runtime.LockOSThread()
+	if err := setFeederThreadPriority(); err != nil {
+		swd.log.Error(err, "failed to set real time priority for the watchdog feeder, which needs the SYS_NICE capability, using normal priority")
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		swd.feed()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// feed feeds the watchdog if it's armed. The state is only locked briefly for updating the feed stats.
+func (swd *synchronizedWatchdog) feed() {
+	swd.ioMutex.Lock()
+	defer swd.ioMutex.Unlock()
+	// prevent feeding of a disarmed watchdog in case the context isn't cancelled yet
+	if swd.Status() != Armed {
+		return
+	}
+	if err := swd.impl.feed(); err != nil {
+		swd.log.Error(err, "failed to feed watchdog!")
+		return
+	}
+
+	now := time.Now()
+	var gap time.Duration
+	swd.mutex.Lock()
+	if !swd.lastFoodTime.IsZero() {
+		gap = now.Sub(swd.lastFoodTime)
+		swd.feedStats.add(gap)
+		if gap > swd.timeout/2 {
+			swd.feedStats.LateFeeds++
+		} else {
+			gap = 0
+		}
+	}
+	swd.lastFoodTime = now
+	stats, timeout := swd.feedStats, swd.timeout
+	swd.mutex.Unlock()
+
+	if gap > 0 {
+		swd.log.Info("watchdog was fed late, the agent might be starved of CPU", "gap", gap, "timeout", timeout,
+			"max gap", stats.MaxGap, "mean jitter", stats.MeanJitter, "late feeds", stats.LateFeeds)
+		if swd.recorder != nil {
+			events.WarningEventf(swd.recorder, swd.nodeRef(), eventReasonWatchdogFeedDelayed,
+				"Watchdog was fed %s after the previous feed, more than half of its timeout of %s", gap.Round(time.Millisecond), timeout)
+		}
+	}
 }
 
+// nodeRef returns a reference for node events, which uses the node name as UID, like the kubelet does
+func (swd *synchronizedWatchdog) nodeRef() *v1.Node {
+	return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: swd.nodeName, UID: types.UID(swd.nodeName)}}
+}
+
+// Stop stops feeding the watchdog. The ioMutex is locked, so that no feed is in progress anymore when it returns.
 func (swd *synchronizedWatchdog) Stop() {
+	swd.ioMutex.Lock()
+	defer swd.ioMutex.Unlock()
 	swd.mutex.Lock()
 	defer swd.mutex.Unlock()
 	if swd.status == Armed {
@@ -121,8 +369,42 @@ func (swd *synchronizedWatchdog) LastFoodTime() time.Time {
 	return swd.lastFoodTime
 }
 
+func (swd *synchronizedWatchdog) DeviceInfo() *DeviceInfo {
+	swd.ioMutex.Lock()
+	defer swd.ioMutex.Unlock()
+	return swd.impl.deviceInfo()
+}
+
+func (swd *synchronizedWatchdog) LastReset() *ResetInfo {
+	swd.mutex.Lock()
+	defer swd.mutex.Unlock()
+	if swd.lastReset == nil {
+		return nil
+	}
+	lastReset := *swd.lastReset
+	return &lastReset
+}
+
+func (swd *synchronizedWatchdog) KeepArmed() {
+	swd.mutex.Lock()
+	defer swd.mutex.Unlock()
+	swd.keepArmed = true
+}
+
+func (swd *synchronizedWatchdog) FeedStats() FeedStats {
+	swd.mutex.Lock()
+	defer swd.mutex.Unlock()
+	return swd.feedStats
+}
+
 func (swd *synchronizedWatchdog) Status() watchdogStatus {
 	swd.mutex.Lock()
 	defer swd.mutex.Unlock()
 	return swd.status
 }
+
+func (swd *synchronizedWatchdog) setStatus(status watchdogStatus) {
+	swd.mutex.Lock()
+	defer swd.mutex.Unlock()
+	swd.status = status
+}
@@ -0,0 +1,83 @@
+package watchdog
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Synchronized watchdog", func() {
+
+	It("should track feed gaps", func() {
+		stats := FeedStats{Interval: 10 * time.Second}
+		stats.add(11 * time.Second)
+		stats.add(9 * time.Second)
+		stats.add(13 * time.Second)
+		Expect(stats.LastGap).To(Equal(13 * time.Second))
+		Expect(stats.MaxGap).To(Equal(13 * time.Second))
+		Expect(stats.MeanJitter).To(Equal(5 * time.Second / 3))
+	})
+
+	It("should feed in the interval", func() {
+		wd := NewFake(true)
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		go func() {
+			defer GinkgoRecover()
+			Expect(wd.Start(ctx)).To(Succeed())
+		}()
+
+		Eventually(func(g Gomega) {
+			stats := wd.FeedStats()
+			g.Expect(stats.Interval).To(Equal(fakeTimeout / 3))
+			g.Expect(stats.LastGap).To(BeNumerically("~", stats.Interval, stats.Interval/2))
+		}, 5*time.Second, 100*time.Millisecond).Should(Succeed())
+		Expect(wd.FeedStats().LateFeeds).To(BeZero())
+	})
+
+	It("should not lock the state while feeding", func() {
+		impl := &blockingFeedWatchdogImpl{fakeWatchdogImpl: fakeWatchdogImpl{IsStartSuccessful: true}, feeding: make(chan struct{}, 1), release: make(chan struct{})}
+		swd := newSynced(ctrl.Log, impl, nil, "", nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			Expect(swd.Start(ctx)).To(Succeed())
+		}()
+		Eventually(impl.feeding).Should(Receive())
+
+		status := make(chan watchdogStatus)
+		go func() {
+			swd.KeepArmed()
+			_ = swd.FeedStats()
+			status <- swd.Status()
+		}()
+		Eventually(status).Should(Receive(Equal(Armed)))
+
+		cancel()
+		close(impl.release)
+		Eventually(done).Should(BeClosed())
+		Expect(swd.Status()).To(Equal(HandedOff))
+	})
+})
+
+// blockingFeedWatchdogImpl blocks the feeds until it's released
+type blockingFeedWatchdogImpl struct {
+	fakeWatchdogImpl
+	feeding chan struct{}
+	release chan struct{}
+}
+
+func (f *blockingFeedWatchdogImpl) feed() error {
+	select {
+	case f.feeding <- struct{}{}:
+	default:
+	}
+	<-f.release
+	return nil
+}